@@ -1,4 +1,7 @@
 // Package s3 brings S3 files handling to afero
+//
+// Deprecated: this is the last v1 version of the package, which is developed as github.com/fclairamb/afero-s3/v2.
+// New code should import the v2 module.
 package s3
 
 import (
@@ -0,0 +1,19 @@
+module github.com/fclairamb/afero-s3/v2
+
+go 1.21
+
+toolchain go1.23.4
+
+require (
+	github.com/aws/aws-sdk-go v1.55.5
+	github.com/spf13/afero v1.12.0
+	github.com/stretchr/testify v1.10.0
+	golang.org/x/text v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
@@ -0,0 +1,101 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// bucketARNHandler is the name of the handler validating the requests of an Fs whose bucket is an access point
+const bucketARNHandler = "afero-s3.ValidateBucketARN"
+
+// ErrInvalidBucketARN is returned by all the operations of an Fs whose bucket is an ARN that isn't an access point
+var ErrInvalidBucketARN = errors.New("invalid bucket ARN")
+
+// ErrReadOnlyAccessPoint is returned when changing files through an Object Lambda access point, which only supports
+// the reads
+var ErrReadOnlyAccessPoint = fmt.Errorf("object lambda access points are read-only: %w", os.ErrPermission)
+
+// accessPoint is an access point used as the bucket of an Fs: a regular, Object Lambda, Outposts or Multi-Region
+// access point
+type accessPoint struct {
+	arn.ARN
+}
+
+// parseAccessPoint returns the access point of a bucket, nil if it's a bucket name
+func parseAccessPoint(bucket string) (*accessPoint, error) {
+	if !arn.IsARN(bucket) {
+		return nil, nil
+	}
+
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w %s: %s", ErrInvalidBucketARN, bucket, reason)
+	}
+
+	parsed, err := arn.Parse(bucket)
+	if err != nil {
+		return nil, invalid(err.Error())
+	}
+
+	if parsed.AccountID == "" {
+		return nil, invalid("no account ID")
+	}
+
+	// The resources are separated by slashes or colons
+	resource := strings.Split(strings.ReplaceAll(parsed.Resource, ":", "/"), "/")
+
+	switch parsed.Service {
+	case "s3", "s3-object-lambda":
+		if len(resource) != 2 || resource[0] != "accesspoint" || resource[1] == "" {
+			return nil, invalid("not an access point")
+		}
+	case "s3-outposts":
+		if len(resource) != 4 || resource[0] != "outpost" || resource[2] != "accesspoint" || resource[3] == "" {
+			return nil, invalid("not an outpost access point")
+		}
+	default:
+		return nil, invalid("unsupported service " + parsed.Service)
+	}
+
+	if parsed.Region == "" && parseMRAP(bucket) == nil {
+		return nil, invalid("no region")
+	}
+
+	return &accessPoint{ARN: parsed}, nil
+}
+
+// bucketARNValidator returns the handler failing the requests of an Fs whose bucket is an invalid ARN, and the
+// changes through an Object Lambda access point
+func bucketARNValidator(ap *accessPoint, errParse error) request.NamedHandler {
+	return request.NamedHandler{Name: bucketARNHandler, Fn: func(r *request.Request) {
+		switch {
+		case errParse != nil:
+			r.Error = errParse
+		case ap.Service == "s3-object-lambda" && !readOperation(r.Operation.Name):
+			r.Error = ErrReadOnlyAccessPoint
+		}
+	}}
+}
+
+// readOperation tells if an operation only reads, like the ones supported by Object Lambda access points
+func readOperation(name string) bool {
+	return strings.HasPrefix(name, "Get") || strings.HasPrefix(name, "Head") || strings.HasPrefix(name, "List")
+}
+
+// accessPointSource returns the bucket of a copy source, which can be an access point ARN followed by /object/
+func accessPointSource(source string) (string, bool) {
+	if !arn.IsARN(source) {
+		return "", false
+	}
+
+	if index := strings.Index(source, "/object/"); index >= 0 {
+		return source[:index], true
+	}
+
+	return source, true
+}
@@ -0,0 +1,74 @@
+package s3
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessPoints(t *testing.T) {
+	req := require.New(t)
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("AKIDEXAMPLE", "secret", ""),
+		Region:      aws.String("eu-west-1"),
+	})
+	req.NoError(err)
+
+	// The requests are routed to the access points of other regions
+	accessPoint := "arn:aws:s3:us-west-2:123456789012:accesspoint/reports"
+	fs := NewFs(accessPoint, sess)
+
+	r, _ := fs.s3API.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(accessPoint), Key: aws.String("a b")})
+	req.NoError(r.Build())
+	req.Equal("https://reports-123456789012.s3-accesspoint.us-west-2.amazonaws.com/a%20b", r.HTTPRequest.URL.String())
+
+	region, err := fs.regionOf(accessPoint)
+	req.NoError(err)
+	req.Equal("us-west-2", region)
+
+	// The objects of the copies are designated by the ARN of the access point
+	source := copySource(accessPoint, "/dir/a b")
+	req.Equal(accessPoint+"/object/dir/a%20b", source)
+	req.Equal(accessPoint, copySourceBucket(source))
+	req.Equal("bucket", copySourceBucket(copySource("bucket", "/dir/file")))
+
+	// The Object Lambda access points are read-only
+	objectLambda := "arn:aws:s3-object-lambda:us-west-2:123456789012:accesspoint/thumbnails"
+	fs = NewFs(objectLambda, sess)
+
+	r, _ = fs.s3API.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(objectLambda), Key: aws.String("file")})
+	req.NoError(r.Build())
+	req.Equal("https://thumbnails-123456789012.s3-object-lambda.us-west-2.amazonaws.com/file",
+		r.HTTPRequest.URL.String())
+
+	r, _ = fs.s3API.PutObjectRequest(&s3.PutObjectInput{Bucket: aws.String(objectLambda), Key: aws.String("file")})
+	err = r.Build()
+	req.ErrorIs(err, ErrReadOnlyAccessPoint)
+	req.ErrorIs(err, os.ErrPermission)
+
+	// The other ARNs are rejected
+	for _, invalid := range []string{
+		"arn:aws:sqs:us-west-2:123456789012:queue",
+		"arn:aws:s3:us-west-2:123456789012:bucket/name",
+		"arn:aws:s3::123456789012:accesspoint/reports",
+		"arn:aws:s3:us-west-2::accesspoint/reports",
+		"arn:aws:s3-outposts:us-west-2:123456789012:outpost/op-01234567890123456",
+	} {
+		_, err = NewFs(invalid, sess).Stat("/file")
+		req.ErrorIs(err, ErrInvalidBucketARN, invalid)
+	}
+
+	ap, err := parseAccessPoint("arn:aws:s3-outposts:us-west-2:123456789012:outpost/op-01234567890123456/accesspoint/ap")
+	req.NoError(err)
+	req.Equal("s3-outposts", ap.Service)
+
+	ap, err = parseAccessPoint("bucket")
+	req.NoError(err)
+	req.Nil(ap)
+}
@@ -0,0 +1,104 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Bucket settings blocking the ACLs, reported by ACLBlockedError
+const (
+	// ACLsDisabled is the BucketOwnerEnforced object ownership, which disables all the ACLs
+	ACLsDisabled = s3.ObjectOwnershipBucketOwnerEnforced
+	// BlockPublicAcls is the public access block setting rejecting the public ACLs
+	BlockPublicAcls = "BlockPublicAcls"
+	// IgnorePublicAcls is the public access block setting ignoring the public ACLs, which have no effect
+	IgnorePublicAcls = "IgnorePublicAcls"
+)
+
+// aclNotSupportedCode is the error code of the ACL requests on the buckets with disabled ACLs
+const aclNotSupportedCode = "AccessControlListNotSupported"
+
+// ACLBlockedError is returned by Chmod when the ACL matching the permissions is blocked by the settings of the bucket,
+// unless SkipBlockedACLs is set. It matches ErrNotSupported.
+type ACLBlockedError struct {
+	ACL     string // ACL is the canned ACL that was blocked
+	Setting string // Setting is the bucket setting blocking it: ACLsDisabled, BlockPublicAcls or IgnorePublicAcls
+}
+
+func (e *ACLBlockedError) Error() string {
+	switch e.Setting {
+	case ACLsDisabled:
+		return fmt.Sprintf("ACL %s can't be applied: the ACLs are disabled by the %s object ownership of the bucket, "+
+			"use a bucket policy instead or set SkipBlockedACLs", e.ACL, e.Setting)
+	default:
+		return fmt.Sprintf("ACL %s can't be applied: the public ACLs are blocked by the %s public access block "+
+			"setting of the bucket, disable it or set SkipBlockedACLs", e.ACL, e.Setting)
+	}
+}
+
+// Unwrap makes the error match ErrNotSupported
+func (e *ACLBlockedError) Unwrap() error {
+	return ErrNotSupported
+}
+
+// aclSettings are the bucket settings restricting the ACLs, detected once
+type aclSettings struct {
+	once             sync.Once
+	aclsDisabled     bool
+	blockPublicAcls  bool
+	ignorePublicAcls bool
+}
+
+// detect reads the ownership controls and public access block of the bucket. The settings that can't be read,
+// because of missing permissions for instance, are considered unset.
+func (a *aclSettings) detect(fs *Fs) {
+	bucket := aws.String(fs.bucket)
+
+	if ownership, err := fs.s3API.GetBucketOwnershipControls(&s3.GetBucketOwnershipControlsInput{
+		Bucket: bucket,
+	}); err == nil && ownership.OwnershipControls != nil {
+		for _, rule := range ownership.OwnershipControls.Rules {
+			a.aclsDisabled = aws.StringValue(rule.ObjectOwnership) == s3.ObjectOwnershipBucketOwnerEnforced
+		}
+	}
+
+	if block, err := fs.s3API.GetPublicAccessBlock(&s3.GetPublicAccessBlockInput{
+		Bucket: bucket,
+	}); err == nil && block.PublicAccessBlockConfiguration != nil {
+		a.blockPublicAcls = aws.BoolValue(block.PublicAccessBlockConfiguration.BlockPublicAcls)
+		a.ignorePublicAcls = aws.BoolValue(block.PublicAccessBlockConfiguration.IgnorePublicAcls)
+	}
+}
+
+// checkACL tells if an ACL has to be applied, and returns the error explaining why the bucket settings block it if
+// they do. The private ACL doesn't have to be applied when the ACLs are disabled, as the objects are private anyway.
+func (fs *Fs) checkACL(acl string) (bool, error) {
+	fs.acls.once.Do(func() { fs.acls.detect(fs) })
+
+	public := acl != s3.ObjectCannedACLPrivate
+
+	switch {
+	case fs.acls.aclsDisabled && !public:
+		return false, nil
+	case fs.acls.aclsDisabled:
+		return false, &ACLBlockedError{ACL: acl, Setting: ACLsDisabled}
+	case public && fs.acls.blockPublicAcls:
+		return false, &ACLBlockedError{ACL: acl, Setting: BlockPublicAcls}
+	case public && fs.acls.ignorePublicAcls:
+		return false, &ACLBlockedError{ACL: acl, Setting: IgnorePublicAcls}
+	default:
+		return true, nil
+	}
+}
+
+// isACLNotSupported tells if an ACL request failed because the ACLs are disabled
+func isACLNotSupported(err error) bool {
+	var errAWS awserr.Error
+	return errors.As(err, &errAWS) && errAWS.Code() == aclNotSupportedCode
+}
@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChmodACLsDisabled(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.PosixMetadata = true
+
+	_, err := fs.s3API.PutBucketOwnershipControls(&s3.PutBucketOwnershipControlsInput{
+		Bucket: aws.String(fs.bucket),
+		OwnershipControls: &s3.OwnershipControls{Rules: []*s3.OwnershipControlsRule{
+			{ObjectOwnership: aws.String(s3.ObjectOwnershipBucketOwnerEnforced)},
+		}},
+	})
+	req.NoError(err)
+
+	testCreateFile(t, fs, "/file", "content")
+	req.NoError(fs.Chmod("/file", 0600))
+
+	err = fs.Chmod("/file", 0644)
+	req.ErrorIs(err, ErrNotSupported)
+
+	var errBlocked *ACLBlockedError
+	req.True(errors.As(err, &errBlocked))
+	req.Equal(ACLsDisabled, errBlocked.Setting)
+	req.Equal("public-read", errBlocked.ACL)
+
+	// Nothing was changed
+	info, err := fs.Stat("/file")
+	req.NoError(err)
+	req.Equal("-rw-------", info.Mode().String())
+
+	fs.SkipBlockedACLs = true
+	req.NoError(fs.Chmod("/file", 0644))
+
+	info, err = fs.Stat("/file")
+	req.NoError(err)
+	req.Equal("-rw-r--r--", info.Mode().String())
+}
+
+func TestChmodPublicAccessBlock(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	_, err := fs.s3API.PutPublicAccessBlock(&s3.PutPublicAccessBlockInput{
+		Bucket:                         aws.String(fs.bucket),
+		PublicAccessBlockConfiguration: &s3.PublicAccessBlockConfiguration{BlockPublicAcls: aws.Bool(true)},
+	})
+	req.NoError(err)
+
+	testCreateFile(t, fs, "/file", "content")
+
+	// The private ACLs are allowed
+	req.NoError(fs.Chmod("/file", 0600))
+
+	var errBlocked *ACLBlockedError
+	req.True(errors.As(fs.Chmod("/file", 0666), &errBlocked))
+	req.Equal(BlockPublicAcls, errBlocked.Setting)
+	req.Contains(errBlocked.Error(), "public access block")
+}
@@ -0,0 +1,199 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBatchMaxSize is the default size of the files buffered by a BatchWriter before they're committed
+const DefaultBatchMaxSize = 64 * 1024 * 1024
+
+// BatchOptions defines how a BatchWriter uploads its files
+type BatchOptions struct {
+	// BulkOptions define the number of parallel uploads, DefaultBulkConcurrency if 0, and the progress callback
+	BulkOptions
+	// MaxSize is the total size of the buffered files above which Add commits them, DefaultBatchMaxSize if 0
+	MaxSize int64
+}
+
+func (o *BatchOptions) maxSize() int64 {
+	if o.MaxSize <= 0 {
+		return DefaultBatchMaxSize
+	}
+
+	return o.MaxSize
+}
+
+// batchFile is a file buffered by a BatchWriter
+type batchFile struct {
+	name string
+	data []byte
+}
+
+// BatchWriter buffers many small files and uploads them together with parallel PutObject requests, which is much
+// faster than writing and closing them one after the other. It's safe for concurrent use.
+type BatchWriter struct {
+	fs    *Fs
+	opts  BatchOptions
+	mu    sync.Mutex
+	files []batchFile
+	size  int64 // size is the total size of the buffered files
+}
+
+// NewBatchWriter creates a BatchWriter writing files to the Fs, opts can be nil
+func (fs *Fs) NewBatchWriter(opts *BatchOptions) *BatchWriter {
+	b := &BatchWriter{fs: fs}
+	if opts != nil {
+		b.opts = *opts
+	}
+
+	return b
+}
+
+// Add buffers a file, which is only written by the next Commit. The data must not be modified until then. The
+// buffered files are committed once their total size exceeds MaxSize, the error of the commit being returned.
+func (b *BatchWriter) Add(name string, data []byte) error {
+	b.mu.Lock()
+	b.files = append(b.files, batchFile{name: name, data: data})
+	b.size += int64(len(data))
+	full := b.size >= b.opts.maxSize()
+	b.mu.Unlock()
+
+	if full {
+		return b.Commit()
+	}
+
+	return nil
+}
+
+// Pending returns the number of buffered files that weren't committed yet
+func (b *BatchWriter) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.files)
+}
+
+// Commit uploads all the buffered files with parallel PutObject requests, the files inlined in each directory being
+// written with a single update of its index, see InlineThreshold. It returns the first error. The files that failed
+// stay buffered, so that the Commit can be retried.
+func (b *BatchWriter) Commit() error {
+	b.mu.Lock()
+	files := b.files
+	b.files, b.size = nil, 0
+	b.mu.Unlock()
+
+	units := b.units(files)
+	errs := make([]error, len(files))
+	indexes := make(chan int)
+
+	var (
+		wg        sync.WaitGroup
+		processed int64
+	)
+
+	for i := 0; i < b.opts.concurrency(); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range indexes {
+				unit := units[index]
+
+				err := b.put(files, unit)
+				for _, file := range unit.files {
+					if errs[file] = err; err != nil {
+						continue
+					}
+
+					count := atomic.AddInt64(&processed, 1)
+					if b.opts.Progress != nil {
+						b.opts.Progress(count, strings.TrimPrefix(files[file].name, "/"))
+					}
+				}
+			}
+		}()
+	}
+
+	for index := range units {
+		indexes <- index
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	var firstErr error
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for index, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+
+		b.files = append(b.files, files[index])
+		b.size += int64(len(files[index].data))
+	}
+
+	return firstErr
+}
+
+// batchUnit is a set of buffered files uploaded together: a regular file, or the inlined files of a directory
+type batchUnit struct {
+	files  []int // files are the indexes of the buffered files
+	inline bool
+}
+
+// units groups the inlined files by directory, so that their index is only updated once
+func (b *BatchWriter) units(files []batchFile) []batchUnit {
+	units := make([]batchUnit, 0, len(files))
+	dirs := make(map[string]int)
+
+	for index, file := range files {
+		if !b.fs.inlined(len(file.data)) {
+			units = append(units, batchUnit{files: []int{index}})
+			continue
+		}
+
+		dir := inlineIndexPath(file.name)
+		if unit, ok := dirs[dir]; ok {
+			units[unit].files = append(units[unit].files, index)
+			continue
+		}
+
+		dirs[dir] = len(units)
+		units = append(units, batchUnit{files: []int{index}, inline: true})
+	}
+
+	return units
+}
+
+// put uploads a unit of buffered files like WriteFile
+func (b *BatchWriter) put(files []batchFile, unit batchUnit) error {
+	if unit.inline {
+		inlined := make([]batchFile, len(unit.files))
+		for i, index := range unit.files {
+			inlined[i] = files[index]
+		}
+
+		return b.fs.writeInline(inlined)
+	}
+
+	file := files[unit.files[0]]
+
+	params, err := b.fs.writePermParams(file.name, 0666)
+	if err != nil {
+		return err
+	}
+
+	return b.fs.putFile(file.name, bytes.NewReader(file.data), int64(len(file.data)), params)
+}
@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchWriter(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	var processed int64
+
+	batch := fs.NewBatchWriter(&BatchOptions{
+		BulkOptions: BulkOptions{
+			Concurrency: 4,
+			Progress:    func(_ int64, _ string) { atomic.AddInt64(&processed, 1) },
+		},
+		MaxSize: 100,
+	})
+
+	for i := 0; i < 10; i++ {
+		req.NoError(batch.Add(fmt.Sprintf("/file-%d", i), []byte("content")))
+	}
+
+	// Nothing was written yet
+	req.Equal(10, batch.Pending())
+
+	_, err := fs.Stat("/file-0")
+	req.Error(err)
+
+	req.NoError(batch.Commit())
+	req.Zero(batch.Pending())
+	req.Equal(int64(10), atomic.LoadInt64(&processed))
+
+	for i := 0; i < 10; i++ {
+		content, err := afero.ReadFile(fs, fmt.Sprintf("/file-%d", i))
+		req.NoError(err)
+		req.Equal("content", string(content))
+	}
+
+	t.Run("MaxSize", func(t *testing.T) {
+		req.NoError(batch.Add("/big", make([]byte, 100)))
+		req.Zero(batch.Pending())
+
+		_, err := fs.Stat("/big")
+		req.NoError(err)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		faults := NewFaultInjector(0)
+		fs.WithFaultInjector(faults)
+
+		defer fs.WithFaultInjector(nil)
+
+		faults.Set("PutObject", &Fault{StatusCode: http.StatusBadRequest, Code: "InvalidRequest"})
+
+		req.NoError(batch.Add("/failed", []byte("content")))
+		req.Error(batch.Commit())
+
+		// The failed files are kept for another commit
+		req.Equal(1, batch.Pending())
+
+		faults.Set("PutObject", nil)
+		req.NoError(batch.Commit())
+		req.Zero(batch.Pending())
+	})
+}
@@ -0,0 +1,203 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// BlockKey identifies a block of a version of a file
+type BlockKey struct {
+	Name  string // Name of the file
+	ETag  string // ETag of the version of the file, a new version never uses the blocks of the previous ones
+	Index int64  // Index of the block in the file, the block size being defined by the reader using the cache
+}
+
+// BlockCache stores blocks of files, see ReaderAt.SetBlockCache. It can be shared by several readers and several
+// instances, with a Redis or memcached backend for instance. The implementations must be safe for concurrent use.
+type BlockCache interface {
+	// Get returns a block, false if it's not in the cache. Failing caches should report a miss.
+	Get(key BlockKey) ([]byte, bool)
+	// Put stores a block, which must not be modified afterwards
+	Put(key BlockKey, data []byte)
+}
+
+// BlockInvalidator is implemented by the BlockCache able to discard all the blocks of a file, like the ones created
+// by NewMemoryBlockCache and NewDiskBlockCache, see CacheInvalidator
+type BlockInvalidator interface {
+	// Invalidate discards the blocks of all the versions of a file
+	Invalidate(name string)
+}
+
+// blockLRU keeps track of the size of the cached blocks and evicts the least recently used ones
+type blockLRU struct {
+	maxSize int64
+	size    int64
+	order   *list.List // order contains the *lruEntry, the most recently used first
+	entries map[BlockKey]*list.Element
+	evict   func(entry *lruEntry)
+}
+
+type lruEntry struct {
+	key  BlockKey
+	size int64
+	data []byte // data is the content of the block, when it is kept in memory
+}
+
+func newBlockLRU(maxSize int64, evict func(entry *lruEntry)) *blockLRU {
+	return &blockLRU{maxSize: maxSize, order: list.New(), entries: make(map[BlockKey]*list.Element), evict: evict}
+}
+
+func (l *blockLRU) get(key BlockKey) (*lruEntry, bool) {
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	l.order.MoveToFront(element)
+
+	return element.Value.(*lruEntry), true
+}
+
+func (l *blockLRU) add(entry *lruEntry) {
+	if element, ok := l.entries[entry.key]; ok {
+		l.remove(element)
+	}
+
+	l.entries[entry.key] = l.order.PushFront(entry)
+	l.size += entry.size
+
+	for l.size > l.maxSize && l.order.Len() > 1 {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *blockLRU) remove(element *list.Element) {
+	entry := l.order.Remove(element).(*lruEntry)
+	delete(l.entries, entry.key)
+	l.size -= entry.size
+
+	if l.evict != nil {
+		l.evict(entry)
+	}
+}
+
+// removeFile removes the blocks of all the versions of a file
+func (l *blockLRU) removeFile(name string) {
+	name = path.Clean("/" + name)
+
+	for key, element := range l.entries {
+		if path.Clean("/"+key.Name) == name {
+			l.remove(element)
+		}
+	}
+}
+
+// memoryBlockCache is an in-memory BlockCache
+type memoryBlockCache struct {
+	mu  sync.Mutex
+	lru *blockLRU
+}
+
+// NewMemoryBlockCache creates an in-memory BlockCache holding up to maxSize bytes, the least recently used blocks
+// being evicted
+func NewMemoryBlockCache(maxSize int64) BlockCache {
+	return &memoryBlockCache{lru: newBlockLRU(maxSize, nil)}
+}
+
+func (c *memoryBlockCache) Get(key BlockKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.lru.get(key)
+	if !ok {
+		return nil, false
+	}
+
+	return entry.data, true
+}
+
+func (c *memoryBlockCache) Put(key BlockKey, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.add(&lruEntry{key: key, size: int64(len(data)), data: data})
+}
+
+func (c *memoryBlockCache) Invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.removeFile(name)
+}
+
+// diskBlockCache is a BlockCache storing the blocks in the files of a local directory
+type diskBlockCache struct {
+	mu  sync.Mutex
+	dir string
+	lru *blockLRU
+}
+
+// NewDiskBlockCache creates a BlockCache storing up to maxSize bytes in the files of a local directory, the least
+// recently used blocks being removed. The blocks written by previous processes aren't reused.
+func NewDiskBlockCache(dir string, maxSize int64) (BlockCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	c := &diskBlockCache{dir: dir}
+	c.lru = newBlockLRU(maxSize, func(entry *lruEntry) { _ = os.Remove(c.path(entry.key)) })
+
+	return c, nil
+}
+
+// path returns the file of a block, named after the hash of its key
+func (c *diskBlockCache) path(key BlockKey) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", key.Name, key.ETag, key.Index)))
+	return filepath.Join(c.dir, hex.EncodeToString(hash[:]))
+}
+
+func (c *diskBlockCache) Get(key BlockKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.lru.get(key); !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+func (c *diskBlockCache) Put(key BlockKey, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The blocks of a version never change
+	if _, ok := c.lru.get(key); ok {
+		return
+	}
+
+	if err := os.WriteFile(c.path(key), data, 0600); err != nil {
+		return
+	}
+
+	c.lru.add(&lruEntry{key: key, size: int64(len(data))})
+}
+
+func (c *diskBlockCache) Invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.removeFile(name)
+}
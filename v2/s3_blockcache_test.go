@@ -0,0 +1,67 @@
+package s3
+
+import (
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockCache(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	size := 300 * 1024
+
+	testWriteFile(t, fs, "/file", size)
+
+	content, err := io.ReadAll(NewLimitedReader(rand.New(rand.NewSource(0)), size))
+	req.NoError(err)
+
+	disk, err := NewDiskBlockCache(t.TempDir(), 1024*1024)
+	req.NoError(err)
+
+	caches := map[string]BlockCache{"Memory": NewMemoryBlockCache(1024 * 1024), "Disk": disk}
+
+	for name, cache := range caches {
+		cache := cache
+
+		t.Run(name, func(t *testing.T) {
+			req := require.New(t)
+			buffer := make([]byte, 100)
+
+			readAll := func() int64 {
+				reader, err := fs.NewReaderAt("/file", 1024)
+				req.NoError(err)
+
+				reader.SetBlockCache(cache, 64*1024)
+
+				for _, offset := range []int{1000, 70000, 200000, size - 2000} {
+					_, err = reader.ReadAt(buffer, int64(offset))
+					req.NoError(err)
+					req.Equal(content[offset:offset+100], buffer)
+				}
+
+				return reader.Requests()
+			}
+
+			// The footer and 2 ranges of the minimum fetch size are fetched by the first reader
+			req.Equal(int64(3), readAll())
+
+			// The other readers share the blocks
+			req.Equal(int64(1), readAll())
+		})
+	}
+
+	t.Run("Eviction", func(t *testing.T) {
+		cache := NewMemoryBlockCache(100)
+		cache.Put(BlockKey{Name: "/file", Index: 0}, make([]byte, 60))
+		cache.Put(BlockKey{Name: "/file", Index: 1}, make([]byte, 60))
+
+		_, ok := cache.Get(BlockKey{Name: "/file", Index: 0})
+		req.False(ok)
+
+		_, ok = cache.Get(BlockKey{Name: "/file", Index: 1})
+		req.True(ok)
+	})
+}
@@ -0,0 +1,86 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// BucketInfo describes the configuration of the bucket of an Fs
+type BucketInfo struct {
+	Region string // Region of the bucket
+	// Versioning is the versioning state: "Enabled", "Suspended" or empty if it was never enabled
+	Versioning string
+	// Encryption is the default server-side encryption algorithm, like "AES256" or "aws:kms", empty if none
+	Encryption string
+	// EncryptionKeyID is the KMS key used by default, if any
+	EncryptionKeyID string
+	// ObjectOwnership is the object ownership setting, like "BucketOwnerEnforced", empty if not set
+	ObjectOwnership string
+}
+
+// VersioningEnabled tells if the objects of the bucket are versioned
+func (bi *BucketInfo) VersioningEnabled() bool {
+	return bi.Versioning == s3.BucketVersioningStatusEnabled
+}
+
+// isUnsetConfig tells if an error means a bucket configuration isn't set, or isn't supported by the server
+func isUnsetConfig(err error, code string) bool {
+	var errRequestFailure awserr.RequestFailure
+	if !errors.As(err, &errRequestFailure) {
+		return false
+	}
+
+	return errRequestFailure.Code() == code || errRequestFailure.StatusCode() == http.StatusNotImplemented
+}
+
+// BucketInfo returns the versioning, encryption, ownership and region settings of the bucket
+func (fs *Fs) BucketInfo() (*BucketInfo, error) {
+	info := &BucketInfo{}
+	bucket := aws.String(fs.bucket)
+
+	location, err := fs.s3API.GetBucketLocation(&s3.GetBucketLocationInput{Bucket: bucket})
+	if err != nil {
+		return nil, err
+	}
+
+	info.Region = normalizeLocation(aws.StringValue(location.LocationConstraint))
+
+	versioning, err := fs.s3API.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: bucket})
+	if err != nil {
+		return nil, err
+	}
+
+	info.Versioning = aws.StringValue(versioning.Status)
+
+	encryption, err := fs.s3API.GetBucketEncryption(&s3.GetBucketEncryptionInput{Bucket: bucket})
+	if err != nil && !isUnsetConfig(err, "ServerSideEncryptionConfigurationNotFoundError") {
+		return nil, err
+	}
+
+	if err == nil && encryption.ServerSideEncryptionConfiguration != nil {
+		for _, rule := range encryption.ServerSideEncryptionConfiguration.Rules {
+			if rule.ApplyServerSideEncryptionByDefault != nil {
+				info.Encryption = aws.StringValue(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+				info.EncryptionKeyID = aws.StringValue(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID)
+			}
+		}
+	}
+
+	ownership, err := fs.s3API.GetBucketOwnershipControls(&s3.GetBucketOwnershipControlsInput{Bucket: bucket})
+	if err != nil && !isUnsetConfig(err, "OwnershipControlsNotFoundError") {
+		return nil, err
+	}
+
+	if err == nil && ownership.OwnershipControls != nil {
+		for _, rule := range ownership.OwnershipControls.Rules {
+			info.ObjectOwnership = aws.StringValue(rule.ObjectOwnership)
+		}
+	}
+
+	return info, nil
+}
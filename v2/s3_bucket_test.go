@@ -0,0 +1,35 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketInfo(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	info, err := fs.BucketInfo()
+	req.NoError(err)
+	req.NotEmpty(info.Region)
+	req.False(info.VersioningEnabled())
+
+	_, err = fs.s3API.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(fs.bucket),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(s3.BucketVersioningStatusEnabled)},
+	})
+	req.NoError(err)
+
+	info, err = fs.BucketInfo()
+	req.NoError(err)
+	req.True(info.VersioningEnabled())
+
+	_, err = fs.s3API.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(fs.bucket),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(s3.BucketVersioningStatusSuspended)},
+	})
+	req.NoError(err)
+}
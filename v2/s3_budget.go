@@ -0,0 +1,166 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// memoryBudget is a weighted semaphore of bytes
+type memoryBudget struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	size      int64
+	available int64
+}
+
+func newMemoryBudget(size int64) *memoryBudget {
+	b := &memoryBudget{size: size, available: size}
+	b.cond = sync.NewCond(&b.mu)
+
+	return b
+}
+
+// acquire waits until n bytes are available and reserves them, it returns the time spent waiting
+func (b *memoryBudget) acquire(n int64) time.Duration {
+	// A reservation bigger than the budget would never be satisfied
+	if n > b.size {
+		n = b.size
+	}
+
+	start := time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	waited := false
+	for b.available < n {
+		waited = true
+		b.cond.Wait()
+	}
+
+	b.available -= n
+
+	if !waited {
+		return 0
+	}
+
+	return time.Since(start)
+}
+
+func (b *memoryBudget) release(n int64) {
+	if n > b.size {
+		n = b.size
+	}
+
+	b.mu.Lock()
+	b.available += n
+	b.mu.Unlock()
+
+	b.cond.Broadcast()
+}
+
+// WithMemoryBudget limits the memory used by the part buffers of all the uploads of the Fs to size bytes,
+// 0 meaning no limit. The uploads needing a new part buffer wait, and so do the writes of their files, until
+// enough parts were sent. The time spent waiting is reported by Metrics.BudgetWait.
+// It should be called before the Fs is used.
+func (fs *Fs) WithMemoryBudget(size int64) *Fs {
+	fs.budget = nil
+	if size > 0 {
+		fs.budget = newMemoryBudget(size)
+	}
+
+	return fs
+}
+
+// budgetReader reserves the memory of each part buffer of an upload before reading its content. The memory is
+// released once the part was sent.
+type budgetReader struct {
+	fs       *Fs
+	reader   io.Reader
+	partSize int64
+	offset   int64 // offset is the number of bytes read so far
+	held     int64 // held is the memory currently reserved, it's accessed atomically
+}
+
+func (r *budgetReader) Read(p []byte) (int, error) {
+	// A new part buffer is being filled
+	if r.offset%r.partSize == 0 {
+		r.reserve()
+	}
+
+	// Parts are filled one at a time, reading the next part is done by another Read
+	if remaining := r.partSize - r.offset%r.partSize; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+
+	// Nothing will be sent for an empty last part
+	if n == 0 && r.offset%r.partSize == 0 && err != nil {
+		r.partDone()
+	}
+
+	return n, err
+}
+
+func (r *budgetReader) reserve() {
+	waited := r.fs.budget.acquire(r.partSize)
+	atomic.AddInt64(&r.held, r.partSize)
+
+	if r.fs.metrics != nil && waited > 0 {
+		atomic.AddInt64(&r.fs.metrics.budgetWait, int64(waited))
+	}
+}
+
+// partDone releases the memory of a part buffer
+func (r *budgetReader) partDone() {
+	for {
+		held := atomic.LoadInt64(&r.held)
+		if held < r.partSize {
+			return
+		}
+
+		if atomic.CompareAndSwapInt64(&r.held, held, held-r.partSize) {
+			r.fs.budget.release(r.partSize)
+			return
+		}
+	}
+}
+
+// close releases the memory still reserved by a finished upload
+func (r *budgetReader) close() {
+	if held := atomic.SwapInt64(&r.held, 0); held > 0 {
+		r.fs.budget.release(held)
+	}
+}
+
+// option releases the memory of the parts once they were sent
+func (r *budgetReader) option() request.Option {
+	return func(req *request.Request) {
+		if partNumber(req) == 0 {
+			return
+		}
+
+		req.Handlers.Complete.PushBack(func(*request.Request) { r.partDone() })
+	}
+}
+
+// withBudget applies the memory budget of the Fs to an upload, it returns the function to call once it's finished
+func (fs *Fs) withBudget(uploader *s3manager.Uploader, input *s3manager.UploadInput) func() {
+	if fs.budget == nil {
+		return func() {}
+	}
+
+	reader := &budgetReader{fs: fs, reader: input.Body, partSize: uploader.PartSize}
+	input.Body = reader
+	uploader.RequestOptions = append(uploader.RequestOptions, reader.option())
+
+	return reader.close
+}
@@ -0,0 +1,48 @@
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	// Enough for two parts of 5MB
+	fs.WithMemoryBudget(10 * 1024 * 1024)
+
+	content := bytes.Repeat([]byte("0123456789"), 1024*1024)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			file, err := fs.OpenFile(fmt.Sprintf("/file-%d", i), os.O_WRONLY, 0)
+			req.NoError(err)
+			_, err = file.Write(content)
+			req.NoError(err)
+			req.NoError(file.Close())
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		info, err := fs.Stat(fmt.Sprintf("/file-%d", i))
+		req.NoError(err)
+		req.Equal(int64(len(content)), info.Size())
+	}
+
+	req.Positive(fs.Metrics().BudgetWait())
+	req.Equal(fs.budget.size, fs.budget.available)
+}
@@ -0,0 +1,84 @@
+package s3
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBuffer(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	// The small writes are buffered until they're synced
+	file, err := fs.OpenFile("/buffered.csv", os.O_WRONLY, 0)
+	req.NoError(err)
+
+	for i := 0; i < 3; i++ {
+		_, err = file.WriteString("field,")
+		req.NoError(err)
+		req.NoError(file.Sync())
+	}
+
+	req.NoError(file.Close())
+
+	content, err := afero.ReadFile(fs, "/buffered.csv")
+	req.NoError(err)
+	req.Equal("field,field,field,", string(content))
+
+	// The sync fails when the buffer can't be handed to the upload
+	file, err = fs.OpenFile("/failed.csv", os.O_WRONLY, 0)
+	req.NoError(err)
+
+	_, err = file.WriteString("field,")
+	req.NoError(err)
+
+	file.(*File).uploadState.abandon()
+
+	err = file.Sync()
+	req.ErrorIs(err, ErrUploadAbandoned)
+
+	var pathErr *os.PathError
+	req.ErrorAs(err, &pathErr)
+	req.Equal("sync", pathErr.Op)
+
+	req.Error(file.Close())
+}
+
+func BenchmarkSmallWrites(b *testing.B) {
+	fs := __getS3Fs(b)
+
+	for _, bench := range []struct {
+		name       string
+		bufferSize int
+	}{
+		{name: "unbuffered", bufferSize: -1},
+		{name: "buffered", bufferSize: DefaultWriteBufferSize},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			fs.WriteBufferSize = bench.bufferSize
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				file, err := fs.OpenFile(fmt.Sprintf("/%s-%d", bench.name, i), os.O_WRONLY, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				// Like an encoder writing a CSV file field by field
+				for j := 0; j < 10000; j++ {
+					if _, err := file.WriteString("field,"); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				if err := file.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
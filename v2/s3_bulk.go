@@ -0,0 +1,212 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DefaultBulkConcurrency is the number of parallel requests performed by the bulk operations by default
+const DefaultBulkConcurrency = 16
+
+// BulkOptions defines how the operations applied to all the files of a directory are performed
+type BulkOptions struct {
+	// Concurrency is the number of parallel requests, DefaultBulkConcurrency if 0
+	Concurrency int
+	// Progress is called after each processed object with the total number of objects processed so far
+	Progress func(processed int64, key string)
+}
+
+func (o *BulkOptions) concurrency() int {
+	if o == nil || o.Concurrency <= 0 {
+		return DefaultBulkConcurrency
+	}
+
+	return o.Concurrency
+}
+
+// dirPrefix returns the S3 prefix of all the objects contained in a directory
+func dirPrefix(name string) string {
+	prefix := strings.TrimPrefix(name, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return prefix
+}
+
+// walkObjects calls fn for every object whose key starts with the prefix, across all the listing pages
+func (fs *Fs) walkObjects(prefix string, fn func(obj *s3.Object) bool) error {
+	return fs.s3API.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(fs.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			if !fn(obj) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// forEachObject applies an operation on all the objects of a directory with the bulk options parallelism.
+// It stops at the first error.
+func (fs *Fs) forEachObject(opName, name string, opts *BulkOptions, op func(obj *s3.Object) error) error {
+	var (
+		processed int64
+		firstErr  error
+		errMu     sync.Mutex
+		wg        sync.WaitGroup
+	)
+
+	failed := func() bool {
+		errMu.Lock()
+		defer errMu.Unlock()
+		return firstErr != nil
+	}
+
+	objects := make(chan *s3.Object)
+
+	for i := 0; i < opts.concurrency(); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for obj := range objects {
+				if err := op(obj); err != nil {
+					errMu.Lock()
+					if firstErr == nil {
+						firstErr = pathError(opName, "/"+*obj.Key, err)
+					}
+					errMu.Unlock()
+
+					continue
+				}
+
+				count := atomic.AddInt64(&processed, 1)
+				if opts != nil && opts.Progress != nil {
+					opts.Progress(count, *obj.Key)
+				}
+			}
+		}()
+	}
+
+	errList := fs.walkObjects(dirPrefix(name), func(obj *s3.Object) bool {
+		if failed() {
+			return false
+		}
+
+		objects <- obj
+
+		return true
+	})
+
+	close(objects)
+	wg.Wait()
+
+	if errList != nil {
+		return &os.PathError{Op: opName, Path: name, Err: errList}
+	}
+
+	return firstErr
+}
+
+// ChmodAll applies Chmod to all the files and directory markers of a directory, recursively, with parallel requests.
+// On a file, it's the same as Chmod.
+func (fs *Fs) ChmodAll(name string, mode os.FileMode, opts *BulkOptions) error {
+	if err := fs.checkWritable("chmod", name); err != nil {
+		return err
+	}
+
+	// A file has nothing to recurse into
+	if info, err := fs.Stat(name); err == nil && !info.IsDir() {
+		return fs.Chmod(name, mode)
+	}
+
+	return fs.forEachObject("chmod", name, opts, func(obj *s3.Object) error {
+		key := "/" + aws.StringValue(obj.Key)
+		return fs.chmodKey(key, key, mode)
+	})
+}
+
+// ChownAll applies Chown to all the files and directory markers of a directory, recursively, with parallel requests.
+// Like Chown, it's only supported in POSIX metadata mode. On a file, it's the same as Chown.
+func (fs *Fs) ChownAll(name string, uid, gid int, opts *BulkOptions) error {
+	if !fs.PosixMetadata {
+		return fs.Chown(name, uid, gid)
+	}
+
+	if err := fs.checkWritable("chown", name); err != nil {
+		return err
+	}
+
+	if info, err := fs.Stat(name); err == nil && !info.IsDir() {
+		return fs.Chown(name, uid, gid)
+	}
+
+	return fs.forEachObject("chown", name, opts, func(obj *s3.Object) error {
+		return fs.chownMetadata("/"+aws.StringValue(obj.Key), uid, gid)
+	})
+}
+
+// forEachIndex runs n operations with the bulk options parallelism. It stops at the first error.
+func forEachIndex(opName string, n int, opts *BulkOptions, op func(i int) (string, error)) error {
+	var (
+		processed int64
+		firstErr  error
+		errMu     sync.Mutex
+		wg        sync.WaitGroup
+	)
+
+	indexes := make(chan int)
+
+	for w := 0; w < opts.concurrency(); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				name, err := op(i)
+				if err != nil {
+					errMu.Lock()
+					if firstErr == nil {
+						firstErr = pathError(opName, name, err)
+					}
+					errMu.Unlock()
+
+					continue
+				}
+
+				count := atomic.AddInt64(&processed, 1)
+				if opts != nil && opts.Progress != nil {
+					opts.Progress(count, name)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		errMu.Lock()
+		failed := firstErr != nil
+		errMu.Unlock()
+
+		if failed {
+			break
+		}
+
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,126 @@
+package s3
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChmodAll(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	for i := 0; i < 10; i++ {
+		testCreateFile(t, fs, fmt.Sprintf("/dir/sub%d/file", i), "content")
+	}
+
+	testCreateFile(t, fs, "/other", "content")
+
+	var progress int64
+
+	req.NoError(fs.ChmodAll("/dir", 0600, &BulkOptions{
+		Concurrency: 3,
+		Progress:    func(processed int64, _ string) { atomic.StoreInt64(&progress, processed) },
+	}))
+	req.Equal(int64(10), atomic.LoadInt64(&progress))
+
+	acl, err := fs.s3API.GetObjectAcl(&s3.GetObjectAclInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String("dir/sub3/file"),
+	})
+	req.NoError(err)
+	req.Len(acl.Grants, 1)
+
+	req.ErrorIs(fs.ChownAll("/dir", 1000, 1000, nil), ErrNotSupported)
+}
+
+func TestChmodAllPosixMetadata(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.PosixMetadata = true
+
+	_, err := fs.s3API.PutPublicAccessBlock(&s3.PutPublicAccessBlockInput{
+		Bucket:                         aws.String(fs.bucket),
+		PublicAccessBlockConfiguration: &s3.PublicAccessBlockConfiguration{BlockPublicAcls: aws.Bool(true)},
+	})
+	req.NoError(err)
+
+	req.NoError(fs.Mkdir("/dir", 0700))
+	testCreateFile(t, fs, "/dir/file", "content")
+
+	// The files and directories get the same permissions as with Chmod
+	req.NoError(fs.ChmodAll("/dir", 0600, nil))
+
+	for _, name := range []string{"/dir", "/dir/file"} {
+		info, err := fs.Stat(name)
+		req.NoError(err)
+		req.Equal(os.FileMode(0600), info.Mode().Perm(), name)
+	}
+
+	// The blocked ACLs are handled like with Chmod
+	var errBlocked *ACLBlockedError
+	req.ErrorAs(fs.ChmodAll("/dir", 0644, nil), &errBlocked)
+
+	fs.SkipBlockedACLs = true
+	req.NoError(fs.ChmodAll("/dir", 0644, nil))
+
+	info, err := fs.Stat("/dir/file")
+	req.NoError(err)
+	req.Equal(os.FileMode(0644), info.Mode().Perm())
+
+	// A file is changed like with Chmod
+	req.NoError(fs.ChmodAll("/dir/file", 0600, nil))
+
+	info, err = fs.Stat("/dir/file")
+	req.NoError(err)
+	req.Equal(os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestChownAllPosixMetadata(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.PosixMetadata = true
+
+	req.NoError(fs.Mkdir("/dir", 0750))
+	testCreateFile(t, fs, "/dir/file", "content")
+	testCreateFile(t, fs, "/dir/sub/file", "content")
+
+	// The files and directories get the same owner as with Chown
+	req.NoError(fs.ChownAll("/dir", 1000, 1001, nil))
+
+	for _, name := range []string{"/dir", "/dir/file", "/dir/sub/file"} {
+		info, err := fs.Stat(name)
+		req.NoError(err)
+		req.Equal(&Owner{UID: 1000, GID: 1001}, info.Sys(), name)
+	}
+
+	// The negative ids are left unchanged, and so are the permissions
+	req.NoError(fs.Chown("/dir/file", 1002, -1))
+
+	info, err := fs.Stat("/dir/file")
+	req.NoError(err)
+	req.Equal(&Owner{UID: 1002, GID: 1001}, info.Sys())
+
+	// A file is changed like with Chown
+	req.NoError(fs.ChownAll("/dir/sub/file", 1003, 1003, nil))
+
+	info, err = fs.Stat("/dir/sub/file")
+	req.NoError(err)
+	req.Equal(&Owner{UID: 1003, GID: 1003}, info.Sys())
+
+	info, err = fs.Stat("/dir")
+	req.NoError(err)
+	req.Equal(os.FileMode(0750), info.Mode().Perm())
+
+	// The files that were never chowned have no owner
+	testCreateFile(t, fs, "/other", "content")
+
+	info, err = fs.Stat("/other")
+	req.NoError(err)
+	req.Nil(info.Sys())
+}
@@ -0,0 +1,88 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	iofs "io/fs"
+	"os"
+)
+
+// Capabilities are the features supported by a file system, so that generic code can detect them instead of
+// relying on failed calls
+type Capabilities uint
+
+const (
+	// CapRandomRead means files can be read at any offset (Seek and ReadAt)
+	CapRandomRead Capabilities = 1 << iota
+	// CapRandomWrite means files can be written at any offset (Seek and WriteAt)
+	CapRandomWrite
+	// CapReadWrite means files can be opened with O_RDWR
+	CapReadWrite
+	// CapAppend means files can be opened with O_APPEND
+	CapAppend
+	// CapTruncate means files can be truncated
+	CapTruncate
+	// CapChmod means the permissions of files are kept
+	CapChmod
+	// CapChown means the owners of files are kept
+	CapChown
+	// CapChtimes means the times of files can be changed
+	CapChtimes
+	// CapSymlink means symbolic links are supported
+	CapSymlink
+	// CapAtomicRename means files are renamed atomically
+	CapAtomicRename
+	// CapServerSideCopy means files can be copied without downloading them
+	CapServerSideCopy
+	// CapRealDirectories means directories exist independently of the files they contain
+	CapRealDirectories
+)
+
+// Has tells if all the capabilities of c are present
+func (c Capabilities) Has(other Capabilities) bool {
+	return c&other == other
+}
+
+// Capabilities returns the features supported by the file system
+func (fs *Fs) Capabilities() Capabilities {
+	caps := CapRandomRead | CapServerSideCopy
+
+	if fs.PosixMetadata || fs.PermissionsACL {
+		caps |= CapChmod
+	}
+
+	return caps
+}
+
+// Capabilities returns the features supported by the chunked file system
+func (cfs *ChunkedFs) Capabilities() Capabilities {
+	return cfs.Fs.Capabilities() | CapRandomWrite | CapReadWrite | CapAppend | CapTruncate
+}
+
+// LstatIfPossible is Stat as S3 doesn't have symbolic links, it implements afero.Lstater
+func (fs *Fs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	info, err := fs.Stat(name)
+	return info, false, err
+}
+
+// dirEntry adapts a FileInfo to fs.DirEntry
+type dirEntry struct {
+	info os.FileInfo
+}
+
+func (d dirEntry) Name() string                 { return d.info.Name() }
+func (d dirEntry) IsDir() bool                  { return d.info.IsDir() }
+func (d dirEntry) Type() iofs.FileMode          { return d.info.Mode().Type() }
+func (d dirEntry) Info() (iofs.FileInfo, error) { return d.info, nil }
+
+// ReadDir reads the content of the directory like Readdir but returns directory entries, it implements
+// fs.ReadDirFile
+func (f *File) ReadDir(n int) ([]iofs.DirEntry, error) {
+	infos, err := f.Readdir(n)
+
+	entries := make([]iofs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = dirEntry{info: info}
+	}
+
+	return entries, err
+}
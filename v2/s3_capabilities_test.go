@@ -0,0 +1,45 @@
+package s3
+
+import (
+	iofs "io/fs"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	var (
+		_ afero.Lstater    = fs
+		_ iofs.ReadDirFile = (*File)(nil)
+	)
+
+	req.True(fs.Capabilities().Has(CapRandomRead | CapServerSideCopy))
+	req.False(fs.Capabilities().Has(CapRandomWrite))
+	req.False(fs.Capabilities().Has(CapChmod))
+
+	fs.PosixMetadata = true
+	req.True(fs.Capabilities().Has(CapChmod))
+
+	chunked := NewChunkedFs(fs, 0)
+	req.True(chunked.Capabilities().Has(CapRandomWrite | CapTruncate | CapChmod))
+
+	testCreateFile(t, fs, "/dir/file", "content")
+
+	info, lstatCalled, err := fs.LstatIfPossible("/dir/file")
+	req.NoError(err)
+	req.False(lstatCalled)
+	req.Equal(int64(7), info.Size())
+
+	dir, err := fs.Open("/dir")
+	req.NoError(err)
+
+	entries, err := dir.(*File).ReadDir(0)
+	req.NoError(err)
+	req.Len(entries, 1)
+	req.Equal("file", entries[0].Name())
+	req.False(entries[0].IsDir())
+}
@@ -0,0 +1,710 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+)
+
+// ChunkedManifestContentType is the Content-Type of the objects describing a chunked file
+const ChunkedManifestContentType = "application/x-afero-s3-chunked-manifest+json"
+
+// chunksSuffix is appended to the name of a chunked file to get the "directory" holding its chunks
+const chunksSuffix = ".chunks"
+
+// metaChunkedSize is the metadata holding the logical size of a chunked file, it allows a simple HEAD
+// request to stat the file
+const metaChunkedSize = "Chunked-Size"
+
+// DefaultChunkSize is the chunk size used when none is specified
+const DefaultChunkSize = 64 * 1024 * 1024
+
+// ErrNotChunked is returned when trying to modify an existing file that is not a chunked file
+var ErrNotChunked = errors.New("file is not a chunked file")
+
+// ChunkedFs is an Fs storing every file as a manifest object and a set of fixed-size chunk objects.
+// It allows files bigger than the maximum S3 object size and WriteAt/Truncate calls at arbitrary
+// offsets, as only the affected chunks are rewritten.
+//
+// For a file "name", the manifest is stored at "name" and the chunks at "name.chunks/<index>".
+// Files that were not written as chunked files can still be read through this Fs.
+type ChunkedFs struct {
+	*Fs
+	ChunkSize int64 // ChunkSize is the size of the chunks of the newly created files
+}
+
+// chunkedManifest is the content of the manifest object
+type chunkedManifest struct {
+	ChunkSize int64 `json:"chunkSize"`
+	Size      int64 `json:"size"`
+}
+
+// NewChunkedFs creates a chunked file system on top of an existing Fs
+func NewChunkedFs(fs *Fs, chunkSize int64) *ChunkedFs {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	return &ChunkedFs{
+		Fs:        fs,
+		ChunkSize: chunkSize,
+	}
+}
+
+// Name returns the type of FS object this is
+func (ChunkedFs) Name() string { return "s3-chunked" }
+
+// Create creates an empty chunked file
+func (cfs *ChunkedFs) Create(name string) (afero.File, error) {
+	return cfs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens a file for reading
+func (cfs *ChunkedFs) Open(name string) (afero.File, error) {
+	return cfs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens a file. Unlike the plain Fs, read-write access, appending and truncation are supported.
+func (cfs *ChunkedFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	info, errStat := cfs.Stat(name)
+	if errStat != nil && !errors.Is(errStat, os.ErrNotExist) {
+		return nil, errStat
+	}
+
+	if errStat == nil && info.IsDir() {
+		file, err := cfs.Fs.OpenFile(name, os.O_RDONLY, perm)
+		if err != nil {
+			return nil, err
+		}
+
+		return &chunkedDir{File: file.(*File), cfs: cfs}, nil
+	}
+
+	writing := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	file := &ChunkedFile{
+		fs:        cfs,
+		name:      name,
+		flag:      flag,
+		chunkSize: cfs.ChunkSize,
+		dirty:     make(map[int64][]byte),
+		cached:    -1,
+		truncated: -1,
+	}
+
+	switch {
+	case errStat != nil && flag&os.O_CREATE == 0:
+		return nil, errStat
+	case errStat != nil:
+		// Any leftover chunk will be deleted
+		file.truncated = 0
+		file.modified = true
+	case info.(FileInfo).chunked:
+		if err := file.loadManifest(); err != nil {
+			return nil, err
+		}
+	case !writing:
+		// Plain objects are read as usual
+		return cfs.Fs.OpenFile(name, os.O_RDONLY, perm)
+	case flag&os.O_TRUNC == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: ErrNotChunked}
+	default:
+		// The plain object will be replaced by the manifest
+		file.truncated = 0
+		file.modified = true
+	}
+
+	if writing && flag&os.O_TRUNC != 0 && file.size > 0 {
+		if err := file.Truncate(0); err != nil {
+			return nil, err
+		}
+	}
+
+	file.modTime = time.Now().UTC()
+
+	return file, nil
+}
+
+// Stat returns the FileInfo of a file, with the logical size for chunked files
+func (cfs *ChunkedFs) Stat(name string) (os.FileInfo, error) {
+	out, err := cfs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(cfs.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil || out.ContentType == nil || *out.ContentType != ChunkedManifestContentType {
+		return cfs.Fs.Stat(name)
+	}
+
+	size, err := strconv.ParseInt(aws.StringValue(out.Metadata[metaChunkedSize]), 10, 64)
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: fmt.Errorf("invalid chunked size: %w", err)}
+	}
+
+	info := NewFileInfo(name, false, size, aws.TimeValue(out.LastModified))
+	info.chunked = true
+
+	return info, nil
+}
+
+// Remove removes a file and all its chunks
+func (cfs *ChunkedFs) Remove(name string) error {
+	info, err := cfs.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	if fi, ok := info.(FileInfo); ok && fi.chunked {
+		if err := cfs.removeChunks(name, 0); err != nil {
+			return err
+		}
+	}
+
+	return cfs.Fs.forceRemove(name)
+}
+
+// Rename renames a file and all its chunks
+func (cfs *ChunkedFs) Rename(oldname, newname string) error {
+	if oldname == newname {
+		return nil
+	}
+
+	info, err := cfs.Stat(oldname)
+	if err != nil {
+		return err
+	}
+
+	if fi, ok := info.(FileInfo); ok && fi.chunked {
+		keys, err := cfs.chunkKeys(oldname)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			index := strings.TrimPrefix(key, chunksPrefix(oldname))
+			if err := cfs.Fs.Rename("/"+key, chunksPrefix(newname)+index); err != nil {
+				return err
+			}
+		}
+	}
+
+	return cfs.Fs.Rename(oldname, newname)
+}
+
+// chunksPrefix returns the prefix of all the chunks of a file
+func chunksPrefix(name string) string {
+	return strings.TrimPrefix(name, "/") + chunksSuffix + "/"
+}
+
+func chunkKey(name string, index int64) string {
+	return fmt.Sprintf("%s%010d", chunksPrefix(name), index)
+}
+
+// chunkKeys lists all the chunks of a file
+func (cfs *ChunkedFs) chunkKeys(name string) ([]string, error) {
+	var keys []string
+
+	err := cfs.s3API.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(cfs.bucket),
+		Prefix: aws.String(chunksPrefix(name)),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, *obj.Key)
+		}
+		return true
+	})
+
+	return keys, err
+}
+
+// removeChunks deletes all the chunks of a file starting at the "from" chunk index
+func (cfs *ChunkedFs) removeChunks(name string, from int64) error {
+	keys, err := cfs.chunkKeys(name)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		index, errParse := strconv.ParseInt(strings.TrimPrefix(key, chunksPrefix(name)), 10, 64)
+		if errParse != nil || index < from {
+			continue
+		}
+
+		if err := cfs.Fs.forceRemove(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chunkedDir is a directory opened through a ChunkedFs, it hides the chunks and reports logical sizes
+type chunkedDir struct {
+	*File
+	cfs *ChunkedFs
+}
+
+// Readdir lists the directory without the chunks directories. Files are stat'ed one by one to resolve
+// their logical size, which makes it slower than the plain Fs listing.
+func (d *chunkedDir) Readdir(n int) ([]os.FileInfo, error) {
+	for {
+		infos, err := d.File.Readdir(n)
+		result := make([]os.FileInfo, 0, len(infos))
+
+		for _, info := range infos {
+			if info.IsDir() && strings.HasSuffix(info.Name(), chunksSuffix) {
+				continue
+			}
+
+			if !info.IsDir() {
+				if logical, errStat := d.cfs.Stat(path.Join(d.Name(), info.Name())); errStat == nil {
+					info = logical
+				}
+			}
+
+			result = append(result, info)
+		}
+
+		// We shouldn't return an empty slice without error when a specific count was requested
+		if len(result) > 0 || err != nil || n <= 0 {
+			return result, err
+		}
+	}
+}
+
+// Readdirnames lists the names of the directory without the chunks directories
+func (d *chunkedDir) Readdirnames(n int) ([]string, error) {
+	infos, err := d.Readdir(n)
+	names := make([]string, len(infos))
+
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+
+	return names, err
+}
+
+// ChunkedFile is a file stored as a set of chunks.
+// Modified chunks are kept in memory until Sync or Close is called.
+// nolint: govet
+type ChunkedFile struct {
+	fs        *ChunkedFs       // Parent file system
+	name      string           // Name of the file
+	flag      int              // Flags used to open the file
+	chunkSize int64            // Size of each chunk
+	size      int64            // Logical size of the file
+	offset    int64            // Current read/write offset
+	modTime   time.Time        // Last modification time
+	dirty     map[int64][]byte // Chunks modified since the last sync
+	cached    int64            // Index of the last chunk read, -1 if none
+	cache     []byte           // Content of the last chunk read
+	truncated int64            // Lowest chunk index that must be deleted on sync, -1 if none
+	modified  bool             // Whether the manifest must be written
+	closed    bool             // Whether the file was closed
+}
+
+func (f *ChunkedFile) loadManifest() error {
+	resp, err := f.fs.s3API.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(f.fs.bucket),
+		Key:    aws.String(f.name),
+	})
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	var manifest chunkedManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return fmt.Errorf("invalid chunked manifest: %w", err)
+	}
+
+	if manifest.ChunkSize <= 0 {
+		return fmt.Errorf("invalid chunked manifest: chunk size %d", manifest.ChunkSize)
+	}
+
+	f.chunkSize = manifest.ChunkSize
+	f.size = manifest.Size
+	f.truncated = -1
+
+	if resp.LastModified != nil {
+		f.modTime = *resp.LastModified
+	}
+
+	return nil
+}
+
+// Name returns the name of the file
+func (f *ChunkedFile) Name() string { return f.name }
+
+// Size returns the logical size of the file
+func (f *ChunkedFile) Size() int64 { return f.size }
+
+// chunk returns the content of a chunk, missing chunks are considered to be filled with zeros
+func (f *ChunkedFile) chunk(index int64) ([]byte, error) {
+	if data, ok := f.dirty[index]; ok {
+		return data, nil
+	}
+
+	if f.cached == index {
+		return f.cache, nil
+	}
+
+	var data []byte
+
+	if f.truncated < 0 || index < f.truncated {
+		resp, err := f.fs.s3API.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(f.fs.bucket),
+			Key:    aws.String(chunkKey(f.name, index)),
+		})
+
+		var awsErr awserr.Error
+
+		switch {
+		case errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey:
+		case err != nil:
+			return nil, err
+		default:
+			data, err = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	f.cached, f.cache = index, data
+
+	return data, nil
+}
+
+// chunkLength returns the length the chunk should have considering the file size
+func (f *ChunkedFile) chunkLength(index int64) int64 {
+	remaining := f.size - index*f.chunkSize
+	if remaining > f.chunkSize {
+		return f.chunkSize
+	}
+
+	return remaining
+}
+
+// ReadAt reads len(p) bytes from the file starting at byte offset off
+func (f *ChunkedFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, afero.ErrFileClosed
+	}
+
+	if off < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	read := 0
+
+	for read < len(p) && off < f.size {
+		index, inChunk := off/f.chunkSize, off%f.chunkSize
+
+		data, err := f.chunk(index)
+		if err != nil {
+			return read, err
+		}
+
+		end := f.chunkLength(index)
+		if limit := inChunk + int64(len(p)-read); limit < end {
+			end = limit
+		}
+
+		n := int(end - inChunk)
+
+		// Anything after the stored data of the chunk is a hole
+		copied := 0
+		if inChunk < int64(len(data)) {
+			copied = copy(p[read:read+n], data[inChunk:])
+		}
+
+		for i := read + copied; i < read+n; i++ {
+			p[i] = 0
+		}
+
+		read += n
+		off += int64(n)
+	}
+
+	if read < len(p) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+// Read reads up to len(p) bytes from the file
+func (f *ChunkedFile) Read(p []byte) (int, error) {
+	if f.flag&os.O_WRONLY != 0 {
+		return 0, ErrNotSupported
+	}
+
+	if f.offset >= f.size && len(p) > 0 {
+		return 0, io.EOF
+	}
+
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+
+	if errors.Is(err, io.EOF) && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// WriteAt writes len(p) bytes to the file starting at byte offset off, only the affected chunks are
+// loaded and rewritten.
+func (f *ChunkedFile) WriteAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, afero.ErrFileClosed
+	}
+
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, ErrNotSupported
+	}
+
+	if off < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	written := 0
+
+	for written < len(p) {
+		index, inChunk := off/f.chunkSize, off%f.chunkSize
+		n := len(p) - written
+
+		if limit := f.chunkSize - inChunk; int64(n) > limit {
+			n = int(limit)
+		}
+
+		data, err := f.chunk(index)
+		if err != nil {
+			return written, err
+		}
+
+		if needed := inChunk + int64(n); int64(len(data)) < needed {
+			grown := make([]byte, needed)
+			copy(grown, data)
+			data = grown
+		} else if _, ok := f.dirty[index]; !ok {
+			data = append([]byte(nil), data...)
+		}
+
+		copy(data[inChunk:], p[written:written+n])
+		f.dirty[index] = data
+
+		written += n
+		off += int64(n)
+	}
+
+	if off > f.size {
+		f.size = off
+	}
+
+	f.modified = true
+	f.modTime = time.Now().UTC()
+
+	return written, nil
+}
+
+// Write writes len(p) bytes to the file at the current offset, or at the end of file when it was
+// opened with O_APPEND.
+func (f *ChunkedFile) Write(p []byte) (int, error) {
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = f.size
+	}
+
+	n, err := f.WriteAt(p, f.offset)
+	f.offset += int64(n)
+
+	return n, err
+}
+
+// WriteString is like Write, but writes the contents of string s rather than a slice of bytes.
+func (f *ChunkedFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// Seek sets the offset for the next Read or Write on file
+func (f *ChunkedFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, afero.ErrFileClosed
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, ErrInvalidSeek
+	}
+
+	if offset < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	f.offset = offset
+
+	return offset, nil
+}
+
+// Truncate changes the size of the file, chunks after the new size are deleted on Sync
+func (f *ChunkedFile) Truncate(size int64) error {
+	if f.closed {
+		return afero.ErrFileClosed
+	}
+
+	if size < 0 {
+		return ErrInvalidSeek
+	}
+
+	if size < f.size {
+		firstRemoved := (size + f.chunkSize - 1) / f.chunkSize
+
+		for index := range f.dirty {
+			if index >= firstRemoved {
+				delete(f.dirty, index)
+			}
+		}
+
+		if f.cached >= firstRemoved {
+			f.cached, f.cache = -1, nil
+		}
+
+		if f.truncated < 0 || firstRemoved < f.truncated {
+			f.truncated = firstRemoved
+		}
+
+		// The last chunk is trimmed
+		if last := size % f.chunkSize; last != 0 {
+			index := size / f.chunkSize
+
+			data, err := f.chunk(index)
+			if err != nil {
+				return err
+			}
+
+			if int64(len(data)) > last {
+				f.dirty[index] = append([]byte(nil), data[:last]...)
+			}
+		}
+	}
+
+	f.size = size
+	f.modified = true
+	f.modTime = time.Now().UTC()
+
+	return nil
+}
+
+// Sync writes the modified chunks and the manifest
+func (f *ChunkedFile) Sync() error {
+	if f.closed {
+		return afero.ErrFileClosed
+	}
+
+	if !f.modified {
+		return nil
+	}
+
+	if f.truncated >= 0 {
+		if err := f.fs.removeChunks(f.name, f.truncated); err != nil {
+			return err
+		}
+
+		f.truncated = -1
+	}
+
+	for index, data := range f.dirty {
+		if _, err := f.fs.s3API.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(f.fs.bucket),
+			Key:    aws.String(chunkKey(f.name, index)),
+			Body:   bytes.NewReader(data),
+		}); err != nil {
+			return err
+		}
+
+		f.cached, f.cache = index, data
+		delete(f.dirty, index)
+	}
+
+	return f.writeManifest()
+}
+
+func (f *ChunkedFile) writeManifest() error {
+	content, err := json.Marshal(&chunkedManifest{ChunkSize: f.chunkSize, Size: f.size})
+	if err != nil {
+		return err
+	}
+
+	req := &s3.PutObjectInput{
+		Bucket:   aws.String(f.fs.bucket),
+		Key:      aws.String(f.name),
+		Body:     bytes.NewReader(content),
+		Metadata: map[string]*string{metaChunkedSize: aws.String(strconv.FormatInt(f.size, 10))},
+	}
+
+	if f.fs.FileProps != nil {
+		applyFileCreateProps(req, f.fs.FileProps)
+	}
+
+	// The content type identifies the manifests
+	req.ContentType = aws.String(ChunkedManifestContentType)
+
+	if _, err := f.fs.s3API.PutObject(req); err != nil {
+		return err
+	}
+
+	f.modified = false
+
+	return nil
+}
+
+// Close syncs the file and renders it unusable for I/O
+func (f *ChunkedFile) Close() error {
+	if f.closed {
+		return afero.ErrFileClosed
+	}
+
+	err := f.Sync()
+	f.closed = true
+	f.dirty, f.cache = nil, nil
+
+	return err
+}
+
+// Stat returns the FileInfo describing the file
+func (f *ChunkedFile) Stat() (os.FileInfo, error) {
+	info := NewFileInfo(f.name, false, f.size, f.modTime)
+	info.chunked = true
+
+	return info, nil
+}
+
+// Readdir is not supported on files
+func (f *ChunkedFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, ErrNotSupported
+}
+
+// Readdirnames is not supported on files
+func (f *ChunkedFile) Readdirnames(int) ([]string, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,87 @@
+package s3
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkedFile(t *testing.T) {
+	req := require.New(t)
+	fs := NewChunkedFs(__getS3Fs(t), 4)
+
+	{ // Writing an initial file spanning multiple chunks
+		file, err := fs.Create("/dir/file")
+		req.NoError(err)
+
+		_, err = file.WriteString("Hello world !")
+		req.NoError(err)
+		req.NoError(file.Close())
+	}
+
+	info, err := fs.Stat("/dir/file")
+	req.NoError(err)
+	req.Equal(int64(13), info.Size())
+
+	{ // Rewriting a part of it
+		file, err := fs.OpenFile("/dir/file", os.O_RDWR, 0)
+		req.NoError(err)
+
+		_, err = file.WriteAt([]byte("W"), 6)
+		req.NoError(err)
+
+		buffer := make([]byte, 5)
+		_, err = file.ReadAt(buffer, 6)
+		req.NoError(err)
+		req.Equal("World", string(buffer))
+
+		req.NoError(file.Truncate(11))
+		req.NoError(file.Close())
+	}
+
+	{ // And reading it back
+		file, err := fs.Open("/dir/file")
+		req.NoError(err)
+
+		content, err := io.ReadAll(file)
+		req.NoError(err)
+		req.Equal("Hello World", string(content))
+		req.NoError(file.Close())
+	}
+
+	{ // Growing it creates a hole
+		file, err := fs.OpenFile("/dir/file", os.O_WRONLY|os.O_APPEND, 0)
+		req.NoError(err)
+		req.NoError(file.Truncate(13))
+		_, err = file.WriteString("!")
+		req.NoError(err)
+		req.NoError(file.Close())
+
+		file, err = fs.Open("/dir/file")
+		req.NoError(err)
+
+		content, err := io.ReadAll(file)
+		req.NoError(err)
+		req.Equal("Hello World\x00\x00!", string(content))
+	}
+
+	{ // The chunks are hidden from the listing
+		dir, err := fs.Open("/dir")
+		req.NoError(err)
+
+		infos, err := dir.Readdir(-1)
+		req.NoError(err)
+		req.Len(infos, 1)
+		req.Equal("file", infos[0].Name())
+		req.Equal(int64(14), infos[0].Size())
+	}
+
+	req.NoError(fs.Rename("/dir/file", "/dir/file2"))
+	req.NoError(fs.Remove("/dir/file2"))
+
+	keys, err := fs.chunkKeys("/dir/file2")
+	req.NoError(err)
+	req.Empty(keys)
+}
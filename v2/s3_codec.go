@@ -0,0 +1,135 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"gopkg.in/yaml.v3"
+)
+
+// EncodeOptions defines how values are written by WriteJSON and WriteYAML
+type EncodeOptions struct {
+	// IfMatch is the ETag the file must have, as returned by ReadJSON or ReadYAML, for the write to happen.
+	// ErrModified is returned if the file was modified since it was read.
+	IfMatch string
+	// IfNotExists only writes the file if it doesn't exist, ErrModified is returned otherwise
+	IfNotExists bool
+	// Indent indents the JSON documents
+	Indent bool
+}
+
+// codec encodes and decodes the values stored in files
+type codec struct {
+	contentType string
+	marshal     func(v interface{}, indent bool) ([]byte, error)
+	unmarshal   func(data []byte, v interface{}) error
+}
+
+var jsonCodec = &codec{
+	contentType: "application/json",
+	marshal: func(v interface{}, indent bool) ([]byte, error) {
+		if indent {
+			return json.MarshalIndent(v, "", "  ")
+		}
+
+		return json.Marshal(v)
+	},
+	unmarshal: json.Unmarshal,
+}
+
+var yamlCodec = &codec{
+	contentType: "application/yaml",
+	marshal:     func(v interface{}, _ bool) ([]byte, error) { return yaml.Marshal(v) },
+	unmarshal:   yaml.Unmarshal,
+}
+
+// ReadJSON decodes a JSON file into v, it returns the ETag of the file to allow conditional writes
+func (fs *Fs) ReadJSON(name string, v interface{}) (string, error) {
+	return fs.readEncoded(name, v, jsonCodec)
+}
+
+// WriteJSON writes v as a JSON file
+func (fs *Fs) WriteJSON(name string, v interface{}, opts *EncodeOptions) error {
+	return fs.writeEncoded(name, v, opts, jsonCodec)
+}
+
+// ReadYAML decodes a YAML file into v, it returns the ETag of the file to allow conditional writes
+func (fs *Fs) ReadYAML(name string, v interface{}) (string, error) {
+	return fs.readEncoded(name, v, yamlCodec)
+}
+
+// WriteYAML writes v as a YAML file
+func (fs *Fs) WriteYAML(name string, v interface{}, opts *EncodeOptions) error {
+	return fs.writeEncoded(name, v, opts, yamlCodec)
+}
+
+func (fs *Fs) readEncoded(name string, v interface{}, c *codec) (string, error) {
+	resp, err := fs.s3API.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			err = os.ErrNotExist
+		}
+
+		return "", &os.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	body, err := transformRead(name, resp.Body, fs.readTransforms(name, resp.Metadata))
+	if err != nil {
+		return "", err
+	}
+
+	defer body.Close() // nolint: errcheck
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", &os.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	if err = c.unmarshal(data, v); err != nil {
+		return "", &os.PathError{Op: "decode", Path: name, Err: err}
+	}
+
+	return aws.StringValue(resp.ETag), nil
+}
+
+func (fs *Fs) writeEncoded(name string, v interface{}, opts *EncodeOptions, c *codec) error {
+	if opts == nil {
+		opts = &EncodeOptions{}
+	}
+
+	data, err := c.marshal(v, opts.Indent)
+	if err != nil {
+		return &os.PathError{Op: "encode", Path: name, Err: err}
+	}
+
+	params, err := fs.writePermParams(name, 0666)
+	if err != nil {
+		return err
+	}
+
+	params.contentType = aws.String(c.contentType)
+
+	if opts.IfMatch != "" {
+		params.ifMatch = aws.String(opts.IfMatch)
+	}
+
+	if opts.IfNotExists {
+		params.ifNoneMatch = aws.String("*")
+	}
+
+	err = fs.putFile(name, bytes.NewReader(data), int64(len(data)), params)
+	if isPreconditionFailed(err) {
+		return &os.PathError{Op: "write", Path: name, Err: fmt.Errorf("%w: %v", ErrModified, err)}
+	}
+
+	return err
+}
@@ -0,0 +1,70 @@
+package s3
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Name    string `json:"name" yaml:"name"`
+	Version int    `json:"version" yaml:"version"`
+}
+
+func TestJSON(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	req.NoError(fs.WriteJSON("/config.json", &testConfig{Name: "app", Version: 1}, &EncodeOptions{IfNotExists: true}))
+
+	// The file already exists
+	err := fs.WriteJSON("/config.json", &testConfig{Name: "other"}, &EncodeOptions{IfNotExists: true})
+	req.ErrorIs(err, ErrModified)
+
+	var config testConfig
+
+	etag, err := fs.ReadJSON("/config.json", &config)
+	req.NoError(err)
+	req.Equal(testConfig{Name: "app", Version: 1}, config)
+	req.NotEmpty(etag)
+
+	head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String("/config.json")})
+	req.NoError(err)
+	req.Equal("application/json", aws.StringValue(head.ContentType))
+
+	// Only the writer having read the latest version can update it
+	config.Version++
+	req.NoError(fs.WriteJSON("/config.json", &config, &EncodeOptions{IfMatch: etag, Indent: true}))
+	req.ErrorIs(fs.WriteJSON("/config.json", &config, &EncodeOptions{IfMatch: etag}), ErrModified)
+
+	content, err := fs.ReadFile("/config.json")
+	req.NoError(err)
+	req.Equal("{\n  \"name\": \"app\",\n  \"version\": 2\n}", string(content))
+
+	_, err = fs.ReadJSON("/missing.json", &config)
+	req.ErrorIs(err, os.ErrNotExist)
+
+	req.NoError(fs.WriteFile("/invalid.json", []byte("{"), nil))
+	_, err = fs.ReadJSON("/invalid.json", &config)
+	req.Error(err)
+}
+
+func TestYAML(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	req.NoError(fs.WriteYAML("/config.yaml", &testConfig{Name: "app", Version: 1}, nil))
+
+	content, err := fs.ReadFile("/config.yaml")
+	req.NoError(err)
+	req.Equal("name: app\nversion: 1\n", string(content))
+
+	var config testConfig
+
+	_, err = fs.ReadYAML("/config.yaml", &config)
+	req.NoError(err)
+	req.Equal(testConfig{Name: "app", Version: 1}, config)
+}
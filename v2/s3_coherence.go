@@ -0,0 +1,172 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"path"
+	"sync"
+)
+
+// coherenceMaxChanges is the number of changes remembered by an instance, the older versions are all considered
+// stale once it's exceeded
+const coherenceMaxChanges = 100000
+
+// CoherenceChange is a change of a file announced on a CoherenceBus
+type CoherenceChange struct {
+	Name    string // Name is the path of the changed file
+	ETag    string // ETag is the ETag of the new version of the file, empty if it isn't known
+	Removed bool   // Removed tells if the file was removed
+}
+
+// CoherenceBus propagates the changes of the files between the instances sharing cache backends, like a Redis
+// pub/sub channel. The implementations must be safe for concurrent use.
+type CoherenceBus interface {
+	// Publish announces a change to all the subscribers, including the ones of the publishing instance
+	Publish(change CoherenceChange) error
+	// Subscribe calls onChange for every published change until the returned function is called
+	Subscribe(onChange func(change CoherenceChange)) (unsubscribe func())
+}
+
+// memoryCoherenceBus is an in-process CoherenceBus
+type memoryCoherenceBus struct {
+	mu          sync.Mutex
+	subscribers map[int]func(change CoherenceChange)
+	next        int
+}
+
+// NewMemoryCoherenceBus creates an in-process CoherenceBus, for the Fs instances of a single process
+func NewMemoryCoherenceBus() CoherenceBus {
+	return &memoryCoherenceBus{subscribers: make(map[int]func(change CoherenceChange))}
+}
+
+func (b *memoryCoherenceBus) Publish(change CoherenceChange) error {
+	b.mu.Lock()
+	subscribers := make([]func(change CoherenceChange), 0, len(b.subscribers))
+	for _, onChange := range b.subscribers {
+		subscribers = append(subscribers, onChange)
+	}
+	b.mu.Unlock()
+
+	for _, onChange := range subscribers {
+		onChange(change)
+	}
+
+	return nil
+}
+
+func (b *memoryCoherenceBus) Subscribe(onChange func(change CoherenceChange)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	b.subscribers[id] = onChange
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers, id)
+	}
+}
+
+// coherenceState keeps track of the changes announced on the bus, each one having a sequence number
+type coherenceState struct {
+	bus         CoherenceBus
+	unsubscribe func()
+	mu          sync.Mutex
+	seq         uint64                    // seq is the sequence number of the last change
+	floor       uint64                    // floor is the sequence number below which all the versions are stale
+	changes     map[string]coherenceToken // changes are the last changes of the files, by path
+}
+
+// coherenceToken is the last change of a file
+type coherenceToken struct {
+	seq  uint64
+	etag string
+}
+
+// WithCoherence keeps the caches of the Fs coherent with the other instances sharing the bus: the changes made by
+// the Fs are published, and the ones made by the other instances discard its preloaded listing and directory markers.
+// Every block read from a BlockCache is validated: the reads fail with ErrModified when the file was changed since the
+// ReaderAt was created, unless the ETag of the announced version is the read one. A nil bus disables it.
+func (fs *Fs) WithCoherence(bus CoherenceBus) *Fs {
+	if fs.coherence != nil {
+		fs.coherence.unsubscribe()
+		fs.coherence = nil
+	}
+
+	if bus != nil {
+		state := &coherenceState{bus: bus, changes: make(map[string]coherenceToken)}
+		state.unsubscribe = bus.Subscribe(func(change CoherenceChange) {
+			state.changed(change)
+			fs.preload.invalidate()
+
+			if change.Removed {
+				fs.forgetMarker(change.Name)
+			}
+		})
+		fs.coherence = state
+	}
+
+	return fs
+}
+
+// publish announces a change made by the Fs, which applies to its own readers right away
+func (c *coherenceState) publish(name string, removed bool) {
+	if c == nil {
+		return
+	}
+
+	change := CoherenceChange{Name: path.Clean("/" + name), Removed: removed}
+	c.changed(change)
+
+	// The other instances can't be notified, their caches are only kept coherent by the expiration of their entries
+	_ = c.bus.Publish(change)
+}
+
+// changed records a change
+func (c *coherenceState) changed(change CoherenceChange) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	if len(c.changes) >= coherenceMaxChanges {
+		c.changes, c.floor = make(map[string]coherenceToken), c.seq
+	}
+
+	c.changes[path.Clean("/"+change.Name)] = coherenceToken{seq: c.seq, etag: change.ETag}
+}
+
+// sequence returns the sequence number of the last change, the versions read afterwards are valid until the next
+// change of their file
+func (c *coherenceState) sequence() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.seq
+}
+
+// valid tells if the version of a file with a given ETag, read after the since sequence number, is still current
+func (c *coherenceState) valid(name, etag string, since uint64) bool {
+	if c == nil {
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	token, ok := c.changes[path.Clean("/"+name)]
+	if !ok {
+		return since >= c.floor
+	}
+
+	return token.seq <= since || (token.etag != "" && token.etag == etag)
+}
@@ -0,0 +1,66 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoherence(t *testing.T) {
+	req := require.New(t)
+	bus := NewMemoryCoherenceBus()
+	fs := __getS3Fs(t).WithCoherence(bus)
+	other := NewFs(fs.bucket, fs.session).WithCoherence(bus)
+	cache := NewMemoryBlockCache(1024 * 1024)
+	buffer := make([]byte, 100)
+
+	testWriteFile(t, fs, "/file", 300*1024)
+
+	newReader := func() *ReaderAt {
+		reader, err := other.NewReaderAt("/file", 1024)
+		req.NoError(err)
+		reader.SetBlockCache(cache, 64*1024)
+
+		return reader
+	}
+
+	_, err := newReader().ReadAt(buffer, 1000)
+	req.NoError(err)
+
+	// The blocks are shared as long as the file isn't changed
+	reader := newReader()
+	_, err = reader.ReadAt(buffer, 1000)
+	req.NoError(err)
+	req.Equal(int64(1), reader.Requests())
+
+	stale := newReader()
+
+	// The change announced with the ETag of the read version doesn't invalidate it
+	req.NoError(bus.Publish(CoherenceChange{Name: "/file", ETag: stale.etag}))
+	_, err = stale.ReadAt(buffer, 1000)
+	req.NoError(err)
+
+	// The blocks of the previous version aren't used once the file is changed by another instance
+	testCreateFile(t, fs, "/file", "changed")
+
+	_, err = stale.ReadAt(buffer, 70000)
+	req.ErrorIs(err, ErrModified)
+	req.Equal(int64(1), stale.Requests())
+
+	reader = newReader()
+	req.Equal(int64(7), reader.Size())
+
+	// The preloaded listings are discarded
+	req.NoError(other.Preload("/"))
+	testCreateFile(t, fs, "/new", "new")
+	req.Equal([]string{"file", "new"}, testReadDirNames(t, other, "/"))
+
+	req.NoError(fs.Remove("/new"))
+	req.Equal([]string{"file"}, testReadDirNames(t, other, "/"))
+
+	// The bus can be detached
+	other.WithCoherence(nil)
+	req.NoError(other.Preload("/"))
+	testCreateFile(t, fs, "/new", "new")
+	req.Equal([]string{"file"}, testReadDirNames(t, other, "/"))
+}
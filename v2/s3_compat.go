@@ -0,0 +1,30 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"os"
+	"time"
+)
+
+// compatFlag converts the O_RDWR flag to O_WRONLY in afero compatibility mode when the file is opened empty, as
+// there is then nothing to read
+func (fs *Fs) compatFlag(flag int) int {
+	if !fs.AferoCompat || flag&os.O_RDWR == 0 {
+		return flag
+	}
+
+	if flag&os.O_TRUNC != 0 || (flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0) {
+		return flag&^os.O_RDWR | os.O_WRONLY
+	}
+
+	return flag
+}
+
+// statWriting describes a file being written in afero compatibility mode, its object only exists once it's closed
+func (f *File) statWriting() (os.FileInfo, bool) {
+	if !f.fs.AferoCompat || f.streamWrite == nil {
+		return nil, false
+	}
+
+	return NewFileInfo(f.name, false, f.streamWriteSize, time.Now()), true
+}
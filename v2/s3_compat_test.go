@@ -0,0 +1,117 @@
+package s3
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAferoCompatBasePathFs(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.AferoCompat = true
+
+	testCreateFile(t, fs, "/base/dir/file", "hello")
+
+	bfs := afero.NewBasePathFs(fs, "/base")
+
+	file, err := bfs.Open("/dir/file")
+	req.NoError(err)
+	req.Equal("/dir/file", file.Name())
+	req.NoError(file.Close())
+
+	_, err = bfs.Stat("/missing")
+	req.True(os.IsNotExist(err))
+
+	req.NoError(afero.WriteFile(bfs, "/dir/new", []byte("new"), 0600))
+	req.NoError(bfs.Chtimes("/dir/new", time.Now(), time.Now()))
+
+	content, err := afero.ReadFile(fs, "/base/dir/new")
+	req.NoError(err)
+	req.Equal("new", string(content))
+
+	var names []string
+
+	req.NoError(afero.Walk(bfs, "/", func(name string, _ os.FileInfo, err error) error {
+		names = append(names, name)
+		return err
+	}))
+	req.Equal([]string{"/", "/dir", "/dir/file", "/dir/new"}, names)
+
+	// The temporary files are opened with O_RDWR
+	temp, err := afero.TempFile(bfs, "/dir", "temp")
+	req.NoError(err)
+	_, err = temp.WriteString("temp")
+	req.NoError(err)
+	req.NoError(temp.Close())
+
+	content, err = afero.ReadFile(bfs, path.Join("/dir", path.Base(temp.Name())))
+	req.NoError(err)
+	req.Equal("temp", string(content))
+}
+
+func TestAferoCompatCacheOnReadFs(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.AferoCompat = true
+
+	testCreateFile(t, fs, "/dir/file", "hello")
+
+	t.Run("Base", func(t *testing.T) {
+		cache := afero.NewMemMapFs()
+		cfs := afero.NewCacheOnReadFs(fs, cache, time.Minute)
+
+		content, err := afero.ReadFile(cfs, "/dir/file")
+		req.NoError(err)
+		req.Equal("hello", string(content))
+
+		content, err = afero.ReadFile(cache, "/dir/file")
+		req.NoError(err)
+		req.Equal("hello", string(content))
+
+		// The written files are copied to the layer before being written to both
+		req.NoError(afero.WriteFile(cfs, "/dir/written", []byte("written"), 0600))
+
+		content, err = afero.ReadFile(fs, "/dir/written")
+		req.NoError(err)
+		req.Equal("written", string(content))
+
+		_, err = cfs.Open("/dir/missing")
+		req.True(os.IsNotExist(err))
+
+		req.NoError(cfs.Remove("/dir/written"))
+
+		exists, err := afero.Exists(fs, "/dir/written")
+		req.NoError(err)
+		req.False(exists)
+	})
+
+	t.Run("Layer", func(t *testing.T) {
+		base := afero.NewMemMapFs()
+		req.NoError(afero.WriteFile(base, "/cached/file", []byte("cached"), 0600))
+
+		cfs := afero.NewCacheOnReadFs(base, fs, time.Minute)
+
+		content, err := afero.ReadFile(cfs, "/cached/file")
+		req.NoError(err)
+		req.Equal("cached", string(content))
+
+		content, err = afero.ReadFile(fs, "/cached/file")
+		req.NoError(err)
+		req.Equal("cached", string(content))
+	})
+}
+
+func TestAferoCompatDisabled(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	req.ErrorIs(fs.Chtimes("/file", time.Now(), time.Now()), ErrNotSupported)
+
+	_, err := fs.OpenFile("/file", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	req.ErrorIs(err, ErrNotSupported)
+}
@@ -0,0 +1,358 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+)
+
+// ComposedManifestContentType is the Content-Type of the objects describing a composed file
+const ComposedManifestContentType = "application/x-afero-s3-composed-manifest+json"
+
+// metaComposedSize is the metadata holding the logical size of a composed file
+const metaComposedSize = "Composed-Size"
+
+// ComposedFs is an Fs on which a file can be composed of an ordered list of objects, read as a single seekable
+// file without being physically merged. It's useful for chunked uploads or log segments.
+//
+// A composed file is stored as a manifest object listing its parts and their sizes, which are expected not to
+// change. Removing a composed file only removes its manifest. Other files are handled like with the plain Fs.
+type ComposedFs struct {
+	*Fs
+}
+
+// composedManifest is the content of the manifest object
+type composedManifest struct {
+	Parts []composedPart `json:"parts"`
+}
+
+// composedPart is an object of a composed file
+type composedPart struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// NewComposedFs creates a composed file system on top of an existing Fs
+func NewComposedFs(fs *Fs) *ComposedFs {
+	return &ComposedFs{Fs: fs}
+}
+
+// Name returns the type of FS object this is
+func (ComposedFs) Name() string { return "s3-composed" }
+
+// Compose creates the name file as the concatenation of the parts
+func (cfs *ComposedFs) Compose(name string, parts ...string) error {
+	manifest := composedManifest{Parts: make([]composedPart, len(parts))}
+
+	var size int64
+
+	for i, part := range parts {
+		info, err := cfs.Fs.Stat(part)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return &os.PathError{Op: "compose", Path: part, Err: ErrNotSupported}
+		}
+
+		manifest.Parts[i] = composedPart{Name: part, Size: info.Size()}
+		size += info.Size()
+	}
+
+	if err := cfs.putManifest(name, &manifest, size); err != nil {
+		return err
+	}
+
+	if err := cfs.fileWritten(name); err != nil {
+		return err
+	}
+
+	if cfs.Replicator != nil {
+		cfs.Replicator.enqueue(ReplicationWrite, name)
+	}
+
+	return nil
+}
+
+// putManifest writes the manifest of a composed file
+func (cfs *ComposedFs) putManifest(name string, manifest *composedManifest, size int64) error {
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	if _, err = cfs.s3API.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(cfs.bucket),
+		Key:         aws.String(name),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String(ComposedManifestContentType),
+		Metadata:    map[string]*string{metaComposedSize: aws.String(strconv.FormatInt(size, 10))},
+	}); err != nil {
+		return &os.PathError{Op: "compose", Path: name, Err: err}
+	}
+
+	return nil
+}
+
+// getManifest reads the manifest of a composed file, it returns a nil manifest if the file isn't a composed file
+func (cfs *ComposedFs) getManifest(name string) (*composedManifest, *s3.GetObjectOutput, error) {
+	resp, err := cfs.s3API.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(cfs.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	defer resp.Body.Close() // nolint: errcheck
+
+	if aws.StringValue(resp.ContentType) != ComposedManifestContentType {
+		return nil, resp, nil
+	}
+
+	var manifest composedManifest
+	if err = json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, nil, &os.PathError{Op: "open", Path: name, Err: fmt.Errorf("invalid composed manifest: %w", err)}
+	}
+
+	return &manifest, resp, nil
+}
+
+// Stat returns the FileInfo of a file, with the logical size for composed files
+func (cfs *ComposedFs) Stat(name string) (os.FileInfo, error) {
+	out, err := cfs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(cfs.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil || aws.StringValue(out.ContentType) != ComposedManifestContentType {
+		return cfs.Fs.Stat(name)
+	}
+
+	size, err := strconv.ParseInt(aws.StringValue(out.Metadata[metaComposedSize]), 10, 64)
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: fmt.Errorf("invalid composed size: %w", err)}
+	}
+
+	return NewFileInfo(name, false, size, aws.TimeValue(out.LastModified)), nil
+}
+
+// Open opens a file for reading
+func (cfs *ComposedFs) Open(name string) (afero.File, error) {
+	return cfs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens a file, composed files can only be read
+func (cfs *ComposedFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND) != 0 {
+		return cfs.Fs.OpenFile(name, flag, perm)
+	}
+
+	manifest, resp, err := cfs.getManifest(name)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+
+	if manifest == nil {
+		return cfs.Fs.OpenFile(name, flag, perm)
+	}
+
+	file := &ComposedFile{
+		fs:      cfs.Fs,
+		name:    name,
+		modTime: aws.TimeValue(resp.LastModified),
+		parts:   manifest.Parts,
+		offsets: make([]int64, len(manifest.Parts)),
+	}
+
+	for i, part := range manifest.Parts {
+		file.offsets[i] = file.size
+		file.size += part.Size
+	}
+
+	return file, nil
+}
+
+// ComposedFile is a read-only file made of several objects
+type ComposedFile struct {
+	fs      *Fs
+	name    string
+	modTime time.Time
+	parts   []composedPart
+	offsets []int64 // offsets are the offsets of the parts in the file
+	size    int64
+	offset  int64         // offset is the offset of the next Read
+	stream  io.ReadCloser // stream is the part being read, from the offset
+}
+
+// part returns the index of the part containing an offset
+func (f *ComposedFile) part(offset int64) int {
+	return sort.Search(len(f.parts), func(i int) bool { return f.offsets[i]+f.parts[i].Size > offset })
+}
+
+// openRange opens the stream of a part from an offset of the file
+func (f *ComposedFile) openRange(index int, offset, end int64) (io.ReadCloser, error) {
+	start := offset - f.offsets[index]
+
+	resp, err := f.fs.s3API.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(f.fs.bucket),
+		Key:    aws.String(f.parts[index].Name),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end-f.offsets[index]-1)),
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: f.parts[index].Name, Err: err}
+	}
+
+	return resp.Body, nil
+}
+
+// Name returns the name of the file
+func (f *ComposedFile) Name() string { return f.name }
+
+// Size returns the logical size of the file
+func (f *ComposedFile) Size() int64 { return f.size }
+
+// Read reads the parts one after the other
+func (f *ComposedFile) Read(p []byte) (int, error) {
+	for f.offset < f.size {
+		index := f.part(f.offset)
+
+		if f.stream == nil {
+			stream, err := f.openRange(index, f.offset, f.offsets[index]+f.parts[index].Size)
+			if err != nil {
+				return 0, err
+			}
+
+			f.stream = stream
+		}
+
+		n, err := f.stream.Read(p)
+		f.offset += int64(n)
+
+		// The stream of a part ends with the part
+		if errors.Is(err, io.EOF) || f.offset == f.offsets[index]+f.parts[index].Size {
+			err = f.closeStream()
+		}
+
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+
+	return 0, io.EOF
+}
+
+// ReadAt reads len(p) bytes from the off offset with ranged requests to the parts covering them
+func (f *ComposedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	read := 0
+
+	for read < len(p) && off < f.size {
+		index := f.part(off)
+
+		end := off + int64(len(p)-read)
+		if partEnd := f.offsets[index] + f.parts[index].Size; end > partEnd {
+			end = partEnd
+		}
+
+		stream, err := f.openRange(index, off, end)
+		if err != nil {
+			return read, err
+		}
+
+		n, err := io.ReadFull(stream, p[read:read+int(end-off)])
+		_ = stream.Close()
+		read += n
+		off += int64(n)
+
+		if err != nil {
+			return read, err
+		}
+	}
+
+	if read < len(p) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+// Seek sets the offset of the next Read
+func (f *ComposedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+
+	if offset < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	if offset != f.offset {
+		if err := f.closeStream(); err != nil {
+			return 0, err
+		}
+
+		f.offset = offset
+	}
+
+	return offset, nil
+}
+
+func (f *ComposedFile) closeStream() error {
+	if f.stream == nil {
+		return nil
+	}
+
+	err := f.stream.Close()
+	f.stream = nil
+
+	return err
+}
+
+// Close closes the part being read
+func (f *ComposedFile) Close() error {
+	return f.closeStream()
+}
+
+// Stat returns the FileInfo describing the file
+func (f *ComposedFile) Stat() (os.FileInfo, error) {
+	return NewFileInfo(f.name, false, f.size, f.modTime), nil
+}
+
+// Write is not supported on composed files
+func (f *ComposedFile) Write([]byte) (int, error) { return 0, ErrNotSupported }
+
+// WriteAt is not supported on composed files
+func (f *ComposedFile) WriteAt([]byte, int64) (int, error) { return 0, ErrNotSupported }
+
+// WriteString is not supported on composed files
+func (f *ComposedFile) WriteString(string) (int, error) { return 0, ErrNotSupported }
+
+// Truncate is not supported on composed files
+func (f *ComposedFile) Truncate(int64) error { return ErrNotSupported }
+
+// Sync does nothing as composed files are read-only
+func (f *ComposedFile) Sync() error { return nil }
+
+// Readdir is not supported on files
+func (f *ComposedFile) Readdir(int) ([]os.FileInfo, error) { return nil, ErrNotSupported }
+
+// Readdirnames is not supported on files
+func (f *ComposedFile) Readdirnames(int) ([]string, error) { return nil, ErrNotSupported }
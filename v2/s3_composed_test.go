@@ -0,0 +1,112 @@
+package s3
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComposedFile(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	cfs := NewComposedFs(fs)
+
+	testCreateFile(t, fs, "/segments/0", "Hello ")
+	testCreateFile(t, fs, "/segments/1", "")
+	testCreateFile(t, fs, "/segments/2", "world")
+	testCreateFile(t, fs, "/segments/3", " !")
+
+	req.NoError(cfs.Compose("/log", "/segments/0", "/segments/1", "/segments/2", "/segments/3"))
+
+	info, err := cfs.Stat("/log")
+	req.NoError(err)
+	req.Equal(int64(13), info.Size())
+
+	content, err := afero.ReadFile(cfs, "/log")
+	req.NoError(err)
+	req.Equal("Hello world !", string(content))
+
+	file, err := cfs.Open("/log")
+	req.NoError(err)
+
+	defer func() { req.NoError(file.Close()) }()
+
+	// Seeking across parts
+	_, err = file.Seek(-7, io.SeekEnd)
+	req.NoError(err)
+
+	buffer := make([]byte, 5)
+	_, err = io.ReadFull(file, buffer)
+	req.NoError(err)
+	req.Equal("world", string(buffer))
+
+	// Reading a range spanning several parts
+	_, err = file.ReadAt(buffer, 4)
+	req.NoError(err)
+	req.Equal("o wor", string(buffer))
+
+	_, err = file.ReadAt(buffer, 10)
+	req.ErrorIs(err, io.EOF)
+
+	_, err = file.Write([]byte("data"))
+	req.ErrorIs(err, ErrNotSupported)
+
+	// Other files are read as usual
+	content, err = afero.ReadFile(cfs, "/segments/0")
+	req.NoError(err)
+	req.Equal("Hello ", string(content))
+}
+
+func TestRotatingWriter(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	cfs := NewComposedFs(fs)
+
+	w, err := cfs.OpenRotating("/access.log", 8)
+	req.NoError(err)
+
+	_, err = w.WriteString("line 1\n")
+	req.NoError(err)
+	req.NoError(w.Sync())
+
+	// Too much data for a single segment
+	_, err = w.WriteString("line 2\nline 3\n")
+	req.NoError(err)
+
+	_, err = w.WriteAt([]byte("line 4\n"), 0)
+	req.ErrorIs(err, ErrNotSupported)
+
+	_, err = w.WriteAt([]byte("line 4\n"), w.Size())
+	req.NoError(err)
+	req.NoError(w.Close())
+
+	content, err := afero.ReadFile(cfs, "/access.log")
+	req.NoError(err)
+	req.Equal("line 1\nline 2\nline 3\nline 4\n", string(content))
+
+	_, err = fs.Stat("/access.log.0004")
+	req.NoError(err)
+
+	// Appending to the existing file adds segments
+	w, err = cfs.OpenRotating("/access.log", 0)
+	req.NoError(err)
+	req.Equal(int64(28), w.Size())
+
+	_, err = w.WriteString("line 5\n")
+	req.NoError(err)
+	req.NoError(w.Close())
+	req.ErrorIs(w.Close(), os.ErrClosed)
+
+	info, err := cfs.Stat("/access.log")
+	req.NoError(err)
+	req.Equal(int64(35), info.Size())
+
+	// Plain files can't be appended to
+	testCreateFile(t, fs, "/plain.log", "content")
+
+	_, err = cfs.OpenRotating("/plain.log", 0)
+	req.ErrorIs(err, ErrNotComposed)
+}
@@ -0,0 +1,204 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// concatPlan builds the parts of a concatenation. S3 requires all the parts but the last one to be at least 5MB,
+// so the big enough ranges of the sources are copied server-side while the smaller ones are downloaded and
+// aggregated into uploaded parts.
+type concatPlan struct {
+	fs       *Fs
+	dstKey   string
+	uploadID *string
+	number   int64               // number is the number of the last planned part
+	buffer   bytes.Buffer        // buffer aggregates the data too small to be copied
+	ranges   []partRange         // ranges are the ranges copied server-side
+	uploaded []*s3.CompletedPart // uploaded are the parts uploaded from the buffer
+}
+
+func (p *concatPlan) add(name string, size int64) error {
+	offset := int64(0)
+
+	// The pending data is completed with the beginning of the object to reach the minimum part size
+	if p.buffer.Len() > 0 {
+		offset = s3manager.MinUploadPartSize - int64(p.buffer.Len())
+		if offset > size {
+			offset = size
+		}
+
+		if err := p.download(name, 0, offset); err != nil {
+			return err
+		}
+
+		if int64(p.buffer.Len()) >= s3manager.MinUploadPartSize {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if size-offset < s3manager.MinUploadPartSize {
+		return p.download(name, offset, size)
+	}
+
+	source := partSource{name: copySource(p.fs.bucket, name)}
+
+	for start := offset; start < size; {
+		end := start + DefaultCopyPartSize
+
+		// A too small tail is copied with the previous range
+		if size-end < s3manager.MinUploadPartSize {
+			end = size
+		}
+
+		p.number++
+		p.ranges = append(p.ranges, partRange{source: source, number: p.number, start: start, end: end})
+		start = end
+	}
+
+	return nil
+}
+
+// download adds the [start, end) range of an object to the buffer
+func (p *concatPlan) download(name string, start, end int64) error {
+	if start >= end {
+		return nil
+	}
+
+	resp, err := p.fs.s3API.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(p.fs.bucket),
+		Key:    aws.String(name),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end-1)),
+	})
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close() // nolint: errcheck
+
+	_, err = io.Copy(&p.buffer, resp.Body)
+
+	return err
+}
+
+// flush uploads the buffer as a part
+func (p *concatPlan) flush() error {
+	p.number++
+
+	out, err := p.fs.s3API.UploadPart(&s3.UploadPartInput{
+		Bucket:     aws.String(p.fs.bucket),
+		Key:        aws.String(p.dstKey),
+		UploadId:   p.uploadID,
+		PartNumber: aws.Int64(p.number),
+		Body:       bytes.NewReader(p.buffer.Bytes()),
+	})
+	if err != nil {
+		return err
+	}
+
+	p.uploaded = append(p.uploaded, &s3.CompletedPart{ETag: out.ETag, PartNumber: aws.Int64(p.number)})
+	p.buffer.Reset()
+
+	return nil
+}
+
+// parts copies the planned ranges and returns all the parts of the concatenation
+func (p *concatPlan) parts() ([]*s3.CompletedPart, error) {
+	// The last part can be smaller than the minimum size, and there must be at least one part
+	if p.buffer.Len() > 0 || p.number == 0 {
+		if err := p.flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	copied, err := p.fs.copyPartRanges(p.dstKey, p.uploadID, p.ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := append(p.uploaded, copied...) // nolint: gocritic
+	sort.Slice(parts, func(i, j int) bool { return *parts[i].PartNumber < *parts[j].PartNumber })
+
+	return parts, nil
+}
+
+// Concat creates the dst file by concatenating the src files. The sources are composed server-side with a
+// multipart copy, only the sources or the ranges of sources smaller than the 5MB minimum part size are downloaded.
+func (fs *Fs) Concat(dst string, srcs ...string) error {
+	sizes := make([]int64, len(srcs))
+
+	for i, src := range srcs {
+		info, err := fs.Stat(src)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return &os.PathError{Op: "concat", Path: src, Err: ErrNotSupported}
+		}
+
+		sizes[i] = info.Size()
+	}
+
+	input, err := fs.multipartUploadInput("", dst, &CopyOptions{ReplaceMetadata: true, DropTags: true})
+	if err != nil {
+		return err
+	}
+
+	upload, err := fs.s3API.CreateMultipartUpload(input)
+	if err != nil {
+		return &os.PathError{Op: "concat", Path: dst, Err: err}
+	}
+
+	plan := &concatPlan{fs: fs, dstKey: dst, uploadID: upload.UploadId}
+
+	for i, src := range srcs {
+		if err = plan.add(src, sizes[i]); err != nil {
+			break
+		}
+	}
+
+	var parts []*s3.CompletedPart
+	if err == nil {
+		parts, err = plan.parts()
+	}
+
+	if err == nil {
+		_, err = fs.s3API.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(fs.bucket),
+			Key:             aws.String(dst),
+			UploadId:        upload.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+
+	if err != nil {
+		_, _ = fs.s3API.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(fs.bucket),
+			Key:      aws.String(dst),
+			UploadId: upload.UploadId,
+		})
+
+		return &os.PathError{Op: "concat", Path: dst, Err: err}
+	}
+
+	if err = fs.fileWritten(dst); err != nil {
+		return err
+	}
+
+	if fs.Replicator != nil {
+		fs.Replicator.enqueue(ReplicationWrite, dst)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package s3
+
+import (
+	"bytes"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcat(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	sources := map[string][]byte{
+		"/small-1": []byte("header\n"),
+		"/big-1":   bytes.Repeat([]byte("a"), 7*1024*1024),
+		"/small-2": bytes.Repeat([]byte("b"), 2*1024*1024),
+		"/big-2":   bytes.Repeat([]byte("c"), 12*1024*1024),
+		"/small-3": []byte("footer\n"),
+	}
+	order := []string{"/small-1", "/big-1", "/small-2", "/big-2", "/small-3"}
+
+	var expected []byte
+
+	for _, name := range order {
+		_, err := fs.s3API.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(fs.bucket),
+			Key:    aws.String(name),
+			Body:   bytes.NewReader(sources[name]),
+		})
+		req.NoError(err)
+
+		expected = append(expected, sources[name]...)
+	}
+
+	var copies int64
+
+	fs.s3API.Handlers.Send.PushBack(func(r *request.Request) {
+		if r.Operation.Name == "UploadPartCopy" {
+			atomic.AddInt64(&copies, 1)
+		}
+	})
+
+	req.NoError(fs.Concat("/merged", order...))
+
+	content, err := afero.ReadFile(fs, "/merged")
+	req.NoError(err)
+	req.Equal(len(expected), len(content))
+	req.True(bytes.Equal(expected, content))
+
+	// Parts of the big sources were copied server-side
+	req.Greater(atomic.LoadInt64(&copies), int64(0))
+
+	t.Run("Small", func(t *testing.T) {
+		req.NoError(fs.Concat("/small", "/small-1", "/small-3"))
+
+		content, err := afero.ReadFile(fs, "/small")
+		req.NoError(err)
+		req.Equal("header\nfooter\n", string(content))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		req.ErrorIs(fs.Concat("/other", "/small-1", "/missing"), os.ErrNotExist)
+	})
+}
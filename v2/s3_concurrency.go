@@ -0,0 +1,74 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Names of the handlers limiting the concurrent requests
+const (
+	acquireRequestHandler = "afero-s3.AcquireRequestSlot"
+	releaseRequestHandler = "afero-s3.ReleaseRequestSlot"
+)
+
+// WithMaxConcurrentUploads limits the number of files uploaded at the same time by the Fs, 0 meaning no limit.
+// The writes of the files waiting for their upload to start are blocked.
+// It should be called before the Fs is used.
+func (fs *Fs) WithMaxConcurrentUploads(n int) *Fs {
+	fs.uploadSlots = nil
+	if n > 0 {
+		fs.uploadSlots = make(chan struct{}, n)
+	}
+
+	return fs
+}
+
+// WithMaxConcurrentRequests limits the number of requests sent at the same time by the Fs, 0 meaning no limit.
+// It should be called before the Fs is used.
+func (fs *Fs) WithMaxConcurrentRequests(n int) *Fs {
+	fs.requestSlots = nil
+	if n > 0 {
+		fs.requestSlots = make(chan struct{}, n)
+	}
+
+	limitRequests(&fs.s3API.Handlers, fs.requestSlots)
+
+	return fs
+}
+
+// limitRequests installs the handlers limiting the number of concurrent requests, or removes them if slots is nil
+func limitRequests(handlers *request.Handlers, slots chan struct{}) {
+	handlers.Send.Remove(request.NamedHandler{Name: acquireRequestHandler})
+	handlers.Send.Remove(request.NamedHandler{Name: releaseRequestHandler})
+
+	if slots == nil {
+		return
+	}
+
+	handlers.Send.PushFrontNamed(request.NamedHandler{
+		Name: acquireRequestHandler,
+		Fn:   func(*request.Request) { slots <- struct{}{} },
+	})
+	handlers.Send.PushBackNamed(request.NamedHandler{
+		Name: releaseRequestHandler,
+		Fn:   func(*request.Request) { <-slots },
+	})
+}
+
+// requestLimitOption applies the concurrent requests limit of the Fs to the requests of other clients
+func (fs *Fs) requestLimitOption() request.Option {
+	return func(r *request.Request) {
+		limitRequests(&r.Handlers, fs.requestSlots)
+	}
+}
+
+// acquireUploadSlot waits until an upload can start, it returns the function releasing the slot
+func (fs *Fs) acquireUploadSlot() func() {
+	if fs.uploadSlots == nil {
+		return func() {}
+	}
+
+	fs.uploadSlots <- struct{}{}
+
+	return func() { <-fs.uploadSlots }
+}
@@ -0,0 +1,72 @@
+package s3
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyMeter measures the maximum number of concurrent requests
+type concurrencyMeter struct {
+	current, max int64
+}
+
+func (m *concurrencyMeter) install(list *request.HandlerList, filter func(r *request.Request) bool) {
+	list.PushFront(func(r *request.Request) {
+		if filter(r) {
+			if current := atomic.AddInt64(&m.current, 1); current > atomic.LoadInt64(&m.max) {
+				atomic.StoreInt64(&m.max, current)
+			}
+
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+	list.PushBack(func(r *request.Request) {
+		if filter(r) {
+			atomic.AddInt64(&m.current, -1)
+		}
+	})
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	// The uploads use their own client
+	fs.session = fs.session.Copy()
+
+	requests, uploads := &concurrencyMeter{}, &concurrencyMeter{}
+	for _, handlers := range []*request.Handlers{&fs.s3API.Handlers, &fs.session.Handlers} {
+		requests.install(&handlers.Send, func(*request.Request) bool { return true })
+	}
+	uploads.install(&fs.session.Handlers.Send, func(r *request.Request) bool { return partNumber(r) != 0 })
+
+	fs.WithMaxConcurrentRequests(3).WithMaxConcurrentUploads(2)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			file, err := fs.OpenFile(fmt.Sprintf("/file-%d", i), os.O_WRONLY, 0)
+			req.NoError(err)
+			_, err = file.WriteString("content")
+			req.NoError(err)
+			req.NoError(file.Close())
+		}(i)
+	}
+
+	wg.Wait()
+
+	req.LessOrEqual(atomic.LoadInt64(&requests.max), int64(3))
+	req.LessOrEqual(atomic.LoadInt64(&uploads.max), int64(2))
+}
@@ -0,0 +1,33 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// ErrModified is returned when a conditional write fails because the file was modified, or created, concurrently
+var ErrModified = errors.New("file was modified concurrently")
+
+// conditionalWrite adds the If-Match and If-None-Match conditions of the upload parameters to a request, the
+// SDK not supporting them on PutObject
+func conditionalWrite(params *uploadParams) request.Option {
+	return func(r *request.Request) {
+		if params.ifMatch != nil {
+			r.HTTPRequest.Header.Set("If-Match", *params.ifMatch)
+		}
+
+		if params.ifNoneMatch != nil {
+			r.HTTPRequest.Header.Set("If-None-Match", *params.ifNoneMatch)
+		}
+	}
+}
+
+// isPreconditionFailed tells if a conditional request failed. A 409 ConditionalRequestConflict is returned by S3
+// when a concurrent conditional write is in progress.
+func isPreconditionFailed(err error) bool {
+	status := httpStatus(err)
+	return status == http.StatusPreconditionFailed || status == http.StatusConflict
+}
@@ -0,0 +1,122 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ConnectionOptions tunes the pool of HTTP connections to the endpoint, the zero values keep the settings of the
+// HTTP client of the session
+type ConnectionOptions struct {
+	// MaxIdleConnsPerHost is the number of idle connections kept per host, which is only 2 by default. It should be
+	// at least the number of concurrent requests for the connections to be reused.
+	MaxIdleConnsPerHost int
+	// MaxIdleConns is the total number of idle connections kept
+	MaxIdleConns int
+	// IdleConnTimeout is the time an idle connection is kept
+	IdleConnTimeout time.Duration
+	// KeepAlive is the interval of the TCP keep-alive probes of the connections
+	KeepAlive time.Duration
+	// PrewarmConnections is the number of connections established in the background, see Prewarm
+	PrewarmConnections int
+}
+
+// dialTimeout is the connection timeout of the dialer of the tuned connections, like the default one of Go
+const dialTimeout = 30 * time.Second
+
+// WithConnectionOptions tunes the pool of HTTP connections of the Fs, with a copy of the transport of the session.
+// The connections are established in the background if PrewarmConnections is set, or before returning in serverless
+// mode. It should be called before the Fs is used.
+func (fs *Fs) WithConnectionOptions(opts *ConnectionOptions) *Fs {
+	fs.withTransport(func(transport *http.Transport) {
+		if opts.MaxIdleConnsPerHost > 0 {
+			transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+		}
+
+		if opts.MaxIdleConns > 0 {
+			transport.MaxIdleConns = opts.MaxIdleConns
+		}
+
+		if opts.IdleConnTimeout > 0 {
+			transport.IdleConnTimeout = opts.IdleConnTimeout
+		}
+
+		if opts.KeepAlive > 0 {
+			transport.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: opts.KeepAlive}).DialContext
+		}
+	})
+
+	switch {
+	case opts.PrewarmConnections <= 0:
+	case fs.serverless != nil:
+		_ = fs.Prewarm(context.Background(), opts.PrewarmConnections)
+	default:
+		go func() {
+			_ = fs.Prewarm(context.Background(), opts.PrewarmConnections)
+		}()
+	}
+
+	return fs
+}
+
+// withTransport tunes a copy of the transport of the HTTP client of the Fs, used by all its requests
+func (fs *Fs) withTransport(tune func(transport *http.Transport)) {
+	client := fs.s3API.Config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+
+	transport = transport.Clone()
+	tune(transport)
+
+	tuned := *client
+	tuned.Transport = transport
+
+	// The uploads use clients created from the session
+	fs.session = fs.session.Copy(&aws.Config{HTTPClient: &tuned})
+	fs.s3API.Config.HTTPClient = &tuned
+}
+
+// Prewarm establishes connections to the endpoint with concurrent HeadBucket requests, which are then kept in the
+// pool of idle connections, up to its MaxIdleConnsPerHost size. It avoids the latency of the TLS handshakes on the
+// first requests, like when a short-lived runtime starts. It returns the first error.
+func (fs *Fs) Prewarm(ctx context.Context, connections int) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := fs.s3API.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(fs.bucket)})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionOptions(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	target, err := url.Parse(testEndpoint)
+	req.NoError(err)
+
+	// A gateway counting the connections it accepts
+	var connections int64
+
+	gateway := httptest.NewUnstartedServer(httputil.NewSingleHostReverseProxy(target))
+	gateway.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&connections, 1)
+		}
+	}
+	gateway.Start()
+
+	defer gateway.Close()
+
+	fs.WithEndpoints(EndpointRoundRobin, gateway.URL).WithConnectionOptions(&ConnectionOptions{
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     time.Minute,
+		KeepAlive:           time.Minute,
+	})
+	defer fs.WithEndpoints(EndpointRoundRobin)
+
+	transport, ok := fs.s3API.Config.HTTPClient.Transport.(*http.Transport)
+	req.True(ok)
+	req.Equal(4, transport.MaxIdleConnsPerHost)
+	req.Equal(time.Minute, transport.IdleConnTimeout)
+
+	req.NoError(fs.Prewarm(context.Background(), 4))
+
+	prewarmed := atomic.LoadInt64(&connections)
+	req.Equal(int64(4), prewarmed)
+
+	// The following requests reuse the connections, including the uploads
+	for i := 0; i < 4; i++ {
+		req.NoError(afero.WriteFile(fs, fmt.Sprintf("/file-%d", i), []byte("content"), 0600))
+	}
+
+	req.Equal(prewarmed, atomic.LoadInt64(&connections))
+}
@@ -0,0 +1,139 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+)
+
+// contextHandler is the name of the handler binding the requests to the context of an Fs
+const contextHandler = "afero-s3.BindContext"
+
+// WithContext returns a copy of the Fs, sharing its state and its configuration, whose requests are bound to a
+// context: they're cancelled with it and respect its deadline, like the listings, uploads and downloads of its
+// operations and of the files it opens, the write-behind closes included. The errors of the requests interrupted by
+// the context match its error. The requests explicitly bound to another context, like the ones of ServeContent, keep
+// it. It must be called once the Fs is configured.
+func (fs *Fs) WithContext(ctx context.Context) *Fs {
+	if ctx == nil {
+		panic("nil context")
+	}
+
+	bound := fs.snapshot()
+	bound.ctx = ctx
+
+	client := *fs.s3API.Client
+	client.Handlers = client.Handlers.Copy()
+	client.Handlers.Validate.PushFrontNamed(bindContext(ctx))
+	bound.s3API = &s3.S3{Client: &client}
+
+	if fs.Mirror != nil {
+		bound.Mirror = fs.Mirror.WithContext(ctx)
+	}
+
+	return bound
+}
+
+// requestContext returns the context of the requests of the Fs
+func (fs *Fs) requestContext() context.Context {
+	if fs.ctx == nil {
+		return aws.BackgroundContext()
+	}
+
+	return fs.ctx
+}
+
+// bindContext returns the handler binding the requests without context to a context
+func bindContext(ctx context.Context) request.NamedHandler {
+	return request.NamedHandler{Name: contextHandler, Fn: func(r *request.Request) {
+		if r.Context() != aws.BackgroundContext() {
+			return
+		}
+
+		r.SetContext(ctx)
+
+		// The requests interrupted by the context fail while they're signed, like when the credentials are retrieved,
+		// or sent, their error being returned after the retry handlers since they aren't retried
+		wrap := func(r *request.Request) { r.Error = contextError(ctx, r.Error) }
+		r.Handlers.Sign.PushBack(wrap)
+		r.Handlers.AfterRetry.PushBack(wrap)
+	}}
+}
+
+// contextError makes the error of a request interrupted by its context match the error of the context
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %v", ctx.Err(), err)
+}
+
+// CreateContext creates a file like Create, with the requests bound to a context
+func (fs *Fs) CreateContext(ctx context.Context, name string) (afero.File, error) {
+	return fs.WithContext(ctx).Create(name)
+}
+
+// MkdirContext creates a directory like Mkdir, with the requests bound to a context
+func (fs *Fs) MkdirContext(ctx context.Context, name string, perm os.FileMode) error {
+	return fs.WithContext(ctx).Mkdir(name, perm)
+}
+
+// MkdirAllContext creates a directory and its parents like MkdirAll, with the requests bound to a context
+func (fs *Fs) MkdirAllContext(ctx context.Context, path string, perm os.FileMode) error {
+	return fs.WithContext(ctx).MkdirAll(path, perm)
+}
+
+// OpenContext opens a file like Open, with the requests of the operation and of the file bound to a context
+func (fs *Fs) OpenContext(ctx context.Context, name string) (afero.File, error) {
+	return fs.WithContext(ctx).Open(name)
+}
+
+// OpenFileContext opens a file like OpenFile, with the requests of the operation and of the file bound to a context
+func (fs *Fs) OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error) {
+	return fs.WithContext(ctx).OpenFile(name, flag, perm)
+}
+
+// RemoveContext removes a file like Remove, with the requests bound to a context
+func (fs *Fs) RemoveContext(ctx context.Context, name string) error {
+	return fs.WithContext(ctx).Remove(name)
+}
+
+// RemoveAllContext removes a directory tree like RemoveAll, with the requests bound to a context: the removal stops
+// when it's cancelled
+func (fs *Fs) RemoveAllContext(ctx context.Context, name string) error {
+	return fs.WithContext(ctx).RemoveAll(name)
+}
+
+// RenameContext renames a file or a directory like Rename, with the requests bound to a context
+func (fs *Fs) RenameContext(ctx context.Context, oldname, newname string) error {
+	return fs.WithContext(ctx).Rename(oldname, newname)
+}
+
+// StatContext returns the FileInfo of a file like Stat, with the requests bound to a context
+func (fs *Fs) StatContext(ctx context.Context, name string) (os.FileInfo, error) {
+	return fs.WithContext(ctx).Stat(name)
+}
+
+// ChmodContext changes the permissions of a file like Chmod, with the requests bound to a context
+func (fs *Fs) ChmodContext(ctx context.Context, name string, mode os.FileMode) error {
+	return fs.WithContext(ctx).Chmod(name, mode)
+}
+
+// ChownContext changes the owner of a file like Chown, with the requests bound to a context
+func (fs *Fs) ChownContext(ctx context.Context, name string, uid, gid int) error {
+	return fs.WithContext(ctx).Chown(name, uid, gid)
+}
+
+// ChtimesContext changes the times of a file like Chtimes, with the requests bound to a context
+func (fs *Fs) ChtimesContext(ctx context.Context, name string, atime, mtime time.Time) error {
+	return fs.WithContext(ctx).Chtimes(name, atime, mtime)
+}
@@ -0,0 +1,66 @@
+package s3
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/dir/file1", "content")
+	testCreateFile(t, fs, "/dir/file2", "content")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The operations are performed with a live context
+	info, err := fs.StatContext(ctx, "/dir/file1")
+	req.NoError(err)
+	req.Equal(int64(7), info.Size())
+
+	file, err := fs.CreateContext(ctx, "/dir/file3")
+	req.NoError(err)
+	_, err = file.WriteString("written")
+	req.NoError(err)
+
+	// The operations and the files are interrupted once it's cancelled
+	cancel()
+
+	req.ErrorIs(file.Close(), context.Canceled)
+
+	_, err = fs.StatContext(ctx, "/dir/file1")
+	req.ErrorIs(err, context.Canceled)
+
+	_, err = fs.OpenFileContext(ctx, "/dir/file1", os.O_RDONLY, 0)
+	req.ErrorIs(err, context.Canceled)
+
+	req.ErrorIs(fs.RemoveAllContext(ctx, "/dir"), context.Canceled)
+
+	// Chtimes doesn't send any request
+	fs.PosixMetadata = true
+	req.ErrorIs(fs.ChownContext(ctx, "/dir/file1", 1000, 1000), context.Canceled)
+	req.ErrorIs(fs.ChtimesContext(ctx, "/dir/file1", time.Now(), time.Now()), ErrNotSupported)
+
+	// Nothing was removed, and the content of the file wasn't written
+	entries, err := afero.ReadDir(fs, "/dir")
+	req.NoError(err)
+	req.Len(entries, 3)
+	req.Zero(entries[2].Size())
+
+	// The deadlines are respected
+	expired, cancelExpired := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelExpired()
+
+	req.ErrorIs(fs.WithContext(expired).Remove("/dir/file1"), context.DeadlineExceeded)
+
+	// The Fs itself isn't affected
+	_, err = fs.Stat("/dir/file1")
+	req.NoError(err)
+	req.NoError(fs.RemoveAll("/dir"))
+}
@@ -0,0 +1,381 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// MaxCopyObjectSize is the maximum size of an object S3 can copy with a single CopyObject request
+const MaxCopyObjectSize = 5 * 1024 * 1024 * 1024
+
+// DefaultCopyPartSize is the size of the parts of multipart copies
+const DefaultCopyPartSize = 512 * 1024 * 1024
+
+// maxParts is the maximum number of parts of a multipart upload
+const maxParts = 10000
+
+// copyPartsConcurrency is the number of parts of an object copied in parallel
+const copyPartsConcurrency = 4
+
+// CopyOptions defines how objects are copied
+type CopyOptions struct {
+	BulkOptions
+	// ReplaceMetadata applies the Fs FileProps to the copies instead of the metadata of the source objects
+	ReplaceMetadata bool
+	// DropTags doesn't copy the tags of the source objects
+	DropTags bool
+	// MultipartThreshold is the size above which objects are copied with a multipart copy,
+	// MaxCopyObjectSize if 0 or bigger
+	MultipartThreshold int64
+	// PartSize is the size of the parts of multipart copies, DefaultCopyPartSize if 0. It's raised to
+	// s3manager.MinUploadPartSize, the minimum size of the parts S3 accepts.
+	PartSize int64
+	// SourceEncryption is the encryption of the source objects with a customer-provided key, when it's not the one
+	// of the Fs. The copies are encrypted like the Fs files, which allows to rotate the keys.
+	SourceEncryption *Encryption
+}
+
+// copyPartSize returns the size of the parts of the multipart copies set by the options, the Fs or the serverless mode
+func (fs *Fs) copyPartSize(opts *CopyOptions) int64 {
+	switch {
+	case opts.PartSize != 0:
+		return opts.PartSize
+	case fs.CopyPartSize != 0:
+		return fs.CopyPartSize
+	case fs.serverless != nil:
+		return fs.serverless.copyPartSize
+	}
+
+	return 0
+}
+
+// copySource builds the URL-encoded source of a copy
+func copySource(bucket, key string) string {
+	segments := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	// The objects of the access points are designated by their ARN
+	if arn.IsARN(bucket) {
+		return bucket + "/object/" + strings.Join(segments, "/")
+	}
+
+	return bucket + "/" + strings.Join(segments, "/")
+}
+
+// CopyDir copies all the files of a directory to another one with server-side copies, the content of the files
+// never goes through this process. Copying a directory to itself or below itself returns a *RenameCollisionError.
+func (fs *Fs) CopyDir(src, dst string, opts *CopyOptions) error {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+
+	// The copies would be copied again
+	srcClean, dstClean := path.Clean("/"+src), path.Clean("/"+dst)
+
+	switch {
+	case srcClean == dstClean:
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: &RenameCollisionError{Reason: "the target is the source"}}
+	case srcClean == "/" || strings.HasPrefix(dstClean, srcClean+"/"):
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: &RenameCollisionError{
+			Reason: "the target is below the source",
+		}}
+	}
+
+	srcPrefix, dstPrefix := dirPrefix(src), dirPrefix(dst)
+
+	return fs.forEachObject("copy", src, &opts.BulkOptions, func(obj *s3.Object) error {
+		dstKey := dstPrefix + strings.TrimPrefix(*obj.Key, srcPrefix)
+		if err := fs.copyObject(*obj.Key, dstKey, *obj.Size, opts); err != nil {
+			return err
+		}
+
+		if fs.Replicator != nil {
+			fs.Replicator.enqueue(ReplicationWrite, "/"+dstKey)
+		}
+
+		return nil
+	})
+}
+
+// copyObject performs a server-side copy, with a multipart copy if the object is too big for CopyObject
+func (fs *Fs) copyObject(srcKey, dstKey string, size int64, opts *CopyOptions) error {
+	threshold := opts.MultipartThreshold
+	if threshold <= 0 || threshold > MaxCopyObjectSize {
+		threshold = MaxCopyObjectSize
+	}
+
+	if size > threshold {
+		return fs.multipartCopy(srcKey, dstKey, size, opts)
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(fs.bucket),
+		CopySource: aws.String(copySource(fs.bucket, srcKey)),
+		Key:        aws.String(dstKey),
+	}
+
+	if opts.ReplaceMetadata {
+		input.MetadataDirective = aws.String(s3.MetadataDirectiveReplace)
+		input.ContentType = aws.String(mime.TypeByExtension(filepath.Ext(dstKey)))
+
+		if fs.FileProps != nil {
+			applyFileCopyProps(input, fs.FileProps)
+		}
+	}
+
+	if opts.DropTags {
+		input.TaggingDirective = aws.String(s3.TaggingDirectiveReplace)
+	}
+
+	if opts.SourceEncryption != nil {
+		opts.SourceEncryption.setCustomer(&input.CopySourceSSECustomerAlgorithm, &input.CopySourceSSECustomerKey)
+	}
+
+	_, err := fs.s3API.CopyObject(input)
+
+	return err
+}
+
+// multipartUploadInput creates the input of the multipart upload of a copy, as multipart copies don't copy the
+// metadata and tags of the source object
+func (fs *Fs) multipartUploadInput(srcKey, dstKey string, opts *CopyOptions) (*s3.CreateMultipartUploadInput, error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(dstKey),
+	}
+
+	if opts.ReplaceMetadata {
+		input.ContentType = aws.String(mime.TypeByExtension(filepath.Ext(dstKey)))
+
+		if fs.FileProps != nil {
+			input.ACL = fs.FileProps.ACL
+			input.CacheControl = fs.FileProps.CacheControl
+			input.WebsiteRedirectLocation = fs.FileProps.WebsiteRedirectLocation
+
+			if fs.FileProps.ContentType != nil {
+				input.ContentType = fs.FileProps.ContentType
+			}
+		}
+	} else {
+		headInput := &s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String(srcKey)}
+		if opts.SourceEncryption != nil {
+			opts.SourceEncryption.setCustomer(&headInput.SSECustomerAlgorithm, &headInput.SSECustomerKey)
+		}
+
+		head, err := fs.s3API.HeadObject(headInput)
+		if err != nil {
+			return nil, err
+		}
+
+		input.ContentType = head.ContentType
+		input.CacheControl = head.CacheControl
+		input.ContentDisposition = head.ContentDisposition
+		input.ContentEncoding = head.ContentEncoding
+		input.ContentLanguage = head.ContentLanguage
+		input.Metadata = head.Metadata
+	}
+
+	if !opts.DropTags {
+		tagging, err := fs.s3API.GetObjectTagging(&s3.GetObjectTaggingInput{
+			Bucket: aws.String(fs.bucket),
+			Key:    aws.String(srcKey),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tagging.TagSet) > 0 {
+			tags := url.Values{}
+			for _, tag := range tagging.TagSet {
+				tags.Set(aws.StringValue(tag.Key), aws.StringValue(tag.Value))
+			}
+
+			input.Tagging = aws.String(tags.Encode())
+		}
+	}
+
+	return input, nil
+}
+
+func (fs *Fs) multipartCopy(srcKey, dstKey string, size int64, opts *CopyOptions) error {
+	input, err := fs.multipartUploadInput(srcKey, dstKey, opts)
+	if err != nil {
+		return err
+	}
+
+	upload, err := fs.s3API.CreateMultipartUpload(input)
+	if err != nil {
+		return err
+	}
+
+	source := partSource{name: copySource(fs.bucket, srcKey), encryption: opts.SourceEncryption}
+
+	parts, err := fs.copyPartRanges(dstKey, upload.UploadId, partRanges(source, size, fs.copyPartSize(opts)))
+	if err == nil {
+		_, err = fs.s3API.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(fs.bucket),
+			Key:             aws.String(dstKey),
+			UploadId:        upload.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+
+	if err != nil {
+		_, _ = fs.s3API.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(fs.bucket),
+			Key:      aws.String(dstKey),
+			UploadId: upload.UploadId,
+		})
+	}
+
+	return err
+}
+
+// partRange is a range of a source object copied to a part of a multipart upload
+type partRange struct {
+	source partSource
+	number int64
+	start  int64
+	end    int64 // end is exclusive
+}
+
+// partSource is the source object of copied parts
+type partSource struct {
+	name       string      // name is the URL-encoded source of the copy
+	encryption *Encryption // encryption is the encryption of the source, if it's not the Fs one
+}
+
+// partRanges splits the [0, size) range of the source into the ranges of the parts of a multipart copy
+func partRanges(source partSource, size, partSize int64) []partRange {
+	if partSize <= 0 {
+		partSize = DefaultCopyPartSize
+	}
+
+	// All the parts but the last one must be at least 5 MiB
+	if partSize < s3manager.MinUploadPartSize {
+		partSize = s3manager.MinUploadPartSize
+	}
+
+	// There can't be more than 10000 parts
+	if minPartSize := (size + maxParts - 1) / maxParts; partSize < minPartSize {
+		partSize = minPartSize
+	}
+
+	var ranges []partRange
+
+	for number, start := int64(1), int64(0); start < size; number, start = number+1, start+partSize {
+		end := start + partSize
+		if end > size {
+			end = size
+		}
+
+		ranges = append(ranges, partRange{source: source, number: number, start: start, end: end})
+	}
+
+	return ranges
+}
+
+// copyPartRanges copies ranges of source objects to the parts of a multipart upload, in parallel
+func (fs *Fs) copyPartRanges(dstKey string, uploadID *string, ranges []partRange) ([]*s3.CompletedPart, error) {
+	var (
+		parts    []*s3.CompletedPart
+		firstErr error
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+	)
+
+	sem := make(chan struct{}, copyPartsConcurrency)
+
+	for _, r := range ranges {
+		sem <- struct{}{}
+
+		// No more parts are copied after a failure
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+
+		if failed {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+
+		go func(r partRange) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			input := &s3.UploadPartCopyInput{
+				Bucket:          aws.String(fs.bucket),
+				Key:             aws.String(dstKey),
+				UploadId:        uploadID,
+				PartNumber:      aws.Int64(r.number),
+				CopySource:      aws.String(r.source.name),
+				CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", r.start, r.end-1)),
+			}
+
+			if r.source.encryption != nil {
+				r.source.encryption.setCustomer(&input.CopySourceSSECustomerAlgorithm, &input.CopySourceSSECustomerKey)
+			}
+
+			out, err := fs.s3API.UploadPartCopy(input)
+			if err == nil && (out.CopyPartResult == nil || out.CopyPartResult.ETag == nil) {
+				err = fmt.Errorf("no ETag returned for the copy of part %d", r.number)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+
+				return
+			}
+
+			parts = append(parts, &s3.CompletedPart{ETag: out.CopyPartResult.ETag, PartNumber: aws.Int64(r.number)})
+		}(r)
+	}
+
+	wg.Wait()
+
+	sort.Slice(parts, func(i, j int) bool { return *parts[i].PartNumber < *parts[j].PartNumber })
+
+	return parts, firstErr
+}
+
+func applyFileCopyProps(req *s3.CopyObjectInput, p *UploadedFileProperties) {
+	if p.ACL != nil {
+		req.ACL = p.ACL
+	}
+
+	if p.CacheControl != nil {
+		req.CacheControl = p.CacheControl
+	}
+
+	if p.ContentType != nil {
+		req.ContentType = p.ContentType
+	}
+
+	if p.WebsiteRedirectLocation != nil {
+		req.WebsiteRedirectLocation = p.WebsiteRedirectLocation
+	}
+}
@@ -0,0 +1,125 @@
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyDir(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	for i := 0; i < 5; i++ {
+		testCreateFile(t, fs, fmt.Sprintf("/src/sub%d/file %d.txt", i, i), fmt.Sprintf("content %d", i))
+	}
+
+	_, err := fs.s3API.PutObjectTagging(&s3.PutObjectTaggingInput{
+		Bucket:  aws.String(fs.bucket),
+		Key:     aws.String("src/sub1/file 1.txt"),
+		Tagging: &s3.Tagging{TagSet: []*s3.Tag{{Key: aws.String("team"), Value: aws.String("data")}}},
+	})
+	req.NoError(err)
+
+	req.NoError(fs.CopyDir("/src", "/dst", &CopyOptions{BulkOptions: BulkOptions{Concurrency: 2}}))
+
+	for i := 0; i < 5; i++ {
+		content, errRead := afero.ReadFile(fs, fmt.Sprintf("/dst/sub%d/file %d.txt", i, i))
+		req.NoError(errRead)
+		req.Equal(fmt.Sprintf("content %d", i), string(content))
+	}
+
+	tagging, err := fs.s3API.GetObjectTagging(&s3.GetObjectTaggingInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String("dst/sub1/file 1.txt"),
+	})
+	req.NoError(err)
+	req.Len(tagging.TagSet, 1)
+
+	// The source is left untouched
+	_, err = fs.Stat("/src/sub1/file 1.txt")
+	req.NoError(err)
+
+	// A directory can't be copied to itself or below itself
+	for _, dst := range []string{"/src", "/src/", "/src/sub1/copy"} {
+		var collision *RenameCollisionError
+		req.ErrorAs(fs.CopyDir("/src", dst, nil), &collision, dst)
+	}
+
+	_, err = fs.Stat("/src/sub1/copy")
+	req.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestCopyDirMultipart(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 12*1024*1024/16)
+
+	_, err := fs.s3API.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(fs.bucket),
+		Key:         aws.String("src/big.bin"),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String("application/x-big"),
+		Metadata:    map[string]*string{"Origin": aws.String("test")},
+		Tagging:     aws.String("size=big"),
+	})
+	req.NoError(err)
+
+	req.NoError(fs.CopyDir("/src", "/dst", &CopyOptions{MultipartThreshold: 6 * 1024 * 1024, PartSize: 5 * 1024 * 1024}))
+
+	out, err := fs.s3API.GetObject(&s3.GetObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String("dst/big.bin")})
+	req.NoError(err)
+
+	defer func() { _ = out.Body.Close() }()
+
+	copied := &bytes.Buffer{}
+	_, err = copied.ReadFrom(out.Body)
+	req.NoError(err)
+	req.True(bytes.Equal(content, copied.Bytes()))
+	req.Equal("application/x-big", *out.ContentType)
+	req.Equal("test", *out.Metadata["Origin"])
+	req.Equal(int64(1), *out.TagCount)
+}
+
+func TestCopyDirMultipartSmallParts(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	content := strings.Repeat("0123456789abcdef", 12*1024*1024/16)
+	testCreateFile(t, fs, "/src/big.bin", content)
+
+	// The parts are raised to the minimum size S3 accepts
+	req.NoError(fs.CopyDir("/src", "/dst", &CopyOptions{MultipartThreshold: 1024 * 1024, PartSize: 1024 * 1024}))
+	req.Len(partRanges(partSource{}, int64(len(content)), 1024*1024), 3)
+
+	copied, err := fs.ReadFile("/dst/big.bin")
+	req.NoError(err)
+	req.Equal(content, string(copied))
+}
+
+func TestCopyDirMultipartFailure(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	faults := NewFaultInjector(0)
+	fs.WithFaultInjector(faults)
+
+	testCreateFile(t, fs, "/src/big.bin", strings.Repeat("0123456789abcdef", 1024*1024/16*32))
+
+	// The parts are not copied anymore after the first failure
+	faults.Set("UploadPartCopy", &Fault{StatusCode: http.StatusBadRequest, Code: "InvalidRequest"})
+	req.Error(fs.CopyDir("/src", "/dst", &CopyOptions{MultipartThreshold: 1024 * 1024, PartSize: 1024 * 1024}))
+	req.LessOrEqual(faults.Injected(), int64(copyPartsConcurrency))
+
+	_, err := fs.Stat("/dst/big.bin")
+	req.ErrorIs(err, os.ErrNotExist)
+}
@@ -0,0 +1,152 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// DefaultFailoverThreshold is the number of consecutive authentication failures switching to the next credentials
+const DefaultFailoverThreshold = 3
+
+// Names of the handlers switching the credentials
+const (
+	signCredentialsHandler  = "afero-s3.SignCredentials"
+	retryCredentialsHandler = "afero-s3.RetryCredentials"
+	resetCredentialsHandler = "afero-s3.ResetCredentials"
+)
+
+// credentialsFailover holds the credentials the requests are signed with, the first ones being the primary ones
+type credentialsFailover struct {
+	fs       *Fs
+	mu       sync.Mutex
+	creds    []*credentials.Credentials
+	active   int // active is the index of the credentials in use
+	failures int // failures is the number of consecutive authentication failures of the active credentials
+}
+
+// isAuthError tells if an error is caused by the credentials
+func isAuthError(err error) bool {
+	var errRequestFailure awserr.RequestFailure
+	if !errors.As(err, &errRequestFailure) {
+		return false
+	}
+
+	switch errRequestFailure.Code() {
+	case "ExpiredToken", "ExpiredTokenException", "InvalidToken", "TokenRefreshRequired":
+		return true
+	default:
+		return errRequestFailure.StatusCode() == http.StatusForbidden
+	}
+}
+
+// WithFallbackCredentials defines the credentials used when the ones of the session consistently fail, for
+// instance during a credentials rotation. After FailoverThreshold consecutive authentication failures, the requests
+// switch to the next credentials, and back to the first ones after the last ones, OnCredentialsFailover being called.
+// It should be called before the Fs is used.
+func (fs *Fs) WithFallbackCredentials(creds ...*credentials.Credentials) *Fs {
+	fs.failover = nil
+	if len(creds) > 0 {
+		fs.failover = &credentialsFailover{
+			fs:    fs,
+			creds: append([]*credentials.Credentials{fs.session.Config.Credentials}, creds...),
+		}
+	}
+
+	fs.failover.install(&fs.s3API.Handlers)
+
+	return fs
+}
+
+// install installs the handlers switching the credentials, or removes them if cf is nil
+func (cf *credentialsFailover) install(handlers *request.Handlers) {
+	handlers.Sign.Remove(request.NamedHandler{Name: signCredentialsHandler})
+	handlers.Retry.Remove(request.NamedHandler{Name: retryCredentialsHandler})
+	handlers.Complete.Remove(request.NamedHandler{Name: resetCredentialsHandler})
+
+	if cf == nil {
+		return
+	}
+
+	handlers.Sign.PushFrontNamed(request.NamedHandler{Name: signCredentialsHandler, Fn: cf.sign})
+	handlers.Retry.PushFrontNamed(request.NamedHandler{Name: retryCredentialsHandler, Fn: cf.retry})
+	handlers.Complete.PushBackNamed(request.NamedHandler{Name: resetCredentialsHandler, Fn: cf.reset})
+}
+
+// sign makes the request signed with the active credentials
+func (cf *credentialsFailover) sign(r *request.Request) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	r.Config.Credentials = cf.creds[cf.active]
+}
+
+// retry counts the authentication failures and switches to the next credentials after too many of them, in
+// which case the request is retried
+func (cf *credentialsFailover) retry(r *request.Request) {
+	if !isAuthError(r.Error) {
+		return
+	}
+
+	cf.mu.Lock()
+
+	// The requests signed with previous credentials don't count
+	if r.Config.Credentials != cf.creds[cf.active] {
+		cf.mu.Unlock()
+		r.Retryable = aws.Bool(true)
+
+		return
+	}
+
+	cf.failures++
+	if cf.failures < cf.fs.failoverThreshold() {
+		cf.mu.Unlock()
+		return
+	}
+
+	from := cf.active
+	cf.active = (cf.active + 1) % len(cf.creds)
+	cf.failures = 0
+	cf.mu.Unlock()
+
+	r.Retryable = aws.Bool(true)
+
+	if cf.fs.OnCredentialsFailover != nil {
+		cf.fs.OnCredentialsFailover(from, cf.active, r.Error)
+	}
+}
+
+// reset resets the failures count once a request succeeded with the active credentials
+func (cf *credentialsFailover) reset(r *request.Request) {
+	if r.Error != nil {
+		return
+	}
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if r.Config.Credentials == cf.creds[cf.active] {
+		cf.failures = 0
+	}
+}
+
+func (fs *Fs) failoverThreshold() int {
+	if fs.FailoverThreshold <= 0 {
+		return DefaultFailoverThreshold
+	}
+
+	return fs.FailoverThreshold
+}
+
+// credentialsOption applies the credentials failover of the Fs to the requests of other clients
+func (fs *Fs) credentialsOption() request.Option {
+	return func(r *request.Request) {
+		fs.failover.install(&r.Handlers)
+	}
+}
@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+// countingProvider provides static credentials and counts how many times they are retrieved
+type countingProvider struct {
+	retrieved int
+}
+
+func (p *countingProvider) Retrieve() (credentials.Value, error) {
+	p.retrieved++
+	return credentials.Value{AccessKeyID: "minioadmin", SecretAccessKey: "minioadmin"}, nil
+}
+
+func (p *countingProvider) IsExpired() bool { return false }
+
+func TestCredentialsFailover(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/file", "content")
+
+	fallback := &countingProvider{}
+	faults := NewFaultInjector(0)
+
+	var failovers [][2]int
+
+	fs.FailoverThreshold = 2
+	fs.OnCredentialsFailover = func(from, to int, err error) {
+		req.True(isAuthError(err))
+		failovers = append(failovers, [2]int{from, to})
+	}
+	fs.WithFallbackCredentials(credentials.NewCredentials(fallback)).WithFaultInjector(faults)
+
+	head := func() error {
+		_, err := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String("/file")})
+		return err
+	}
+
+	faults.Set("HeadObject", &Fault{StatusCode: http.StatusForbidden, Code: "InvalidAccessKeyId"})
+
+	// The first failure isn't enough to switch
+	req.Error(head())
+	req.Empty(failovers)
+
+	// The second one switches to the fallback credentials, with which the request is retried
+	req.Error(head())
+	req.Equal([][2]int{{0, 1}}, failovers)
+	req.Equal(1, fallback.retrieved)
+
+	faults.Set("HeadObject", nil)
+	req.NoError(head())
+
+	// The Fs keeps working with the fallback credentials
+	testCreateFile(t, fs, "/other", "content")
+	req.Equal([][2]int{{0, 1}}, failovers)
+
+	fs.WithFaultInjector(nil).WithFallbackCredentials()
+	req.NoError(head())
+}
@@ -0,0 +1,231 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DirStrategy defines how directories, which don't exist in S3, are kept consistent when their files are removed
+type DirStrategy int
+
+const (
+	// DirStrategyImplicit makes directories exist as long as they contain objects. A directory without a marker
+	// disappears with its last file.
+	DirStrategyImplicit DirStrategy = iota
+	// DirStrategyMarkers creates the missing markers of the parent directories of the written files, so that
+	// directories survive the removal of their files.
+	DirStrategyMarkers
+	// DirStrategyGracePeriod makes the directories emptied by this Fs still exist for DirGracePeriod
+	DirStrategyGracePeriod
+)
+
+// DefaultDirGracePeriod is the time emptied directories still exist with DirStrategyGracePeriod
+const DefaultDirGracePeriod = time.Minute
+
+// dirState is the state of the directories shared by all the copies of an Fs
+type dirState struct {
+	mu      sync.Mutex
+	markers map[string]bool      // markers are the directory markers known to exist
+	emptied map[string]time.Time // emptied are the directories whose files were removed, with the removal time
+}
+
+func newDirState() *dirState {
+	return &dirState{
+		markers: make(map[string]bool),
+		emptied: make(map[string]time.Time),
+	}
+}
+
+func (fs *Fs) dirGracePeriod() time.Duration {
+	if fs.DirGracePeriod <= 0 {
+		return DefaultDirGracePeriod
+	}
+
+	return fs.DirGracePeriod
+}
+
+// parentDirs returns the parent directories of a file, the deepest first and the root excluded
+func parentDirs(name string) []string {
+	var dirs []string
+
+	for dir := path.Dir(path.Clean("/" + name)); dir != "/"; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+
+	return dirs
+}
+
+// fileWritten applies the directory strategy after a file was written
+func (fs *Fs) fileWritten(name string) error {
+	fs.preload.invalidate()
+	fs.coherence.publish(name, false)
+
+	if fs.DirStrategy != DirStrategyMarkers || fs.dirs == nil {
+		return nil
+	}
+
+	for _, dir := range parentDirs(name) {
+		marker := fs.dirMarkerKey(dir)
+
+		fs.dirs.mu.Lock()
+		known := fs.dirs.markers[marker]
+		fs.dirs.mu.Unlock()
+
+		// The markers are created from the deepest directory, so the parents of an existing one also exist
+		if known {
+			return nil
+		}
+
+		exists, err := fs.markerExists(marker)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			if _, err := fs.s3API.PutObject(&s3.PutObjectInput{
+				Bucket: aws.String(fs.bucket),
+				Key:    aws.String(marker),
+				Body:   bytes.NewReader([]byte{}),
+			}); err != nil {
+				return err
+			}
+		}
+
+		fs.dirs.mu.Lock()
+		fs.dirs.markers[marker] = true
+		fs.dirs.mu.Unlock()
+
+		if exists {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// isNotFound tells if a request failed because the object doesn't exist
+func isNotFound(err error) bool {
+	var errRequestFailure awserr.RequestFailure
+	return errors.As(err, &errRequestFailure) && errRequestFailure.StatusCode() == http.StatusNotFound
+}
+
+func (fs *Fs) markerExists(marker string) (bool, error) {
+	_, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(marker),
+	})
+	if err == nil {
+		return true, nil
+	}
+
+	if isNotFound(err) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// fileRemoved applies the directory strategy after a file was removed
+func (fs *Fs) fileRemoved(name string) {
+	fs.preload.invalidate()
+	fs.coherence.publish(name, true)
+
+	if fs.dirs == nil {
+		return
+	}
+
+	fs.forgetMarker(name)
+
+	fs.dirs.mu.Lock()
+	defer fs.dirs.mu.Unlock()
+
+	if fs.DirStrategy != DirStrategyGracePeriod {
+		return
+	}
+
+	now := time.Now()
+	for dir, removal := range fs.dirs.emptied {
+		if now.Sub(removal) > fs.dirGracePeriod() {
+			delete(fs.dirs.emptied, dir)
+		}
+	}
+
+	for _, dir := range parentDirs(strings.TrimSuffix(name, "/")) {
+		fs.dirs.emptied[dir] = now
+	}
+}
+
+// forgetMarker forgets a removed marker, which has to be created again
+func (fs *Fs) forgetMarker(name string) {
+	if fs.dirs == nil {
+		return
+	}
+
+	fs.dirs.mu.Lock()
+	defer fs.dirs.mu.Unlock()
+
+	delete(fs.dirs.markers, strings.TrimPrefix(name, "/"))
+}
+
+// inGracePeriod tells if a directory was emptied recently enough to still exist
+func (fs *Fs) inGracePeriod(dir string) bool {
+	if fs.DirStrategy != DirStrategyGracePeriod || fs.dirs == nil {
+		return false
+	}
+
+	fs.dirs.mu.Lock()
+	defer fs.dirs.mu.Unlock()
+
+	removal, ok := fs.dirs.emptied[path.Clean("/"+dir)]
+
+	return ok && time.Since(removal) <= fs.dirGracePeriod()
+}
+
+// removeDir removes an empty directory: its markers and its grace period. It fails with syscall.ENOTEMPTY if the
+// directory contains any object other than its marker.
+func (fs *Fs) removeDir(name string) error {
+	dir := path.Clean("/" + name)
+	if dir == "/" {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrInvalid}
+	}
+
+	// The "/" marker is listed first
+	out, err := fs.s3API.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(fs.bucket),
+		Prefix:  aws.String(dirPrefix(dir)),
+		MaxKeys: aws.Int64(2),
+	})
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: name, Err: err}
+	}
+
+	for _, obj := range out.Contents {
+		if aws.StringValue(obj.Key) != dirPrefix(dir) {
+			return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+
+	if err = fs.forceRemove(dir + "/"); err != nil {
+		return &os.PathError{Op: "remove", Path: name, Err: err}
+	}
+
+	if fs.dirs != nil {
+		fs.dirs.mu.Lock()
+		delete(fs.dirs.emptied, dir)
+		fs.dirs.mu.Unlock()
+	}
+
+	return fs.removeSuffixMarkers(dir)
+}
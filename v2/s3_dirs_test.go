@@ -0,0 +1,97 @@
+package s3
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirStrategyImplicit(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/dir/file", "content")
+	req.NoError(fs.Remove("/dir/file"))
+
+	_, err := fs.Stat("/dir")
+	req.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestDirStrategyMarkers(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.DirStrategy = DirStrategyMarkers
+
+	testCreateFile(t, fs, "/dir/sub/file", "content")
+	testCreateFile(t, fs, "/dir/sub/other", "content")
+	req.NoError(fs.Remove("/dir/sub/file"))
+	req.NoError(fs.Remove("/dir/sub/other"))
+
+	for _, dir := range []string{"/dir", "/dir/sub"} {
+		info, err := fs.Stat(dir)
+		req.NoError(err)
+		req.True(info.IsDir())
+	}
+}
+
+func TestDirStrategyGracePeriod(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.DirStrategy = DirStrategyGracePeriod
+	fs.DirGracePeriod = 200 * time.Millisecond
+
+	testCreateFile(t, fs, "/dir/file", "content")
+	req.NoError(fs.Remove("/dir/file"))
+
+	info, err := fs.Stat("/dir")
+	req.NoError(err)
+	req.True(info.IsDir())
+
+	time.Sleep(300 * time.Millisecond)
+
+	_, err = fs.Stat("/dir")
+	req.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestRemoveDir(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.DirStrategy = DirStrategyMarkers
+
+	req.NoError(fs.Mkdir("/dir", 0750))
+	testCreateFile(t, fs, "/dir/sub/file", "content")
+
+	// The directories containing anything can't be removed
+	for _, dir := range []string{"/dir", "/dir/sub", "/dir/sub/"} {
+		req.ErrorIs(fs.Remove(dir), syscall.ENOTEMPTY, dir)
+	}
+
+	req.NoError(fs.Remove("/dir/sub/file"))
+
+	// The empty directories only have a marker left
+	req.NoError(fs.Remove("/dir/sub"))
+	req.NoError(fs.Remove("/dir"))
+
+	for _, dir := range []string{"/dir", "/dir/sub"} {
+		_, err := fs.Stat(dir)
+		req.ErrorIs(err, os.ErrNotExist)
+	}
+
+	req.ErrorIs(fs.Remove("/"), os.ErrInvalid)
+}
+
+func TestRemoveDirGracePeriod(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.DirStrategy = DirStrategyGracePeriod
+
+	testCreateFile(t, fs, "/dir/file", "content")
+	req.NoError(fs.Remove("/dir/file"))
+	req.NoError(fs.Remove("/dir"))
+
+	_, err := fs.Stat("/dir")
+	req.ErrorIs(err, os.ErrNotExist)
+}
@@ -0,0 +1,190 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DefaultDownloadPartSize is the size of the parts of the parallel downloads, if DownloadPartSize isn't set
+const DefaultDownloadPartSize = 8 * 1024 * 1024
+
+// downloadPartSize returns the size of the parts of the parallel downloads
+func (fs *Fs) downloadPartSize() int64 {
+	if fs.DownloadPartSize > 0 {
+		return fs.DownloadPartSize
+	}
+
+	return DefaultDownloadPartSize
+}
+
+// downloadPart is a part of a parallel download
+type downloadPart struct {
+	data []byte
+	err  error
+}
+
+// parallelReader reads a file through parallel ranged requests, like s3manager.Downloader, its parts being returned
+// in order. Up to DownloadConcurrency parts are downloaded ahead of the reads, the part being read included.
+type parallelReader struct {
+	fs       *Fs                    // fs is the file system of the file
+	input    s3.GetObjectInput      // input is the input of the requests of the file, checking the ETag of its content
+	end      int64                  // end is the size of the file
+	partSize int64                  // partSize is the size of the parts
+	ctx      context.Context        // ctx cancels the downloads when the reader is closed
+	cancel   context.CancelFunc     // cancel cancels ctx
+	window   chan struct{}          // window limits the number of parts downloaded ahead
+	parts    chan chan downloadPart // parts are the parts being downloaded, in order
+	current  *bytes.Reader          // current is the part being read
+	counters *fileCounters          // counters of the requests of the file
+	err      error                  // err is the error of the download
+}
+
+// openParallelStream opens the read stream of a file with a parallel download. The first part is read before
+// the others are requested, to find the transforms of the file, which are read from a single stream. It returns false
+// if the file has to be opened with a single stream.
+func (f *File) openParallelStream(startAt int64) (bool, error) {
+	fs := f.fs
+	size := f.cachedInfo.Size()
+	partSize := fs.downloadPartSize()
+
+	if startAt > 0 {
+		f.counters.rangedRequests++
+	}
+
+	resp, err := fs.s3API.GetObjectWithContext(fs.requestContext(),
+		f.getInput(aws.String(fmt.Sprintf("bytes=%d-%d", startAt, min(startAt+partSize, size)-1))),
+		countRetries(&f.counters.retries))
+	if err != nil {
+		return true, err
+	}
+
+	if f.transforms = fs.readTransforms(f.name, resp.Metadata); f.transforms != nil {
+		_ = resp.Body.Close()
+		return false, nil
+	}
+
+	first, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		return true, err
+	}
+
+	ctx, cancel := context.WithCancel(fs.requestContext())
+	// The reader doesn't reference the file, for it to be collected when it's leaked
+	r := &parallelReader{
+		fs:       fs,
+		input:    *f.getInput(nil),
+		end:      size,
+		partSize: partSize,
+		ctx:      ctx,
+		cancel:   cancel,
+		window:   make(chan struct{}, fs.DownloadConcurrency),
+		parts:    make(chan chan downloadPart, fs.DownloadConcurrency),
+		current:  bytes.NewReader(first),
+		counters: f.counters,
+	}
+	r.input.IfMatch = resp.ETag
+
+	// The first part takes a place in the window until it's read
+	r.window <- struct{}{}
+
+	go r.schedule(startAt + int64(len(first)))
+
+	f.streamReadOffset = startAt
+	f.streamRead = fs.withReadAhead(r)
+
+	return true, nil
+}
+
+// schedule requests the parts from an offset, as the window allows it
+func (r *parallelReader) schedule(offset int64) {
+	defer close(r.parts)
+
+	for ; offset < r.end; offset += r.partSize {
+		select {
+		case r.window <- struct{}{}:
+		case <-r.ctx.Done():
+			return
+		}
+
+		part := make(chan downloadPart, 1)
+		r.parts <- part
+
+		go func(start, end int64) {
+			data, err := r.download(start, end)
+			part <- downloadPart{data: data, err: err}
+		}(offset, min(offset+r.partSize, r.end))
+	}
+}
+
+// download downloads a part of the file
+func (r *parallelReader) download(start, end int64) ([]byte, error) {
+	input := r.input
+	input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", start, end-1))
+
+	resp, err := r.fs.s3API.GetObjectWithContext(r.ctx, &input, countRetries(&r.counters.retries))
+	if err != nil {
+		return nil, contextError(r.ctx, err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	data := make([]byte, end-start)
+	if _, err = io.ReadFull(resp.Body, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (r *parallelReader) Read(p []byte) (int, error) {
+	for r.err == nil {
+		if r.current.Len() > 0 {
+			return r.current.Read(p)
+		}
+
+		// The part that was read leaves its place in the window
+		<-r.window
+
+		part, ok := <-r.parts
+		if !ok {
+			// The parts stop being scheduled at the end of the file, or when the context is cancelled
+			if r.err = r.ctx.Err(); r.err == nil {
+				r.err = io.EOF
+			}
+
+			break
+		}
+
+		r.counters.rangedRequests++
+
+		result := <-part
+		if result.err != nil {
+			r.err = result.err
+			break
+		}
+
+		r.current = bytes.NewReader(result.data)
+	}
+
+	return 0, r.err
+}
+
+// Close cancels the parts being downloaded
+func (r *parallelReader) Close() error {
+	r.cancel()
+
+	// The scheduler is waiting for a place in the window or stopped, and the downloads end with the context
+	for part := range r.parts {
+		<-part
+	}
+
+	return nil
+}
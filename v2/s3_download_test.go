@@ -0,0 +1,74 @@
+package s3
+
+import (
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelDownload(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	raw := make([]byte, 3*1024*1024+123)
+	_, err := rand.Read(raw)
+	req.NoError(err)
+
+	content := string(raw)
+	testCreateFile(t, fs, "/large.bin", content)
+	testCreateFile(t, fs, "/small.bin", "small")
+
+	fs.DownloadConcurrency = 4
+	fs.DownloadPartSize = 256 * 1024
+
+	// The large files are read in parallel parts, in order
+	file, err := fs.Open("/large.bin")
+	req.NoError(err)
+
+	data, err := io.ReadAll(file)
+	req.NoError(err)
+	req.Equal(content, string(data))
+	req.Equal(int64(12), file.(*File).Stats().RangedRequests)
+
+	// From any offset
+	buffer := make([]byte, 1000)
+	_, err = file.ReadAt(buffer, 1024*1024+7)
+	req.NoError(err)
+	req.Equal(content[1024*1024+7:1024*1024+1007], string(buffer))
+
+	rest, err := io.ReadAll(file)
+	req.NoError(err)
+	req.Equal(content[1024*1024+1007:], string(rest))
+	req.NoError(file.Close())
+
+	// The downloads are cancelled when the file is closed
+	file, err = fs.Open("/large.bin")
+	req.NoError(err)
+
+	_, err = io.ReadFull(file, buffer)
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	// The small files are read with a single stream
+	data, err = fs.ReadFile("/small.bin")
+	req.NoError(err)
+	req.Equal("small", string(data))
+
+	// Like the transformed ones
+	text := strings.Repeat("compressed text\n", 100000)
+	testCreateFile(t, fs, "/text.gz", gzipped(t, text))
+
+	fs.ReadTransforms = []*ReadTransform{{Extensions: []string{".gz"}, Transform: gunzipTransform}}
+	fs.DownloadPartSize = 1024
+
+	file, err = fs.Open("/text.gz")
+	req.NoError(err)
+
+	data, err = io.ReadAll(file)
+	req.NoError(err)
+	req.Equal(text, string(data))
+	req.NoError(file.Close())
+}
@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadEmptyFile(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/empty", "")
+
+	file, err := fs.Open("/empty")
+	req.NoError(err)
+
+	defer func() { req.NoError(file.Close()) }()
+
+	buffer := make([]byte, 10)
+
+	n, err := file.Read(buffer)
+	req.Equal(0, n)
+	req.Equal(io.EOF, err)
+
+	// An empty read does nothing
+	n, err = file.Read(buffer[:0])
+	req.Equal(0, n)
+	req.NoError(err)
+
+	n, err = file.ReadAt(buffer, 0)
+	req.Equal(0, n)
+	req.Equal(io.EOF, err)
+
+	data, err := io.ReadAll(file)
+	req.NoError(err)
+	req.Empty(data)
+}
+
+func TestReadBeyondEnd(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/file", "abc")
+
+	file, err := fs.Open("/file")
+	req.NoError(err)
+
+	defer func() { req.NoError(file.Close()) }()
+
+	buffer := make([]byte, 10)
+
+	for _, offset := range []int64{3, 10} {
+		pos, err := file.Seek(offset, io.SeekStart)
+		req.NoError(err)
+		req.Equal(offset, pos)
+
+		n, err := file.Read(buffer)
+		req.Equal(0, n)
+		req.Equal(io.EOF, err)
+	}
+
+	n, err := file.ReadAt(buffer, 1)
+	req.Equal(2, n)
+	req.Equal(io.EOF, err)
+	req.Equal("bc", string(buffer[:n]))
+}
@@ -0,0 +1,250 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// EncryptedNamesFs is an Fs storing the files under encrypted names, for the deployments where the names of the files
+// are sensitive. Each segment of the names is encrypted on its own, so that the directories can still be listed: a
+// name like "/a/b" is stored as "/E(a)/E(b)". The files are presented with their clear names. The objects whose name
+// can't be decrypted, like the ones written without the EncryptedNamesFs, are left out of the listings.
+type EncryptedNamesFs struct {
+	fs     *Fs
+	cipher NameCipher
+}
+
+// NewEncryptedNamesFs creates a file system encrypting the names of the files of an existing Fs, with a cipher like
+// NewSIVNameCipher
+func NewEncryptedNamesFs(fs *Fs, cipher NameCipher) *EncryptedNamesFs {
+	return &EncryptedNamesFs{fs: fs, cipher: cipher}
+}
+
+// Name returns the type of FS object this is
+func (EncryptedNamesFs) Name() string { return "s3-encrypted-names" }
+
+// Capabilities returns the features supported by the encrypted names file system
+func (efs *EncryptedNamesFs) Capabilities() Capabilities {
+	return efs.fs.Capabilities()
+}
+
+// encrypt returns the stored name of a file, a trailing slash being kept
+func (efs *EncryptedNamesFs) encrypt(name string) (string, error) {
+	clean := path.Clean("/" + name)
+	if clean == "/" {
+		return "/", nil
+	}
+
+	segments := strings.Split(clean[1:], "/")
+	for i, segment := range segments {
+		encrypted, err := efs.cipher.EncryptName(segment)
+		if err != nil {
+			return "", err
+		}
+
+		segments[i] = encrypted
+	}
+
+	encrypted := "/" + strings.Join(segments, "/")
+	if strings.HasSuffix(name, "/") {
+		encrypted += "/"
+	}
+
+	return encrypted, nil
+}
+
+// clearError replaces the stored names of an error by the clear ones
+func clearError(err error, name string) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return &os.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+
+	return err
+}
+
+// call applies an operation on the stored name of a file
+func (efs *EncryptedNamesFs) call(op, name string, fn func(stored string) error) error {
+	stored, err := efs.encrypt(name)
+	if err != nil {
+		return &os.PathError{Op: op, Path: name, Err: err}
+	}
+
+	return clearError(fn(stored), name)
+}
+
+// Create creates a file
+func (efs *EncryptedNamesFs) Create(name string) (afero.File, error) {
+	return efs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Mkdir creates a directory
+func (efs *EncryptedNamesFs) Mkdir(name string, perm os.FileMode) error {
+	return efs.call("mkdir", name, func(stored string) error { return efs.fs.Mkdir(stored, perm) })
+}
+
+// MkdirAll creates a directory and all its parents
+func (efs *EncryptedNamesFs) MkdirAll(name string, perm os.FileMode) error {
+	return efs.call("mkdir", name, func(stored string) error { return efs.fs.MkdirAll(stored, perm) })
+}
+
+// Open opens a file for reading
+func (efs *EncryptedNamesFs) Open(name string) (afero.File, error) {
+	return efs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens a file like Fs.OpenFile
+func (efs *EncryptedNamesFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	var file afero.File
+
+	err := efs.call("open", name, func(stored string) error {
+		var err error
+		file, err = efs.fs.OpenFile(stored, flag, perm)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedNamesFile{File: file, efs: efs, name: name}, nil
+}
+
+// Remove removes a file or an empty directory
+func (efs *EncryptedNamesFs) Remove(name string) error {
+	return efs.call("remove", name, efs.fs.Remove)
+}
+
+// RemoveAll removes a directory tree
+func (efs *EncryptedNamesFs) RemoveAll(name string) error {
+	return efs.call("removeall", name, efs.fs.RemoveAll)
+}
+
+// Rename renames a file
+func (efs *EncryptedNamesFs) Rename(oldname, newname string) error {
+	storedOld, err := efs.encrypt(oldname)
+	if err != nil {
+		return renameError(oldname, newname, err)
+	}
+
+	storedNew, err := efs.encrypt(newname)
+	if err != nil {
+		return renameError(oldname, newname, err)
+	}
+
+	err = efs.fs.Rename(storedOld, storedNew)
+
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		err = linkErr.Err
+	}
+
+	return renameError(oldname, newname, err)
+}
+
+// Stat describes a file with its clear name
+func (efs *EncryptedNamesFs) Stat(name string) (os.FileInfo, error) {
+	var info os.FileInfo
+
+	err := efs.call("stat", name, func(stored string) error {
+		var err error
+		info, err = efs.fs.Stat(stored)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return clearInfo(info, path.Base(path.Clean("/"+name))), nil
+}
+
+// Chmod changes the permissions of a file
+func (efs *EncryptedNamesFs) Chmod(name string, mode os.FileMode) error {
+	return efs.call("chmod", name, func(stored string) error { return efs.fs.Chmod(stored, mode) })
+}
+
+// Chown changes the owner of a file
+func (efs *EncryptedNamesFs) Chown(name string, uid, gid int) error {
+	return efs.call("chown", name, func(stored string) error { return efs.fs.Chown(stored, uid, gid) })
+}
+
+// Chtimes changes the times of a file
+func (efs *EncryptedNamesFs) Chtimes(name string, atime, mtime time.Time) error {
+	return efs.call("chtimes", name, func(stored string) error { return efs.fs.Chtimes(stored, atime, mtime) })
+}
+
+// clearNameInfo is the FileInfo of a file presented with its clear name
+type clearNameInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i clearNameInfo) Name() string { return i.name }
+
+func clearInfo(info os.FileInfo, name string) os.FileInfo {
+	return clearNameInfo{FileInfo: info, name: name}
+}
+
+// encryptedNamesFile is a file opened through an EncryptedNamesFs, presented with its clear name
+type encryptedNamesFile struct {
+	afero.File
+	efs  *EncryptedNamesFs
+	name string
+}
+
+// Name returns the clear name of the file
+func (f *encryptedNamesFile) Name() string { return f.name }
+
+// Stat describes the file with its clear name
+func (f *encryptedNamesFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, clearError(err, f.name)
+	}
+
+	return clearInfo(info, path.Base(path.Clean("/"+f.name))), nil
+}
+
+// Readdir lists the directory with the clear names, the entries that can't be decrypted being left out. Only the
+// whole listings are sorted.
+func (f *encryptedNamesFile) Readdir(n int) ([]os.FileInfo, error) {
+	for {
+		infos, err := f.File.Readdir(n)
+		result := make([]os.FileInfo, 0, len(infos))
+
+		for _, info := range infos {
+			if name, errName := f.efs.cipher.DecryptName(info.Name()); errName == nil {
+				result = append(result, clearInfo(info, name))
+			}
+		}
+
+		// The stored names are listed in their order, the whole listings are sorted by clear names
+		if n <= 0 {
+			sortEntries(result)
+		}
+
+		// We shouldn't return an empty slice without error when a specific count was requested
+		if len(result) > 0 || err != nil || n <= 0 {
+			return result, clearError(err, f.name)
+		}
+	}
+}
+
+// Readdirnames lists the clear names of the directory
+func (f *encryptedNamesFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+	names := make([]string, len(infos))
+
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+
+	return names, err
+}
@@ -0,0 +1,117 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSIVVector(t *testing.T) {
+	req := require.New(t)
+
+	// RFC 5297, A.1
+	unhex := func(s string) []byte {
+		raw, err := hex.DecodeString(s)
+		req.NoError(err)
+
+		return raw
+	}
+
+	key := unhex("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	ad := unhex("101112131415161718191a1b1c1d1e1f2021222324252627")
+	plaintext := unhex("112233445566778899aabbccddee")
+
+	nc, err := NewSIVNameCipher(key)
+	req.NoError(err)
+
+	siv := nc.(*sivNameCipher)
+
+	sealed := siv.seal(plaintext, ad)
+	req.Equal("85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c", hex.EncodeToString(sealed))
+
+	opened, ok := siv.open(sealed, ad)
+	req.True(ok)
+	req.Equal(plaintext, opened)
+
+	sealed[len(sealed)-1] ^= 1
+	_, ok = siv.open(sealed, ad)
+	req.False(ok)
+
+	_, err = NewSIVNameCipher(key[:16])
+	req.Error(err)
+}
+
+func TestEncryptedNamesFs(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	nc, err := NewSIVNameCipher(bytes.Repeat([]byte{1}, 32))
+	req.NoError(err)
+
+	efs := NewEncryptedNamesFs(fs, nc)
+
+	req.NoError(afero.WriteFile(efs, "/secret/b-plans.txt", []byte("b"), 0600))
+	req.NoError(afero.WriteFile(efs, "/secret/a-plans.txt", []byte("a"), 0600))
+	req.NoError(efs.Mkdir("/secret/sub", 0750))
+
+	// A file written without the encryption
+	testCreateFile(t, fs, "/plain", "plain")
+
+	data, err := afero.ReadFile(efs, "/secret/a-plans.txt")
+	req.NoError(err)
+	req.Equal("a", string(data))
+
+	info, err := efs.Stat("/secret/a-plans.txt")
+	req.NoError(err)
+	req.Equal("a-plans.txt", info.Name())
+	req.Equal(int64(1), info.Size())
+
+	names, err := afero.ReadDir(efs, "/secret")
+	req.NoError(err)
+	req.Len(names, 3)
+	req.Equal("a-plans.txt", names[0].Name())
+	req.Equal("b-plans.txt", names[1].Name())
+	req.Equal("sub", names[2].Name())
+
+	root, err := afero.ReadDir(efs, "/")
+	req.NoError(err)
+	req.Len(root, 1)
+	req.Equal("secret", root[0].Name())
+
+	// Nothing is stored in clear
+	req.NoError(fs.walkObjects("", func(obj *s3.Object) bool {
+		req.NotContains(*obj.Key, "secret")
+		req.NotContains(*obj.Key, "plans")
+
+		return true
+	}))
+
+	// Errors are reported with the clear names
+	_, err = efs.Open("/secret/missing")
+
+	var pathErr *os.PathError
+	req.True(errors.As(err, &pathErr))
+	req.Equal("/secret/missing", pathErr.Path)
+	req.ErrorIs(err, os.ErrNotExist)
+
+	req.NoError(efs.Rename("/secret/a-plans.txt", "/secret/c-plans.txt"))
+	_, err = efs.Stat("/secret/c-plans.txt")
+	req.NoError(err)
+
+	req.NoError(efs.Remove("/secret/c-plans.txt"))
+	req.NoError(efs.RemoveAll("/secret"))
+
+	exists, err := afero.DirExists(efs, "/secret")
+	req.NoError(err)
+	req.False(exists)
+
+	_, err = nc.DecryptName(strings.Repeat("A", 40))
+	req.ErrorIs(err, ErrInvalidName)
+}
@@ -0,0 +1,101 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// encryptionHandler is the name of the handler applying the encryption of an Fs to its requests
+const encryptionHandler = "afero-s3.Encryption"
+
+// sseCustomerAlgorithm is the only algorithm of the encryption with customer-provided keys
+const sseCustomerAlgorithm = "AES256"
+
+// Encryption defines the server-side encryption of the files. The encryption with keys managed by S3 or KMS and
+// the one with a customer-provided key are exclusive.
+type Encryption struct {
+	// Algorithm is the encryption with keys managed by S3 or KMS of the written files,
+	// s3.ServerSideEncryptionAes256 or s3.ServerSideEncryptionAwsKms, the default one of the bucket if empty
+	Algorithm string
+	// KMSKeyID is the KMS key of the aws:kms encryption, the AWS managed key if empty
+	KMSKeyID string
+	// BucketKeyEnabled uses an S3 Bucket Key for the aws:kms encryption, which reduces the requests to KMS
+	BucketKeyEnabled bool
+	// CustomerKey is the 256-bit key of the encryption with a customer-provided key (SSE-C). S3 doesn't keep it,
+	// it's sent with all the requests reading, writing or copying the files, which requires HTTPS.
+	CustomerKey []byte
+}
+
+// setManaged sets the encryption with managed keys of a written object, unless it's already set
+func (e *Encryption) setManaged(algorithm, keyID **string, bucketKey **bool) {
+	if e.Algorithm == "" || *algorithm != nil {
+		return
+	}
+
+	*algorithm = aws.String(e.Algorithm)
+
+	if e.KMSKeyID != "" && *keyID == nil {
+		*keyID = aws.String(e.KMSKeyID)
+	}
+
+	if e.BucketKeyEnabled && *bucketKey == nil {
+		*bucketKey = aws.Bool(true)
+	}
+}
+
+// setCustomer sets the customer-provided key of a request, unless it's already set. The SDK computes its MD5.
+func (e *Encryption) setCustomer(algorithm, key **string) {
+	if len(e.CustomerKey) == 0 || *key != nil {
+		return
+	}
+
+	*algorithm = aws.String(sseCustomerAlgorithm)
+	*key = aws.String(string(e.CustomerKey))
+}
+
+// apply sets the encryption parameters of the input of a request, the source and the destination of the copies
+// being encrypted the same way
+func (e *Encryption) apply(params interface{}) {
+	switch input := params.(type) {
+	case *s3.PutObjectInput:
+		e.setManaged(&input.ServerSideEncryption, &input.SSEKMSKeyId, &input.BucketKeyEnabled)
+		e.setCustomer(&input.SSECustomerAlgorithm, &input.SSECustomerKey)
+	case *s3.CreateMultipartUploadInput:
+		e.setManaged(&input.ServerSideEncryption, &input.SSEKMSKeyId, &input.BucketKeyEnabled)
+		e.setCustomer(&input.SSECustomerAlgorithm, &input.SSECustomerKey)
+	case *s3.CopyObjectInput:
+		e.setManaged(&input.ServerSideEncryption, &input.SSEKMSKeyId, &input.BucketKeyEnabled)
+		e.setCustomer(&input.SSECustomerAlgorithm, &input.SSECustomerKey)
+		e.setCustomer(&input.CopySourceSSECustomerAlgorithm, &input.CopySourceSSECustomerKey)
+	case *s3.UploadPartInput:
+		e.setCustomer(&input.SSECustomerAlgorithm, &input.SSECustomerKey)
+	case *s3.UploadPartCopyInput:
+		e.setCustomer(&input.SSECustomerAlgorithm, &input.SSECustomerKey)
+		e.setCustomer(&input.CopySourceSSECustomerAlgorithm, &input.CopySourceSSECustomerKey)
+	case *s3.GetObjectInput:
+		e.setCustomer(&input.SSECustomerAlgorithm, &input.SSECustomerKey)
+	case *s3.HeadObjectInput:
+		e.setCustomer(&input.SSECustomerAlgorithm, &input.SSECustomerKey)
+	}
+}
+
+// encryption applies the Encryption of an Fs to its requests
+func encryption(fs *Fs) request.NamedHandler {
+	return request.NamedHandler{
+		Name: encryptionHandler,
+		Fn: func(r *request.Request) {
+			if fs.Encryption != nil {
+				fs.Encryption.apply(r.Params)
+			}
+		},
+	}
+}
+
+// encryptionOption applies the encryption of the Fs to the requests of other clients
+func (fs *Fs) encryptionOption() request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Validate.PushFrontNamed(encryption(fs))
+	}
+}
@@ -0,0 +1,102 @@
+package s3
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/fclairamb/afero-s3/v2/s3test"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// getTLSFs returns an Fs using an HTTPS stub server, as the customer-provided keys can't be sent over HTTP
+func getTLSFs(t *testing.T, server *s3test.Server, encryption *Encryption) *Fs {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials("minioadmin", "minioadmin", ""),
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("eu-west-1"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	require.NoError(t, err)
+
+	// Set after the session creation, which would apply the CA bundle of the environment to it
+	sess.Config.HTTPClient = server.Client()
+
+	fs := NewFs("encrypted", sess)
+	fs.Encryption = encryption
+
+	return fs
+}
+
+func TestEncryptionCustomerKey(t *testing.T) {
+	req := require.New(t)
+
+	server := s3test.NewTLSServer()
+	defer server.Close()
+
+	key1, key2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	fs := getTLSFs(t, server, &Encryption{CustomerKey: key1})
+
+	_, err := fs.s3API.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("encrypted")})
+	req.NoError(err)
+
+	// Streamed uploads and single requests
+	req.NoError(afero.WriteFile(fs, "/dir/streamed.txt", []byte("streamed"), 0600))
+	req.NoError(fs.WriteFile("/dir/put.txt", []byte("put"), nil))
+
+	content, err := afero.ReadFile(fs, "/dir/streamed.txt")
+	req.NoError(err)
+	req.Equal("streamed", string(content))
+
+	// The files can't be read without the key
+	plain := getTLSFs(t, server, nil)
+	_, err = plain.Stat("/dir/put.txt")
+	req.Error(err)
+	req.NotErrorIs(err, os.ErrNotExist)
+
+	// Server-side copies
+	req.NoError(fs.Rename("/dir/put.txt", "/dir/renamed.txt"))
+
+	content, err = fs.ReadFile("/dir/renamed.txt")
+	req.NoError(err)
+	req.Equal("put", string(content))
+
+	// Key rotation, with multipart copies
+	rotated := getTLSFs(t, server, &Encryption{CustomerKey: key2})
+	req.NoError(rotated.CopyDir("/dir", "/rotated", &CopyOptions{
+		SourceEncryption:   &Encryption{CustomerKey: key1},
+		MultipartThreshold: 1,
+	}))
+	req.NoError(rotated.CopyDir("/rotated", "/rotated-single", &CopyOptions{}))
+
+	for _, name := range []string{"/rotated/streamed.txt", "/rotated-single/renamed.txt"} {
+		_, err = rotated.ReadFile(name)
+		req.NoError(err)
+
+		_, err = fs.ReadFile(name)
+		req.Error(err)
+	}
+}
+
+func TestEncryptionKMS(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	fs.Encryption = &Encryption{Algorithm: s3.ServerSideEncryptionAwsKms, KMSKeyID: "key-id", BucketKeyEnabled: true}
+
+	req.NoError(afero.WriteFile(fs, "/streamed.txt", []byte("streamed"), 0600))
+	req.NoError(fs.WriteFile("/put.txt", []byte("put"), nil))
+	req.NoError(fs.Rename("/put.txt", "/renamed.txt"))
+
+	for _, name := range []string{"/streamed.txt", "/renamed.txt"} {
+		head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String(name)})
+		req.NoError(err)
+		req.Equal(s3.ServerSideEncryptionAwsKms, aws.StringValue(head.ServerSideEncryption))
+		req.Equal("key-id", aws.StringValue(head.SSEKMSKeyId))
+	}
+}
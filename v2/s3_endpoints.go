@@ -0,0 +1,273 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// DefaultEndpointCooldown is the default time a failing endpoint isn't used
+const DefaultEndpointCooldown = 30 * time.Second
+
+// Names of the handlers balancing the requests between the endpoints
+const (
+	selectEndpointHandler  = "afero-s3.SelectEndpoint"
+	retryEndpointHandler   = "afero-s3.RetryEndpoint"
+	releaseEndpointHandler = "afero-s3.ReleaseEndpoint"
+)
+
+// EndpointStrategy defines how the endpoint of each request is chosen among the healthy ones
+type EndpointStrategy int
+
+const (
+	// EndpointRoundRobin uses the endpoints in turn
+	EndpointRoundRobin EndpointStrategy = iota
+	// EndpointLeastLoaded uses the endpoint with the fewest requests in flight
+	EndpointLeastLoaded
+)
+
+// EndpointStatus describes the state of an endpoint, see EndpointsStatus
+type EndpointStatus struct {
+	Endpoint string
+	Healthy  bool  // Healthy is false during the cooldown following a failure
+	InFlight int   // InFlight is the number of requests being sent to the endpoint
+	Requests int64 // Requests is the number of requests sent to the endpoint, retries included
+	Failures int64 // Failures is the number of requests that failed with a network or server error
+}
+
+// endpoint is an endpoint of an endpointPool
+type endpoint struct {
+	url       *url.URL
+	inFlight  int
+	requests  int64
+	failures  int64
+	failedAt  time.Time // failedAt is the time of the last failure
+	unhealthy bool      // unhealthy is set by a failure, until the cooldown is over
+}
+
+// endpointPool balances the requests between several endpoints serving the same buckets
+type endpointPool struct {
+	fs        *Fs
+	strategy  EndpointStrategy
+	err       error // err is the error of the invalid endpoints, returned by all the requests
+	mu        sync.Mutex
+	endpoints []*endpoint
+	next      int                      // next is the next endpoint of the round-robin
+	selected  map[*request.Request]int // selected are the endpoints of the requests in flight
+}
+
+// WithEndpoints balances the requests between several endpoints serving the same buckets, like the gateways of a
+// MinIO or Ceph cluster, instead of the endpoint of the session. An endpoint failing with a network or server error
+// is avoided for EndpointCooldown, the failed requests being retried on another one. The endpoints are URLs like
+// "https://minio-1:9000", which should be used in path style. It should be called before the Fs is used.
+func (fs *Fs) WithEndpoints(strategy EndpointStrategy, endpoints ...string) *Fs {
+	fs.endpoints = nil
+
+	if len(endpoints) > 0 {
+		fs.endpoints = &endpointPool{fs: fs, strategy: strategy, selected: make(map[*request.Request]int)}
+
+		for _, raw := range endpoints {
+			u, err := url.Parse(raw)
+			if err == nil && (u.Scheme == "" || u.Host == "") {
+				err = errors.New("endpoint must be an absolute URL")
+			}
+
+			if err != nil {
+				fs.endpoints.err = awserr.New(request.InvalidParameterErrCode, "invalid endpoint "+raw, err)
+				break
+			}
+
+			fs.endpoints.endpoints = append(fs.endpoints.endpoints, &endpoint{url: u})
+		}
+	}
+
+	fs.endpoints.install(&fs.s3API.Handlers)
+
+	return fs
+}
+
+// EndpointsStatus returns the state of the endpoints defined with WithEndpoints
+func (fs *Fs) EndpointsStatus() []EndpointStatus {
+	if fs.endpoints == nil {
+		return nil
+	}
+
+	ep := fs.endpoints
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	statuses := make([]EndpointStatus, len(ep.endpoints))
+	for i, e := range ep.endpoints {
+		statuses[i] = EndpointStatus{
+			Endpoint: e.url.String(),
+			Healthy:  ep.healthy(e, time.Now()),
+			InFlight: e.inFlight,
+			Requests: e.requests,
+			Failures: e.failures,
+		}
+	}
+
+	return statuses
+}
+
+// install installs the handlers balancing the requests, or removes them if ep is nil
+func (ep *endpointPool) install(handlers *request.Handlers) {
+	handlers.Sign.Remove(request.NamedHandler{Name: selectEndpointHandler})
+	handlers.Retry.Remove(request.NamedHandler{Name: retryEndpointHandler})
+	handlers.Complete.Remove(request.NamedHandler{Name: releaseEndpointHandler})
+
+	if ep == nil {
+		return
+	}
+
+	handlers.Sign.PushFrontNamed(request.NamedHandler{Name: selectEndpointHandler, Fn: ep.selectEndpoint})
+	handlers.Retry.PushFrontNamed(request.NamedHandler{Name: retryEndpointHandler, Fn: ep.retry})
+	handlers.Complete.PushBackNamed(request.NamedHandler{Name: releaseEndpointHandler, Fn: ep.release})
+}
+
+// healthy tells if an endpoint can be used, the cooldown of a failed endpoint being over
+func (ep *endpointPool) healthy(e *endpoint, now time.Time) bool {
+	return !e.unhealthy || now.Sub(e.failedAt) >= ep.fs.endpointCooldown()
+}
+
+// pick chooses the endpoint of a request among the healthy ones, or among all of them if none is healthy
+func (ep *endpointPool) pick() int {
+	now := time.Now()
+	candidates := make([]int, 0, len(ep.endpoints))
+
+	for i, e := range ep.endpoints {
+		if ep.healthy(e, now) {
+			candidates = append(candidates, i)
+		}
+	}
+
+	if len(candidates) == 0 {
+		for i := range ep.endpoints {
+			candidates = append(candidates, i)
+		}
+	}
+
+	if ep.strategy == EndpointLeastLoaded {
+		best := candidates[0]
+		for _, i := range candidates[1:] {
+			if ep.endpoints[i].inFlight < ep.endpoints[best].inFlight {
+				best = i
+			}
+		}
+
+		return best
+	}
+
+	selected := candidates[0]
+
+	for _, i := range candidates {
+		if i >= ep.next {
+			selected = i
+			break
+		}
+	}
+
+	ep.next = selected + 1
+
+	return selected
+}
+
+// selectEndpoint sends the request, or its retry, to an endpoint before it's signed
+func (ep *endpointPool) selectEndpoint(r *request.Request) {
+	if ep.err != nil {
+		r.Error = ep.err
+		return
+	}
+
+	ep.mu.Lock()
+	ep.releaseLocked(r)
+
+	index := ep.pick()
+	e := ep.endpoints[index]
+
+	// The presigned requests aren't sent by the Fs
+	if r.ExpireTime == 0 {
+		e.inFlight++
+		e.requests++
+		ep.selected[r] = index
+	}
+	ep.mu.Unlock()
+
+	// Virtual-hosted style requests keep the bucket in the host
+	host := e.url.Host
+	if prefix := ep.fs.bucket + "."; strings.HasPrefix(r.HTTPRequest.URL.Host, prefix) {
+		host = prefix + host
+	}
+
+	r.HTTPRequest.URL.Scheme = e.url.Scheme
+	r.HTTPRequest.URL.Host = host
+	r.HTTPRequest.Host = ""
+}
+
+// isEndpointFailure tells if an error is caused by the endpoint rather than by the request
+func isEndpointFailure(err error) bool {
+	if status := httpStatus(err); status != 0 {
+		return status >= http.StatusInternalServerError
+	}
+
+	var errAWS awserr.Error
+
+	return errors.As(err, &errAWS) && errAWS.Code() == request.ErrCodeRequestError
+}
+
+// retry marks the endpoint of a failed attempt as unhealthy if it's responsible for the failure
+func (ep *endpointPool) retry(r *request.Request) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	index, ok := ep.selected[r]
+	if ok && isEndpointFailure(r.Error) {
+		e := ep.endpoints[index]
+		e.failures++
+		e.unhealthy = true
+		e.failedAt = time.Now()
+	}
+
+	ep.releaseLocked(r)
+}
+
+// release releases the endpoint of a completed request, a successful request making its endpoint healthy again
+func (ep *endpointPool) release(r *request.Request) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	if index, ok := ep.selected[r]; ok && r.Error == nil {
+		ep.endpoints[index].unhealthy = false
+	}
+
+	ep.releaseLocked(r)
+}
+
+func (ep *endpointPool) releaseLocked(r *request.Request) {
+	if index, ok := ep.selected[r]; ok {
+		ep.endpoints[index].inFlight--
+		delete(ep.selected, r)
+	}
+}
+
+func (fs *Fs) endpointCooldown() time.Duration {
+	if fs.EndpointCooldown <= 0 {
+		return DefaultEndpointCooldown
+	}
+
+	return fs.EndpointCooldown
+}
+
+// endpointsOption applies the endpoints balancing of the Fs to the requests of other clients
+func (fs *Fs) endpointsOption() request.Option {
+	return func(r *request.Request) {
+		fs.endpoints.install(&r.Handlers)
+	}
+}
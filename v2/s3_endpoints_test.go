@@ -0,0 +1,96 @@
+package s3
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// countingProxy is a gateway to the test endpoint counting its requests
+func countingProxy(t *testing.T, count *int64) *httptest.Server {
+	target, err := url.Parse(testEndpoint)
+	require.NoError(t, err)
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(count, 1)
+		proxy.ServeHTTP(w, r)
+	}))
+}
+
+func TestEndpoints(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	var count1, count2 int64
+
+	gateway1, gateway2 := countingProxy(t, &count1), countingProxy(t, &count2)
+	defer gateway1.Close()
+
+	fs.WithEndpoints(EndpointRoundRobin, gateway1.URL, gateway2.URL)
+
+	for i := 0; i < 4; i++ {
+		req.NoError(afero.WriteFile(fs, fmt.Sprintf("/file-%d", i), []byte("content"), 0600))
+	}
+
+	req.NotZero(atomic.LoadInt64(&count1))
+	req.NotZero(atomic.LoadInt64(&count2))
+
+	// The requests keep working when an endpoint goes down
+	gateway2.Close()
+
+	for i := 0; i < 4; i++ {
+		content, err := afero.ReadFile(fs, fmt.Sprintf("/file-%d", i))
+		req.NoError(err)
+		req.Equal("content", string(content))
+	}
+
+	statuses := fs.EndpointsStatus()
+	req.Len(statuses, 2)
+	req.True(statuses[0].Healthy)
+	req.False(statuses[1].Healthy)
+	req.NotZero(statuses[1].Failures)
+	req.Zero(statuses[0].InFlight)
+
+	// Back to the endpoint of the session for the cleanup
+	fs.WithEndpoints(EndpointRoundRobin)
+}
+
+func TestEndpointsLeastLoaded(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	fs.WithEndpoints(EndpointLeastLoaded, "http://gateway-1", "http://gateway-2", "http://gateway-3")
+	defer fs.WithEndpoints(EndpointLeastLoaded)
+
+	ep := fs.endpoints
+	ep.endpoints[0].inFlight = 2
+	ep.endpoints[1].inFlight = 1
+	ep.endpoints[2].inFlight = 1
+	ep.endpoints[1].unhealthy, ep.endpoints[1].failedAt = true, time.Now()
+
+	req.Equal(2, ep.pick())
+}
+
+func TestEndpointsInvalid(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	fs.WithEndpoints(EndpointRoundRobin, "gateway-1")
+
+	_, err := fs.Stat("/file")
+	req.Error(err)
+	req.Contains(err.Error(), request.InvalidParameterErrCode)
+
+	fs.WithEndpoints(EndpointRoundRobin)
+}
@@ -0,0 +1,55 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/afero"
+)
+
+// ErrExist is returned by CreateExclusive when the file already exists or was created first by another writer. It
+// matches os.ErrExist.
+var ErrExist = fmt.Errorf("file was created by another writer: %w", os.ErrExist)
+
+// CreateExclusive creates a file only if it doesn't exist, with a conditional write that makes the first writer win
+// when several ones race, which makes job claims and lock files reliable. Unlike O_EXCL, which checks the existence
+// of the file before writing it, the check and the creation are atomic. The file is created empty and returned opened
+// for writing, its content replacing the empty object when it's closed.
+func (fs *Fs) CreateExclusive(name string) (afero.File, error) {
+	if err := fs.checkWritable("create", name); err != nil {
+		return nil, err
+	}
+
+	params, err := fs.writePermParams(name, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	params.ifNoneMatch = aws.String("*")
+
+	if err = fs.putEmpty(name, &params); isPreconditionFailed(err) {
+		return nil, &os.PathError{Op: "create", Path: name, Err: ErrExist}
+	} else if err != nil {
+		return nil, &os.PathError{Op: "create", Path: name, Err: err}
+	}
+
+	if err = fs.fileWritten(name); err != nil {
+		return nil, err
+	}
+
+	if fs.Mirror != nil {
+		params.ifNoneMatch = nil
+
+		if err = fs.Mirror.putEmpty(name, &params); err != nil {
+			return nil, err
+		}
+	}
+
+	if fs.Replicator != nil {
+		fs.Replicator.enqueue(ReplicationWrite, name)
+	}
+
+	return fs.OpenFile(name, os.O_WRONLY, 0666)
+}
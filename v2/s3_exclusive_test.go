@@ -0,0 +1,81 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateExclusive(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	file, err := fs.CreateExclusive("/lock")
+	req.NoError(err)
+
+	_, err = file.WriteString("owner")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	content, err := afero.ReadFile(fs, "/lock")
+	req.NoError(err)
+	req.Equal("owner", string(content))
+
+	_, err = fs.CreateExclusive("/lock")
+	req.ErrorIs(err, ErrExist)
+	req.ErrorIs(err, os.ErrExist)
+}
+
+func TestCreateExclusiveRace(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	const writers = 10
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		owners []int
+		errs   []error
+	)
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			file, err := fs.CreateExclusive("/claim")
+			if err == nil {
+				_, err = fmt.Fprint(file, i)
+				if errClose := file.Close(); err == nil {
+					err = errClose
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err == nil {
+				owners = append(owners, i)
+			} else if !errors.Is(err, ErrExist) {
+				errs = append(errs, err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	// A single writer claimed the file
+	req.Empty(errs)
+	req.Len(owners, 1)
+
+	content, err := afero.ReadFile(fs, "/claim")
+	req.NoError(err)
+	req.Equal(fmt.Sprint(owners[0]), string(content))
+}
@@ -0,0 +1,188 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+)
+
+// ExportOptions defines how ExportTo copies the files
+type ExportOptions struct {
+	BulkOptions
+	// AsOf is the time of the snapshot exported from a versioned bucket, the versions current when the export starts
+	// being exported if it's zero
+	AsOf time.Time
+	// Perm is the permissions of the exported files, 0644 if 0. The directories are created with 0755.
+	Perm os.FileMode
+}
+
+// exportEntry is an object of an exported snapshot
+type exportEntry struct {
+	key       string // key of the object
+	versionID string // versionID is the exported version of the object, in a versioned bucket
+	etag      string // etag of the exported content of the object, in a bucket without versioning
+}
+
+// ExportTo copies a consistent snapshot of the files of a directory, recursively, to another file system under
+// the same names, like for the backups to a local disk or to another provider. In a versioned bucket, the snapshot is
+// made of the versions of the files current when the export starts, or at AsOf, which the later changes don't affect.
+// Otherwise, the export fails with ErrModified when a file is changed or removed while it's running. The stored
+// content of the files is exported, without ReadTransforms, and the directory markers create empty directories.
+func (fs *Fs) ExportTo(dst afero.Fs, prefix string, opts *ExportOptions) error {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+
+	entries, err := fs.exportEntries(prefix, opts.AsOf)
+	if err != nil {
+		return &os.PathError{Op: "export", Path: prefix, Err: err}
+	}
+
+	perm := opts.Perm
+	if perm == 0 {
+		perm = 0644
+	}
+
+	return forEachIndex("export", len(entries), &opts.BulkOptions, func(i int) (string, error) {
+		return "/" + entries[i].key, fs.exportEntry(dst, entries[i], perm)
+	})
+}
+
+// exportEntries returns the objects of the snapshot of a directory
+func (fs *Fs) exportEntries(prefix string, asOf time.Time) ([]*exportEntry, error) {
+	versioning, err := fs.s3API.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: aws.String(fs.bucket)})
+	if err != nil && !isUnsetConfig(err, "NotImplemented") {
+		return nil, err
+	}
+
+	if err == nil && aws.StringValue(versioning.Status) != "" {
+		return fs.exportVersions(dirPrefix(prefix), asOf)
+	}
+
+	var entries []*exportEntry
+
+	err = fs.walkObjects(dirPrefix(prefix), func(obj *s3.Object) bool {
+		entries = append(entries, &exportEntry{key: aws.StringValue(obj.Key), etag: aws.StringValue(obj.ETag)})
+		return true
+	})
+
+	return entries, err
+}
+
+// exportVersions returns the versions of the objects of a prefix current at a time, or when they're listed if it's
+// zero. The objects whose version is a delete marker are left out.
+func (fs *Fs) exportVersions(prefix string, asOf time.Time) ([]*exportEntry, error) {
+	type snapshotVersion struct {
+		id      string
+		modTime time.Time
+		deleted bool
+	}
+
+	var keys []string
+
+	current := make(map[string]*snapshotVersion)
+
+	pick := func(key, id string, modTime time.Time, latest, deleted bool) {
+		if (asOf.IsZero() && !latest) || (!asOf.IsZero() && modTime.After(asOf)) {
+			return
+		}
+
+		// The versions of an object are listed from the latest
+		v, ok := current[key]
+		if !ok {
+			keys = append(keys, key)
+		} else if !modTime.After(v.modTime) {
+			return
+		}
+
+		current[key] = &snapshotVersion{id: id, modTime: modTime, deleted: deleted}
+	}
+
+	err := fs.s3API.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+		Bucket: aws.String(fs.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectVersionsOutput, _ bool) bool {
+		for _, v := range page.Versions {
+			pick(aws.StringValue(v.Key), aws.StringValue(v.VersionId), aws.TimeValue(v.LastModified),
+				aws.BoolValue(v.IsLatest), false)
+		}
+
+		for _, m := range page.DeleteMarkers {
+			pick(aws.StringValue(m.Key), aws.StringValue(m.VersionId), aws.TimeValue(m.LastModified),
+				aws.BoolValue(m.IsLatest), true)
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*exportEntry, 0, len(keys))
+
+	for _, key := range keys {
+		if v := current[key]; !v.deleted {
+			entries = append(entries, &exportEntry{key: key, versionID: v.id})
+		}
+	}
+
+	return entries, nil
+}
+
+// exportEntry copies an object of a snapshot to another file system
+func (fs *Fs) exportEntry(dst afero.Fs, entry *exportEntry, perm os.FileMode) error {
+	if strings.HasSuffix(entry.key, "/") {
+		return dst.MkdirAll("/"+strings.TrimSuffix(entry.key, "/"), 0755)
+	}
+
+	if dir, ok := fs.markerDir(entry.key); ok {
+		return dst.MkdirAll("/"+dir, 0755)
+	}
+
+	name := "/" + entry.key
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(entry.key),
+	}
+
+	if entry.versionID != "" {
+		input.VersionId = aws.String(entry.versionID)
+	} else {
+		input.IfMatch = aws.String(entry.etag)
+	}
+
+	resp, err := fs.s3API.GetObjectWithContext(fs.requestContext(), input)
+	if isPreconditionFailed(err) || (isNotFound(err) && entry.versionID == "") {
+		return ErrModified
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close() // nolint: errcheck
+
+	if err = dst.MkdirAll(path.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	file, err := dst.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(file, resp.Body); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
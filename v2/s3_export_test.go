@@ -0,0 +1,100 @@
+package s3
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportTo(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/data/a.txt", "a")
+	testCreateFile(t, fs, "/data/sub/b.txt", "b")
+	testCreateFile(t, fs, "/other.txt", "other")
+	req.NoError(fs.Mkdir("/data/empty", 0755))
+
+	// The files of the directory are exported under the same names
+	dst := afero.NewMemMapFs()
+	req.NoError(fs.ExportTo(dst, "/data", nil))
+
+	for name, content := range map[string]string{"/data/a.txt": "a", "/data/sub/b.txt": "b"} {
+		data, err := afero.ReadFile(dst, name)
+		req.NoError(err)
+		req.Equal(content, string(data))
+	}
+
+	info, err := dst.Stat("/data/empty")
+	req.NoError(err)
+	req.True(info.IsDir())
+
+	_, err = dst.Stat("/other.txt")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	// The files changed during the export fail it
+	var progressed bool
+
+	err = fs.ExportTo(afero.NewMemMapFs(), "/data", &ExportOptions{BulkOptions: BulkOptions{
+		Concurrency: 1,
+		Progress: func(processed int64, _ string) {
+			if !progressed {
+				progressed = true
+				testCreateFile(t, fs, "/data/sub/b.txt", "changed")
+			}
+		},
+	}})
+	req.ErrorIs(err, ErrModified)
+}
+
+func TestExportToVersioned(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	_, err := fs.s3API.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(fs.bucket),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(s3.BucketVersioningStatusEnabled)},
+	})
+	req.NoError(err)
+
+	testCreateFile(t, fs, "/data/a.txt", "a1")
+	testCreateFile(t, fs, "/data/b.txt", "b1")
+
+	time.Sleep(1100 * time.Millisecond)
+	asOf := time.Now()
+	time.Sleep(1100 * time.Millisecond)
+
+	testCreateFile(t, fs, "/data/a.txt", "a2")
+	testCreateFile(t, fs, "/data/c.txt", "c2")
+	req.NoError(fs.Remove("/data/b.txt"))
+
+	read := func(dst afero.Fs, name string) string {
+		data, errRead := afero.ReadFile(dst, name)
+		if os.IsNotExist(errRead) {
+			return ""
+		}
+
+		req.NoError(errRead)
+
+		return string(data)
+	}
+
+	// The current versions are exported
+	dst := afero.NewMemMapFs()
+	req.NoError(fs.ExportTo(dst, "/data", nil))
+	req.Equal("a2", read(dst, "/data/a.txt"))
+	req.Equal("", read(dst, "/data/b.txt"))
+	req.Equal("c2", read(dst, "/data/c.txt"))
+
+	// Or the ones of a previous time
+	dst = afero.NewMemMapFs()
+	req.NoError(fs.ExportTo(dst, "/data", &ExportOptions{AsOf: asOf}))
+	req.Equal("a1", read(dst, "/data/a.txt"))
+	req.Equal("b1", read(dst, "/data/b.txt"))
+	req.Equal("", read(dst, "/data/c.txt"))
+}
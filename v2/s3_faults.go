@@ -0,0 +1,137 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// faultHandler is the name of the send handler injecting faults
+const faultHandler = "afero-s3.FaultInjection"
+
+// AllOperations matches all the operations of a FaultInjector
+const AllOperations = "*"
+
+// Fault describes an error or a latency injected in the requests of an operation
+type Fault struct {
+	Code       string        // Code of the injected error, like "InternalError" or "SlowDown"
+	StatusCode int           // StatusCode of the injected error, no error is injected if 0
+	Latency    time.Duration // Latency added before the request is sent or the error returned
+	Rate       float64       // Rate is the probability of the fault, it always happens if 0
+}
+
+// FaultInjector injects faults in the requests sent by an Fs, per operation. It's meant to test the error
+// paths of the code using an Fs without relying on a misbehaving endpoint.
+type FaultInjector struct {
+	mu       sync.Mutex
+	faults   map[string]*Fault
+	random   *rand.Rand
+	injected int64
+}
+
+// NewFaultInjector creates a fault injector, the seed makes the random faults reproducible
+func NewFaultInjector(seed int64) *FaultInjector {
+	return &FaultInjector{
+		faults: make(map[string]*Fault),
+		random: rand.New(rand.NewSource(seed)), // nolint: gosec
+	}
+}
+
+// Set defines the fault of an operation, like "GetObject", or of AllOperations. A nil fault removes it.
+func (fi *FaultInjector) Set(operation string, fault *Fault) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fault == nil {
+		delete(fi.faults, operation)
+	} else {
+		fi.faults[operation] = fault
+	}
+}
+
+// Injected returns the number of errors injected so far
+func (fi *FaultInjector) Injected() int64 {
+	return atomic.LoadInt64(&fi.injected)
+}
+
+// fault returns the fault to apply to a request, if any
+func (fi *FaultInjector) fault(operation string) *Fault {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fault, ok := fi.faults[operation]
+	if !ok {
+		fault, ok = fi.faults[AllOperations]
+	}
+
+	if !ok || (fault.Rate > 0 && fi.random.Float64() >= fault.Rate) {
+		return nil
+	}
+
+	return fault
+}
+
+// send sends a request unless an error is injected
+func (fi *FaultInjector) send(r *request.Request) {
+	fault := fi.fault(r.Operation.Name)
+	if fault == nil {
+		corehandlers.SendHandler.Fn(r)
+		return
+	}
+
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+
+	if fault.StatusCode == 0 {
+		corehandlers.SendHandler.Fn(r)
+		return
+	}
+
+	atomic.AddInt64(&fi.injected, 1)
+
+	r.HTTPResponse = &http.Response{
+		StatusCode: fault.StatusCode,
+		Status:     http.StatusText(fault.StatusCode),
+		Header:     http.Header{},
+		Body:       http.NoBody,
+	}
+	r.Error = awserr.NewRequestFailure(awserr.New(fault.Code, "injected fault", nil), fault.StatusCode, "")
+}
+
+// WithFaultInjector injects faults in the requests of the Fs, nil removes the injection.
+// It should be called before the Fs is used.
+func (fs *Fs) WithFaultInjector(fi *FaultInjector) *Fs {
+	fs.faults = fi
+	injectFaults(&fs.s3API.Handlers, fi)
+
+	return fs
+}
+
+// injectFaults replaces the send handler by one injecting faults, or restores it if fi is nil
+func injectFaults(handlers *request.Handlers, fi *FaultInjector) {
+	send := corehandlers.SendHandler
+	if fi != nil {
+		send = request.NamedHandler{Name: faultHandler, Fn: fi.send}
+	}
+
+	if !handlers.Send.Swap(corehandlers.SendHandler.Name, send) {
+		handlers.Send.Swap(faultHandler, send)
+	}
+}
+
+// faultOption applies the fault injection of the Fs to the requests of other clients
+func (fs *Fs) faultOption() request.Option {
+	return func(r *request.Request) {
+		if fs.faults != nil {
+			injectFaults(&r.Handlers, fs.faults)
+		}
+	}
+}
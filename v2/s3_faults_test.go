@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjection(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/file", "Hello world !")
+
+	faults := NewFaultInjector(0)
+	fs.WithFaultInjector(faults)
+
+	t.Run("ReadFailure", func(t *testing.T) {
+		file, err := fs.Open("/file")
+		req.NoError(err)
+
+		faults.Set("GetObject", &Fault{StatusCode: http.StatusForbidden, Code: "AccessDenied"})
+		defer faults.Set("GetObject", nil)
+
+		// Re-opening the stream fails, which leaves the file without stream
+		_, err = file.Seek(6, io.SeekStart)
+		req.Error(err)
+
+		_, err = file.Read(make([]byte, 5))
+		req.Error(err)
+		req.Positive(faults.Injected())
+	})
+
+	t.Run("WriteFailure", func(t *testing.T) {
+		faults.Set(AllOperations, &Fault{StatusCode: http.StatusBadRequest, Code: "InvalidRequest"})
+		defer faults.Set(AllOperations, nil)
+
+		file, err := fs.OpenFile("/other", os.O_WRONLY, 0)
+		req.NoError(err)
+		_, _ = file.WriteString("content")
+		req.Error(file.Close())
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		faults.Set("HeadObject", &Fault{Latency: 50 * time.Millisecond})
+		defer faults.Set("HeadObject", nil)
+
+		start := time.Now()
+		info, err := fs.Stat("/file")
+		req.NoError(err)
+		req.Equal(int64(13), info.Size())
+		req.GreaterOrEqual(time.Since(start), 50*time.Millisecond)
+	})
+
+	fs.WithFaultInjector(nil)
+
+	_, err := fs.Stat("/file")
+	req.NoError(err)
+}
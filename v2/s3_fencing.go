@@ -0,0 +1,127 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+)
+
+// metaFencingToken is the metadata storing the fencing token of the files written with OpenFenced
+const metaFencingToken = "Fencing-Token"
+
+// FencedError is returned when a file written with a fencing token was written with a newer token, it matches
+// ErrModified
+type FencedError struct {
+	Token   uint64 // Token of the rejected writer
+	Current uint64 // Current token of the file
+}
+
+func (e *FencedError) Error() string {
+	return fmt.Sprintf("fencing token %d superseded by %d", e.Token, e.Current)
+}
+
+// Unwrap makes the error match ErrModified
+func (e *FencedError) Unwrap() error {
+	return ErrModified
+}
+
+// OpenFenced opens a file for writing with a fencing token, given by a lock service to the successive holders of a
+// lock with increasing values. The token is stored in the metadata of the file. The file can't be opened, and its
+// Close fails without changing it, once it was written with a newer token, which protects it from a writer that held
+// the lock for too long. The check is atomic with the commit of the upload, thanks to a conditional write.
+func (fs *Fs) OpenFenced(name string, token uint64) (afero.File, error) {
+	_, current, err := fs.fencingState(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if current > token {
+		return nil, &os.PathError{Op: "open", Path: name, Err: &FencedError{Token: token, Current: current}}
+	}
+
+	params, err := fs.writePermParams(name, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.metadata == nil {
+		params.metadata = make(map[string]*string)
+	}
+
+	params.metadata[metaFencingToken] = aws.String(strconv.FormatUint(token, 10))
+	params.fencingToken = aws.Uint64(token)
+
+	file := NewFile(fs, name)
+	file.upload = params
+
+	return file, file.openWriteStream()
+}
+
+// fencingState returns the ETag and the fencing token of a file, none if it doesn't exist
+func (fs *Fs) fencingState(name string) (string, uint64, error) {
+	out, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	})
+	if isNotFound(err) {
+		return "", 0, nil
+	} else if err != nil {
+		return "", 0, err
+	}
+
+	raw := aws.StringValue(out.Metadata[metaFencingToken])
+	if raw == "" {
+		return aws.StringValue(out.ETag), 0, nil
+	}
+
+	token, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid fencing token %q: %w", raw, err)
+	}
+
+	return aws.StringValue(out.ETag), token, nil
+}
+
+// fencingOption makes the request committing an upload check the fencing token of the file, and conditional to its
+// ETag so that no other writer can commit in between. The rejection is reported through fenced, as the uploader
+// hides the errors of the multipart uploads.
+func (fs *Fs) fencingOption(name string, token uint64, fenced *error) request.Option {
+	return func(r *request.Request) {
+		if r.Operation.Name != "PutObject" && r.Operation.Name != "CompleteMultipartUpload" {
+			return
+		}
+
+		r.Handlers.Build.PushBack(func(r *request.Request) {
+			etag, current, err := fs.fencingState(name)
+
+			switch {
+			case err != nil:
+				r.Error = err
+			case current > token:
+				*fenced = &FencedError{Token: token, Current: current}
+				r.Error = *fenced
+			case etag == "":
+				r.HTTPRequest.Header.Set("If-None-Match", "*")
+			default:
+				r.HTTPRequest.Header.Set("If-Match", etag)
+			}
+		})
+
+		// The file was written concurrently, by a newer writer or not
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			if isPreconditionFailed(r.Error) {
+				if _, current, err := fs.fencingState(name); err == nil && current > token {
+					*fenced = &FencedError{Token: token, Current: current}
+				} else {
+					*fenced = ErrModified
+				}
+			}
+		})
+	}
+}
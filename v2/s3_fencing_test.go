@@ -0,0 +1,72 @@
+package s3
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenFenced(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	// A writer holding the lock for too long
+	stale, err := fs.OpenFenced("/fenced", 1)
+	req.NoError(err)
+
+	_, err = stale.WriteString("stale")
+	req.NoError(err)
+
+	// The next holder of the lock
+	fresh, err := fs.OpenFenced("/fenced", 2)
+	req.NoError(err)
+
+	_, err = fresh.WriteString("fresh")
+	req.NoError(err)
+	req.NoError(fresh.Close())
+
+	err = stale.Close()
+
+	var fenced *FencedError
+	req.True(errors.As(err, &fenced))
+	req.Equal(uint64(1), fenced.Token)
+	req.Equal(uint64(2), fenced.Current)
+	req.ErrorIs(err, ErrModified)
+
+	data, err := afero.ReadFile(fs, "/fenced")
+	req.NoError(err)
+	req.Equal("fresh", string(data))
+
+	// The stale token can't even open the file
+	_, err = fs.OpenFenced("/fenced", 1)
+	req.ErrorIs(err, ErrModified)
+
+	// The same holder can write the file again
+	file, err := fs.OpenFenced("/fenced", 2)
+	req.NoError(err)
+
+	_, err = file.WriteString("again")
+	req.NoError(err)
+	req.NoError(file.Close())
+}
+
+func TestOpenFencedConcurrentWrite(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	file, err := fs.OpenFenced("/fenced", 5)
+	req.NoError(err)
+
+	// An older token committing first doesn't fence the newer one
+	testCreateFile(t, fs, "/fenced", "unfenced")
+
+	_, err = file.WriteString("fenced")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	data, err := afero.ReadFile(fs, "/fenced")
+	req.NoError(err)
+	req.Equal("fenced", string(data))
+}
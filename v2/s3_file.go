@@ -0,0 +1,1028 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// File represents a file in S3.
+// nolint: govet
+type File struct {
+	fs                       *Fs              // Parent file system
+	name                     string           // Name of the file
+	cachedInfo               os.FileInfo      // File info cached for later used
+	streamRead               io.ReadCloser    // streamRead is the underlying stream we are reading from
+	streamReadOffset         int64            // streamReadOffset is the offset of the read-only stream
+	streamWrite              io.WriteCloser   // streamWrite is the underlying stream we are reading to
+	streamWriteErr           streamError      // streamWriteErr is the error that should be returned in case of a write
+	streamWriteCloseErr      chan error       // streamWriteCloseErr is the channel containing the underlying write error
+	streamWriteSize          int64            // streamWriteSize is the number of bytes written so far
+	removeIfUnwritten        bool             // removeIfUnwritten removes the file on close if nothing was written
+	upload                   uploadParams     // upload are the parameters of the upload of the written file
+	readdirContinuationToken *string          // readdirContinuationToken is used to perform files listing across calls
+	readdirNotTruncated      bool             // readdirNotTruncated is set when we shall continue reading
+	readdirDirs              map[string]bool  // readdirDirs are the listed directories, when markers can duplicate them
+	listArtifacts            bool             // listArtifacts lists the Hadoop artifacts even if they are hidden
+	versionID                string           // versionID is the read version of the file, the current one if empty
+	virtualName              string           // virtualName is the name of a version in the versions directory
+	versionsEntries          []os.FileInfo    // versionsEntries are the entries of the versions directory to list
+	versionsDirListed        bool             // versionsDirListed is set once the versions directory was listed
+	bytesRead                int64            // bytesRead is the number of bytes read, see Stats
+	counters                 *fileCounters    // counters of the requests of the file, see Stats
+	reopens                  int64            // reopens is the number of times the read stream was reopened
+	transforms               []*ReadTransform // transforms are the read transforms applied to the file
+	uploadState              *uploadState     // uploadState tracks the activity of the upload, see ActiveUploads
+	openStack                []byte           // openStack is the stack of the opening, see OnOrphanedFile
+	// I think readdirNotTruncated can be dropped. The continuation token is probably enough.
+}
+
+// NewFile initializes an File object.
+func NewFile(fs *Fs, name string) *File {
+	file := &File{
+		fs:       fs,
+		name:     name,
+		counters: &fileCounters{},
+	}
+
+	if fs.OnOrphanedFile != nil {
+		file.watchOrphaned()
+	}
+
+	return file
+}
+
+// Name returns the filename, i.e. S3 path without the bucket name.
+func (f *File) Name() string {
+	if f.virtualName != "" {
+		return f.virtualName
+	}
+
+	return f.name
+}
+
+// Readdir reads the contents of the directory associated with file and
+// returns a slice of up to n FileInfo values, as would be returned
+// by ListObjects, in directory order. Subsequent calls on the same file will yield further FileInfos.
+//
+// The entries are sorted in the lexical order of their keys, a directory being ordered like its name followed by a
+// slash, which S3 follows across the pages. Only the inlined files and the directories of foreign markers can break
+// this order between two pages, ReaddirAll always sorts all of them. The versions directory is listed first.
+//
+// If n > 0, Readdir returns at most n FileInfo structures. In this case, if
+// Readdir returns an empty slice, it will return a non-nil error
+// explaining why. At the end of a directory, the error is io.EOF.
+//
+// If n <= 0, Readdir returns all the FileInfo from the directory in
+// a single slice. In this case, if Readdir succeeds (reads all
+// the way to the end of the directory), it returns the slice and a
+// nil error. If it encounters an error before the end of the
+// directory, Readdir returns the FileInfo read until that point
+// and a non-nil error.
+func (f *File) Readdir(n int) ([]os.FileInfo, error) {
+	fis, err := f.readdir(n)
+	return fis, pathError("readdir", f.Name(), err)
+}
+
+func (f *File) readdir(n int) ([]os.FileInfo, error) {
+	if rel, ok := f.fs.versionsPath(f.name); ok {
+		return f.readdirVersions(rel, n)
+	}
+
+	if n <= 0 {
+		if fis, ok, err := f.readdirPreloaded(); ok {
+			return fis, err
+		}
+
+		return f.ReaddirAll()
+	}
+
+	// The versions directory is listed first at the root
+	if f.fs.VersionsDir && !f.versionsDirListed && path.Clean("/"+f.name) == "/" {
+		f.versionsDirListed = true
+
+		return []os.FileInfo{NewFileInfo(VersionsDirName, true, 0, time.Unix(0, 0))}, nil
+	}
+
+	// Some pages might only contain the directory marker
+	for !f.readdirNotTruncated {
+		fis, err := f.readdirPage(n)
+		if err != nil || len(fis) > 0 {
+			return fis, err
+		}
+	}
+
+	return nil, io.EOF
+}
+
+// readdirPage lists the next page of at most n entries of the directory
+func (f *File) readdirPage(n int) ([]os.FileInfo, error) {
+	// ListObjects treats leading slashes as part of the directory name
+	// It also needs a trailing slash to list contents of a directory.
+	name := strings.TrimPrefix(f.Name(), "/") // + "/"
+
+	// For the root of the bucket, we need to remove any prefix
+	if name != "" && !strings.HasSuffix(name, "/") {
+		name += "/"
+	}
+	output, err := f.fs.s3API.ListObjectsV2(&s3.ListObjectsV2Input{
+		ContinuationToken: f.readdirContinuationToken,
+		Bucket:            aws.String(f.fs.bucket),
+		Prefix:            aws.String(name),
+		Delimiter:         aws.String("/"),
+		MaxKeys:           aws.Int64(int64(n)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.readdirContinuationToken = output.NextContinuationToken
+	if !aws.BoolValue(output.IsTruncated) {
+		f.readdirNotTruncated = true
+	}
+
+	return f.listingEntries(output.CommonPrefixes, output.Contents)
+}
+
+// listingEntries converts the sub-directories and objects of a listing of the directory to its entries
+func (f *File) listingEntries(prefixes []*s3.CommonPrefix, contents []*s3.Object) ([]os.FileInfo, error) {
+	var (
+		fis = make([]os.FileInfo, 0, len(prefixes)+len(contents))
+		err error
+	)
+	for _, subfolder := range prefixes {
+		fis = f.appendDir(fis, "/"+strings.TrimSuffix(*subfolder.Prefix, "/"))
+	}
+	for _, fileObject := range contents {
+		if strings.HasSuffix(*fileObject.Key, "/") {
+			// S3 includes <name>/ in the Contents listing for <name>
+			continue
+		}
+
+		// The inlined files are listed instead of their index
+		if f.fs.InlineThreshold > 0 && path.Base(*fileObject.Key) == InlineIndexName {
+			if fis, err = f.appendInline(fis, "/"+*fileObject.Key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Markers of other tools, like "<name>_$folder$", are listed as directories
+		if dir, ok := f.fs.markerDir(*fileObject.Key); ok {
+			fis = f.appendDir(fis, "/"+dir)
+			continue
+		}
+
+		fis = append(fis, NewFileInfo(
+			"/"+*fileObject.Key, false, aws.Int64Value(fileObject.Size), aws.TimeValue(fileObject.LastModified),
+		))
+	}
+
+	if f.fs.HideHadoopArtifacts && !f.listArtifacts {
+		fis = withoutHadoopArtifacts(fis)
+	}
+
+	sortEntries(fis)
+
+	return fis, nil
+}
+
+// sortEntries sorts the entries of a directory in the order of their keys, S3 listing the common prefixes and the
+// objects separately
+func sortEntries(fis []os.FileInfo) {
+	key := func(fi os.FileInfo) string {
+		if fi.IsDir() {
+			return fi.Name() + "/"
+		}
+
+		return fi.Name()
+	}
+
+	sort.SliceStable(fis, func(i, j int) bool { return key(fis[i]) < key(fis[j]) })
+}
+
+// appendDir adds a directory to a listing by its path, unless it was already listed through another marker
+func (f *File) appendDir(fis []os.FileInfo, name string) []os.FileInfo {
+	if len(f.fs.markerSuffixes()) > 0 {
+		if f.readdirDirs == nil {
+			f.readdirDirs = make(map[string]bool)
+		}
+
+		if f.readdirDirs[name] {
+			return fis
+		}
+
+		f.readdirDirs[name] = true
+	}
+
+	return append(fis, NewFileInfo(name, true, 0, time.Unix(0, 0)))
+}
+
+// Listing page sizes of ReaddirAll when they are adapted
+const (
+	minListPageSize = 100
+	maxListPageSize = 1000 // maxListPageSize is the maximum number of keys returned by S3
+)
+
+// ReaddirAll provides list of file cachedInfo.
+// Unless Fs.ListPageSize is set, the listing starts with small pages and doubles their size as the listing
+// continues, which makes small directories fast to list and big ones listed with few requests.
+func (f *File) ReaddirAll() ([]os.FileInfo, error) {
+	pageSize := f.fs.ListPageSize
+	adaptive := pageSize <= 0
+	if adaptive {
+		pageSize = minListPageSize
+	}
+
+	// The versions directory stays first
+	sorted := 0
+	if f.fs.VersionsDir && !f.versionsDirListed && path.Clean("/"+f.name) == "/" {
+		sorted = 1
+	}
+
+	var fileInfos []os.FileInfo
+	for {
+		infos, err := f.Readdir(pageSize)
+		fileInfos = append(fileInfos, infos...)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			} else {
+				return nil, err
+			}
+		}
+		if adaptive && pageSize < maxListPageSize {
+			pageSize *= 2
+			if pageSize > maxListPageSize {
+				pageSize = maxListPageSize
+			}
+		}
+	}
+	if len(fileInfos) > sorted {
+		sortEntries(fileInfos[sorted:])
+	}
+	return fileInfos, nil
+}
+
+// Readdirnames reads and returns a slice of names from the directory f.
+//
+// If n > 0, Readdirnames returns at most n names. In this case, if
+// Readdirnames returns an empty slice, it will return a non-nil error
+// explaining why. At the end of a directory, the error is io.EOF.
+//
+// If n <= 0, Readdirnames returns all the names from the directory in
+// a single slice. In this case, if Readdirnames succeeds (reads all
+// the way to the end of the directory), it returns the slice and a
+// nil error. If it encounters an error before the end of the
+// directory, Readdirnames returns the names read until that point and
+// a non-nil error.
+func (f *File) Readdirnames(n int) ([]string, error) {
+	fi, err := f.Readdir(n)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(fi))
+	for i, f := range fi {
+		_, names[i] = path.Split(f.Name())
+	}
+	return names, nil
+}
+
+// Stat returns the FileInfo structure describing file.
+// If there is an error, it will be of type *PathError.
+func (f *File) Stat() (os.FileInfo, error) {
+	var (
+		info os.FileInfo
+		err  error
+	)
+
+	if info, writing := f.statWriting(); writing {
+		return info, nil
+	}
+
+	switch {
+	case f.virtualName != "":
+		info, err = f.fs.Stat(f.virtualName)
+	case f.versionID != "":
+		info, err = f.fs.statVersion(f.name, f.versionID)
+	default:
+		info, err = f.fs.Stat(f.Name())
+	}
+
+	if err == nil {
+		f.cachedInfo = info
+	}
+	return info, pathError("stat", f.Name(), err)
+}
+
+// Sync hands the buffered small writes to the uploads, it doesn't wait for them to be sent.
+func (f *File) Sync() error {
+	if flusher, ok := f.streamWrite.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			return pathError("sync", f.name, f.writeError(err))
+		}
+	}
+
+	return nil
+}
+
+// Truncate changes the size of the file.
+// It does not change the I/O offset.
+// If there is an error, it will be of type *PathError.
+func (f *File) Truncate(int64) error {
+	return &os.PathError{Op: "truncate", Path: f.name, Err: ErrNotImplemented}
+}
+
+// WriteString is like Write, but writes the contents of string s rather than
+// a slice of bytes.
+func (f *File) WriteString(s string) (int, error) {
+	if f.streamWrite == nil {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: afero.ErrFileClosed}
+	}
+
+	// The write buffer copies the string without allocating a byte slice
+	if writer, ok := f.streamWrite.(io.StringWriter); ok {
+		return f.written(writer.WriteString(s))
+	}
+
+	return f.Write([]byte(s)) // nolint: gocritic
+}
+
+// Close closes the File, rendering it unusable for I/O.
+// It returns an error, if any.
+func (f *File) Close() error {
+	return pathError("close", f.name, f.close())
+}
+
+func (f *File) close() error {
+	// Closing a reading stream
+	if f.streamRead != nil {
+		// We try to close the Reader
+		defer func() {
+			f.streamRead = nil
+		}()
+		return f.streamRead.Close()
+	}
+
+	// Closing a writing stream
+	if f.streamWrite != nil {
+		f.fs.writing.remove(f)
+
+		defer func() {
+			f.streamWrite = nil
+			f.streamWriteCloseErr = nil
+		}()
+
+		// We try to close the Writer
+		errClose := f.streamWrite.Close()
+		uploadErr := f.streamWriteCloseErr
+
+		// The upload is committed in the background in write-behind mode
+		if f.fs.WriteBehind {
+			f.fs.commitBehind(f.name, func() error { return f.commitWrite(uploadErr, errClose) })
+			return nil
+		}
+
+		return f.commitWrite(uploadErr, errClose)
+	}
+
+	// Or maybe we don't have anything to close
+	return nil
+}
+
+// commitWrite waits for the upload of a closed writing stream and commits the written file
+func (f *File) commitWrite(uploadErr chan error, errClose error) error {
+	// We wait for the actual writing performed in go-routine to finish.
+	// We might have at most 2*5=10MB of data waiting to be flushed before close returns. This
+	// might be rather slow.
+	err := <-uploadErr
+	close(uploadErr)
+	// The uploads of the rejected files fail with the rejection
+	if err == nil || isRejected(errClose) {
+		err = errClose
+	}
+	err = f.uploadState.uploadError(err)
+	if err == nil && f.removeIfUnwritten && f.streamWriteSize == 0 {
+		return f.removeUnwritten()
+	}
+	if err == nil {
+		err = f.fs.fileWritten(f.name)
+	}
+	if err == nil && f.fs.Replicator != nil {
+		f.fs.Replicator.enqueue(ReplicationWrite, f.name)
+	}
+	return err
+}
+
+// Read reads up to len(b) bytes from the File.
+// It returns the number of bytes read and an error, if any.
+// EOF is signaled by a zero count with err set to io.EOF, which the first Read of an empty file returns.
+func (f *File) Read(p []byte) (int, error) {
+	// The stream might have failed to be re-opened by a seek
+	if f.streamRead == nil {
+		if _, writing := f.statWriting(); writing {
+			return 0, io.EOF
+		}
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: afero.ErrFileClosed}
+	}
+
+	// Like with os.File, an empty read does nothing, even at the end of the file
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, err := f.streamRead.Read(p)
+
+	if err == nil {
+		f.streamReadOffset += int64(n)
+	}
+
+	f.bytesRead += int64(n)
+
+	return n, pathError("read", f.name, err)
+}
+
+// ReadAt reads len(p) bytes from the file starting at byte offset off.
+// It returns the number of bytes read and the error, if any.
+// ReadAt always returns a non-nil error when n < len(b).
+// At end of file, that error is io.EOF.
+func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
+	_, err = f.Seek(off, io.SeekStart)
+	if err != nil {
+		return
+	}
+	n, err = f.Read(p)
+	return
+}
+
+// Seek sets the offset for the next Read or Write on file to offset, interpreted
+// according to whence: 0 means relative to the origin of the file, 1 means
+// relative to the current offset, and 2 means relative to the end.
+// It returns the new offset and an error, if any.
+// The behavior of Seek on a file opened with O_APPEND is not specified.
+// Directories can only be rewound, with Seek(0, io.SeekStart).
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	offset, err := f.seek(offset, whence)
+	return offset, pathError("seek", f.name, err)
+}
+
+func (f *File) seek(offset int64, whence int) (int64, error) {
+	// Write seek is not supported
+	if f.streamWrite != nil {
+		return 0, ErrNotSupported
+	}
+
+	// Read seek has its own implementation
+	if f.streamRead != nil {
+		return f.seekRead(offset, whence)
+	}
+
+	// Directories can only be rewound
+	if f.cachedInfo != nil && f.cachedInfo.IsDir() {
+		if offset != 0 || whence != io.SeekStart {
+			return 0, ErrNotSupported
+		}
+
+		f.ResumeReaddir("")
+
+		return 0, nil
+	}
+
+	// Not having a stream
+	return 0, afero.ErrFileClosed
+}
+
+// ReaddirCursor returns the position of the directory listing, which allows to resume it later, possibly with
+// another handle, through ResumeReaddir. It returns io.EOF once the listing is complete.
+func (f *File) ReaddirCursor() (string, error) {
+	if f.readdirNotTruncated {
+		return "", io.EOF
+	}
+
+	return aws.StringValue(f.readdirContinuationToken), nil
+}
+
+// ResumeReaddir continues the directory listing from a cursor returned by ReaddirCursor, an empty cursor
+// restarts it from the beginning.
+func (f *File) ResumeReaddir(cursor string) {
+	f.readdirNotTruncated = false
+	f.readdirContinuationToken = nil
+	f.readdirDirs = nil
+	f.versionsDirListed = cursor != ""
+
+	if cursor != "" {
+		f.readdirContinuationToken = aws.String(cursor)
+	}
+}
+
+func (f *File) seekRead(offset int64, whence int) (int64, error) {
+	startByte := int64(0)
+
+	switch whence {
+	case io.SeekStart:
+		startByte = offset
+	case io.SeekCurrent:
+		startByte = f.streamReadOffset + offset
+	case io.SeekEnd:
+		// The size of the transformed content isn't known
+		if f.transforms != nil {
+			return 0, ErrNotSupported
+		}
+
+		startByte = f.cachedInfo.Size() - offset
+	}
+
+	// Short forward seeks are performed within the read-ahead window
+	if f.seekAhead(startByte) {
+		return startByte, nil
+	}
+
+	// The transformed files can only be read forward
+	if f.transforms != nil && startByte >= f.streamReadOffset {
+		return startByte, f.skipTransformed(startByte)
+	}
+
+	if err := f.streamRead.Close(); err != nil {
+		return 0, fmt.Errorf("couldn't close previous stream: %w", err)
+	}
+	f.streamRead = nil
+	f.reopens++
+
+	if startByte < 0 {
+		return startByte, ErrInvalidSeek
+	}
+
+	return startByte, f.openReadStream(startByte)
+}
+
+// Write writes len(b) bytes to the File.
+// It returns the number of bytes written and an error, if any.
+// Write returns a non-nil error when n != len(b).
+func (f *File) Write(p []byte) (int, error) {
+	if f.streamWrite == nil {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: afero.ErrFileClosed}
+	}
+
+	return f.written(f.streamWrite.Write(p))
+}
+
+// written handles the result of a write to the stream
+func (f *File) written(n int, err error) (int, error) {
+	if err != nil {
+		return 0, pathError("write", f.name, f.writeError(err))
+	}
+
+	f.streamWriteSize += int64(n)
+	f.uploadState.touch()
+
+	return n, err
+}
+
+// writeError returns the error of a write to the stream. If it's only the "read/write on closed pipe" one, the
+// underlying error of the uploads is reported instead, unless the content was rejected by the scan.
+func (f *File) writeError(err error) error {
+	if errUpload := f.streamWriteErr.get(); errUpload != nil && !isRejected(err) {
+		err = errUpload
+	}
+
+	return f.uploadState.uploadError(err)
+}
+
+func (f *File) openWriteStream() error {
+	if f.streamWrite != nil {
+		return ErrAlreadyOpened
+	}
+
+	f.upload.retries = &f.counters.retries
+
+	transforms := f.fs.writeTransforms(f.name)
+	f.upload.metadata = transformWriteMetadata(f.upload.metadata, transforms)
+
+	// The uploads run in the background with the configuration the file was opened with
+	targets := []*Fs{f.fs.snapshot()}
+	if f.fs.Mirror != nil {
+		targets = append(targets, f.fs.Mirror.snapshot())
+	}
+
+	writers := make([]io.Writer, len(targets))
+	closers := make([]io.Closer, len(targets))
+	readers := make([]io.Reader, len(targets))
+	uploadErrs := make(chan error, len(targets))
+
+	for i := range targets {
+		reader, writer := io.Pipe()
+		readers[i], writers[i], closers[i] = reader, writer, writer
+	}
+
+	// The file of reference is only written once its mirror succeeded, so that it's left unchanged otherwise
+	var mirrored sync.WaitGroup
+	if len(targets) > 1 {
+		mirrored.Add(len(targets) - 1)
+		readers[0] = &gatedReader{Reader: readers[0], wait: func() error {
+			mirrored.Wait()
+			return f.streamWriteErr.get()
+		}}
+	}
+
+	for i, target := range targets {
+		go func(i int, target *Fs) {
+			err := target.uploadStream(f.name, readers[i], &f.upload)
+
+			// Any pending or future write fails, and none of the uploads completes
+			if err != nil && f.streamWriteErr.set(err) {
+				failPipes(closers, err)
+			}
+
+			if i > 0 {
+				mirrored.Done()
+			}
+
+			uploadErrs <- err
+		}(i, target)
+	}
+
+	stream, err := f.fs.contentWriter(f.name, &multiWriteCloser{Writer: io.MultiWriter(writers...), closers: closers},
+		closers, transforms)
+	if err != nil {
+		abortUploads(closers, uploadErrs, err)
+		return err
+	}
+
+	// The result of the uploads is kept until the file is closed, the aborted uploads ending without it
+	closeErr := make(chan error, 1)
+	f.streamWriteCloseErr = closeErr
+	f.streamWrite = stream
+
+	if size := f.fs.writeBufferSize(); size > 0 {
+		f.streamWrite = &bufferedWriteCloser{
+			Writer:    bufio.NewWriterSize(f.streamWrite, size),
+			direct:    f.streamWrite,
+			smallSize: size / 16,
+		}
+	}
+
+	f.trackUpload(closers)
+
+	go func() {
+		// The file is only written once all the uploads succeeded, the first error being reported
+		for range targets {
+			<-uploadErrs
+		}
+
+		closeErr <- f.streamWriteErr.get()
+	}()
+
+	return nil
+}
+
+// uploadParams are the parameters of an upload specific to a file
+type uploadParams struct {
+	tagging      *string            // tagging is the URL-encoded set of tags of the file
+	metadata     map[string]*string // metadata of the file
+	acl          *string            // acl is the canned ACL of the file
+	redirect     *string            // redirect is the website redirect location of the file
+	cacheControl *string            // cacheControl overrides the Cache-Control header of the Fs file properties
+	contentType  *string            // contentType overrides the Content-Type of the Fs file properties
+	ifMatch      *string            // ifMatch makes the single request uploads conditional to the ETag of the file
+	ifNoneMatch  *string            // ifNoneMatch makes the single request uploads conditional, "*" if it must not exist
+	retries      *int64             // retries counts the retries of the requests of the streamed uploads
+	fencingToken *uint64            // fencingToken makes the streamed uploads check the token of the file, see OpenFenced
+}
+
+// applyProps overrides the FileProps of the Fs with the properties of a file, if not nil
+func (params *uploadParams) applyProps(props *UploadedFileProperties) {
+	if props == nil {
+		return
+	}
+
+	if props.ACL != nil {
+		params.acl = props.ACL
+	}
+
+	params.cacheControl = props.CacheControl
+	params.contentType = props.ContentType
+	params.redirect = props.WebsiteRedirectLocation
+}
+
+// uploadStream uploads the content of a stream to a file
+func (fs *Fs) uploadStream(name string, body io.Reader, params *uploadParams) error {
+	release := fs.acquireUploadSlot()
+	defer release()
+
+	uploader := s3manager.NewUploader(fs.session)
+	uploader.Concurrency = 1
+	uploader.RequestOptions = append(uploader.RequestOptions,
+		fs.faultOption(), fs.requestLimitOption(), fs.credentialsOption(), fs.requestHeadersOption(),
+		fs.encryptionOption(), fs.endpointsOption(), fs.serverlessOption(),
+		fs.partRetryOption(name), fs.metricsOption(), fs.residencyOption(),
+		fs.mrapOption())
+
+	if params.retries != nil {
+		uploader.RequestOptions = append(uploader.RequestOptions, countRetries(params.retries))
+	}
+
+	var fenced error
+	if params.fencingToken != nil {
+		uploader.RequestOptions = append(uploader.RequestOptions, fs.fencingOption(name, *params.fencingToken, &fenced))
+	}
+
+	if fs.serverless != nil {
+		uploader.PartSize = fs.serverless.partSize
+	}
+
+	input := &s3manager.UploadInput{
+		Bucket:   aws.String(fs.bucket),
+		Key:      aws.String(name),
+		Body:     body,
+		Tagging:  params.tagging,
+		Metadata: params.metadata,
+	}
+
+	if fs.FileProps != nil {
+		applyFileWriteProps(input, fs.FileProps)
+	}
+
+	if params.acl != nil {
+		input.ACL = params.acl
+	}
+
+	if params.redirect != nil {
+		input.WebsiteRedirectLocation = params.redirect
+	}
+
+	if params.cacheControl != nil {
+		input.CacheControl = params.cacheControl
+	}
+
+	if params.contentType != nil {
+		input.ContentType = params.contentType
+	}
+
+	// If no Content-Type was specified, we'll guess one
+	if input.ContentType == nil {
+		input.ContentType = aws.String(mime.TypeByExtension(filepath.Ext(name)))
+	}
+
+	if fs.BeforeUpload != nil {
+		fs.BeforeUpload(name, input)
+	}
+
+	done := fs.withBudget(uploader, input)
+	defer done()
+
+	_, err := uploader.UploadWithContext(fs.requestContext(), input)
+	if fenced != nil {
+		return fenced
+	}
+
+	return contextError(fs.requestContext(), err)
+}
+
+// contentWriter returns the writer of the content of a file to the stream of its uploads, which transforms, scans
+// and checks it against the content policy
+func (fs *Fs) contentWriter(name string, stream io.WriteCloser, pipes []io.Closer,
+	transforms []*WriteTransform) (io.WriteCloser, error) {
+	stream, err := transformWrite(name, stream, transforms)
+	if err != nil {
+		return nil, err
+	}
+
+	// The scanned and checked content is the one written, before its transforms
+	if fs.Scan != nil {
+		if stream, err = fs.scanWriter(name, stream, pipes); err != nil {
+			return nil, err
+		}
+	}
+
+	if fs.ContentPolicy != nil {
+		stream = &policyWriteCloser{WriteCloser: stream, name: name, policy: fs.ContentPolicy, pipes: pipes}
+	}
+
+	return stream, nil
+}
+
+// abortUploads fails the uploads of the pipes of a write stream that couldn't be opened, and waits for them
+func abortUploads(pipes []io.Closer, uploadErrs chan error, err error) {
+	failPipes(pipes, err)
+
+	for range pipes {
+		<-uploadErrs
+	}
+}
+
+// failPipes fails the uploads of the pipes of a write stream, which aborts them before anything is written
+func failPipes(pipes []io.Closer, err error) {
+	for _, pipe := range pipes {
+		_ = pipe.(*io.PipeWriter).CloseWithError(err)
+	}
+}
+
+// streamError keeps the first error of the uploads of a write stream
+type streamError struct {
+	mu  sync.Mutex
+	err error
+}
+
+// set records an error, it tells if it's the first one
+func (e *streamError) set(err error) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err != nil {
+		return false
+	}
+
+	e.err = err
+
+	return true
+}
+
+// get returns the first error, nil if there is none
+func (e *streamError) get() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.err
+}
+
+// gatedReader holds the end of a stream until wait returns, failing the stream if it returns an error
+type gatedReader struct {
+	io.Reader
+	wait func() error
+}
+
+func (g *gatedReader) Read(p []byte) (int, error) {
+	n, err := g.Reader.Read(p)
+	if errors.Is(err, io.EOF) {
+		if errWait := g.wait(); errWait != nil {
+			return n, errWait
+		}
+	}
+
+	return n, err
+}
+
+// multiWriteCloser writes to all its writers and closes all its closers
+type multiWriteCloser struct {
+	io.Writer
+	closers []io.Closer
+}
+
+func (m *multiWriteCloser) Close() error {
+	var err error
+
+	for _, c := range m.closers {
+		if errClose := c.Close(); errClose != nil && err == nil {
+			err = errClose
+		}
+	}
+
+	return err
+}
+
+// bufferedWriteCloser aggregates the small writes before handing them to the uploads, the other writes are
+// performed directly
+type bufferedWriteCloser struct {
+	*bufio.Writer
+	direct    io.WriteCloser
+	smallSize int // smallSize is the size from which writes aren't aggregated
+}
+
+func (b *bufferedWriteCloser) Write(p []byte) (int, error) {
+	if len(p) < b.smallSize {
+		return b.Writer.Write(p)
+	}
+
+	if err := b.Flush(); err != nil {
+		return 0, err
+	}
+
+	return b.direct.Write(p)
+}
+
+func (b *bufferedWriteCloser) Close() error {
+	errFlush := b.Flush()
+
+	// The uploads have to be ended whatever happens
+	if err := b.direct.Close(); err != nil {
+		return err
+	}
+
+	return errFlush
+}
+
+func (f *File) openReadStream(startAt int64) error {
+	if f.streamRead != nil {
+		return ErrAlreadyOpened
+	}
+
+	if f.transforms != nil {
+		return f.openTransformedStream(startAt)
+	}
+
+	// The content of the inlined files was read with their index
+	if info, ok := f.cachedInfo.(FileInfo); ok && info.inline != nil && f.versionID == "" {
+		if startAt > info.sizeInBytes {
+			startAt = info.sizeInBytes
+		}
+
+		f.streamReadOffset = startAt
+		f.streamRead = io.NopCloser(bytes.NewReader(info.inline[startAt:]))
+		return nil
+	}
+
+	// Like with os.File, reading at the end of the file or beyond returns io.EOF. S3 rejects the ranges starting
+	// there, and an empty file doesn't need to be requested at all.
+	if f.cachedInfo != nil && startAt >= f.cachedInfo.Size() {
+		f.streamReadOffset = startAt
+		f.streamRead = io.NopCloser(bytes.NewReader(nil))
+		return nil
+	}
+
+	// The large files are downloaded in parallel parts
+	if f.fs.DownloadConcurrency > 1 && f.cachedInfo != nil && f.cachedInfo.Size()-startAt > f.fs.downloadPartSize() {
+		if parallel, err := f.openParallelStream(startAt); parallel || err != nil {
+			return err
+		}
+
+		return f.openTransformedStream(startAt)
+	}
+
+	var streamRange *string
+
+	if startAt > 0 {
+		streamRange = aws.String(fmt.Sprintf("bytes=%d-%d", startAt, f.cachedInfo.Size()))
+		f.counters.rangedRequests++
+	}
+
+	resp, err := f.fs.s3API.GetObjectWithContext(f.fs.requestContext(), f.getInput(streamRange),
+		countRetries(&f.counters.retries))
+	if err != nil {
+		return err
+	}
+
+	// The transforms are found when the file is opened, the transformed files are then always read from the start
+	if f.transforms = f.fs.readTransforms(f.name, resp.Metadata); f.transforms != nil {
+		if startAt > 0 {
+			_ = resp.Body.Close()
+
+			return f.openTransformedStream(startAt)
+		}
+
+		stream, errTransform := transformRead(f.name, f.fs.withPrefetch(resp.Body), f.transforms)
+		if errTransform != nil {
+			return errTransform
+		}
+
+		f.streamReadOffset = startAt
+		f.streamRead = f.fs.withReadAhead(stream)
+		return nil
+	}
+
+	f.streamReadOffset = startAt
+	f.streamRead = f.fs.withReadAhead(f.fs.withPrefetch(resp.Body))
+	return nil
+}
+
+// getInput returns the input of the requests reading the file
+func (f *File) getInput(streamRange *string) *s3.GetObjectInput {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(f.fs.bucket),
+		Key:    aws.String(f.name),
+		Range:  streamRange,
+	}
+
+	if f.versionID != "" {
+		input.VersionId = aws.String(f.versionID)
+	}
+
+	return input
+}
+
+// WriteAt writes len(p) bytes to the file starting at byte offset off.
+// It returns the number of bytes written and an error, if any.
+// WriteAt returns a non-nil error when n != len(p).
+func (f *File) WriteAt(p []byte, off int64) (n int, err error) {
+	_, err = f.Seek(off, 0)
+	if err != nil {
+		return
+	}
+	n, err = f.Write(p)
+	return
+}
@@ -0,0 +1,45 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// FileStats are the statistics of the operations performed through a file handle, see File.Stats
+type FileStats struct {
+	BytesRead      int64 // BytesRead is the number of bytes read from the file
+	BytesWritten   int64 // BytesWritten is the number of bytes written to the file
+	RangedRequests int64 // RangedRequests is the number of requests reading the file from an offset
+	Reopens        int64 // Reopens is the number of times the read stream was reopened by a seek
+	Retries        int64 // Retries is the number of retries of the requests reading or uploading the file
+}
+
+// Stats returns the statistics of the operations performed through the file handle so far, which helps to diagnose
+// slow transfers. The retries of the upload are only known once the requests completed.
+func (f *File) Stats() FileStats {
+	return FileStats{
+		BytesRead:      f.bytesRead,
+		BytesWritten:   f.streamWriteSize,
+		RangedRequests: f.counters.rangedRequests,
+		Reopens:        f.reopens,
+		Retries:        atomic.LoadInt64(&f.counters.retries),
+	}
+}
+
+// fileCounters are the counters of the requests of a file. They're kept apart from the file, for the requests running
+// in the background to update them without referencing it, see OnOrphanedFile.
+type fileCounters struct {
+	rangedRequests int64 // rangedRequests is the number of requests reading from an offset
+	retries        int64 // retries is the number of retries of the requests, updated atomically
+}
+
+// countRetries counts the retries of the requests in a counter
+func countRetries(counter *int64) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			atomic.AddInt64(counter, int64(r.RetryCount))
+		})
+	}
+}
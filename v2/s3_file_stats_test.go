@@ -0,0 +1,51 @@
+package s3
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStats(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/file", "Hello world !")
+
+	writer, err := fs.Create("/written")
+	req.NoError(err)
+
+	_, err = writer.WriteString("content")
+	req.NoError(err)
+	req.NoError(writer.Close())
+	req.Equal(int64(7), writer.(*File).Stats().BytesWritten)
+
+	faults := NewFaultInjector(0)
+	fs.WithFaultInjector(faults)
+
+	defer fs.WithFaultInjector(nil)
+
+	// Some attempts fail and are retried
+	faults.Set("GetObject", &Fault{StatusCode: http.StatusServiceUnavailable, Code: "SlowDown", Rate: 0.5})
+
+	file, err := fs.Open("/file")
+	req.NoError(err)
+
+	defer func() { req.NoError(file.Close()) }()
+
+	_, err = file.Seek(6, io.SeekStart)
+	req.NoError(err)
+
+	content, err := io.ReadAll(file)
+	req.NoError(err)
+	req.Equal("world !", string(content))
+
+	stats := file.(*File).Stats()
+	req.Equal(int64(7), stats.BytesRead)
+	req.Equal(int64(1), stats.RangedRequests)
+	req.Equal(int64(1), stats.Reopens)
+	req.Positive(stats.Retries)
+	req.Equal(faults.Injected(), stats.Retries)
+}
@@ -0,0 +1,78 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"os"
+	"path"
+	"time"
+)
+
+// FileInfo implements os.FileInfo for a file in S3.
+type FileInfo struct {
+	modTime     time.Time
+	name        string
+	fullPath    string // fullPath is the absolute path of the file
+	directory   bool
+	chunked     bool        // chunked is set for the manifests of the files written through a ChunkedFs
+	mode        os.FileMode // mode is the stored permissions of the file, if any
+	owner       *Owner      // owner is the stored owner of the file, if any
+	inline      []byte      // inline is the content of an inlined file, see InlineThreshold
+	sizeInBytes int64
+}
+
+// NewFileInfo creates file cachedInfo. The name is the path of the file, Name returning its base name.
+func NewFileInfo(name string, directory bool, sizeInBytes int64, modTime time.Time) FileInfo {
+	return FileInfo{
+		name:        path.Base(name),
+		fullPath:    path.Clean("/" + name),
+		directory:   directory,
+		sizeInBytes: sizeInBytes,
+		modTime:     modTime,
+	}
+}
+
+// Name provides the base name of the file, the root being named "/" like with os.Stat.
+func (fi FileInfo) Name() string {
+	return fi.name
+}
+
+// FullPath provides the absolute path of the file, to which the names of the entries of a directory can be joined
+func (fi FileInfo) FullPath() string {
+	return fi.fullPath
+}
+
+// Size provides the length in bytes for a file.
+func (fi FileInfo) Size() int64 {
+	return fi.sizeInBytes
+}
+
+// Mode provides the file mode bits. For a file in S3 this defaults to
+// 664 for files, 775 for directories.
+// The permissions stored in POSIX metadata mode are returned instead.
+func (fi FileInfo) Mode() os.FileMode {
+	if fi.mode != 0 {
+		return fi.mode
+	}
+	if fi.directory {
+		return 0755
+	}
+	return 0664
+}
+
+// ModTime provides the last modification time.
+func (fi FileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+// IsDir provides the abbreviation for Mode().IsDir()
+func (fi FileInfo) IsDir() bool {
+	return fi.directory
+}
+
+// Sys provides the underlying data source (can return nil), which is the *Owner stored by Chown if there is one
+func (fi FileInfo) Sys() interface{} {
+	if fi.owner != nil {
+		return fi.owner
+	}
+	return nil
+}
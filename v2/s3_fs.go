@@ -0,0 +1,909 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/spf13/afero"
+)
+
+// Fs is an FS object backed by S3.
+// It's used through the pointer returned by NewFs, all its methods and files sharing the same state, like the caches
+// and the metrics. The exported fields are its configuration, read by every operation: they must be set before the
+// Fs is used, it's then safe for concurrent use.
+type Fs struct {
+	FileProps *UploadedFileProperties // FileProps define the file properties we want to set for all new files
+	Mirror    *Fs                     // Mirror is a secondary Fs to which all the file changes are also applied
+	// Replicator asynchronously replicates all the changes to other file systems, see NewReplicator
+	Replicator *Replicator
+	// Journal records the Rename and RemoveAll operations so that they can be recovered after a crash
+	Journal Journal
+	// TempFileAutoDelete removes the files created with TempFile that are closed without having been written
+	TempFileAutoDelete bool
+	// WriteBufferSize is the size of the buffer aggregating the small writes of the files,
+	// DefaultWriteBufferSize if 0, no buffer is used if negative
+	WriteBufferSize int
+	// WriteBehind makes Close return once the remaining buffered data is handed to the upload, the file being
+	// committed in the background. The errors are reported by Wait and Errors.
+	WriteBehind bool
+	// UploadIdleTimeout aborts the uploads of the files opened for writing and left without any write for longer,
+	// like the files that are never closed, whose uploads would keep running. Nothing is written, their writes and
+	// Close failing with ErrUploadAbandoned. They're never aborted if 0, see ActiveUploads.
+	UploadIdleTimeout time.Duration
+	// OnOrphanedFile is called with the file handles leaked without being closed, to find the leaks. The read handles
+	// are found once they're garbage collected, their stream being closed, and it's called by the finalizer goroutine.
+	// The write handles are tracked until they're closed: they're found when their upload is aborted by
+	// UploadIdleTimeout. The stack of the opening of each file is captured, which slows the openings down.
+	OnOrphanedFile func(file OrphanedFile)
+	// WriteBehindLimit is the maximum number of files committed in the background, Close waiting for one of them
+	// to be committed beyond, DefaultWriteBehindLimit if 0
+	WriteBehindLimit int
+	// InlineThreshold is the size up to which the files written with WriteFile or a BatchWriter are stored in the
+	// InlineIndexName object of their directory, instead of one object each, which saves requests and objects with
+	// millions of tiny files. Their properties aren't stored. Open, Stat, Readdir, Remove and Rename resolve them
+	// transparently, an object written for the same name hides an inlined file until it's removed. Experimental.
+	InlineThreshold int
+	// ReadAheadSize is the size of the read-ahead window of the read streams, which aggregates the small reads and
+	// performs the short forward seeks without new requests, like the ReadAt calls of parsers. 256KB to 4MB is a good
+	// range. There is no read-ahead if 0.
+	ReadAheadSize int
+	// PrefetchSize is the size of the data read ahead in the background from the read streams, while the reader
+	// processes the data already read, which keeps the transfer going with the slow readers, like the clients of
+	// an FTP server. It's read in chunks of up to PrefetchChunkSize. There is no prefetch if 0. The parallel
+	// downloads already prefetch their parts.
+	PrefetchSize int
+	// DownloadConcurrency is the number of parts of the files downloaded in parallel by the sequential reads, like
+	// with s3manager.Downloader, to saturate the bandwidth when reading large files. The files bigger than a part are
+	// read with ranged requests of DownloadPartSize, up to DownloadConcurrency parts being held in memory ahead of
+	// the reads. The files are read with a single stream if it's 0 or 1.
+	DownloadConcurrency int
+	// DownloadPartSize is the size of the parts of the parallel downloads, DefaultDownloadPartSize if 0
+	DownloadPartSize int64
+	// MultipartCopyThreshold is the size above which Rename copies the files with a multipart copy, which S3 requires
+	// for the files bigger than MaxCopyObjectSize. It's MaxCopyObjectSize if 0 or bigger.
+	MultipartCopyThreshold int64
+	// CopyPartSize is the size of the parts of the multipart copies of Rename, and of CopyDir when its PartSize is 0.
+	// It's DefaultCopyPartSize if 0, or the CopyPartSize of the serverless mode, and at least 5 MiB.
+	CopyPartSize int64
+	// PreloadTTL is the time the listing loaded by Preload is used, DefaultPreloadTTL if 0
+	PreloadTTL time.Duration
+	// AferoCompat makes the Fs behave like the file systems the afero wrappers, like CacheOnReadFs and BasePathFs, are
+	// designed for: Chtimes and Chown succeed without changing anything, O_RDWR is accepted with O_TRUNC or O_EXCL
+	// and writes the file like O_WRONLY, and the files being written can be stated and read, without any content.
+	AferoCompat bool
+	// SkipDirFallback makes Stat consider that the names without a trailing slash can only be files, which saves
+	// a listing request when a file doesn't exist
+	SkipDirFallback bool
+	// PosixMetadata stores the permissions of the created files in their metadata, Stat returns them
+	PosixMetadata bool
+	// PermissionsACL applies the ACL matching the permissions of the created files, see Chmod
+	PermissionsACL bool
+	// SkipBlockedACLs ignores the ACLs blocked by the object ownership or public access block settings of the bucket,
+	// instead of failing with an *ACLBlockedError. The permissions are then only stored in POSIX metadata mode.
+	SkipBlockedACLs bool
+	// ListPageSize is the number of entries requested per listing page when reading whole directories,
+	// adapted to the size of the directory if 0
+	ListPageSize int
+	// DirStrategy defines how directories are kept consistent when their files are removed
+	DirStrategy DirStrategy
+	// DirGracePeriod is the time emptied directories still exist with DirStrategyGracePeriod,
+	// DefaultDirGracePeriod if 0
+	DirGracePeriod time.Duration
+	// DirMarkerSuffix is the suffix of the directory markers created by Mkdir, "/" if empty. FolderMarkerSuffix
+	// creates markers compatible with EMR.
+	DirMarkerSuffix string
+	// DirMarkerSuffixes are other suffixes of the keys recognized as directory markers, like FolderMarkerSuffix.
+	// Their objects are listed as directories.
+	DirMarkerSuffixes []string
+	// HideHadoopArtifacts hides the _SUCCESS, _temporary and .spark-staging entries created by the Hadoop and Spark
+	// committers from the listings
+	HideHadoopArtifacts bool
+	// VersionsDir exposes the versions of the files of a versioned bucket in the read-only VersionsDirName virtual
+	// directory, listed at the root, so that generic file browsers can navigate the history of the files
+	VersionsDir bool
+	// BeforeUpload is called before every upload of a file with the upload input, which can be modified to set
+	// any S3 field, like grants, website redirect location or checksums. The Body must not be changed.
+	BeforeUpload func(name string, input *s3manager.UploadInput)
+	// BeforeGet is called before every GetObject request with its input, which can be modified to set any S3 field,
+	// like the response header overrides or the requester pays flag. The Key and Range must not be changed.
+	BeforeGet func(input *s3.GetObjectInput)
+	// ReadTransforms are applied in order to the content of the matching files read by Open, ReadFile, ReadJSON and
+	// ReadYAML, like decompressions or decryptions. The transformed files are read from the start by the backward
+	// seeks, and can't be seeked from their end. NewReaderAt and ServeContent read the stored content.
+	ReadTransforms []*ReadTransform
+	// WriteTransforms are applied in order to the content of the matching files written by Create, OpenFile,
+	// WriteFile, WriteJSON, WriteYAML and PutFile, like compressions or encryptions. Their content is transformed as
+	// it's streamed, or in memory for the single request writes.
+	WriteTransforms []*WriteTransform
+	// Scan scans the content of the files written by Create, OpenFile, WriteFile, WriteJSON, WriteYAML and PutFile
+	// before they're visible, like an antivirus. It reads the content while it's streamed, and the uploads are only
+	// completed once it accepted it. The rejected files aren't written, Close or the write failing with
+	// a *RejectedError, while the files created by Create remain empty.
+	Scan ScanFunc
+	// ContentPolicy restricts the extensions and the content types of the files written by Create, OpenFile,
+	// WriteFile, WriteJSON, WriteYAML and PutFile, which fail with a *PolicyError
+	ContentPolicy *ContentPolicy
+	// ScanSpooled makes Scan read a complete copy of the streamed content, spooled to a temporary file, instead of
+	// reading it while it's written, for the scanners requiring whole files
+	ScanSpooled bool
+	// BeforeHead is called before every HeadObject request with its input, like BeforeGet
+	BeforeHead func(input *s3.HeadObjectInput)
+	// ExpectedBucketOwner is the account ID expected to own the bucket, the requests fail with a 403 error otherwise.
+	// It protects multi-tenant services against writing to buckets of other accounts.
+	ExpectedBucketOwner string
+	// RequestPayer makes the requests acknowledge that the requester pays for them, required with the buckets
+	// configured with Requester Pays
+	RequestPayer bool
+	// RequestHeaders are added to all the requests, like tracing or cost allocation headers
+	RequestHeaders http.Header
+	// Encryption defines the server-side encryption of the files. A customer-provided key is sent with all the
+	// requests reading, writing or copying the files, including the copies of Rename and CopyDir.
+	Encryption *Encryption
+	// ContentMD5 sends the MD5 sum of the files written with a single request, like with PutFile or WriteFile,
+	// for S3 to check their integrity
+	ContentMD5 bool
+	// MFA returns the serial number and the current code of the MFA device, separated by a space, required to
+	// permanently delete the versions of the files of the buckets with MFA delete enabled, see DeleteVersion
+	MFA func() (string, error)
+	// RetentionAware checks the object lock retention and legal hold of the files removed by Remove, RemoveAll,
+	// RemoveAllWithOptions and DeleteVersion on the buckets with object lock enabled. The removals fail with a
+	// *RetainedError before deleting anything, instead of hiding the retained files behind delete markers or failing
+	// with an AccessDenied error in the middle of a removal.
+	RetentionAware bool
+	// UploadPartRetries is the maximum number of retries of each uploaded part, the SDK default if 0
+	UploadPartRetries int
+	// OnPartRetry is called once a part that had to be retried was sent, or failed, err being the final error
+	OnPartRetry func(name string, part int64, retries int, err error)
+	// FailoverThreshold is the number of consecutive authentication failures switching to the next credentials,
+	// DefaultFailoverThreshold if 0, see WithFallbackCredentials
+	FailoverThreshold int
+	// OnCredentialsFailover is called when the requests switch from the from credentials to the to ones, 0 being
+	// the credentials of the session, err being the last authentication error. It's useful for alerting.
+	OnCredentialsFailover func(from, to int, err error)
+	// EndpointCooldown is the time an endpoint failing with a network or server error isn't used,
+	// DefaultEndpointCooldown if 0, see WithEndpoints
+	EndpointCooldown time.Duration
+	metrics          *Metrics
+	uploadSlots      chan struct{}        // uploadSlots limits the number of concurrent uploads
+	requestSlots     chan struct{}        // requestSlots limits the number of concurrent requests
+	budget           *memoryBudget        // budget limits the memory used by the uploads
+	faults           *FaultInjector       // faults are injected in the requests, for tests
+	dirs             *dirState            // dirs is the state of the directory strategy
+	acls             *aclSettings         // acls are the bucket settings restricting the ACLs
+	failover         *credentialsFailover // failover switches the credentials when they fail
+	endpoints        *endpointPool        // endpoints balance the requests between several endpoints
+	preload          *preloadState        // preload is the listing loaded by Preload
+	serverless       *serverlessMode      // serverless is the serverless mode, see WithServerlessMode
+	writing          *fileSet             // writing are the files being written, see FlushAll
+	behind           *writeBehind         // behind commits the files closed in write-behind mode
+	residency        *residencyState      // residency restricts the regions of the buckets, see WithAllowedRegions
+	objectLock       *objectLockState     // objectLock tells if object lock is enabled on the bucket
+	coherence        *coherenceState      // coherence tracks the changes announced by the instances, see WithCoherence
+	ctx              context.Context      // ctx is the context of the requests, see WithContext
+	session          *session.Session     // Session config
+	s3API            *s3.S3
+	bucket           string                  // Bucket name
+	mrap             *multiRegionAccessPoint // mrap is the Multi-Region Access Point used as bucket, if any
+}
+
+// UploadedFileProperties defines all the set properties applied to future files
+type UploadedFileProperties struct {
+	ACL          *string // ACL defines the right to apply
+	CacheControl *string // CacheControl defines the Cache-Control header
+	ContentType  *string // ContentType define the Content-Type header
+	// WebsiteRedirectLocation redirects the requests to the files to another object or URL when the bucket is
+	// configured as a website
+	WebsiteRedirectLocation *string
+}
+
+// NewFs creates a new Fs object writing files to a given S3 bucket.
+func NewFs(bucket string, session *session.Session) *Fs {
+	ap, errAccessPoint := parseAccessPoint(bucket)
+	if ap != nil {
+		// The access points are reached in their own region
+		session = session.Copy(&aws.Config{S3UseARNRegion: aws.Bool(true)})
+	}
+
+	s3Api := s3.New(session)
+	fs := &Fs{
+		bucket:     bucket,
+		session:    session,
+		s3API:      s3Api,
+		metrics:    &Metrics{},
+		dirs:       newDirState(),
+		acls:       &aclSettings{},
+		writing:    newFileSet(),
+		behind:     newWriteBehind(),
+		preload:    &preloadState{},
+		objectLock: &objectLockState{},
+		mrap:       parseMRAP(bucket),
+	}
+	s3Api.Handlers.Validate.PushFrontNamed(readHooks(fs))
+	s3Api.Handlers.Validate.PushFrontNamed(encryption(fs))
+	s3Api.Handlers.Build.PushBackNamed(requestHeaders(fs))
+	fs.mrap.install(&s3Api.Handlers)
+	if ap != nil || errAccessPoint != nil {
+		s3Api.Handlers.Validate.PushBackNamed(bucketARNValidator(ap, errAccessPoint))
+	}
+	fs.metrics.countRequests(&s3Api.Handlers)
+	return fs
+}
+
+// DefaultWriteBufferSize is the default size of the buffer aggregating the small writes
+const DefaultWriteBufferSize = 64 * 1024
+
+func (fs *Fs) writeBufferSize() int {
+	if fs.WriteBufferSize == 0 {
+		return DefaultWriteBufferSize
+	}
+
+	return fs.WriteBufferSize
+}
+
+// ErrNotImplemented is returned when this operation is not (yet) implemented
+var ErrNotImplemented = errors.New("not implemented")
+
+// ErrNotSupported is returned when this operations is not supported by S3
+var ErrNotSupported = errors.New("s3 doesn't support this operation")
+
+// ErrAlreadyOpened is returned when the file is already opened
+var ErrAlreadyOpened = errors.New("already opened")
+
+// ErrInvalidSeek is returned when the seek operation is not doable
+var ErrInvalidSeek = errors.New("invalid seek offset")
+
+// pathError wraps the error of an operation in an *os.PathError, like the os package does. The *os.PathError of the
+// inner operations take the name of the operation. The other errors of the os package are returned as they are, as
+// well as io.EOF which is compared directly by the callers.
+func pathError(op, name string, err error) error {
+	switch e := err.(type) { // nolint: errorlint
+	case nil, *os.LinkError, *os.SyscallError:
+		return err
+	case *os.PathError:
+		return &os.PathError{Op: op, Path: e.Path, Err: e.Err}
+	}
+
+	if err == io.EOF { // nolint: errorlint
+		return err
+	}
+
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// snapshot returns a copy of the Fs sharing its state, whose configuration isn't affected by the later changes of
+// the Fs, for the operations running in the background
+func (fs *Fs) snapshot() *Fs {
+	config := *fs
+	return &config
+}
+
+// Name returns the type of FS object this is: Fs.
+func (*Fs) Name() string { return "s3" }
+
+// Create a file.
+func (fs *Fs) Create(name string) (afero.File, error) {
+	file, err := fs.create(name)
+	return file, pathError("open", name, err)
+}
+
+func (fs *Fs) create(name string) (afero.File, error) {
+	if err := fs.checkWritable("open", name); err != nil {
+		return nil, err
+	}
+	if err := checkFileName(name); err != nil {
+		return nil, err
+	}
+	if err := fs.checkPolicyName(name); err != nil {
+		return nil, err
+	}
+	// It's faster to trigger an explicit empty put object than opening a file for write, closing it and re-opening it
+	if err := fs.createEmpty(name, 0666); err != nil {
+		return nil, err
+	}
+
+	file, err := fs.OpenFile(name, os.O_WRONLY, 0666)
+	if err != nil {
+		return file, err
+	}
+
+	// Create(), like all of S3, is eventually consistent.
+	// To protect against unexpected behavior, have this method
+	// wait until S3 reports the object exists.
+	return file, fs.s3API.WaitUntilObjectExists(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	})
+}
+
+// createEmpty creates an empty file on the Fs and its mirror
+func (fs *Fs) createEmpty(name string, perm os.FileMode) error {
+	params := fs.permParams(perm)
+
+	if err := fs.putEmpty(name, &params); err != nil {
+		return err
+	}
+
+	if err := fs.fileWritten(name); err != nil {
+		return err
+	}
+
+	if fs.Mirror != nil {
+		return fs.Mirror.putEmpty(name, &params)
+	}
+
+	return nil
+}
+
+func (fs *Fs) putEmpty(name string, params *uploadParams) error {
+	return fs.putObject(name, bytes.NewReader([]byte{}), 0, params)
+}
+
+// putObject uploads a seekable body of a known size with a single request
+func (fs *Fs) putObject(name string, body io.ReadSeeker, size int64, params *uploadParams) error {
+	req := &s3.PutObjectInput{
+		Bucket:        aws.String(fs.bucket),
+		Key:           aws.String(name),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		Metadata:      params.metadata,
+		Tagging:       params.tagging,
+	}
+
+	if fs.FileProps != nil {
+		applyFileCreateProps(req, fs.FileProps)
+	}
+
+	if params.acl != nil {
+		req.ACL = params.acl
+	}
+
+	if params.redirect != nil {
+		req.WebsiteRedirectLocation = params.redirect
+	}
+
+	if params.cacheControl != nil {
+		req.CacheControl = params.cacheControl
+	}
+
+	if params.contentType != nil {
+		req.ContentType = params.contentType
+	}
+
+	// If no Content-Type was specified, we'll guess one
+	if req.ContentType == nil {
+		req.ContentType = aws.String(mime.TypeByExtension(filepath.Ext(name)))
+	}
+
+	if fs.BeforeUpload != nil {
+		input := &s3manager.UploadInput{}
+		awsutil.Copy(input, req)
+		fs.BeforeUpload(name, input)
+
+		// The body can't be copied back as it's not seekable in the upload input
+		req = &s3.PutObjectInput{}
+		awsutil.Copy(req, input)
+		req.Body = body
+		req.ContentLength = aws.Int64(size)
+	}
+
+	if fs.ContentMD5 {
+		sum, err := contentMD5(body)
+		if err != nil {
+			return err
+		}
+
+		req.ContentMD5 = aws.String(sum)
+	}
+
+	_, err := fs.s3API.PutObjectWithContext(fs.requestContext(), req, conditionalWrite(params))
+
+	return err
+}
+
+// Mkdir makes a directory in S3.
+func (fs *Fs) Mkdir(name string, perm os.FileMode) error {
+	file, err := fs.OpenFile("/"+fs.dirMarkerKey(name), os.O_CREATE, perm)
+	if err == nil {
+		err = file.Close()
+	}
+	return pathError("mkdir", name, err)
+}
+
+// MkdirAll creates a directory and all parent directories if necessary.
+func (fs *Fs) MkdirAll(path string, perm os.FileMode) error {
+	return fs.Mkdir(path, perm)
+}
+
+// Open a file for reading.
+func (fs *Fs) Open(name string) (afero.File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0777)
+}
+
+// OpenFile opens a file. As S3 objects can only be written as a whole, the supported flags are:
+//   - O_RDONLY: the file is read, O_CREATE creates it empty if it doesn't exist
+//   - O_WRONLY: the file is replaced by the written content, created or not, O_TRUNC being implied
+//   - O_EXCL, with O_CREATE: the file must not exist, CreateExclusive checks it atomically
+//
+// O_RDWR and O_APPEND return an *UnsupportedFlagError, see AferoCompat. The perm is stored with the created files in
+// POSIX metadata mode. A name with a trailing slash must be a directory: writing it fails with syscall.EISDIR and
+// opening a file with it fails with syscall.ENOTDIR. Like all the operations of the Fs and its files, its errors are
+// *os.PathError, or *os.LinkError for Rename.
+func (fs *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	file, err := fs.openFile(name, flag, perm)
+	if err != nil {
+		// A file returned with an error still has to be closed
+		return file, pathError("open", name, err)
+	}
+
+	return file, nil
+}
+
+func (fs *Fs) openFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	flag = fs.compatFlag(flag)
+
+	if err := checkOpenFlag(flag); err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if rel, ok := fs.versionsPath(name); ok {
+		return fs.openVersionsPath(name, rel, flag)
+	}
+
+	file := NewFile(fs, name)
+
+	if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+		if _, err := fs.Stat(name); err == nil {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	// We either write
+	if flag&os.O_WRONLY != 0 {
+		if err := fs.checkPolicyName(name); err != nil {
+			return nil, err
+		}
+
+		params, err := fs.writePermParams(name, perm)
+		if err != nil {
+			return nil, err
+		}
+
+		file.upload = params
+
+		return file, file.openWriteStream()
+	}
+
+	info, err := file.Stat()
+
+	// Or read a file we might have to create
+	if errors.Is(err, os.ErrNotExist) && flag&os.O_CREATE != 0 {
+		if err = fs.createEmpty(name, perm); err == nil {
+			if fs.Replicator != nil {
+				fs.Replicator.enqueue(ReplicationWrite, name)
+			}
+
+			info, err = file.Stat()
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return file, nil
+	}
+
+	return file, file.openReadStream(0)
+}
+
+// UnsupportedFlagError is returned by OpenFile for the flags S3 can't support, it matches ErrNotSupported
+type UnsupportedFlagError struct {
+	Reason string // Reason why the flag isn't supported
+	Flag   int    // Flag that isn't supported
+}
+
+func (e *UnsupportedFlagError) Error() string {
+	return fmt.Sprintf("unsupported open flag %#x: %s", e.Flag, e.Reason)
+}
+
+// Unwrap makes the error match ErrNotSupported
+func (e *UnsupportedFlagError) Unwrap() error {
+	return ErrNotSupported
+}
+
+func checkOpenFlag(flag int) error {
+	// Reading and writing is technically supported but can't lead to anything that makes sense
+	if flag&os.O_RDWR != 0 {
+		return &UnsupportedFlagError{Flag: os.O_RDWR, Reason: "files can't be read and written at the same time"}
+	}
+
+	// Appending is not supported by S3. It's do-able though by:
+	// - Copying the existing file to a new place (for example $file.previous)
+	// - Writing a new file, streaming the content of the previous file in it
+	// - Writing the data you want to append
+	// Quite network intensive, if used in abondance this would lead to terrible performances.
+	if flag&os.O_APPEND != 0 {
+		return &UnsupportedFlagError{Flag: os.O_APPEND, Reason: "files can only be written as a whole"}
+	}
+
+	return nil
+}
+
+// Remove a file, or an empty directory like os.Remove. Removing a directory containing any file or directory fails
+// with syscall.ENOTEMPTY.
+func (fs *Fs) Remove(name string) error {
+	return pathError("remove", name, fs.remove(name))
+}
+
+func (fs *Fs) remove(name string) error {
+	if err := fs.checkWritable("remove", name); err != nil {
+		return err
+	}
+	info, err := fs.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fs.removeDir(name)
+	}
+	if err := fs.checkRetention(name, ""); err != nil {
+		return err
+	}
+	if fs.InlineThreshold > 0 {
+		if err := fs.removeInline(name); err != nil {
+			return err
+		}
+	}
+	return fs.forceRemove(name)
+}
+
+// forceRemove doesn't error if a file does not exist.
+func (fs *Fs) forceRemove(name string) error {
+	_, err := fs.s3API.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		return err
+	}
+	fs.objectRemoved(name)
+	if fs.Mirror != nil {
+		return fs.Mirror.forceRemove(name)
+	}
+	return nil
+}
+
+// objectRemoved updates the caches and the replication of a removed object
+func (fs *Fs) objectRemoved(name string) {
+	fs.fileRemoved(name)
+	if fs.Replicator != nil {
+		fs.Replicator.enqueue(ReplicationRemove, name)
+	}
+}
+
+// renameCopy copies a renamed file, with a multipart copy if it's bigger than MultipartCopyThreshold
+func (fs *Fs) renameCopy(oldname, newname string) error {
+	head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(oldname),
+	})
+	if err != nil {
+		return err
+	}
+	return fs.copyObject(oldname, newname, aws.Int64Value(head.ContentLength), &CopyOptions{
+		MultipartThreshold: fs.MultipartCopyThreshold,
+	})
+}
+
+// RemoveAll removes a path.
+func (fs *Fs) RemoveAll(name string) error {
+	return pathError("removeall", name, fs.removeAllJournaled(fs.requestContext(), name, nil))
+}
+
+// Rename a file.
+// There is no method to directly rename an S3 object, so the Rename
+// will copy the file to an object with the new name and then delete
+// the original. The files bigger than MultipartCopyThreshold are copied by parts of CopyPartSize, which allows
+// renaming the files over 5GB. Renaming a file to itself does nothing, renaming it below itself or to one of its
+// parent directories returns a *RenameCollisionError.
+func (fs *Fs) Rename(oldname, newname string) error {
+	if done, err := fs.checkRename(oldname, newname); done {
+		return err
+	}
+	if err := fs.checkWritable("rename", oldname); err != nil {
+		return renameError(oldname, newname, err)
+	}
+	if err := fs.checkWritable("rename", newname); err != nil {
+		return renameError(oldname, newname, err)
+	}
+	entry, err := fs.journalBegin(JournalOpRename, oldname, newname)
+	if err != nil {
+		return renameError(oldname, newname, err)
+	}
+	return renameError(oldname, newname, fs.journalEnd(entry, fs.rename(oldname, newname)))
+}
+
+func (fs *Fs) rename(oldname, newname string) error {
+	err := fs.renameCopy(oldname, newname)
+	if isNotFound(err) && fs.InlineThreshold > 0 {
+		if renamed, errInline := fs.renameInline(oldname, newname); renamed || errInline != nil {
+			return errInline
+		}
+	}
+	if isNotFound(err) {
+		if info, errStat := fs.Stat(oldname); errStat == nil && info.IsDir() {
+			return ErrNotSupported
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := fs.fileWritten(newname); err != nil {
+		return err
+	}
+	if fs.Replicator != nil {
+		fs.Replicator.enqueue(ReplicationWrite, newname)
+	}
+	if fs.Mirror != nil {
+		if err := fs.Mirror.renameCopy(oldname, newname); err != nil {
+			return err
+		}
+	}
+	return fs.forceRemove(oldname)
+}
+
+// Stat returns a FileInfo describing the named file.
+// If there is an error, it will be of type *os.PathError.
+// A name with a trailing slash can only be a directory. Other names are looked up as files first, and then as
+// directories unless SkipDirFallback is set.
+func (fs *Fs) Stat(name string) (os.FileInfo, error) {
+	if rel, ok := fs.versionsPath(name); ok {
+		return fs.statVersionsPath(name, rel)
+	}
+	if info, ok := fs.statPreloaded(name); ok {
+		return info, nil
+	}
+	if strings.HasSuffix(name, "/") {
+		return fs.statDirName(name)
+	}
+	out, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		var errRequestFailure awserr.RequestFailure
+		if errors.As(err, &errRequestFailure) {
+			if errRequestFailure.StatusCode() == 404 {
+				if fs.InlineThreshold > 0 {
+					if info, errInline := fs.statInline(name); info != nil || errInline != nil {
+						return info, errInline
+					}
+				}
+				if fs.SkipDirFallback {
+					return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+				}
+				statDir, errStat := fs.statDirectory(name)
+				return statDir, errStat
+			}
+		}
+		return nil, &os.PathError{
+			Op:   "stat",
+			Path: name,
+			Err:  err,
+		}
+	}
+	info := NewFileInfo(name, false, aws.Int64Value(out.ContentLength), aws.TimeValue(out.LastModified))
+	if info.mode, err = fs.storedMode(name, out.Metadata); err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+	info.owner = parseOwnerMetadata(out.Metadata)
+	return info, nil
+}
+
+func (fs *Fs) statDirectory(name string) (os.FileInfo, error) {
+	nameClean := path.Clean("/" + name)
+	// The root of the bucket always exists
+	if nameClean == "/" {
+		return NewFileInfo("/", true, 0, time.Unix(0, 0)), nil
+	}
+	// Any object below the directory, including its marker, makes it exist. No delimiter is used as some
+	// S3 implementations don't list the marker of a directory when a delimiter is specified.
+	out, err := fs.s3API.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(fs.bucket),
+		Prefix:  aws.String(strings.TrimPrefix(nameClean, "/") + "/"),
+		MaxKeys: aws.Int64(1),
+	})
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "stat",
+			Path: name,
+			Err:  err,
+		}
+	}
+	if aws.Int64Value(out.KeyCount) == 0 || len(out.Contents) == 0 {
+		if exists, errMarker := fs.hasSuffixMarker(nameClean); errMarker != nil {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: errMarker}
+		} else if exists || fs.inGracePeriod(nameClean) {
+			return NewFileInfo(nameClean, true, 0, time.Unix(0, 0)), nil
+		}
+		return nil, &os.PathError{
+			Op:   "stat",
+			Path: name,
+			Err:  os.ErrNotExist,
+		}
+	}
+	info := NewFileInfo(nameClean, true, 0, time.Unix(0, 0))
+	// The permissions of a directory are stored on its marker
+	if marker := aws.StringValue(out.Contents[0].Key); (fs.PosixMetadata || fs.PermissionsACL) &&
+		marker == aws.StringValue(out.Prefix) {
+		if info.mode, info.owner, err = fs.markerPerms(marker); err != nil {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+		}
+	}
+	return info, nil
+}
+
+// Chmod doesn't exists in S3 but could be implemented by analyzing ACLs.
+// The "other" permissions are converted to an ACL, and the permissions are also stored in POSIX metadata mode.
+// When the ACL is blocked by the settings of the bucket, an *ACLBlockedError is returned before any change, unless
+// SkipBlockedACLs is set.
+func (fs *Fs) Chmod(name string, mode os.FileMode) error {
+	return pathError("chmod", name, fs.chmod(name, mode))
+}
+
+func (fs *Fs) chmod(name string, mode os.FileMode) error {
+	if err := fs.checkWritable("chmod", name); err != nil {
+		return err
+	}
+	if err := fs.checkDirName(name); err != nil {
+		return err
+	}
+	return fs.chmodKey(name, fs.permKey(name), mode)
+}
+
+// permKey returns the key of the object storing the permissions of a file, the marker of a directory
+func (fs *Fs) permKey(name string) string {
+	if fs.PosixMetadata || fs.PermissionsACL {
+		// The permissions of a directory are stored on its marker
+		if info, err := fs.Stat(name); err == nil && info.IsDir() {
+			return strings.TrimSuffix(name, "/") + "/"
+		}
+	}
+	return name
+}
+
+// chmodKey stores the permissions of a file on the object of a key, in its metadata and its ACL
+func (fs *Fs) chmodKey(name, key string, mode os.FileMode) error {
+	acl := modeToACL(mode)
+	applyACL, errBlocked := fs.checkACL(acl)
+	if errBlocked != nil && !fs.SkipBlockedACLs {
+		return &os.PathError{Op: "chmod", Path: name, Err: errBlocked}
+	}
+	if fs.PosixMetadata {
+		if err := fs.chmodMetadata(key, mode); err != nil {
+			return err
+		}
+	}
+	if !applyACL {
+		return nil
+	}
+	_, err := fs.s3API.PutObjectAcl(&s3.PutObjectAclInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+		ACL:    aws.String(acl),
+	})
+	// The ACLs can be disabled without the permission to read the ownership controls
+	if isACLNotSupported(err) {
+		if fs.SkipBlockedACLs {
+			return nil
+		}
+		return &os.PathError{Op: "chmod", Path: name, Err: &ACLBlockedError{ACL: acl, Setting: ACLsDisabled}}
+	}
+	return err
+}
+
+// modeToACL converts the "other" permissions of a mode to a canned ACL
+func modeToACL(mode os.FileMode) string {
+	otherRead := mode&(1<<2) != 0
+	otherWrite := mode&(1<<1) != 0
+
+	switch {
+	case otherRead && otherWrite:
+		return "public-read-write"
+	case otherRead:
+		return "public-read"
+	default:
+		return "private"
+	}
+}
+
+// Chown doesn't exist in S3 should probably NOT have been added to afero as it's POSIX-only concept.
+// In POSIX metadata mode, the owner is stored in the metadata of the file, or of the marker of a directory, and
+// returned by the Sys method of its FileInfo. The negative ids are left unchanged, like with os.Chown.
+// It does nothing in AferoCompat mode.
+func (fs *Fs) Chown(name string, uid, gid int) error {
+	if fs.PosixMetadata {
+		return pathError("chown", name, fs.chown(name, uid, gid))
+	}
+	if fs.AferoCompat {
+		return nil
+	}
+	return &os.PathError{Op: "chown", Path: name, Err: ErrNotSupported}
+}
+
+func (fs *Fs) chown(name string, uid, gid int) error {
+	if err := fs.checkWritable("chown", name); err != nil {
+		return err
+	}
+	if err := fs.checkDirName(name); err != nil {
+		return err
+	}
+	return fs.chownMetadata(fs.permKey(name), uid, gid)
+}
+
+// Chtimes could be implemented if needed, but that would require to override object properties using metadata,
+// which makes it a non-standard solution. It does nothing in AferoCompat mode.
+func (fs *Fs) Chtimes(name string, _, _ time.Time) error {
+	if fs.AferoCompat {
+		return nil
+	}
+	return &os.PathError{Op: "chtimes", Path: name, Err: ErrNotSupported}
+}
+
+// I couldn't find a way to make this code cleaner. It's basically a big copy-paste on two
+// very similar structures.
+func applyFileCreateProps(req *s3.PutObjectInput, p *UploadedFileProperties) {
+	if p.ACL != nil {
+		req.ACL = p.ACL
+	}
+
+	if p.CacheControl != nil {
+		req.CacheControl = p.CacheControl
+	}
+
+	if p.ContentType != nil {
+		req.ContentType = p.ContentType
+	}
+
+	if p.WebsiteRedirectLocation != nil {
+		req.WebsiteRedirectLocation = p.WebsiteRedirectLocation
+	}
+}
+
+func applyFileWriteProps(req *s3manager.UploadInput, p *UploadedFileProperties) {
+	if p.ACL != nil {
+		req.ACL = p.ACL
+	}
+
+	if p.CacheControl != nil {
+		req.CacheControl = p.CacheControl
+	}
+
+	if p.ContentType != nil {
+		req.ContentType = p.ContentType
+	}
+
+	if p.WebsiteRedirectLocation != nil {
+		req.WebsiteRedirectLocation = p.WebsiteRedirectLocation
+	}
+}
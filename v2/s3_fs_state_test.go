@@ -0,0 +1,37 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFsSharedState(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	// The configuration set through the pointer applies to the calls made through the afero interface
+	var afs afero.Fs = fs
+	fs.FileProps = &UploadedFileProperties{CacheControl: aws.String("no-cache")}
+
+	req.NoError(afero.WriteFile(afs, "/file", []byte("content"), 0600))
+
+	head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String("/file")})
+	req.NoError(err)
+	req.Equal("no-cache", aws.StringValue(head.CacheControl))
+
+	// The state changed by the calls is shared
+	req.NoError(fs.Preload("/"))
+	req.NoError(afs.Remove("/file"))
+
+	_, err = fs.Stat("/file")
+	req.Error(err)
+
+	file, err := afs.Open("/")
+	req.NoError(err)
+	req.Same(fs, file.(*File).fs)
+	req.NoError(file.Close())
+}
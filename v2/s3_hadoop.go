@@ -0,0 +1,154 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// HadoopSuccessMarker is the file written by Hadoop and Spark committers once the output of a job is complete
+const HadoopSuccessMarker = "_SUCCESS"
+
+// ErrIncompleteOutput is returned when reading the parts of a job output that doesn't have its success marker
+var ErrIncompleteOutput = errors.New("job output without success marker")
+
+// isHadoopArtifact tells if a file is created by the Hadoop and Spark committers rather than being part of the data
+func isHadoopArtifact(name string) bool {
+	return name == HadoopSuccessMarker ||
+		strings.HasPrefix(name, "_temporary") ||
+		strings.HasPrefix(name, ".spark-staging")
+}
+
+// isHadoopHidden tells if a file is ignored by the Hadoop readers, which skip the files starting with "_" or "."
+func isHadoopHidden(name string) bool {
+	return strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".")
+}
+
+// withoutHadoopArtifacts removes the Hadoop artifacts from a listing
+func withoutHadoopArtifacts(fis []os.FileInfo) []os.FileInfo {
+	filtered := fis[:0]
+
+	for _, fi := range fis {
+		if !isHadoopArtifact(fi.Name()) {
+			filtered = append(filtered, fi)
+		}
+	}
+
+	return filtered
+}
+
+// PartsOptions defines how the parts of a job output are read
+type PartsOptions struct {
+	// AllowIncomplete reads the parts even if the output doesn't have a success marker
+	AllowIncomplete bool
+}
+
+// PartsReader reads all the part files of a job output directory, like "part-00000", as a single concatenated file
+type PartsReader struct {
+	fs      *Fs
+	keys    []string
+	size    int64
+	index   int           // index is the part currently read
+	current io.ReadCloser // current is the stream of the part currently read
+}
+
+// OpenParts opens the output directory of a Hadoop or Spark job to read all its parts, in the order of their names,
+// as a single file. The hidden files, starting with "_" or ".", are ignored.
+func (fs *Fs) OpenParts(dir string, opts *PartsOptions) (*PartsReader, error) {
+	if opts == nil {
+		opts = &PartsOptions{}
+	}
+
+	reader := &PartsReader{fs: fs}
+	prefix := dirPrefix(dir)
+	complete := false
+
+	// S3 lists the keys in the lexicographic order
+	err := fs.walkObjects(prefix, func(obj *s3.Object) bool {
+		name := strings.TrimPrefix(aws.StringValue(obj.Key), prefix)
+
+		switch {
+		case name == HadoopSuccessMarker:
+			complete = true
+		case strings.Contains(name, "/") || isHadoopHidden(name):
+		default:
+			reader.keys = append(reader.keys, aws.StringValue(obj.Key))
+			reader.size += aws.Int64Value(obj.Size)
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: err}
+	}
+
+	if !complete && !opts.AllowIncomplete {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: ErrIncompleteOutput}
+	}
+
+	return reader, nil
+}
+
+// Parts returns the names of the read parts
+func (r *PartsReader) Parts() []string {
+	names := make([]string, len(r.keys))
+	for i, key := range r.keys {
+		names[i] = "/" + key
+	}
+
+	return names
+}
+
+// Size returns the total size of the parts
+func (r *PartsReader) Size() int64 {
+	return r.size
+}
+
+// Read reads the parts one after the other
+func (r *PartsReader) Read(p []byte) (int, error) {
+	for r.index < len(r.keys) {
+		if r.current == nil {
+			resp, err := r.fs.s3API.GetObject(&s3.GetObjectInput{
+				Bucket: aws.String(r.fs.bucket),
+				Key:    aws.String(r.keys[r.index]),
+			})
+			if err != nil {
+				return 0, &os.PathError{Op: "read", Path: "/" + r.keys[r.index], Err: err}
+			}
+
+			r.current = resp.Body
+		}
+
+		n, err := r.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			err = r.current.Close()
+			r.current = nil
+			r.index++
+		}
+
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+
+	return 0, io.EOF
+}
+
+// Close closes the part being read
+func (r *PartsReader) Close() error {
+	r.index = len(r.keys)
+
+	if r.current == nil {
+		return nil
+	}
+
+	err := r.current.Close()
+	r.current = nil
+
+	return err
+}
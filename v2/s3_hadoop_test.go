@@ -0,0 +1,47 @@
+package s3
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHadoopOutput(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/out/part-00001", "world !")
+	testCreateFile(t, fs, "/out/part-00000", "Hello ")
+	testCreateFile(t, fs, "/out/.part-00000.crc", "crc")
+	testCreateFile(t, fs, "/out/_temporary/0/part-00002", "partial")
+	testCreateFile(t, fs, "/out/.spark-staging-1/part-00003", "staged")
+
+	_, err := fs.OpenParts("/out", nil)
+	req.ErrorIs(err, ErrIncompleteOutput)
+
+	reader, err := fs.OpenParts("/out", &PartsOptions{AllowIncomplete: true})
+	req.NoError(err)
+	req.NoError(reader.Close())
+
+	testCreateFile(t, fs, "/out/_SUCCESS", "")
+
+	fs.HideHadoopArtifacts = true
+
+	dir, err := fs.Open("/out")
+	req.NoError(err)
+
+	names, err := dir.Readdirnames(0)
+	req.NoError(err)
+	req.ElementsMatch([]string{"part-00000", "part-00001", ".part-00000.crc"}, names)
+
+	reader, err = fs.OpenParts("/out", nil)
+	req.NoError(err)
+	req.Equal([]string{"/out/part-00000", "/out/part-00001"}, reader.Parts())
+	req.Equal(int64(13), reader.Size())
+
+	content, err := io.ReadAll(reader)
+	req.NoError(err)
+	req.Equal("Hello world !", string(content))
+	req.NoError(reader.Close())
+}
@@ -0,0 +1,78 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"os"
+)
+
+// ReadOnlyFile is a file handle that can only be read, it statically prevents writes to a file opened for reading
+type ReadOnlyFile struct {
+	file *File
+}
+
+// WriteOnlyFile is a file handle that can only be written, it statically prevents reads from a file opened for
+// writing
+type WriteOnlyFile struct {
+	file *File
+}
+
+// OpenReader opens a file for reading
+func (fs *Fs) OpenReader(name string) (*ReadOnlyFile, error) {
+	file, err := fs.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return file.(*File).ReadOnly(), nil
+}
+
+// OpenWriter opens a file for writing, its content is replaced by the written one
+func (fs *Fs) OpenWriter(name string, perm os.FileMode) (*WriteOnlyFile, error) {
+	file, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return file.(*File).WriteOnly(), nil
+}
+
+// ReadOnly restricts the file handle to reads. The handle of a file opened for writing fails all reads.
+func (f *File) ReadOnly() *ReadOnlyFile { return &ReadOnlyFile{file: f} }
+
+// WriteOnly restricts the file handle to writes. The handle of a file opened for reading fails all writes.
+func (f *File) WriteOnly() *WriteOnlyFile { return &WriteOnlyFile{file: f} }
+
+// Name returns the name of the file
+func (f *ReadOnlyFile) Name() string { return f.file.Name() }
+
+// Stat describes the file
+func (f *ReadOnlyFile) Stat() (os.FileInfo, error) { return f.file.Stat() }
+
+// Read reads up to len(p) bytes from the file
+func (f *ReadOnlyFile) Read(p []byte) (int, error) { return f.file.Read(p) }
+
+// ReadAt reads len(p) bytes from the file starting at the off offset
+func (f *ReadOnlyFile) ReadAt(p []byte, off int64) (int, error) { return f.file.ReadAt(p, off) }
+
+// Seek sets the offset of the next Read
+func (f *ReadOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	return f.file.Seek(offset, whence)
+}
+
+// Close closes the file
+func (f *ReadOnlyFile) Close() error { return f.file.Close() }
+
+// Name returns the name of the file
+func (f *WriteOnlyFile) Name() string { return f.file.Name() }
+
+// Write writes len(p) bytes to the file
+func (f *WriteOnlyFile) Write(p []byte) (int, error) { return f.file.Write(p) }
+
+// WriteString writes the content of a string to the file
+func (f *WriteOnlyFile) WriteString(s string) (int, error) { return f.file.WriteString(s) }
+
+// Sync hands the buffered writes to the upload
+func (f *WriteOnlyFile) Sync() error { return f.file.Sync() }
+
+// Close closes the file and waits for its upload to finish
+func (f *WriteOnlyFile) Close() error { return f.file.Close() }
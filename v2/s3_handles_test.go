@@ -0,0 +1,41 @@
+package s3
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWriteOnlyFiles(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	var (
+		_ io.ReadSeekCloser = (*ReadOnlyFile)(nil)
+		_ io.WriteCloser    = (*WriteOnlyFile)(nil)
+	)
+
+	writer, err := fs.OpenWriter("/file", 0600)
+	req.NoError(err)
+	req.Equal("/file", writer.Name())
+
+	_, err = writer.WriteString("Hello world !")
+	req.NoError(err)
+	req.NoError(writer.Close())
+
+	reader, err := fs.OpenReader("/file")
+	req.NoError(err)
+
+	_, err = reader.Seek(6, io.SeekStart)
+	req.NoError(err)
+
+	content, err := io.ReadAll(reader)
+	req.NoError(err)
+	req.Equal("world !", string(content))
+	req.NoError(reader.Close())
+
+	_, err = fs.OpenReader("/missing")
+	req.ErrorIs(err, os.ErrNotExist)
+}
@@ -0,0 +1,67 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Names of the handlers customizing the requests
+const (
+	readHooksHandler      = "afero-s3.ReadHooks"
+	requestHeadersHandler = "afero-s3.RequestHeaders"
+)
+
+// Headers set by requestHeaders
+const (
+	expectedBucketOwnerHeader = "X-Amz-Expected-Bucket-Owner"
+	requestPayerHeader        = "X-Amz-Request-Payer"
+)
+
+// readHooks applies the BeforeGet and BeforeHead hooks of an Fs to its requests
+func readHooks(fs *Fs) request.NamedHandler {
+	return request.NamedHandler{
+		Name: readHooksHandler,
+		Fn: func(r *request.Request) {
+			switch input := r.Params.(type) {
+			case *s3.GetObjectInput:
+				if fs.BeforeGet != nil {
+					fs.BeforeGet(input)
+				}
+			case *s3.HeadObjectInput:
+				if fs.BeforeHead != nil {
+					fs.BeforeHead(input)
+				}
+			}
+		},
+	}
+}
+
+// requestHeaders adds the ExpectedBucketOwner, RequestPayer and RequestHeaders headers of an Fs to its requests
+func requestHeaders(fs *Fs) request.NamedHandler {
+	return request.NamedHandler{
+		Name: requestHeadersHandler,
+		Fn: func(r *request.Request) {
+			for name, values := range fs.RequestHeaders {
+				for _, value := range values {
+					r.HTTPRequest.Header.Add(name, value)
+				}
+			}
+
+			if fs.ExpectedBucketOwner != "" {
+				r.HTTPRequest.Header.Set(expectedBucketOwnerHeader, fs.ExpectedBucketOwner)
+			}
+
+			if fs.RequestPayer {
+				r.HTTPRequest.Header.Set(requestPayerHeader, s3.RequestPayerRequester)
+			}
+		},
+	}
+}
+
+// requestHeadersOption applies the request headers of the Fs to the requests of other clients
+func (fs *Fs) requestHeadersOption() request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Build.PushBackNamed(requestHeaders(fs))
+	}
+}
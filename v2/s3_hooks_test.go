@@ -0,0 +1,116 @@
+package s3
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBeforeUpload(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	fs.BeforeUpload = func(name string, input *s3manager.UploadInput) {
+		input.WebsiteRedirectLocation = aws.String("/redirected" + name)
+		input.ContentType = nil
+	}
+
+	// Written files
+	file, err := fs.OpenFile("/written.txt", os.O_WRONLY, 0)
+	req.NoError(err)
+	_, err = file.WriteString("content")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	// Created files
+	_, err = fs.Create("/created.txt")
+	req.NoError(err)
+
+	for _, name := range []string{"/written.txt", "/created.txt"} {
+		head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String(name)})
+		req.NoError(err)
+		req.Equal("/redirected"+name, aws.StringValue(head.WebsiteRedirectLocation))
+		req.NotEqual("text/plain; charset=utf-8", aws.StringValue(head.ContentType))
+	}
+}
+
+func TestReadHooks(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/file.txt", "content")
+
+	var gets, heads int
+
+	fs.BeforeGet = func(input *s3.GetObjectInput) {
+		gets++
+		input.ResponseContentType = aws.String("application/x-overridden")
+	}
+	fs.BeforeHead = func(input *s3.HeadObjectInput) {
+		heads++
+	}
+
+	var contentType string
+
+	fs.s3API.Handlers.Complete.PushBack(func(r *request.Request) {
+		if out, ok := r.Data.(*s3.GetObjectOutput); ok {
+			contentType = aws.StringValue(out.ContentType)
+		}
+	})
+
+	content, err := afero.ReadFile(fs, "/file.txt")
+	req.NoError(err)
+	req.Equal("content", string(content))
+
+	req.Equal(1, gets)
+	req.Positive(heads)
+	req.Equal("application/x-overridden", contentType)
+}
+
+func TestRequestHeaders(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	var (
+		mu      sync.Mutex
+		headers = map[string]http.Header{}
+	)
+
+	capture := request.NamedHandler{Name: "test.Capture", Fn: func(r *request.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		headers[r.Operation.Name] = r.HTTPRequest.Header.Clone()
+	}}
+
+	// The session handlers are used by the uploads
+	fs.s3API.Handlers.Send.PushFrontNamed(capture)
+	fs.session.Handlers.Send.PushFrontNamed(capture)
+
+	fs.ExpectedBucketOwner = "123456789012"
+	fs.RequestPayer = true
+	fs.RequestHeaders = http.Header{"X-Trace-Id": []string{"trace"}}
+
+	testCreateFile(t, fs, "/file.txt", "content")
+
+	_, err := fs.Stat("/file.txt")
+	req.NoError(err)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, operation := range []string{"PutObject", "HeadObject"} {
+		req.Contains(headers, operation)
+		req.Equal("123456789012", headers[operation].Get("X-Amz-Expected-Bucket-Owner"), operation)
+		req.Equal("requester", headers[operation].Get("X-Amz-Request-Payer"), operation)
+		req.Equal("trace", headers[operation].Get("X-Trace-Id"), operation)
+	}
+}
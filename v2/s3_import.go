@@ -0,0 +1,90 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	iofs "io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// ImportRule defines the properties of the files imported by ImportFS whose name matches a pattern
+type ImportRule struct {
+	// Pattern is matched with path.Match against the name of the files in the source, like "assets/*.js", or
+	// against their base name if it has no "/", like "*.html"
+	Pattern string
+	// Properties of the matching files, overriding the FileProps of the Fs
+	Properties UploadedFileProperties
+}
+
+// matches tells if the rule applies to a file of the source
+func (r *ImportRule) matches(name string) bool {
+	if !strings.Contains(r.Pattern, "/") {
+		name = path.Base(name)
+	}
+
+	matched, err := path.Match(r.Pattern, name)
+
+	return err == nil && matched
+}
+
+// ImportOptions defines how ImportFS uploads the files
+type ImportOptions struct {
+	BulkOptions
+	// Rules define the properties of the files, the first matching rule applying to each file
+	Rules []ImportRule
+}
+
+// importProps returns the properties of a file of the source, nil if no rule matches it
+func (o *ImportOptions) importProps(name string) *UploadedFileProperties {
+	for i := range o.Rules {
+		if o.Rules[i].matches(name) {
+			return &o.Rules[i].Properties
+		}
+	}
+
+	return nil
+}
+
+// ImportFS uploads the files of a standard library file system, like an embed.FS or a zip.Reader, to the dstPrefix
+// directory under the same names, with the properties of their matching rule. It's meant to publish the static
+// assets embedded in a binary in one call. The files are streamed in parallel, the source doesn't have to support
+// seeking, and the directories are left implicit. It stops at the first error.
+func (fs *Fs) ImportFS(src iofs.FS, dstPrefix string, opts *ImportOptions) error {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	var names []string
+
+	err := iofs.WalkDir(src, ".", func(name string, entry iofs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			names = append(names, name)
+		}
+
+		return err
+	})
+	if err != nil {
+		return &os.PathError{Op: "import", Path: dstPrefix, Err: err}
+	}
+
+	return forEachIndex("import", len(names), &opts.BulkOptions, func(i int) (string, error) {
+		name := path.Join("/", dstPrefix, names[i])
+		return name, fs.importFile(src, names[i], name, opts.importProps(names[i]))
+	})
+}
+
+// importFile uploads a file of a standard library file system
+func (fs *Fs) importFile(src iofs.FS, srcName, name string, props *UploadedFileProperties) error {
+	in, err := src.Open(srcName)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = in.Close() }()
+
+	params := fs.permParams(0666)
+	params.applyProps(props)
+
+	return fs.uploadFrom(in, name, params)
+}
@@ -0,0 +1,80 @@
+package s3
+
+import (
+	"archive/zip"
+	"bytes"
+	iofs "io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportFS(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	src := fstest.MapFS{
+		"index.html":       {Data: []byte("<html></html>")},
+		"assets/app.js":    {Data: []byte("app()")},
+		"assets/logo.svg":  {Data: []byte("<svg/>")},
+		"assets/empty":     {Mode: iofs.ModeDir | 0755},
+		"docs/readme.html": {Data: []byte("readme")},
+	}
+
+	req.NoError(fs.ImportFS(src, "/site", &ImportOptions{Rules: []ImportRule{
+		{Pattern: "*.html", Properties: UploadedFileProperties{CacheControl: aws.String("no-cache")}},
+		{Pattern: "assets/*", Properties: UploadedFileProperties{
+			CacheControl: aws.String("max-age=3600"),
+			ContentType:  aws.String("application/octet-stream"),
+		}},
+	}}))
+
+	for name, content := range map[string]string{
+		"/site/index.html":       "<html></html>",
+		"/site/assets/app.js":    "app()",
+		"/site/assets/logo.svg":  "<svg/>",
+		"/site/docs/readme.html": "readme",
+	} {
+		data, err := afero.ReadFile(fs, name)
+		req.NoError(err)
+		req.Equal(content, string(data))
+	}
+
+	// The properties of the first matching rule are applied
+	head := func(name string) *s3.HeadObjectOutput {
+		out, err := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String(name)})
+		req.NoError(err)
+
+		return out
+	}
+
+	req.Equal("no-cache", aws.StringValue(head("/site/docs/readme.html").CacheControl))
+
+	out := head("/site/assets/app.js")
+	req.Equal("max-age=3600", aws.StringValue(out.CacheControl))
+	req.Equal("application/octet-stream", aws.StringValue(out.ContentType))
+
+	// The sources don't have to support seeking
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+
+	file, err := writer.Create("data/file.txt")
+	req.NoError(err)
+
+	_, err = file.Write([]byte("zipped"))
+	req.NoError(err)
+	req.NoError(writer.Close())
+
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	req.NoError(err)
+
+	req.NoError(fs.ImportFS(reader, "/zip", &ImportOptions{BulkOptions: BulkOptions{Concurrency: 1}}))
+
+	data, err := afero.ReadFile(fs, "/zip/data/file.txt")
+	req.NoError(err)
+	req.Equal("zipped", string(data))
+}
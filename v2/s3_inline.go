@@ -0,0 +1,175 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"os"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// InlineIndexName is the name of the object of a directory packing its inlined files, see InlineThreshold
+const InlineIndexName = ".afero-inline.json"
+
+// inlineUpdateAttempts is the number of times an inline index is updated when it's modified concurrently
+const inlineUpdateAttempts = 5
+
+// inlineIndex is the content of an inline index
+type inlineIndex struct {
+	Files map[string]*inlineEntry `json:"files"`
+}
+
+// inlineEntry is an inlined file
+type inlineEntry struct {
+	Data    []byte    `json:"data"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// inlineIndexPath returns the name of the inline index of the directory of a file
+func inlineIndexPath(name string) string {
+	return path.Join(path.Dir(path.Clean("/"+name)), InlineIndexName)
+}
+
+// inlined tells if a file of a given size is inlined
+func (fs *Fs) inlined(size int) bool {
+	return fs.InlineThreshold > 0 && size <= fs.InlineThreshold
+}
+
+// readInlineIndex reads an inline index, which is empty with no ETag if it doesn't exist
+func (fs *Fs) readInlineIndex(indexName string) (*inlineIndex, string, error) {
+	index := &inlineIndex{}
+
+	etag, err := fs.ReadJSON(indexName, index)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, "", err
+	}
+
+	if index.Files == nil {
+		index.Files = make(map[string]*inlineEntry)
+	}
+
+	return index, etag, nil
+}
+
+// updateInlineIndex changes an inline index with a conditional write, which is retried when the index is modified
+// concurrently. The update returns false if it didn't change anything. The index is removed once empty.
+func (fs *Fs) updateInlineIndex(indexName string, update func(index *inlineIndex) bool) error {
+	for attempt := 1; ; attempt++ {
+		index, etag, err := fs.readInlineIndex(indexName)
+		if err != nil {
+			return err
+		}
+
+		if !update(index) {
+			return nil
+		}
+
+		if len(index.Files) == 0 {
+			return fs.forceRemove(indexName)
+		}
+
+		err = fs.WriteJSON(indexName, index, &EncodeOptions{IfMatch: etag, IfNotExists: etag == ""})
+		if !errors.Is(err, ErrModified) || attempt >= inlineUpdateAttempts {
+			return err
+		}
+	}
+}
+
+// writeInline stores files of the same directory in its inline index
+func (fs *Fs) writeInline(files []batchFile) error {
+	now := time.Now()
+
+	err := fs.updateInlineIndex(inlineIndexPath(files[0].name), func(index *inlineIndex) bool {
+		for _, file := range files {
+			index.Files[path.Base(file.name)] = &inlineEntry{Data: file.data, ModTime: now}
+		}
+
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	// The objects previously written for the files would hide them
+	for _, file := range files {
+		if _, err = fs.s3API.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(fs.bucket),
+			Key:    aws.String(file.name),
+		}); err != nil {
+			return &os.PathError{Op: "write", Path: file.name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// removeInline removes a file from its inline index, if it's there
+func (fs *Fs) removeInline(name string) error {
+	return fs.updateInlineIndex(inlineIndexPath(name), func(index *inlineIndex) bool {
+		if _, ok := index.Files[path.Base(name)]; !ok {
+			return false
+		}
+
+		delete(index.Files, path.Base(name))
+
+		return true
+	})
+}
+
+// statInline describes an inlined file, it returns no info and no error if the file isn't inlined
+func (fs *Fs) statInline(name string) (os.FileInfo, error) {
+	index, _, err := fs.readInlineIndex(inlineIndexPath(name))
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	entry, ok := index.Files[path.Base(name)]
+	if !ok {
+		return nil, nil
+	}
+
+	return entry.info(name), nil
+}
+
+// info describes an inlined file by its path, its content is kept to be read without any other request
+func (e *inlineEntry) info(name string) FileInfo {
+	info := NewFileInfo(name, false, int64(len(e.Data)), e.ModTime)
+	info.inline = e.Data
+
+	if info.inline == nil {
+		info.inline = []byte{}
+	}
+
+	return info
+}
+
+// renameInline renames an inlined file, it returns false if the file isn't inlined
+func (fs *Fs) renameInline(oldname, newname string) (bool, error) {
+	info, err := fs.statInline(oldname)
+	if info == nil || err != nil {
+		return false, err
+	}
+
+	if err = fs.writeInline([]batchFile{{name: newname, data: info.(FileInfo).inline}}); err != nil {
+		return true, err
+	}
+
+	return true, fs.removeInline(oldname)
+}
+
+// appendInline adds the files of an inline index to a listing
+func (f *File) appendInline(fis []os.FileInfo, indexName string) ([]os.FileInfo, error) {
+	index, _, err := f.fs.readInlineIndex(indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, entry := range index.Files {
+		fis = append(fis, entry.info(path.Join(path.Dir(indexName), name)))
+	}
+
+	return fis, nil
+}
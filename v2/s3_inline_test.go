@@ -0,0 +1,87 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineFiles(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	fs.InlineThreshold = 16
+
+	req.NoError(fs.WriteFile("/dir/small", []byte("small content"), nil))
+	req.NoError(fs.WriteFile("/dir/big", []byte("content too big to be inlined"), nil))
+
+	// The small file is packed in the index of the directory
+	_, err := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String("/dir/small")})
+	req.True(isNotFound(err))
+
+	info, err := fs.Stat("/dir/small")
+	req.NoError(err)
+	req.Equal(int64(13), info.Size())
+
+	file, err := fs.Open("/dir/small")
+	req.NoError(err)
+
+	_, err = file.Seek(6, io.SeekStart)
+	req.NoError(err)
+
+	content, err := io.ReadAll(file)
+	req.NoError(err)
+	req.Equal("content", string(content))
+	req.NoError(file.Close())
+
+	names, err := afero.ReadDir(fs, "/dir")
+	req.NoError(err)
+	req.Len(names, 2)
+
+	t.Run("Batch", func(t *testing.T) {
+		batch := fs.NewBatchWriter(nil)
+		for i := 0; i < 10; i++ {
+			req.NoError(batch.Add(fmt.Sprintf("/batch/file-%d", i), []byte("content")))
+		}
+
+		req.NoError(batch.Commit())
+
+		for i := 0; i < 10; i++ {
+			content, err := afero.ReadFile(fs, fmt.Sprintf("/batch/file-%d", i))
+			req.NoError(err)
+			req.Equal("content", string(content))
+		}
+	})
+
+	t.Run("RenameRemove", func(t *testing.T) {
+		req.NoError(fs.Rename("/dir/small", "/dir/renamed"))
+
+		_, err := fs.Stat("/dir/small")
+		req.Error(err)
+
+		req.NoError(fs.Remove("/dir/renamed"))
+
+		_, err = fs.Stat("/dir/renamed")
+		req.Error(err)
+
+		// The index is removed with its last file
+		_, err = fs.s3API.HeadObject(&s3.HeadObjectInput{
+			Bucket: aws.String(fs.bucket),
+			Key:    aws.String("/dir/" + InlineIndexName),
+		})
+		req.True(isNotFound(err))
+	})
+
+	t.Run("RemoveAll", func(t *testing.T) {
+		req.NoError(fs.RemoveAll("/batch"))
+
+		exists, err := afero.DirExists(fs, "/batch")
+		req.NoError(err)
+		req.False(exists)
+	})
+}
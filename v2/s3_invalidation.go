@@ -0,0 +1,248 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// DefaultInvalidatorWaitTime is the default long polling duration of the queues consumed by a CacheInvalidator
+const DefaultInvalidatorWaitTime = 20 * time.Second
+
+// invalidatorRetryDelay is the delay before receiving the messages of a queue again after a failure
+const invalidatorRetryDelay = time.Second
+
+// ErrNoNotification is returned when the bucket doesn't send its events to any SQS queue
+var ErrNoNotification = errors.New("the bucket doesn't send its events to any queue")
+
+// CacheInvalidator keeps the caches of an Fs coherent with the changes made by the other writers of the bucket: the
+// preloaded listing, the known directory markers and the blocks of its BlockCaches. It consumes the event
+// notifications the bucket sends to SQS queues, or the messages passed to Handle when they're delivered another way.
+type CacheInvalidator struct {
+	// BlockCaches are the caches of the readers of the Fs, the blocks of the changed files are discarded from those
+	// implementing BlockInvalidator
+	BlockCaches []BlockCache
+	// WaitTime is the long polling duration of the queues, DefaultInvalidatorWaitTime if 0
+	WaitTime time.Duration
+	// OnError is called when receiving or handling a message fails, it can be nil
+	OnError func(err error)
+
+	fs     *Fs
+	queue  sqsiface.SQSAPI
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// s3Event is an S3 event notification message, with the fields used to invalidate the caches
+type s3Event struct {
+	Records []struct {
+		EventName string `json:"eventName"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key string `json:"key"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+	// Type and Message are set when the event is wrapped in an SNS notification
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// NewCacheInvalidator creates a cache invalidator for an Fs, the queues are consumed with Start. The queue client can
+// be nil to use SQS clients of the session of the Fs, in the regions of the queues.
+func NewCacheInvalidator(fs *Fs, queue sqsiface.SQSAPI) *CacheInvalidator {
+	return &CacheInvalidator{fs: fs, queue: queue}
+}
+
+func (c *CacheInvalidator) waitTime() time.Duration {
+	if c.WaitTime <= 0 {
+		return DefaultInvalidatorWaitTime
+	}
+
+	return c.WaitTime
+}
+
+// Invalidate discards the cached state of a file that was written or removed
+func (c *CacheInvalidator) Invalidate(name string, removed bool) {
+	c.fs.coherence.changed(CoherenceChange{Name: name, Removed: removed})
+
+	if removed {
+		c.fs.fileRemoved(name)
+	} else {
+		c.fs.preload.invalidate()
+	}
+
+	for _, cache := range c.BlockCaches {
+		if invalidator, ok := cache.(BlockInvalidator); ok {
+			invalidator.Invalidate(name)
+		}
+	}
+}
+
+// Handle invalidates the caches from an event notification message, possibly wrapped in an SNS notification. The
+// events of other buckets and the test events are ignored.
+func (c *CacheInvalidator) Handle(message []byte) error {
+	var event s3Event
+	if err := json.Unmarshal(message, &event); err != nil {
+		return fmt.Errorf("invalid event notification: %w", err)
+	}
+
+	if event.Type == "Notification" {
+		return c.Handle([]byte(event.Message))
+	}
+
+	for _, record := range event.Records {
+		if record.S3.Bucket.Name != c.fs.bucket {
+			continue
+		}
+
+		// The keys are URL-encoded
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return fmt.Errorf("invalid event notification key %q: %w", record.S3.Object.Key, err)
+		}
+
+		c.Invalidate("/"+key, strings.HasPrefix(record.EventName, "ObjectRemoved:") ||
+			strings.HasPrefix(record.EventName, "LifecycleExpiration:"))
+	}
+
+	return nil
+}
+
+// invalidationQueue is a queue the bucket sends its events to
+type invalidationQueue struct {
+	url    string
+	client sqsiface.SQSAPI
+}
+
+// queues returns the SQS queues the bucket sends its events to, from its notification configuration. The queues are
+// consumed with clients of their regions when no client was given.
+func (c *CacheInvalidator) queues() ([]invalidationQueue, error) {
+	config, err := c.fs.s3API.GetBucketNotificationConfiguration(&s3.GetBucketNotificationConfigurationRequest{
+		Bucket: aws.String(c.fs.bucket),
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "invalidate", Path: "/", Err: err}
+	}
+
+	queues := make([]invalidationQueue, 0, len(config.QueueConfigurations))
+
+	for _, queue := range config.QueueConfigurations {
+		queueARN, err := arn.Parse(aws.StringValue(queue.QueueArn))
+		if err != nil {
+			return nil, &os.PathError{Op: "invalidate", Path: "/", Err: err}
+		}
+
+		client := c.queue
+		if client == nil {
+			client = sqs.New(c.fs.session, aws.NewConfig().WithRegion(queueARN.Region))
+		}
+
+		out, err := client.GetQueueUrl(&sqs.GetQueueUrlInput{
+			QueueName:              aws.String(queueARN.Resource),
+			QueueOwnerAWSAccountId: aws.String(queueARN.AccountID),
+		})
+		if err != nil {
+			return nil, &os.PathError{Op: "invalidate", Path: "/", Err: err}
+		}
+
+		queues = append(queues, invalidationQueue{url: aws.StringValue(out.QueueUrl), client: client})
+	}
+
+	return queues, nil
+}
+
+// Start consumes the queues the bucket sends its events to until Stop is called. It fails with ErrNoNotification if
+// there isn't any.
+func (c *CacheInvalidator) Start() error {
+	queues, err := c.queues()
+	if err != nil {
+		return err
+	}
+
+	if len(queues) == 0 {
+		return &os.PathError{Op: "invalidate", Path: "/", Err: ErrNoNotification}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	for _, queue := range queues {
+		c.wg.Add(1)
+
+		go func(queue invalidationQueue) {
+			defer c.wg.Done()
+			c.consume(ctx, queue)
+		}(queue)
+	}
+
+	return nil
+}
+
+// Stop stops consuming the queues, and waits for the messages being handled
+func (c *CacheInvalidator) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.wg.Wait()
+}
+
+// consume receives the messages of a queue and handles them, they're removed once the caches are invalidated
+func (c *CacheInvalidator) consume(ctx context.Context, queue invalidationQueue) {
+	for ctx.Err() == nil {
+		out, err := queue.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queue.url),
+			MaxNumberOfMessages: aws.Int64(10),
+			WaitTimeSeconds:     aws.Int64(int64(c.waitTime() / time.Second)),
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				c.failed(err)
+
+				select {
+				case <-time.After(invalidatorRetryDelay):
+				case <-ctx.Done():
+				}
+			}
+
+			continue
+		}
+
+		for _, message := range out.Messages {
+			if err = c.Handle([]byte(aws.StringValue(message.Body))); err != nil {
+				c.failed(err)
+				continue
+			}
+
+			if _, err = queue.client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(queue.url),
+				ReceiptHandle: message.ReceiptHandle,
+			}); err != nil && ctx.Err() == nil {
+				c.failed(err)
+			}
+		}
+	}
+}
+
+func (c *CacheInvalidator) failed(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
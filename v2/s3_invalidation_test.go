@@ -0,0 +1,161 @@
+package s3
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// testQueue is an SQS queue delivering its messages once
+type testQueue struct {
+	sqsiface.SQSAPI
+	mu       sync.Mutex
+	messages []*sqs.Message
+	deleted  []string
+}
+
+func (q *testQueue) GetQueueUrl(input *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs.test/" + aws.StringValue(input.QueueName))}, nil
+}
+
+func (q *testQueue) ReceiveMessageWithContext(
+	ctx aws.Context, _ *sqs.ReceiveMessageInput, _ ...request.Option,
+) (*sqs.ReceiveMessageOutput, error) {
+	q.mu.Lock()
+	messages := q.messages
+	q.messages = nil
+	q.mu.Unlock()
+
+	if len(messages) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (q *testQueue) DeleteMessageWithContext(
+	_ aws.Context, input *sqs.DeleteMessageInput, _ ...request.Option,
+) (*sqs.DeleteMessageOutput, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.deleted = append(q.deleted, aws.StringValue(input.ReceiptHandle))
+
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (q *testQueue) deletedHandles() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.deleted
+}
+
+func testEvent(bucket, eventName, key string) string {
+	return fmt.Sprintf(`{"Records":[{"eventName":%q,"s3":{"bucket":{"name":%q},"object":{"key":%q}}}]}`,
+		eventName, bucket, key)
+}
+
+func testReadDirNames(t *testing.T, fs *Fs, dir string) []string {
+	fis, err := afero.ReadDir(fs, dir)
+	require.NoError(t, err)
+
+	names := make([]string, len(fis))
+	for i, fi := range fis {
+		names[i] = fi.Name()
+	}
+
+	return names
+}
+
+func TestCacheInvalidator(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	other := NewFs(fs.bucket, fs.session)
+
+	testCreateFile(t, fs, "/dir/a b", "ab")
+	testCreateFile(t, fs, "/dir/old", "old")
+	req.NoError(fs.Preload("/dir"))
+
+	// The changes made by another instance aren't seen while the preloaded listing is used
+	testCreateFile(t, other, "/dir/new", "new")
+	req.NoError(other.Remove("/dir/old"))
+	req.Equal([]string{"a b", "old"}, testReadDirNames(t, fs, "/dir"))
+
+	cache := NewMemoryBlockCache(1024)
+	cache.Put(BlockKey{Name: "/dir/a b", ETag: "etag", Index: 0}, []byte("ab"))
+
+	invalidator := NewCacheInvalidator(fs, nil)
+	invalidator.BlockCaches = []BlockCache{cache}
+
+	// The events of other buckets and the test events are ignored
+	req.NoError(invalidator.Handle([]byte(testEvent("other", "ObjectCreated:Put", "dir/new"))))
+	req.NoError(invalidator.Handle([]byte(`{"Service":"Amazon S3","Event":"s3:TestEvent"}`)))
+	req.Equal([]string{"a b", "old"}, testReadDirNames(t, fs, "/dir"))
+
+	req.NoError(invalidator.Handle([]byte(testEvent(fs.bucket, "ObjectRemoved:Delete", "dir/old"))))
+	req.Equal([]string{"a b", "new"}, testReadDirNames(t, fs, "/dir"))
+
+	// The events can be wrapped in SNS notifications, with URL-encoded keys
+	_, ok := cache.Get(BlockKey{Name: "/dir/a b", ETag: "etag", Index: 0})
+	req.True(ok)
+
+	message := fmt.Sprintf(`{"Type":"Notification","Message":%q}`,
+		testEvent(fs.bucket, "ObjectCreated:Put", "dir/a+b"))
+	req.NoError(invalidator.Handle([]byte(message)))
+
+	_, ok = cache.Get(BlockKey{Name: "/dir/a b", ETag: "etag", Index: 0})
+	req.False(ok)
+
+	req.Error(invalidator.Handle([]byte("not json")))
+}
+
+func TestCacheInvalidatorQueue(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	queue := &testQueue{}
+	invalidator := NewCacheInvalidator(fs, queue)
+
+	err := invalidator.Start()
+	req.ErrorIs(err, ErrNoNotification)
+
+	_, err = fs.s3API.PutBucketNotificationConfiguration(&s3.PutBucketNotificationConfigurationInput{
+		Bucket: aws.String(fs.bucket),
+		NotificationConfiguration: &s3.NotificationConfiguration{
+			QueueConfigurations: []*s3.QueueConfiguration{{
+				QueueArn: aws.String("arn:aws:sqs:eu-west-1:123456789012:events"),
+				Events:   []*string{aws.String("s3:ObjectCreated:*"), aws.String("s3:ObjectRemoved:*")},
+			}},
+		},
+	})
+	req.NoError(err)
+
+	testCreateFile(t, fs, "/dir/a", "a")
+	req.NoError(fs.Preload("/dir"))
+	testCreateFile(t, NewFs(fs.bucket, fs.session), "/dir/new", "new")
+	req.Equal([]string{"a"}, testReadDirNames(t, fs, "/dir"))
+
+	queue.messages = []*sqs.Message{{
+		Body:          aws.String(testEvent(fs.bucket, "ObjectCreated:Put", "dir/new")),
+		ReceiptHandle: aws.String("handle"),
+	}}
+
+	req.NoError(invalidator.Start())
+	defer invalidator.Stop()
+
+	req.Eventually(func() bool { return len(queue.deletedHandles()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	req.Equal([]string{"handle"}, queue.deletedHandles())
+
+	req.Equal([]string{"a", "new"}, testReadDirNames(t, fs, "/dir"))
+}
@@ -0,0 +1,323 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Journaled operations
+const (
+	JournalOpRename    = "rename"
+	JournalOpRemoveAll = "removeAll"
+)
+
+// RecoveryMode defines how interrupted operations are recovered
+type RecoveryMode int
+
+const (
+	// RecoveryRollForward finishes the interrupted operations
+	RecoveryRollForward RecoveryMode = iota
+	// RecoveryRollBack cancels the interrupted operations when possible. As deleted objects can't be restored,
+	// an interrupted RemoveAll is always finished.
+	RecoveryRollBack
+)
+
+// JournalEntry is a record of the journal. An operation is recorded once before its execution and once
+// with Done set after its completion.
+type JournalEntry struct {
+	Time   time.Time `json:"time"`
+	ID     string    `json:"id"`
+	Op     string    `json:"op"`
+	Name   string    `json:"name"`
+	Target string    `json:"target,omitempty"`
+	Done   bool      `json:"done,omitempty"`
+	// TargetExisted tells if the target existed before the operation, so that a rollback doesn't remove it
+	TargetExisted bool `json:"targetExisted,omitempty"`
+	// Err is the error of a failed operation, which was reported to the caller and isn't recovered
+	Err string `json:"err,omitempty"`
+}
+
+// Journal durably records the mutations performed by an Fs, so that the multi-requests operations
+// (Rename, RemoveAll) interrupted by a crash can be recovered with Fs.Recover.
+type Journal interface {
+	// Append durably adds an entry to the journal
+	Append(entry *JournalEntry) error
+	// Entries returns all the entries of the journal, in order
+	Entries() ([]*JournalEntry, error)
+}
+
+var journalCounter uint64
+
+func newJournalID() string {
+	return fmt.Sprintf("%020d-%06d", time.Now().UnixNano(), atomic.AddUint64(&journalCounter, 1)%1000000)
+}
+
+// fileJournal is a journal stored in a local file, as JSON lines
+type fileJournal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileJournal creates a journal stored in a local file
+func NewFileJournal(name string) (Journal, error) {
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600) // nolint: gosec
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileJournal{file: file}, nil
+}
+
+func (j *fileJournal) Append(entry *JournalEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(append(content, '\n')); err != nil {
+		return err
+	}
+
+	return j.file.Sync()
+}
+
+func (j *fileJournal) Entries() ([]*JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	var entries []*JournalEntry
+
+	scanner := bufio.NewScanner(j.file)
+	for scanner.Scan() {
+		entry := &JournalEntry{}
+
+		// An entry partially written during a crash is ignored
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// objectJournal is a journal stored as a stream of small objects sharing a prefix
+type objectJournal struct {
+	fs     *Fs
+	prefix string
+}
+
+// NewObjectJournal creates a journal stored in S3, each entry being stored in an object below the prefix.
+// The prefix should not be part of the paths modified through the journaled Fs.
+func NewObjectJournal(fs *Fs, prefix string) Journal {
+	return &objectJournal{fs: fs, prefix: prefix}
+}
+
+func (j *objectJournal) Append(entry *JournalEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	suffix := "begin"
+	if entry.Done {
+		suffix = "done"
+	}
+
+	_, err = j.fs.s3API.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(j.fs.bucket),
+		Key:         aws.String(fmt.Sprintf("%s/%s-%s", j.prefix, entry.ID, suffix)),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String("application/json"),
+	})
+
+	return err
+}
+
+func (j *objectJournal) Entries() ([]*JournalEntry, error) {
+	var keys []string
+
+	err := j.fs.s3API.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(j.fs.bucket),
+		Prefix: aws.String(j.prefix + "/"),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, *obj.Key)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*JournalEntry, 0, len(keys))
+
+	for _, key := range keys {
+		resp, err := j.fs.s3API.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(j.fs.bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &JournalEntry{}
+		err = json.NewDecoder(resp.Body).Decode(entry)
+		_ = resp.Body.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid journal entry %s: %w", key, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// journalBegin records an operation before its execution, it returns nil when there is no journal
+func (fs *Fs) journalBegin(op, name, target string) (*JournalEntry, error) {
+	if fs.Journal == nil {
+		return nil, nil
+	}
+
+	entry := &JournalEntry{ID: newJournalID(), Op: op, Name: name, Target: target, Time: time.Now().UTC()}
+
+	if target != "" {
+		_, err := fs.Stat(target)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		entry.TargetExisted = err == nil
+	}
+
+	return entry, fs.Journal.Append(entry)
+}
+
+// journalEnd records the completion of an operation, or its failure so that it isn't recovered. It returns the error
+// of the operation, or the one of the journal.
+func (fs *Fs) journalEnd(entry *JournalEntry, err error) error {
+	if entry == nil {
+		return err
+	}
+
+	done := *entry
+	done.Done = true
+	done.Time = time.Now().UTC()
+
+	if err != nil {
+		done.Err = err.Error()
+	}
+
+	if errJournal := fs.Journal.Append(&done); err == nil {
+		return errJournal
+	}
+
+	return err
+}
+
+// PendingJournalEntries returns the operations that were started but not completed, the failed ones being left out
+func (fs *Fs) PendingJournalEntries() ([]*JournalEntry, error) {
+	if fs.Journal == nil {
+		return nil, errors.New("no journal defined")
+	}
+
+	entries, err := fs.Journal.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]*JournalEntry)
+
+	for _, entry := range entries {
+		if entry.Done {
+			delete(pending, entry.ID)
+		} else {
+			pending[entry.ID] = entry
+		}
+	}
+
+	list := make([]*JournalEntry, 0, len(pending))
+	for _, entry := range pending {
+		list = append(list, entry)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list, nil
+}
+
+// Recover finishes or rolls back the operations that were interrupted, it returns the recovered operations.
+// It should be called at startup, before any other operation is performed on the Fs.
+func (fs *Fs) Recover(mode RecoveryMode) ([]*JournalEntry, error) {
+	pending, err := fs.PendingJournalEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range pending {
+		switch entry.Op {
+		case JournalOpRename:
+			err = fs.recoverRename(entry, mode)
+		case JournalOpRemoveAll:
+			err = fs.removeAllWithOptions(fs.requestContext(), entry.Name, nil)
+		default:
+			err = fmt.Errorf("unknown journal operation: %s", entry.Op)
+		}
+
+		// A failed recovery stays pending, to be tried again
+		if err == nil {
+			err = fs.journalEnd(entry, nil)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("couldn't recover %s of %s: %w", entry.Op, entry.Name, err)
+		}
+	}
+
+	return pending, nil
+}
+
+func (fs *Fs) recoverRename(entry *JournalEntry, mode RecoveryMode) error {
+	_, errSource := fs.Stat(entry.Name)
+	sourceExists := errSource == nil
+
+	if errSource != nil && !errors.Is(errSource, os.ErrNotExist) {
+		return errSource
+	}
+
+	switch {
+	case sourceExists && mode == RecoveryRollBack && entry.TargetExisted:
+		// The copy might have replaced the target, which can't be restored but must not be removed
+		return nil
+	case sourceExists && mode == RecoveryRollBack:
+		// The copy might have been made
+		return fs.forceRemove(entry.Target)
+	case sourceExists:
+		return fs.rename(entry.Name, entry.Target)
+	default:
+		// The source was deleted, which means the copy was complete
+		return nil
+	}
+}
@@ -0,0 +1,144 @@
+package s3
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	journal, err := NewFileJournal(filepath.Join(t.TempDir(), "journal"))
+	req.NoError(err)
+
+	fs.Journal = journal
+
+	testCreateFile(t, fs, "/file1", "Hello")
+	req.NoError(fs.Rename("/file1", "/file2"))
+
+	pending, err := fs.PendingJournalEntries()
+	req.NoError(err)
+	req.Empty(pending)
+
+	// We simulate a crash that happened right after the copy of a rename
+	testCreateFile(t, fs, "/file3", "world")
+	req.NoError(journal.Append(&JournalEntry{
+		ID: newJournalID(), Op: JournalOpRename, Name: "/file3", Target: "/file4", Time: time.Now(),
+	}))
+
+	t.Run("RollBack", func(t *testing.T) {
+		testCreateFile(t, fs, "/file4", "world")
+
+		recovered, err := fs.Recover(RecoveryRollBack)
+		req.NoError(err)
+		req.Len(recovered, 1)
+
+		_, err = fs.Stat("/file3")
+		req.NoError(err)
+		_, err = fs.Stat("/file4")
+		req.Error(err)
+	})
+
+	t.Run("RollForward", func(t *testing.T) {
+		req.NoError(journal.Append(&JournalEntry{
+			ID: newJournalID(), Op: JournalOpRename, Name: "/file3", Target: "/file4", Time: time.Now(),
+		}))
+
+		recovered, err := fs.Recover(RecoveryRollForward)
+		req.NoError(err)
+		req.Len(recovered, 1)
+
+		_, err = fs.Stat("/file3")
+		req.Error(err)
+		_, err = fs.Stat("/file4")
+		req.NoError(err)
+	})
+
+	t.Run("RollBackExistingTarget", func(t *testing.T) {
+		// The rename records that its target exists
+		testCreateFile(t, fs, "/file5", "source")
+		testCreateFile(t, fs, "/file6", "target")
+		req.NoError(fs.Rename("/file5", "/file6"))
+
+		entries, err := journal.Entries()
+		req.NoError(err)
+		req.True(entries[len(entries)-1].TargetExisted)
+
+		// We simulate a crash that happened before the copy of a rename over an existing file
+		testCreateFile(t, fs, "/file5", "other source")
+		req.NoError(journal.Append(&JournalEntry{
+			ID: newJournalID(), Op: JournalOpRename, Name: "/file5", Target: "/file6", Time: time.Now(),
+			TargetExisted: true,
+		}))
+
+		recovered, err := fs.Recover(RecoveryRollBack)
+		req.NoError(err)
+		req.Len(recovered, 1)
+
+		data, err := afero.ReadFile(fs, "/file6")
+		req.NoError(err)
+		req.Equal("source", string(data))
+		_, err = fs.Stat("/file5")
+		req.NoError(err)
+	})
+}
+
+func TestObjectJournal(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.Journal = NewObjectJournal(__getS3Fs(t), "journal")
+
+	// The journal bucket is cleaned up first
+	t.Cleanup(func() { fs.Journal = nil })
+
+	testCreateFile(t, fs, "/dir/file1", "Hello")
+	req.NoError(fs.RemoveAll("/dir"))
+
+	entries, err := fs.Journal.Entries()
+	req.NoError(err)
+	req.Len(entries, 2)
+	req.Equal(JournalOpRemoveAll, entries[0].Op)
+	req.True(entries[1].Done)
+}
+
+func TestJournalFailure(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	faults := NewFaultInjector(0)
+	fs.WithFaultInjector(faults)
+
+	journal, err := NewFileJournal(filepath.Join(t.TempDir(), "journal"))
+	req.NoError(err)
+
+	fs.Journal = journal
+
+	// The rename fails after the copy, its failure is reported to the caller
+	testCreateFile(t, fs, "/file1", "Hello")
+	faults.Set("DeleteObject", &Fault{StatusCode: http.StatusForbidden, Code: "AccessDenied"})
+	req.Error(fs.Rename("/file1", "/file2"))
+	faults.Set("DeleteObject", nil)
+
+	entries, err := journal.Entries()
+	req.NoError(err)
+	req.True(entries[len(entries)-1].Done)
+	req.NotEmpty(entries[len(entries)-1].Err)
+
+	// It isn't finished by the recovery
+	pending, err := fs.PendingJournalEntries()
+	req.NoError(err)
+	req.Empty(pending)
+
+	recovered, err := fs.Recover(RecoveryRollForward)
+	req.NoError(err)
+	req.Empty(recovered)
+
+	_, err = fs.Stat("/file1")
+	req.NoError(err)
+}
@@ -0,0 +1,168 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// LifecycleRule is an aging policy applied to the files of a directory
+type LifecycleRule struct {
+	ID string // ID of the rule, unique in the bucket
+	// Path is the directory (or files name prefix) the rule applies to, relative to the directory
+	// the rules are managed for
+	Path string
+	// ExpirationDays is the number of days after which the files are deleted, 0 means never
+	ExpirationDays int64
+	// Transitions move the files to other storage classes
+	Transitions []LifecycleTransition
+	// Disabled keeps the rule without applying it
+	Disabled bool
+}
+
+// LifecycleTransition moves the files to another storage class after some days
+type LifecycleTransition struct {
+	StorageClass string // StorageClass is the target storage class, like s3.TransitionStorageClassGlacier
+	Days         int64  // Days is the number of days after the file creation
+}
+
+// bucketLifecycleRules returns the lifecycle rules of the bucket, the absence of configuration isn't an error
+func (fs *Fs) bucketLifecycleRules() ([]*s3.LifecycleRule, error) {
+	out, err := fs.s3API.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(fs.bucket),
+	})
+	if err != nil {
+		var errAws awserr.Error
+		if errors.As(err, &errAws) && errAws.Code() == "NoSuchLifecycleConfiguration" {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return out.Rules, nil
+}
+
+// putBucketLifecycleRules replaces the lifecycle rules of the bucket
+func (fs *Fs) putBucketLifecycleRules(rules []*s3.LifecycleRule) error {
+	// S3 refuses empty configurations
+	if len(rules) == 0 {
+		_, err := fs.s3API.DeleteBucketLifecycle(&s3.DeleteBucketLifecycleInput{Bucket: aws.String(fs.bucket)})
+		return err
+	}
+
+	_, err := fs.s3API.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(fs.bucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: rules},
+	})
+
+	return err
+}
+
+// rulePrefix returns the prefix a bucket rule applies to, and false if the rule isn't only filtered by prefix
+func rulePrefix(rule *s3.LifecycleRule) (string, bool) {
+	if rule.Filter == nil {
+		return aws.StringValue(rule.Prefix), rule.Prefix != nil
+	}
+
+	if rule.Filter.Tag != nil || rule.Filter.And != nil || rule.Filter.ObjectSizeGreaterThan != nil ||
+		rule.Filter.ObjectSizeLessThan != nil {
+		return "", false
+	}
+
+	return aws.StringValue(rule.Filter.Prefix), true
+}
+
+// GetLifecycleRules returns the lifecycle rules applied to the files of a directory. The rules that aren't only
+// filtered by prefix (like the ones of SetTTLLifecycleRule) are ignored.
+func (fs *Fs) GetLifecycleRules(dir string) ([]*LifecycleRule, error) {
+	bucketRules, err := fs.bucketLifecycleRules()
+	if err != nil {
+		return nil, &os.PathError{Op: "lifecycle", Path: dir, Err: err}
+	}
+
+	scope := dirPrefix(dir)
+	rules := make([]*LifecycleRule, 0, len(bucketRules))
+
+	for _, bucketRule := range bucketRules {
+		prefix, ok := rulePrefix(bucketRule)
+		if !ok || !strings.HasPrefix(prefix, scope) {
+			continue
+		}
+
+		rule := &LifecycleRule{
+			ID:       aws.StringValue(bucketRule.ID),
+			Path:     strings.TrimPrefix(prefix, scope),
+			Disabled: aws.StringValue(bucketRule.Status) == s3.ExpirationStatusDisabled,
+		}
+
+		if bucketRule.Expiration != nil {
+			rule.ExpirationDays = aws.Int64Value(bucketRule.Expiration.Days)
+		}
+
+		for _, transition := range bucketRule.Transitions {
+			rule.Transitions = append(rule.Transitions, LifecycleTransition{
+				StorageClass: aws.StringValue(transition.StorageClass),
+				Days:         aws.Int64Value(transition.Days),
+			})
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// SetLifecycleRules replaces the lifecycle rules applied to the files of a directory by the given ones.
+// The rules of the bucket applying to other directories are kept.
+func (fs *Fs) SetLifecycleRules(dir string, rules []*LifecycleRule) error {
+	bucketRules, err := fs.bucketLifecycleRules()
+	if err != nil {
+		return &os.PathError{Op: "lifecycle", Path: dir, Err: err}
+	}
+
+	scope := dirPrefix(dir)
+	kept := make([]*s3.LifecycleRule, 0, len(bucketRules)+len(rules))
+
+	for _, bucketRule := range bucketRules {
+		if prefix, ok := rulePrefix(bucketRule); !ok || !strings.HasPrefix(prefix, scope) {
+			kept = append(kept, bucketRule)
+		}
+	}
+
+	for _, rule := range rules {
+		bucketRule := &s3.LifecycleRule{
+			ID:     aws.String(rule.ID),
+			Status: aws.String(s3.ExpirationStatusEnabled),
+			Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(scope + strings.TrimPrefix(rule.Path, "/"))},
+		}
+
+		if rule.Disabled {
+			bucketRule.Status = aws.String(s3.ExpirationStatusDisabled)
+		}
+
+		if rule.ExpirationDays > 0 {
+			bucketRule.Expiration = &s3.LifecycleExpiration{Days: aws.Int64(rule.ExpirationDays)}
+		}
+
+		for _, transition := range rule.Transitions {
+			bucketRule.Transitions = append(bucketRule.Transitions, &s3.Transition{
+				StorageClass: aws.String(transition.StorageClass),
+				Days:         aws.Int64(transition.Days),
+			})
+		}
+
+		kept = append(kept, bucketRule)
+	}
+
+	if err := fs.putBucketLifecycleRules(kept); err != nil {
+		return &os.PathError{Op: "lifecycle", Path: dir, Err: err}
+	}
+
+	return nil
+}
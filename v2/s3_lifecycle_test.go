@@ -0,0 +1,78 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleRules(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	req.NoError(fs.SetTTLLifecycleRule(24 * time.Hour))
+	req.NoError(fs.SetLifecycleRules("/other", []*LifecycleRule{{ID: "other", ExpirationDays: 7}}))
+
+	rules := []*LifecycleRule{
+		{ID: "logs", Path: "logs/", ExpirationDays: 30},
+		{
+			ID:          "archives",
+			Path:        "archives/",
+			Transitions: []LifecycleTransition{{StorageClass: s3.TransitionStorageClassGlacier, Days: 90}},
+		},
+	}
+	req.NoError(fs.SetLifecycleRules("/data", rules))
+
+	got, err := fs.GetLifecycleRules("/data")
+	req.NoError(err)
+	req.Equal(rules, got)
+
+	// Replacing the rules of a directory keeps the other ones
+	req.NoError(fs.SetLifecycleRules("/data", rules[:1]))
+
+	got, err = fs.GetLifecycleRules("/data")
+	req.NoError(err)
+	req.Len(got, 1)
+
+	bucketRules, err := fs.bucketLifecycleRules()
+	req.NoError(err)
+	req.Len(bucketRules, 3)
+
+	// The TTL rule isn't scoped to any directory
+	got, err = fs.GetLifecycleRules("/")
+	req.NoError(err)
+	req.Len(got, 2)
+
+	req.NoError(fs.SetLifecycleRules("/", nil))
+
+	bucketRules, err = fs.bucketLifecycleRules()
+	req.NoError(err)
+	req.Len(bucketRules, 1)
+
+	// The disabled rules stay disabled when the rules are written back
+	_, err = fs.s3API.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(fs.bucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: []*s3.LifecycleRule{{
+			ID:         aws.String("paused"),
+			Status:     aws.String(s3.ExpirationStatusDisabled),
+			Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String("data/paused/")},
+			Expiration: &s3.LifecycleExpiration{Days: aws.Int64(3)},
+		}}},
+	})
+	req.NoError(err)
+
+	got, err = fs.GetLifecycleRules("/data")
+	req.NoError(err)
+	req.Equal([]*LifecycleRule{{ID: "paused", Path: "paused/", ExpirationDays: 3, Disabled: true}}, got)
+
+	req.NoError(fs.SetLifecycleRules("/data", append(got, rules[0])))
+
+	bucketRules, err = fs.bucketLifecycleRules()
+	req.NoError(err)
+	req.Len(bucketRules, 2)
+	req.Equal(s3.ExpirationStatusDisabled, aws.StringValue(bucketRules[0].Status))
+	req.Equal(s3.ExpirationStatusEnabled, aws.StringValue(bucketRules[1].Status))
+}
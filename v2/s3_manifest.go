@@ -0,0 +1,398 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+)
+
+// manifestBatchSize is the number of objects described in parallel while exporting a manifest with details
+const manifestBatchSize = 1000
+
+// ManifestFormat is the encoding of a manifest
+type ManifestFormat int
+
+const (
+	// ManifestJSON encodes a manifest with one JSON object per line
+	ManifestJSON ManifestFormat = iota
+	// ManifestCSV encodes a manifest in CSV with a header line, the metadata being encoded in JSON
+	ManifestCSV
+)
+
+// manifestColumns are the columns of the CSV manifests
+var manifestColumns = []string{"key", "size", "etag", "checksum", "mtime", "metadata"}
+
+// ManifestEntry describes an object in a manifest
+type ManifestEntry struct {
+	Key      string            `json:"key"`                // Key of the object
+	Size     int64             `json:"size"`               // Size of the object
+	ETag     string            `json:"etag"`               // ETag of the object
+	Checksum string            `json:"checksum,omitempty"` // Checksum is the additional checksum stored by S3, if any
+	ModTime  time.Time         `json:"mtime"`              // ModTime is the last modification time of the object
+	Metadata map[string]string `json:"metadata,omitempty"` // Metadata is the user metadata of the object
+}
+
+// ManifestOptions defines how ExportManifest describes the objects
+type ManifestOptions struct {
+	// Format of the manifest, ManifestJSON by default
+	Format ManifestFormat
+	// Details adds the metadata and the checksums to the entries, with a HeadObject request per object
+	Details bool
+	// Concurrency is the number of parallel HeadObject requests, DefaultBulkConcurrency if 0
+	Concurrency int
+}
+
+// ExportManifest writes the manifest of all the objects of a directory, recursively, in the order of their keys. It
+// allows to validate a migration or to catalog a backup, see VerifyManifest and ImportManifest. The opts can be nil.
+func (fs *Fs) ExportManifest(prefix string, w io.Writer, opts *ManifestOptions) error {
+	if opts == nil {
+		opts = &ManifestOptions{}
+	}
+
+	enc := newManifestEncoder(w, opts.Format)
+	batch := make([]*ManifestEntry, 0, manifestBatchSize)
+
+	var errWrite error
+
+	flush := func() bool {
+		if errWrite = fs.describeEntries(batch, opts); errWrite == nil {
+			errWrite = enc.encode(batch)
+		}
+
+		batch = batch[:0]
+
+		return errWrite == nil
+	}
+
+	errList := fs.walkObjects(dirPrefix(prefix), func(obj *s3.Object) bool {
+		batch = append(batch, &ManifestEntry{
+			Key:     aws.StringValue(obj.Key),
+			Size:    aws.Int64Value(obj.Size),
+			ETag:    aws.StringValue(obj.ETag),
+			ModTime: aws.TimeValue(obj.LastModified).UTC(),
+		})
+
+		return len(batch) < cap(batch) || flush()
+	})
+
+	if errList == nil && errWrite == nil {
+		flush()
+	}
+
+	for _, err := range []error{errList, errWrite} {
+		if err != nil {
+			return &os.PathError{Op: "manifest", Path: prefix, Err: err}
+		}
+	}
+
+	return enc.flush()
+}
+
+// describeEntries adds the metadata and the checksums to entries when the details are requested
+func (fs *Fs) describeEntries(entries []*ManifestEntry, opts *ManifestOptions) error {
+	if !opts.Details {
+		return nil
+	}
+
+	bulk := &BulkOptions{Concurrency: opts.Concurrency}
+
+	return forEachIndex("manifest", len(entries), bulk, func(i int) (string, error) {
+		entry := entries[i]
+
+		out, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+			Bucket:       aws.String(fs.bucket),
+			Key:          aws.String(entry.Key),
+			ChecksumMode: aws.String(s3.ChecksumModeEnabled),
+		})
+		if err != nil {
+			return "/" + entry.Key, err
+		}
+
+		entry.Checksum = headChecksum(out)
+
+		if len(out.Metadata) > 0 {
+			entry.Metadata = aws.StringValueMap(out.Metadata)
+		}
+
+		return "/" + entry.Key, nil
+	})
+}
+
+// headChecksum returns the additional checksum of an object, prefixed by its algorithm
+func headChecksum(out *s3.HeadObjectOutput) string {
+	for algorithm, checksum := range map[string]*string{
+		s3.ChecksumAlgorithmSha256: out.ChecksumSHA256,
+		s3.ChecksumAlgorithmSha1:   out.ChecksumSHA1,
+		s3.ChecksumAlgorithmCrc32c: out.ChecksumCRC32C,
+		s3.ChecksumAlgorithmCrc32:  out.ChecksumCRC32,
+	} {
+		if checksum != nil {
+			return algorithm + ":" + *checksum
+		}
+	}
+
+	return ""
+}
+
+// manifestEncoder writes the entries of a manifest
+type manifestEncoder struct {
+	buffer *bufio.Writer
+	csv    *csv.Writer // csv writes the CSV manifests, nil for the JSON ones
+	header bool        // header tells if the CSV header was written
+}
+
+func newManifestEncoder(w io.Writer, format ManifestFormat) *manifestEncoder {
+	enc := &manifestEncoder{buffer: bufio.NewWriter(w)}
+
+	if format == ManifestCSV {
+		enc.csv = csv.NewWriter(enc.buffer)
+	}
+
+	return enc
+}
+
+func (e *manifestEncoder) encode(entries []*ManifestEntry) error {
+	if e.csv == nil {
+		encoder := json.NewEncoder(e.buffer)
+
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if !e.header {
+		e.header = true
+
+		if err := e.csv.Write(manifestColumns); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		metadata := ""
+
+		if entry.Metadata != nil {
+			raw, err := json.Marshal(entry.Metadata)
+			if err != nil {
+				return err
+			}
+
+			metadata = string(raw)
+		}
+
+		if err := e.csv.Write([]string{
+			entry.Key, strconv.FormatInt(entry.Size, 10), entry.ETag, entry.Checksum,
+			entry.ModTime.Format(time.RFC3339Nano), metadata,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *manifestEncoder) flush() error {
+	if e.csv != nil {
+		if !e.header {
+			if err := e.csv.Write(manifestColumns); err != nil {
+				return err
+			}
+		}
+
+		e.csv.Flush()
+
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+
+	return e.buffer.Flush()
+}
+
+// ReadManifest reads the entries of a manifest written by ExportManifest
+func ReadManifest(r io.Reader, format ManifestFormat) ([]ManifestEntry, error) {
+	if format == ManifestCSV {
+		return readCSVManifest(r)
+	}
+
+	var entries []ManifestEntry
+
+	decoder := json.NewDecoder(r)
+
+	for {
+		var entry ManifestEntry
+
+		if err := decoder.Decode(&entry); err == io.EOF { // nolint: errorlint
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid manifest entry %d: %w", len(entries)+1, err)
+		}
+
+		entries = append(entries, entry)
+	}
+}
+
+func readCSVManifest(r io.Reader) ([]ManifestEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(manifestColumns)
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	entries := make([]ManifestEntry, 0, len(records)-1)
+
+	for line, record := range records[1:] {
+		entry := ManifestEntry{Key: record[0], ETag: record[2], Checksum: record[3]}
+
+		if entry.Size, err = strconv.ParseInt(record[1], 10, 64); err == nil {
+			entry.ModTime, err = time.Parse(time.RFC3339Nano, record[4])
+		}
+
+		if err == nil && record[5] != "" {
+			err = json.Unmarshal([]byte(record[5]), &entry.Metadata)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest entry %d: %w", line+1, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ManifestDiff is the difference between a manifest and the objects of a directory
+type ManifestDiff struct {
+	Missing []ManifestEntry // Missing are the entries of the manifest without any object
+	Changed []ManifestEntry // Changed are the entries of the manifest whose object has another size or ETag
+	Extra   []ManifestEntry // Extra are the objects that aren't in the manifest
+}
+
+// Empty tells if the objects match the manifest
+func (d *ManifestDiff) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Changed) == 0 && len(d.Extra) == 0
+}
+
+// VerifyManifest compares the objects of a directory, recursively, with the entries of a manifest. The objects are
+// compared by size and ETag: the ETags of the objects uploaded in several parts of different sizes, or encrypted with
+// KMS, differ even if their content is the same.
+func (fs *Fs) VerifyManifest(prefix string, entries []ManifestEntry) (*ManifestDiff, error) {
+	expected := make(map[string]*ManifestEntry, len(entries))
+	for i := range entries {
+		expected[entries[i].Key] = &entries[i]
+	}
+
+	diff := &ManifestDiff{}
+
+	err := fs.walkObjects(dirPrefix(prefix), func(obj *s3.Object) bool {
+		entry, ok := expected[aws.StringValue(obj.Key)]
+
+		switch {
+		case !ok:
+			diff.Extra = append(diff.Extra, ManifestEntry{
+				Key:     aws.StringValue(obj.Key),
+				Size:    aws.Int64Value(obj.Size),
+				ETag:    aws.StringValue(obj.ETag),
+				ModTime: aws.TimeValue(obj.LastModified).UTC(),
+			})
+		case entry.Size != aws.Int64Value(obj.Size) || entry.ETag != aws.StringValue(obj.ETag):
+			diff.Changed = append(diff.Changed, *entry)
+		}
+
+		delete(expected, aws.StringValue(obj.Key))
+
+		return true
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "manifest", Path: prefix, Err: err}
+	}
+
+	// The order of the manifest is kept
+	for _, entry := range entries {
+		if _, ok := expected[entry.Key]; ok {
+			diff.Missing = append(diff.Missing, entry)
+		}
+	}
+
+	return diff, nil
+}
+
+// ImportManifest copies from another file system the entries of a manifest that are missing or changed in a
+// directory, with their metadata, to complete a migration. The objects that aren't in the manifest are kept. It stops
+// at the first error.
+func (fs *Fs) ImportManifest(src afero.Fs, prefix string, entries []ManifestEntry, opts *BulkOptions) error {
+	diff, err := fs.VerifyManifest(prefix, entries)
+	if err != nil {
+		return err
+	}
+
+	imported := append(diff.Missing, diff.Changed...) // nolint: gocritic
+
+	return forEachIndex("manifest", len(imported), opts, func(i int) (string, error) {
+		name := "/" + imported[i].Key
+		return name, fs.importEntry(src, name, imported[i].Metadata)
+	})
+}
+
+// importEntry copies a file from another file system with the metadata of its manifest entry
+func (fs *Fs) importEntry(src afero.Fs, name string, metadata map[string]string) error {
+	in, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = in.Close() }()
+
+	params, err := fs.writePermParams(name, 0666)
+	if err != nil {
+		return err
+	}
+
+	if len(metadata) > 0 && params.metadata == nil {
+		params.metadata = make(map[string]*string, len(metadata))
+	}
+
+	for key, value := range metadata {
+		params.metadata[key] = aws.String(value)
+	}
+
+	return fs.uploadFrom(in, name, params)
+}
+
+// uploadFrom streams the content of a reader to a file with the given upload parameters
+func (fs *Fs) uploadFrom(in io.Reader, name string, params uploadParams) error {
+	out := NewFile(fs, name)
+	out.upload = params
+
+	if err := out.openWriteStream(); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	return out.Close()
+}
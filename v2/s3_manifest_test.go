@@ -0,0 +1,104 @@
+package s3
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func testPutWithMetadata(t *testing.T, fs *Fs, name, content, owner string) {
+	_, err := fs.s3API.PutObject(&s3.PutObjectInput{
+		Bucket:   aws.String(fs.bucket),
+		Key:      aws.String(name),
+		Body:     strings.NewReader(content),
+		Metadata: map[string]*string{"Owner": aws.String(owner)},
+	})
+	require.NoError(t, err)
+}
+
+func TestManifestExport(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testPutWithMetadata(t, fs, "/dir/b", "bb", "alice")
+	testCreateFile(t, fs, "/dir/sub/a", "a")
+	testCreateFile(t, fs, "/other", "other")
+
+	for _, format := range []ManifestFormat{ManifestJSON, ManifestCSV} {
+		buffer := &bytes.Buffer{}
+		req.NoError(fs.ExportManifest("/dir", buffer, &ManifestOptions{Format: format, Details: true}))
+
+		entries, err := ReadManifest(buffer, format)
+		req.NoError(err)
+		req.Len(entries, 2)
+		req.Equal("dir/b", entries[0].Key)
+		req.Equal(int64(2), entries[0].Size)
+		req.Equal(map[string]string{"Owner": "alice"}, entries[0].Metadata)
+		req.Equal("dir/sub/a", entries[1].Key)
+		req.Nil(entries[1].Metadata)
+		req.NotEmpty(entries[1].ETag)
+		req.False(entries[1].ModTime.IsZero())
+
+		diff, err := fs.VerifyManifest("/dir", entries)
+		req.NoError(err)
+		req.True(diff.Empty())
+	}
+
+	// An empty manifest still has its header
+	buffer := &bytes.Buffer{}
+	req.NoError(fs.ExportManifest("/missing", buffer, &ManifestOptions{Format: ManifestCSV}))
+	req.Equal("key,size,etag,checksum,mtime,metadata\n", buffer.String())
+
+	_, err := ReadManifest(strings.NewReader("{"), ManifestJSON)
+	req.Error(err)
+}
+
+func TestManifestImport(t *testing.T) {
+	req := require.New(t)
+	src := __getS3Fs(t)
+	dst := __getS3Fs(t)
+
+	testPutWithMetadata(t, src, "/data/a", "a", "alice")
+	testCreateFile(t, src, "/data/b", "b")
+	testCreateFile(t, src, "/data/c", "c")
+
+	testCreateFile(t, dst, "/data/b", "changed")
+	testCreateFile(t, dst, "/data/c", "c")
+	testCreateFile(t, dst, "/data/extra", "extra")
+
+	buffer := &bytes.Buffer{}
+	req.NoError(src.ExportManifest("/data", buffer, &ManifestOptions{Details: true}))
+
+	entries, err := ReadManifest(buffer, ManifestJSON)
+	req.NoError(err)
+
+	diff, err := dst.VerifyManifest("/data", entries)
+	req.NoError(err)
+	req.Len(diff.Missing, 1)
+	req.Equal("data/a", diff.Missing[0].Key)
+	req.Len(diff.Changed, 1)
+	req.Equal("data/b", diff.Changed[0].Key)
+	req.Len(diff.Extra, 1)
+	req.Equal("data/extra", diff.Extra[0].Key)
+
+	req.NoError(dst.ImportManifest(src, "/data", entries, nil))
+
+	data, err := afero.ReadFile(dst, "/data/b")
+	req.NoError(err)
+	req.Equal("b", string(data))
+
+	head, err := dst.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(dst.bucket), Key: aws.String("/data/a")})
+	req.NoError(err)
+	req.Equal("alice", aws.StringValue(head.Metadata["Owner"]))
+
+	diff, err = dst.VerifyManifest("/data", entries)
+	req.NoError(err)
+	req.Empty(diff.Missing)
+	req.Empty(diff.Changed)
+	req.Len(diff.Extra, 1)
+}
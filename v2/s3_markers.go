@@ -0,0 +1,92 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"path"
+	"strings"
+)
+
+// FolderMarkerSuffix is the suffix of the directory markers created by EMR and some other Hadoop tools,
+// "dir_$folder$" representing the "dir" directory
+const FolderMarkerSuffix = "_$folder$"
+
+// dirMarkerSuffix returns the suffix of the created directory markers
+func (fs *Fs) dirMarkerSuffix() string {
+	if fs.DirMarkerSuffix == "" {
+		return "/"
+	}
+
+	return fs.DirMarkerSuffix
+}
+
+// dirMarkerKey returns the key of the marker created for a directory
+func (fs *Fs) dirMarkerKey(dir string) string {
+	return strings.TrimPrefix(path.Clean("/"+dir), "/") + fs.dirMarkerSuffix()
+}
+
+// markerSuffixes returns the recognized marker suffixes, apart from the "/" one which is always recognized
+func (fs *Fs) markerSuffixes() []string {
+	suffixes := fs.DirMarkerSuffixes
+
+	if suffix := fs.dirMarkerSuffix(); suffix != "/" {
+		suffixes = append([]string{suffix}, suffixes...)
+	}
+
+	return suffixes
+}
+
+// markerDir returns the directory represented by a key if it's a marker with a recognized suffix
+func (fs *Fs) markerDir(key string) (string, bool) {
+	for _, suffix := range fs.markerSuffixes() {
+		if suffix != "/" && strings.HasSuffix(key, suffix) && len(key) > len(suffix) {
+			return strings.TrimSuffix(key, suffix), true
+		}
+	}
+
+	return "", false
+}
+
+// hasSuffixMarker tells if a directory has a marker with a recognized suffix, other than "/"
+func (fs *Fs) hasSuffixMarker(dir string) (bool, error) {
+	for _, suffix := range fs.markerSuffixes() {
+		if suffix == "/" {
+			continue
+		}
+
+		exists, err := fs.markerExists(strings.TrimPrefix(dir, "/") + suffix)
+		if exists || err != nil {
+			return exists, err
+		}
+	}
+
+	return false, nil
+}
+
+// suffixMarkers returns the names of the markers of a directory with a recognized suffix, other than "/"
+func (fs *Fs) suffixMarkers(dir string) []string {
+	// The root doesn't have any marker
+	if path.Clean("/"+dir) == "/" {
+		return nil
+	}
+
+	var markers []string
+
+	for _, suffix := range fs.markerSuffixes() {
+		if suffix != "/" {
+			markers = append(markers, strings.TrimSuffix(dir, "/")+suffix)
+		}
+	}
+
+	return markers
+}
+
+// removeSuffixMarkers removes the markers of a directory with a recognized suffix, other than "/"
+func (fs *Fs) removeSuffixMarkers(dir string) error {
+	for _, marker := range fs.suffixMarkers(dir) {
+		if err := fs.forceRemove(marker); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFolderMarkers(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.DirMarkerSuffixes = []string{FolderMarkerSuffix}
+
+	// Markers created by another tool
+	for _, key := range []string{"dir/empty_$folder$", "dir/sub_$folder$", "dir/sub/file"} {
+		_, err := fs.s3API.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(fs.bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader([]byte{}),
+		})
+		req.NoError(err)
+	}
+
+	dir, err := fs.Open("/dir")
+	req.NoError(err)
+
+	infos, err := dir.Readdir(0)
+	req.NoError(err)
+	req.Len(infos, 2)
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	req.Equal("empty", infos[0].Name())
+	req.True(infos[0].IsDir())
+	req.Equal("sub", infos[1].Name())
+
+	info, err := fs.Stat("/dir/empty")
+	req.NoError(err)
+	req.True(info.IsDir())
+
+	// Markers are created with the same style
+	fs.DirMarkerSuffix = FolderMarkerSuffix
+	req.NoError(fs.Mkdir("/other", 0755))
+
+	_, err = fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String("other_$folder$"),
+	})
+	req.NoError(err)
+
+	info, err = fs.Stat("/other")
+	req.NoError(err)
+	req.True(info.IsDir())
+
+	req.NoError(fs.RemoveAll("/dir"))
+
+	_, err = fs.Stat("/dir/empty")
+	req.Error(err)
+}
@@ -0,0 +1,220 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Metrics counts some events of an Fs. They are shared by all the copies of an Fs.
+type Metrics struct {
+	partRetries int64
+	budgetWait  int64
+	mu          sync.Mutex
+	requests    map[OperationClass]*RequestStats
+}
+
+// OperationClass groups the operations sharing the same S3 request rate limit
+type OperationClass string
+
+const (
+	// OperationClassRead are the GET and HEAD requests
+	OperationClassRead OperationClass = "read"
+	// OperationClassWrite are the PUT, COPY, POST and DELETE requests
+	OperationClassWrite OperationClass = "write"
+	// OperationClassList are the listing requests, which S3 limits like the GET requests
+	OperationClassList OperationClass = "list"
+)
+
+// RequestStats counts the requests of an operation class
+type RequestStats struct {
+	Attempts  int64 // Attempts is the number of requests sent, the retries included
+	Retries   int64 // Retries is the number of requests sent again after a failure
+	Throttles int64 // Throttles is the number of requests throttled by S3 with a 503 SlowDown response
+	Timeouts  int64 // Timeouts is the number of requests that timed out
+}
+
+// PartRetries returns the number of times an uploaded part had to be sent again
+func (m *Metrics) PartRetries() int64 {
+	return atomic.LoadInt64(&m.partRetries)
+}
+
+// BudgetWait returns the total time the uploads spent waiting for the memory budget
+func (m *Metrics) BudgetWait() time.Duration {
+	return time.Duration(atomic.LoadInt64(&m.budgetWait))
+}
+
+// Requests returns the requests counters of an operation class. Comparing the Attempts and Throttles over time to
+// the request rate limits of S3, per prefix, helps to plan the capacity of the bucket.
+func (m *Metrics) Requests(class OperationClass) RequestStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stats, ok := m.requests[class]; ok {
+		return *stats
+	}
+
+	return RequestStats{}
+}
+
+// operationClass returns the class of the operation of a request
+func operationClass(r *request.Request) OperationClass {
+	switch {
+	case strings.HasPrefix(r.Operation.Name, "List"):
+		return OperationClassList
+	case r.Operation.HTTPMethod == http.MethodGet || r.Operation.HTTPMethod == http.MethodHead:
+		return OperationClassRead
+	default:
+		return OperationClassWrite
+	}
+}
+
+// isThrottle tells if an error is a 503 SlowDown response
+func isThrottle(err error) bool {
+	var aerr awserr.Error
+
+	return httpStatus(err) == http.StatusServiceUnavailable || (errors.As(err, &aerr) && aerr.Code() == "SlowDown")
+}
+
+// isTimeout tells if an error is a timeout, reported by S3 or by the client
+func isTimeout(err error) bool {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case "RequestTimeout", request.ErrCodeResponseTimeout:
+			return true
+		}
+
+		if aerr.OrigErr() != nil {
+			err = aerr.OrigErr()
+		}
+	}
+
+	var nerr net.Error
+
+	return (errors.As(err, &nerr) && nerr.Timeout()) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// count updates the counters of an operation class
+func (m *Metrics) count(class OperationClass, update func(stats *RequestStats)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.requests == nil {
+		m.requests = make(map[OperationClass]*RequestStats)
+	}
+
+	if m.requests[class] == nil {
+		m.requests[class] = &RequestStats{}
+	}
+
+	update(m.requests[class])
+}
+
+// countRequests adds handlers counting the attempts, retries, throttles and timeouts of the requests
+func (m *Metrics) countRequests(handlers *request.Handlers) {
+	handlers.CompleteAttempt.PushBackNamed(request.NamedHandler{
+		Name: "afero-s3.CountAttempts",
+		Fn: func(r *request.Request) {
+			m.count(operationClass(r), func(stats *RequestStats) {
+				stats.Attempts++
+
+				if r.Error == nil {
+					return
+				}
+
+				if isThrottle(r.Error) {
+					stats.Throttles++
+				}
+
+				if isTimeout(r.Error) {
+					stats.Timeouts++
+				}
+			})
+		},
+	})
+
+	handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "afero-s3.CountRetries",
+		Fn: func(r *request.Request) {
+			if r.RetryCount > 0 {
+				m.count(operationClass(r), func(stats *RequestStats) { stats.Retries += int64(r.RetryCount) })
+			}
+		},
+	})
+}
+
+// metricsOption counts the requests of other clients in the metrics of the Fs
+func (fs *Fs) metricsOption() request.Option {
+	return func(r *request.Request) {
+		if fs.metrics != nil {
+			fs.metrics.countRequests(&r.Handlers)
+		}
+	}
+}
+
+// Metrics returns the metrics of the Fs
+func (fs *Fs) Metrics() *Metrics {
+	if fs.metrics == nil {
+		return &Metrics{}
+	}
+
+	return fs.metrics
+}
+
+// partNumber returns the part number of a request uploading a part, PutObject being the single part of an upload.
+// It returns 0 for other requests.
+func partNumber(r *request.Request) int64 {
+	switch params := r.Params.(type) {
+	case *s3.UploadPartInput:
+		return *params.PartNumber
+	case *s3.PutObjectInput:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// partRetryOption applies the part retry policy of the Fs to the requests of an upload. The uploader buffers each
+// part, which allows to send a part again when a transient error like RequestTimeout happens.
+func (fs *Fs) partRetryOption(name string) request.Option {
+	return func(r *request.Request) {
+		part := partNumber(r)
+		if part == 0 {
+			return
+		}
+
+		if fs.UploadPartRetries > 0 {
+			r.Retryer = client.DefaultRetryer{
+				NumMaxRetries: fs.UploadPartRetries,
+				MinRetryDelay: client.DefaultRetryerMinRetryDelay,
+				MaxRetryDelay: client.DefaultRetryerMaxRetryDelay,
+			}
+		}
+
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			if r.RetryCount == 0 {
+				return
+			}
+
+			if fs.metrics != nil {
+				atomic.AddInt64(&fs.metrics.partRetries, int64(r.RetryCount))
+			}
+
+			if fs.OnPartRetry != nil {
+				fs.OnPartRetry(name, part, r.RetryCount, r.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package s3
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadPartRetries(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.UploadPartRetries = 5
+
+	var (
+		mu      sync.Mutex
+		retried = make(map[int64]int)
+		failed  = make(map[int64]bool)
+	)
+
+	// Every part times out once
+	fs.session = fs.session.Copy()
+	fs.session.Handlers.Send.PushBack(func(r *request.Request) {
+		part := partNumber(r)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if part != 0 && !failed[part] {
+			failed[part] = true
+			r.Error = awserr.NewRequestFailure(awserr.New("RequestTimeout", "timeout", nil), 400, "")
+		}
+	})
+
+	fs.OnPartRetry = func(name string, part int64, retries int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		req.NoError(err)
+		retried[part] = retries
+	}
+
+	content := bytes.Repeat([]byte("a"), 12*1024*1024)
+
+	file, err := fs.OpenFile("/big", os.O_WRONLY, 0)
+	req.NoError(err)
+	_, err = file.Write(content)
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	info, err := fs.Stat("/big")
+	req.NoError(err)
+	req.Equal(int64(len(content)), info.Size())
+
+	req.Equal(map[int64]int{1: 1, 2: 1, 3: 1}, retried)
+	req.Equal(int64(3), fs.Metrics().PartRetries())
+}
+
+func TestRequestMetrics(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/file", "content")
+
+	before := fs.Metrics().Requests(OperationClassRead)
+
+	fs.s3API.Retryer = client.DefaultRetryer{
+		NumMaxRetries:    2,
+		MinRetryDelay:    time.Millisecond,
+		MaxRetryDelay:    time.Millisecond,
+		MinThrottleDelay: time.Millisecond,
+		MaxThrottleDelay: time.Millisecond,
+	}
+
+	faults := NewFaultInjector(0)
+	fs.WithFaultInjector(faults)
+
+	defer fs.WithFaultInjector(nil)
+
+	// Each throttled or timed out request is retried twice before failing
+	faults.Set("HeadObject", &Fault{StatusCode: http.StatusServiceUnavailable, Code: "SlowDown"})
+	_, err := fs.Stat("/file")
+	req.Error(err)
+
+	faults.Set("HeadObject", &Fault{StatusCode: http.StatusBadRequest, Code: "RequestTimeout"})
+	_, err = fs.Stat("/file")
+	req.Error(err)
+
+	stats := fs.Metrics().Requests(OperationClassRead)
+	req.Equal(before.Attempts+6, stats.Attempts)
+	req.Equal(before.Retries+4, stats.Retries)
+	req.Equal(before.Throttles+3, stats.Throttles)
+	req.Equal(before.Timeouts+3, stats.Timeouts)
+
+	// The other classes are counted separately
+	fs.WithFaultInjector(nil)
+
+	_, err = afero.ReadDir(fs, "/")
+	req.NoError(err)
+	req.Positive(fs.Metrics().Requests(OperationClassList).Attempts)
+	req.Positive(fs.Metrics().Requests(OperationClassWrite).Attempts)
+	req.Zero(fs.Metrics().Requests(OperationClassWrite).Throttles)
+}
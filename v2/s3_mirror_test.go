@@ -0,0 +1,78 @@
+package s3
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirror(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.Mirror = __getS3Fs(t)
+
+	testCreateFile(t, fs, "/dir/file", "Hello world !")
+
+	file, err := fs.Create("/empty")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	for _, target := range []*Fs{fs, fs.Mirror} {
+		info, err := target.Stat("/dir/file")
+		req.NoError(err)
+		req.Equal(int64(13), info.Size())
+
+		_, err = target.Stat("/empty")
+		req.NoError(err)
+	}
+
+	t.Run("RenameAndRemove", func(t *testing.T) {
+		testCreateFile(t, fs, "/moved/file1", "content")
+		testCreateFile(t, fs, "/moved/file2", "content")
+		testCreateFile(t, fs, "/tree/sub/file", "content")
+
+		req.NoError(fs.Rename("/moved/file1", "/moved/file3"))
+		req.NoError(fs.Remove("/moved/file2"))
+		req.NoError(fs.RemoveAll("/tree"))
+
+		// The mirror gets the same changes
+		for _, target := range []*Fs{fs, fs.Mirror} {
+			_, err := target.Stat("/moved/file3")
+			req.NoError(err)
+
+			for _, name := range []string{"/moved/file1", "/moved/file2", "/tree/sub/file"} {
+				_, err = target.Stat(name)
+				req.ErrorIs(err, os.ErrNotExist, name)
+			}
+		}
+	})
+
+	t.Run("MirrorFailure", func(t *testing.T) {
+		bucket := fs.Mirror.bucket
+		fs.Mirror.bucket = "missing-bucket"
+		defer func() { fs.Mirror.bucket = bucket }()
+
+		file, err := fs.OpenFile("/dir/file2", os.O_WRONLY, 0)
+		req.NoError(err)
+		_, _ = file.WriteString("Hello")
+		req.Error(file.Close())
+
+		_, err = fs.Stat("/dir/file2")
+		req.ErrorIs(err, os.ErrNotExist)
+
+		// The file of reference is left unchanged, whatever the size of the content
+		for _, size := range []int{5, 6 * 1024 * 1024} {
+			file, err = fs.OpenFile("/dir/file", os.O_WRONLY|os.O_TRUNC, 0)
+			req.NoError(err)
+			_, _ = file.Write(bytes.Repeat([]byte("x"), size))
+			req.Error(file.Close())
+
+			content, err := afero.ReadFile(fs, "/dir/file")
+			req.NoError(err)
+			req.Equal("Hello world !", string(content))
+		}
+	})
+}
@@ -0,0 +1,120 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// Names of the handlers routing the requests to a Multi-Region Access Point
+const (
+	mrapRouteHandler   = "afero-s3.RouteMRAP"
+	mrapRestoreHandler = "afero-s3.RestoreMRAPBucket"
+)
+
+// multiRegionAccessPoint is an S3 Multi-Region Access Point used as the bucket of an Fs, like
+// arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap
+type multiRegionAccessPoint struct {
+	arn   string
+	alias string // alias is the alias of the access point, like mfzwi23gnjvgw.mrap
+	host  string // host is the global endpoint of the access point, routing to the nearest region
+}
+
+// parseMRAP returns the Multi-Region Access Point of a bucket, nil if it isn't one
+func parseMRAP(bucket string) *multiRegionAccessPoint {
+	if !arn.IsARN(bucket) {
+		return nil
+	}
+
+	parsed, err := arn.Parse(bucket)
+	if err != nil || parsed.Service != "s3" || parsed.Region != "" {
+		return nil
+	}
+
+	alias := strings.TrimPrefix(strings.TrimPrefix(parsed.Resource, "accesspoint/"), "accesspoint:")
+	if alias == parsed.Resource || !strings.HasSuffix(alias, ".mrap") || strings.ContainsAny(alias, "/:") {
+		return nil
+	}
+
+	dnsSuffix := "amazonaws.com"
+
+	for _, partition := range endpoints.DefaultPartitions() {
+		if partition.ID() == parsed.Partition {
+			dnsSuffix = partition.DNSSuffix()
+		}
+	}
+
+	return &multiRegionAccessPoint{arn: bucket, alias: alias, host: alias + ".accesspoint.s3-global." + dnsSuffix}
+}
+
+// install installs the handlers routing the requests of the Fs to the access point, they're signed with SigV4A for
+// all the regions
+func (ap *multiRegionAccessPoint) install(handlers *request.Handlers) {
+	if ap == nil {
+		return
+	}
+
+	// The SDK can't route the ARNs without region: the bucket is replaced by the alias while the request is built
+	handlers.Build.PushFrontNamed(request.NamedHandler{Name: mrapRouteHandler, Fn: ap.route})
+	handlers.Build.PushBackNamed(request.NamedHandler{Name: mrapRestoreHandler, Fn: ap.restore})
+}
+
+// mrapOption installs the handlers routing the requests to the Multi-Region Access Point of the Fs, for the
+// requests of the clients other than the Fs one, like the uploaders
+func (fs *Fs) mrapOption() request.Option {
+	return func(r *request.Request) {
+		fs.mrap.install(&r.Handlers)
+	}
+}
+
+// route sends a request to the global endpoint of the access point
+func (ap *multiRegionAccessPoint) route(r *request.Request) {
+	if values, _ := awsutil.ValuesAtPath(r.Params, "Bucket"); len(values) == 0 || !bucketIs(values[0], ap.arn) {
+		return
+	}
+
+	awsutil.SetValueAtPath(r.Params, "Bucket", ap.alias)
+	r.Config.S3ForcePathStyle = aws.Bool(true)
+
+	u := r.HTTPRequest.URL
+	u.Host = ap.host
+	u.Scheme = "https"
+
+	if aws.BoolValue(r.Config.DisableSSL) {
+		u.Scheme = "http"
+	}
+
+	u.Path = strings.Replace(u.Path, "/{Bucket}", "", 1)
+	u.RawPath = strings.Replace(u.RawPath, "/{Bucket}", "", 1)
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	r.Handlers.Sign.Swap(v4.SignRequestHandler.Name, SigV4AHandler("s3", "*"))
+}
+
+// restore sets the ARN back as the bucket of a built request
+func (ap *multiRegionAccessPoint) restore(r *request.Request) {
+	if values, _ := awsutil.ValuesAtPath(r.Params, "Bucket"); len(values) > 0 && bucketIs(values[0], ap.alias) {
+		awsutil.SetValueAtPath(r.Params, "Bucket", ap.arn)
+	}
+}
+
+// bucketIs tells if a bucket parameter has a value
+func bucketIs(bucket interface{}, value string) bool {
+	switch b := bucket.(type) {
+	case *string:
+		return aws.StringValue(b) == value
+	case string:
+		return b == value
+	default:
+		return false
+	}
+}
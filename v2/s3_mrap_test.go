@@ -0,0 +1,159 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiRegionAccessPoint(t *testing.T) {
+	req := require.New(t)
+	mrap := "arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap"
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "token"),
+		Region:      aws.String("eu-west-1"),
+	})
+	req.NoError(err)
+
+	fs := NewFs(mrap, sess)
+
+	input := &s3.GetObjectInput{Bucket: aws.String(mrap), Key: aws.String("dir/file name.txt")}
+	r, _ := fs.s3API.GetObjectRequest(input)
+	req.NoError(r.Sign())
+
+	// The request is sent to the global endpoint of the access point
+	req.Equal("https://mfzwi23gnjvgw.mrap.accesspoint.s3-global.amazonaws.com/dir/file%20name.txt",
+		r.HTTPRequest.URL.String())
+	req.Equal(mrap, aws.StringValue(input.Bucket))
+
+	// It's signed with SigV4A for all the regions
+	header := r.HTTPRequest.Header
+	req.Equal("*", header.Get("X-Amz-Region-Set"))
+	req.Equal("token", header.Get("X-Amz-Security-Token"))
+
+	matches := regexp.MustCompile(
+		`^AWS4-ECDSA-P256-SHA256 Credential=AKIDEXAMPLE/\d{8}/s3/aws4_request, SignedHeaders=(\S+), Signature=(\w+)$`,
+	).FindStringSubmatch(header.Get("Authorization"))
+	req.Len(matches, 3)
+	req.Contains(strings.Split(matches[1], ";"), "host")
+	req.Contains(strings.Split(matches[1], ";"), "x-amz-region-set")
+
+	key, err := deriveV4AKey("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	req.NoError(err)
+
+	canonical, signedHeaders := v4ACanonicalRequest(r.HTTPRequest, header.Get("X-Amz-Content-Sha256"))
+	req.Equal(matches[1], signedHeaders)
+
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	digest := sha256.Sum256([]byte(strings.Join([]string{
+		"AWS4-ECDSA-P256-SHA256",
+		header.Get("X-Amz-Date"),
+		header.Get("X-Amz-Date")[:8] + "/s3/aws4_request",
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")))
+
+	signature, err := hex.DecodeString(matches[2])
+	req.NoError(err)
+	req.True(ecdsa.VerifyASN1(&key.PublicKey, digest[:], signature))
+
+	// The regular buckets aren't affected
+	r, _ = NewFs("bucket", sess).s3API.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("file"),
+	})
+	req.NoError(r.Sign())
+	req.True(strings.HasPrefix(r.HTTPRequest.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "))
+
+	_, err = fs.regionOf(mrap)
+	req.ErrorIs(err, ErrUnknownRegion)
+}
+
+// mrapTransport sends the requests of the global endpoints of the Multi-Region Access Points to a bucket of the test
+// server, recording their signature algorithm
+type mrapTransport struct {
+	bucket     string
+	mu         sync.Mutex
+	algorithms map[string]bool
+}
+
+func (m *mrapTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(r.URL.Host, ".accesspoint.s3-global.amazonaws.com") {
+		endpoint, err := url.Parse(testEndpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		r.URL.Scheme, r.URL.Host = endpoint.Scheme, endpoint.Host
+		r.URL.Path, r.URL.RawPath = "/"+m.bucket+r.URL.Path, ""
+
+		m.mu.Lock()
+		m.algorithms[strings.Fields(r.Header.Get("Authorization"))[0]] = true
+		m.mu.Unlock()
+	}
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestMultiRegionAccessPointWrites(t *testing.T) {
+	req := require.New(t)
+	target := __getS3Fs(t)
+	transport := &mrapTransport{bucket: target.bucket, algorithms: make(map[string]bool)}
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", ""),
+		Region:      aws.String("eu-west-1"),
+	})
+	req.NoError(err)
+
+	sess.Config.HTTPClient = &http.Client{Transport: transport}
+
+	fs := NewFs("arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap", sess)
+
+	// The streamed uploads are routed to the access point too
+	for _, size := range []int{5, 6 * 1024 * 1024} {
+		file, err := fs.OpenFile("/file", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		req.NoError(err)
+
+		_, err = file.Write(bytes.Repeat([]byte("x"), size))
+		req.NoError(err)
+		req.NoError(file.Close())
+
+		info, err := target.Stat("/file")
+		req.NoError(err)
+		req.Equal(int64(size), info.Size())
+	}
+
+	req.Equal(map[string]bool{"AWS4-ECDSA-P256-SHA256": true}, transport.algorithms)
+}
+
+func TestSigV4AKey(t *testing.T) {
+	req := require.New(t)
+
+	key, err := deriveV4AKey("AKIDEXAMPLE", "secret")
+	req.NoError(err)
+	req.True(key.Curve.IsOnCurve(key.X, key.Y))
+
+	// The key only depends on the credentials
+	same, err := deriveV4AKey("AKIDEXAMPLE", "secret")
+	req.NoError(err)
+	req.Equal(0, key.D.Cmp(same.D))
+
+	other, err := deriveV4AKey("AKIDEXAMPLE", "other")
+	req.NoError(err)
+	req.NotEqual(0, key.D.Cmp(other.D))
+}
@@ -0,0 +1,57 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// The trailing slashes of the names follow the POSIX semantics: "/dir/" is the same as "/dir" but requires it to be
+// a directory.
+//   - Stat, Open, Chmod, Remove and RemoveAll fail with syscall.ENOTDIR for a file with a trailing slash
+//   - the writes of a name with a trailing slash fail with syscall.EISDIR, as its object would be a directory marker
+//   - Rename fails with syscall.ENOTDIR when a trailing slash is given for a file, and with ErrNotSupported for
+//     a directory, with or without trailing slash
+
+// statDirName describes a name with a trailing slash, which must be a directory
+func (fs *Fs) statDirName(name string) (os.FileInfo, error) {
+	info, err := fs.statDirectory(name)
+	if !errors.Is(err, os.ErrNotExist) || path.Clean("/"+name) == "/" {
+		return info, err
+	}
+
+	if _, errFile := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(strings.TrimRight(name, "/")),
+	}); errFile == nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	return nil, err
+}
+
+// checkDirName checks that a name with a trailing slash is a directory
+func (fs *Fs) checkDirName(name string) error {
+	if !strings.HasSuffix(name, "/") {
+		return nil
+	}
+
+	_, err := fs.Stat(name)
+
+	return err
+}
+
+// checkFileName checks that a written name doesn't have a trailing slash
+func checkFileName(name string) error {
+	if strings.HasSuffix(name, "/") {
+		return &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	}
+
+	return nil
+}
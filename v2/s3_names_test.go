@@ -0,0 +1,118 @@
+package s3
+
+import (
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrailingSlashes(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/file", "content")
+	testCreateFile(t, fs, "/dir/file", "content")
+
+	t.Run("Directory", func(t *testing.T) {
+		for _, name := range []string{"/dir", "/dir/", "dir/"} {
+			info, err := fs.Stat(name)
+			req.NoError(err, name)
+			req.True(info.IsDir(), name)
+			req.Equal("dir", info.Name(), name)
+
+			file, err := fs.Open(name)
+			req.NoError(err, name)
+
+			names, err := file.Readdirnames(-1)
+			req.NoError(err, name)
+			req.Equal([]string{"file"}, names, name)
+			req.NoError(file.Close())
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		_, err := fs.Stat("/file/")
+		req.ErrorIs(err, syscall.ENOTDIR)
+
+		_, err = fs.Open("/file/")
+		req.ErrorIs(err, syscall.ENOTDIR)
+
+		req.ErrorIs(fs.Chmod("/file/", 0600), syscall.ENOTDIR)
+		req.ErrorIs(fs.Remove("/file/"), syscall.ENOTDIR)
+		req.ErrorIs(fs.RemoveAll("/file/"), syscall.ENOTDIR)
+		req.ErrorIs(fs.Rename("/file/", "/renamed"), syscall.ENOTDIR)
+		req.ErrorIs(fs.Rename("/file", "/renamed/"), syscall.ENOTDIR)
+
+		_, err = fs.Stat("/file")
+		req.NoError(err)
+
+		_, err = fs.Stat("/missing/")
+		req.ErrorIs(err, os.ErrNotExist)
+		req.NoError(fs.RemoveAll("/missing/"))
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		_, err := fs.Create("/new/")
+		req.ErrorIs(err, syscall.EISDIR)
+
+		_, err = fs.OpenFile("/new/", os.O_WRONLY|os.O_CREATE, 0600)
+		req.ErrorIs(err, syscall.EISDIR)
+
+		req.ErrorIs(fs.WriteFile("/new/", []byte("content"), nil), syscall.EISDIR)
+
+		_, err = fs.Stat("/new")
+		req.ErrorIs(err, os.ErrNotExist)
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		for _, name := range []string{"/dir", "/dir/"} {
+			err := fs.Rename(name, "/other")
+			req.ErrorIs(err, ErrNotSupported, name)
+			req.EqualError(err, "rename "+name+" /other: "+ErrNotSupported.Error())
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		req.NoError(fs.Remove("/dir/file"))
+		req.NoError(fs.Mkdir("/empty", 0750))
+		req.NoError(fs.Remove("/empty/"))
+
+		_, err := fs.Stat("/empty")
+		req.ErrorIs(err, os.ErrNotExist)
+	})
+}
+
+func TestFileInfoNames(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/dir/sub/file", "content")
+	testCreateFile(t, fs, "/dir/file", "content")
+
+	for name, expected := range map[string]string{
+		"/dir/sub/file": "/dir/sub/file",
+		"dir/sub/file":  "/dir/sub/file",
+		"/dir/sub/":     "/dir/sub",
+		"/dir":          "/dir",
+		"/":             "/",
+	} {
+		info, err := fs.Stat(name)
+		req.NoError(err, name)
+		req.Equal(path.Base(expected), info.Name(), name)
+		req.Equal(expected, info.(FileInfo).FullPath(), name)
+	}
+
+	// The entries of a directory have base names and their full path
+	fis, err := afero.ReadDir(fs, "/dir")
+	req.NoError(err)
+	req.Len(fis, 2)
+
+	for _, fi := range fis {
+		req.NotContains(fi.Name(), "/")
+		req.Equal(path.Join("/dir", fi.Name()), fi.(FileInfo).FullPath())
+	}
+}
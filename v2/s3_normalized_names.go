@@ -0,0 +1,376 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/spf13/afero"
+	"golang.org/x/text/unicode/norm"
+)
+
+// OriginalNameMetadataKey is the metadata holding the original name of the files whose name was normalized, escaped
+// like a URL path segment
+const OriginalNameMetadataKey = "Original-Name"
+
+// WindowsReservedNames are the names Windows reserves for its devices, with or without extension
+var WindowsReservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// NameNormalizer normalizes the names of the files, like for the uploads coming from clients of different systems.
+// Each segment of the names is normalized on its own.
+type NameNormalizer struct {
+	// NFC normalizes the unicode names to their composed form, so that "é" typed on macOS and on Linux is the same
+	// file
+	NFC bool
+	// StripControl removes the control characters from the names
+	StripControl bool
+	// MaxComponentLength is the maximum length in bytes of each segment of the names, the longer ones being truncated
+	// with their extension kept, no maximum if 0
+	MaxComponentLength int
+	// ReservedNames are forbidden names, matched case-insensitively and without extension, like WindowsReservedNames.
+	// An underscore is appended to them: "con.txt" is stored as "con_.txt".
+	ReservedNames []string
+}
+
+// Normalize returns the normalized name of a file, a trailing slash being kept. The segments emptied by the
+// normalization are replaced by an underscore.
+func (n *NameNormalizer) Normalize(name string) string {
+	clean := path.Clean("/" + name)
+	if clean == "/" {
+		return "/"
+	}
+
+	segments := strings.Split(clean[1:], "/")
+	for i, segment := range segments {
+		segments[i] = n.normalizeSegment(segment)
+	}
+
+	normalized := "/" + strings.Join(segments, "/")
+	if strings.HasSuffix(name, "/") {
+		normalized += "/"
+	}
+
+	return normalized
+}
+
+// normalizeSegment normalizes a segment of a name
+func (n *NameNormalizer) normalizeSegment(segment string) string {
+	if n.NFC {
+		segment = norm.NFC.String(segment)
+	}
+
+	if n.StripControl {
+		segment = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+
+			return r
+		}, segment)
+	}
+
+	if stem, _, _ := strings.Cut(segment, "."); stem != "" && n.reserved(stem) {
+		segment = stem + "_" + segment[len(stem):]
+	}
+
+	if n.MaxComponentLength > 0 && len(segment) > n.MaxComponentLength {
+		segment = truncateSegment(segment, n.MaxComponentLength)
+	}
+
+	// The emptied segments, or the ones becoming relative, would change the path
+	if segment == "" || segment == "." || segment == ".." {
+		segment = strings.Repeat("_", len(segment)+1)
+	}
+
+	return segment
+}
+
+// reserved tells if the stem of a segment is a reserved name
+func (n *NameNormalizer) reserved(stem string) bool {
+	for _, name := range n.ReservedNames {
+		if strings.EqualFold(stem, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// truncateSegment truncates a segment to a length in bytes on a character boundary, keeping its extension when it's
+// short enough
+func truncateSegment(segment string, length int) string {
+	ext := path.Ext(segment)
+	if len(ext) > length/2 {
+		ext = ""
+	}
+
+	stem := segment[:len(segment)-len(ext)]
+	end := length - len(ext)
+
+	for end > 0 && !utf8.RuneStart(stem[end]) {
+		end--
+	}
+
+	return stem[:end] + ext
+}
+
+// NormalizedNamesFs is an Fs normalizing the names of the files with a NameNormalizer, for the deployments accepting
+// any name from their clients. The files are stored and listed under their normalized names. The original name of
+// the files written under a normalized name is recorded in their OriginalNameMetadataKey metadata, which OriginalName
+// returns.
+type NormalizedNamesFs struct {
+	fs         *Fs
+	normalizer *NameNormalizer
+}
+
+// NewNormalizedNamesFs creates a file system normalizing the names of the files of an existing Fs
+func NewNormalizedNamesFs(fs *Fs, normalizer *NameNormalizer) *NormalizedNamesFs {
+	return &NormalizedNamesFs{fs: fs, normalizer: normalizer}
+}
+
+// Name returns the type of FS object this is
+func (NormalizedNamesFs) Name() string { return "s3-normalized-names" }
+
+// Capabilities returns the features supported by the normalized names file system
+func (nfs *NormalizedNamesFs) Capabilities() Capabilities {
+	return nfs.fs.Capabilities()
+}
+
+// normalized tells if the name of a file is changed by the normalization
+func (nfs *NormalizedNamesFs) normalized(name, stored string) bool {
+	return strings.TrimSuffix(stored, "/") != path.Clean("/"+name)
+}
+
+// writer returns the Fs writing a file, which records its original name when it's normalized
+func (nfs *NormalizedNamesFs) writer(name, stored string) *Fs {
+	if !nfs.normalized(name, stored) {
+		return nfs.fs
+	}
+
+	original := url.PathEscape(path.Clean("/" + name))
+	key := path.Clean("/" + stored)
+	beforeUpload := nfs.fs.BeforeUpload
+
+	fs := nfs.fs.snapshot()
+	fs.BeforeUpload = func(uploaded string, input *s3manager.UploadInput) {
+		if path.Clean("/"+uploaded) == key {
+			metadata := make(map[string]*string, len(input.Metadata)+1)
+			for k, v := range input.Metadata {
+				metadata[k] = v
+			}
+
+			metadata[OriginalNameMetadataKey] = aws.String(original)
+			input.Metadata = metadata
+		}
+
+		if beforeUpload != nil {
+			beforeUpload(uploaded, input)
+		}
+	}
+
+	return fs
+}
+
+// call applies an operation on the normalized name of a file, its errors reporting the original name
+func (nfs *NormalizedNamesFs) call(name string, fn func(stored string) error) error {
+	return clearError(fn(nfs.normalizer.Normalize(name)), name)
+}
+
+// OriginalName returns the original name of a file from its stored name, which is the name itself when it wasn't
+// normalized
+func (nfs *NormalizedNamesFs) OriginalName(stored string) (string, error) {
+	head, err := nfs.fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(nfs.fs.bucket),
+		Key:    aws.String(stored),
+	})
+	if err != nil {
+		return "", pathError("stat", stored, err)
+	}
+
+	return originalName(stored, head.Metadata)
+}
+
+// originalName returns the original name of a file recorded in its metadata
+func originalName(stored string, metadata map[string]*string) (string, error) {
+	for key, value := range metadata {
+		if strings.EqualFold(key, OriginalNameMetadataKey) {
+			original, err := url.PathUnescape(aws.StringValue(value))
+			if err != nil {
+				return "", &os.PathError{Op: "stat", Path: stored, Err: err}
+			}
+
+			return original, nil
+		}
+	}
+
+	return stored, nil
+}
+
+// recordOriginalName records the original name of a renamed file in its metadata, by copying it onto itself. It's
+// removed when the new name isn't normalized.
+func (nfs *NormalizedNamesFs) recordOriginalName(name, stored string) error {
+	fs := nfs.fs
+	key := path.Clean("/" + stored)
+
+	head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		// The directories have no object
+		if isNotFound(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	metadata := make(map[string]*string, len(head.Metadata)+1)
+	recorded := ""
+
+	for k, v := range head.Metadata {
+		if strings.EqualFold(k, OriginalNameMetadataKey) {
+			recorded = aws.StringValue(v)
+			continue
+		}
+
+		metadata[k] = v
+	}
+
+	original := ""
+	if nfs.normalized(name, stored) {
+		original = url.PathEscape(path.Clean("/" + name))
+		metadata[OriginalNameMetadataKey] = aws.String(original)
+	}
+
+	if original == recorded {
+		return nil
+	}
+
+	_, err = fs.s3API.CopyObject(&s3.CopyObjectInput{
+		Bucket:             aws.String(fs.bucket),
+		Key:                aws.String(key),
+		CopySource:         aws.String(copySource(fs.bucket, key)),
+		MetadataDirective:  aws.String(s3.MetadataDirectiveReplace),
+		Metadata:           metadata,
+		ContentType:        head.ContentType,
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		ContentLanguage:    head.ContentLanguage,
+	})
+
+	return err
+}
+
+// Create creates a file
+func (nfs *NormalizedNamesFs) Create(name string) (afero.File, error) {
+	var file afero.File
+
+	err := nfs.call(name, func(stored string) error {
+		var err error
+		file, err = nfs.writer(name, stored).Create(stored)
+
+		return err
+	})
+
+	return file, err
+}
+
+// Mkdir creates a directory
+func (nfs *NormalizedNamesFs) Mkdir(name string, perm os.FileMode) error {
+	return nfs.call(name, func(stored string) error { return nfs.writer(name, stored).Mkdir(stored, perm) })
+}
+
+// MkdirAll creates a directory and all its parents
+func (nfs *NormalizedNamesFs) MkdirAll(name string, perm os.FileMode) error {
+	return nfs.call(name, func(stored string) error { return nfs.writer(name, stored).MkdirAll(stored, perm) })
+}
+
+// Open opens a file for reading
+func (nfs *NormalizedNamesFs) Open(name string) (afero.File, error) {
+	return nfs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens a file like Fs.OpenFile
+func (nfs *NormalizedNamesFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	var file afero.File
+
+	err := nfs.call(name, func(stored string) error {
+		var err error
+		file, err = nfs.writer(name, stored).OpenFile(stored, flag, perm)
+
+		return err
+	})
+
+	return file, err
+}
+
+// Remove removes a file or an empty directory
+func (nfs *NormalizedNamesFs) Remove(name string) error {
+	return nfs.call(name, nfs.fs.Remove)
+}
+
+// RemoveAll removes a directory tree
+func (nfs *NormalizedNamesFs) RemoveAll(name string) error {
+	return nfs.call(name, nfs.fs.RemoveAll)
+}
+
+// Rename renames a file, the original name of the new one being recorded when it's normalized
+func (nfs *NormalizedNamesFs) Rename(oldname, newname string) error {
+	storedNew := nfs.normalizer.Normalize(newname)
+
+	err := nfs.fs.Rename(nfs.normalizer.Normalize(oldname), storedNew)
+	if err == nil {
+		err = nfs.recordOriginalName(newname, storedNew)
+	}
+
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		err = linkErr.Err
+	}
+
+	return renameError(oldname, newname, err)
+}
+
+// Stat describes a file
+func (nfs *NormalizedNamesFs) Stat(name string) (os.FileInfo, error) {
+	var info os.FileInfo
+
+	err := nfs.call(name, func(stored string) error {
+		var err error
+		info, err = nfs.fs.Stat(stored)
+
+		return err
+	})
+
+	return info, err
+}
+
+// Chmod changes the permissions of a file
+func (nfs *NormalizedNamesFs) Chmod(name string, mode os.FileMode) error {
+	return nfs.call(name, func(stored string) error { return nfs.fs.Chmod(stored, mode) })
+}
+
+// Chown changes the owner of a file
+func (nfs *NormalizedNamesFs) Chown(name string, uid, gid int) error {
+	return nfs.call(name, func(stored string) error { return nfs.fs.Chown(stored, uid, gid) })
+}
+
+// Chtimes changes the times of a file
+func (nfs *NormalizedNamesFs) Chtimes(name string, atime, mtime time.Time) error {
+	return nfs.call(name, func(stored string) error { return nfs.fs.Chtimes(stored, atime, mtime) })
+}
@@ -0,0 +1,108 @@
+package s3
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameNormalizer(t *testing.T) {
+	req := require.New(t)
+	n := &NameNormalizer{
+		NFC:                true,
+		StripControl:       true,
+		MaxComponentLength: 16,
+		ReservedNames:      WindowsReservedNames,
+	}
+
+	for name, normalized := range map[string]string{
+		"/dir/file.txt":              "/dir/file.txt",
+		"/café/résumé":            "/café/résumé",
+		"/bad\x00na\x1fme\n.txt":     "/badname.txt",
+		"/\x01\x02/file":             "/_/file",
+		"/.\x07./file":               "/___/file",
+		"/con.txt":                   "/con_.txt",
+		"/dir/Lpt1":                  "/dir/Lpt1_",
+		"/console.txt":               "/console.txt",
+		"/a-very-long-file-name.txt": "/a-very-long-.txt",
+		"/ééééééééé":                 "/éééééééé",
+		"/dir/sub/":                  "/dir/sub/",
+		"/":                          "/",
+	} {
+		req.Equal(normalized, n.Normalize(name), name)
+	}
+}
+
+func TestNormalizedNamesFs(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	nfs := NewNormalizedNamesFs(fs, &NameNormalizer{NFC: true, StripControl: true, ReservedNames: WindowsReservedNames})
+
+	decomposed := "/résumé.txt"
+	composed := "/résumé.txt"
+
+	// The files are stored under their normalized names, and found with any form of them
+	req.NoError(afero.WriteFile(nfs, decomposed, []byte("resume"), 0600))
+
+	data, err := afero.ReadFile(nfs, composed)
+	req.NoError(err)
+	req.Equal("resume", string(data))
+
+	data, err = fs.ReadFile(composed)
+	req.NoError(err)
+	req.Equal("resume", string(data))
+
+	// Their original name is recorded
+	original, err := nfs.OriginalName(composed)
+	req.NoError(err)
+	req.Equal(decomposed, original)
+
+	// Not for the names that weren't changed
+	testCreateFile(t, nfs, "/plain.txt", "plain")
+
+	original, err = nfs.OriginalName("/plain.txt")
+	req.NoError(err)
+	req.Equal("/plain.txt", original)
+
+	// The directories too
+	req.NoError(nfs.MkdirAll("/con/sub\ndir", 0755))
+
+	info, err := fs.Stat("/con_/subdir")
+	req.NoError(err)
+	req.True(info.IsDir())
+
+	names, err := afero.ReadDir(nfs, "/con")
+	req.NoError(err)
+	req.Len(names, 1)
+	req.Equal("subdir", names[0].Name())
+
+	// The renamed files record their new original name
+	req.NoError(nfs.Rename(composed, "/aux.txt"))
+
+	original, err = nfs.OriginalName("/aux_.txt")
+	req.NoError(err)
+	req.Equal("/aux.txt", original)
+
+	data, err = fs.ReadFile("/aux_.txt")
+	req.NoError(err)
+	req.Equal("resume", string(data))
+
+	req.NoError(nfs.Rename("/aux.txt", "/summary.txt"))
+
+	original, err = nfs.OriginalName("/summary.txt")
+	req.NoError(err)
+	req.Equal("/summary.txt", original)
+
+	// The errors report the original names
+	_, err = nfs.Stat("/missing\t.txt")
+	req.ErrorIs(err, os.ErrNotExist)
+	req.True(strings.Contains(err.Error(), "/missing\t.txt"))
+
+	req.NoError(nfs.Remove("/summary.txt"))
+
+	_, err = fs.Stat("/summary.txt")
+	req.ErrorIs(err, os.ErrNotExist)
+}
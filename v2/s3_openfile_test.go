@@ -0,0 +1,120 @@
+package s3
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenFileFlags(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	for _, flag := range []int{os.O_RDWR, os.O_WRONLY | os.O_APPEND, os.O_RDWR | os.O_CREATE} {
+		_, err := fs.OpenFile("/file", flag, 0)
+		req.ErrorIs(err, ErrNotSupported)
+
+		var errFlag *UnsupportedFlagError
+		req.True(errors.As(err, &errFlag))
+	}
+
+	_, err := fs.OpenFile("/file", os.O_RDONLY, 0)
+	req.ErrorIs(err, os.ErrNotExist)
+
+	// Creating a file to read it
+	file, err := fs.OpenFile("/file", os.O_RDONLY|os.O_CREATE, 0644)
+	req.NoError(err)
+
+	content, err := io.ReadAll(file)
+	req.NoError(err)
+	req.Empty(content)
+	req.NoError(file.Close())
+
+	// Which only creates it if it doesn't exist
+	testCreateFile(t, fs, "/file", "content")
+
+	file, err = fs.OpenFile("/file", os.O_RDONLY|os.O_CREATE, 0644)
+	req.NoError(err)
+
+	content, err = io.ReadAll(file)
+	req.NoError(err)
+	req.Equal("content", string(content))
+	req.NoError(file.Close())
+
+	_, err = fs.OpenFile("/file", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	req.ErrorIs(err, os.ErrExist)
+}
+
+func TestPosixMetadataMode(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.PosixMetadata = true
+
+	file, err := fs.OpenFile("/file", os.O_WRONLY|os.O_CREATE, 0600)
+	req.NoError(err)
+	_, err = file.WriteString("content")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	info, err := fs.Stat("/file")
+	req.NoError(err)
+	req.Equal(os.FileMode(0600), info.Mode())
+
+	// Rewriting a file keeps its permissions
+	file, err = fs.OpenFile("/file", os.O_WRONLY|os.O_TRUNC, 0644)
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	info, err = fs.Stat("/file")
+	req.NoError(err)
+	req.Equal(os.FileMode(0600), info.Mode())
+}
+
+func TestPermissionsOnCreate(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.PosixMetadata = true
+
+	req.NoError(fs.Mkdir("/dir", 0700))
+
+	info, err := fs.Stat("/dir")
+	req.NoError(err)
+	req.True(info.IsDir())
+	req.Equal(os.FileMode(0700), info.Mode())
+
+	req.NoError(fs.Chmod("/dir", 0750))
+
+	info, err = fs.Stat("/dir/")
+	req.NoError(err)
+	req.Equal(os.FileMode(0750), info.Mode())
+
+	testCreateFile(t, fs, "/dir/file", "content")
+	req.NoError(fs.Chmod("/dir/file", 0640))
+
+	info, err = fs.Stat("/dir/file")
+	req.NoError(err)
+	req.Equal(os.FileMode(0640), info.Mode())
+	req.Equal(int64(7), info.Size())
+
+	t.Run("ACL", func(t *testing.T) {
+		fs.PosixMetadata = false
+		fs.PermissionsACL = true
+
+		file, err := fs.OpenFile("/public", os.O_WRONLY|os.O_CREATE, 0644)
+		req.NoError(err)
+		req.NoError(file.Close())
+
+		info, err := fs.Stat("/public")
+		req.NoError(err)
+		req.Equal(os.FileMode(0644), info.Mode())
+
+		req.NoError(fs.Chmod("/public", 0600))
+
+		info, err = fs.Stat("/public")
+		req.NoError(err)
+		req.Equal(os.FileMode(0600), info.Mode())
+	})
+}
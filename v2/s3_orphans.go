@@ -0,0 +1,33 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// OrphanedFile describes a file handle that was leaked without being closed, see OnOrphanedFile
+type OrphanedFile struct {
+	Name    string // Name of the file
+	Writing bool   // Writing is set for the files opened for writing, whose upload was aborted
+	Stack   []byte // Stack is the stack trace of the opening of the file
+}
+
+// watchOrphaned makes the file reported to OnOrphanedFile when it's leaked, with the stack of its opening
+func (f *File) watchOrphaned() {
+	f.openStack = debug.Stack()
+	runtime.SetFinalizer(f, (*File).finalize)
+}
+
+// finalize closes the read stream of a file collected without being closed. The files being written can't be
+// collected, they're reported when their upload is abandoned.
+func (f *File) finalize() {
+	if f.streamRead == nil {
+		return
+	}
+
+	_ = f.streamRead.Close()
+	f.streamRead = nil
+
+	f.fs.OnOrphanedFile(OrphanedFile{Name: f.name, Stack: f.openStack})
+}
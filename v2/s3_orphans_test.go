@@ -0,0 +1,103 @@
+package s3
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// orphans collects the orphaned files
+type orphans struct {
+	mu    sync.Mutex
+	files []OrphanedFile
+}
+
+func (o *orphans) add(file OrphanedFile) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.files = append(o.files, file)
+}
+
+func (o *orphans) list() []OrphanedFile {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]OrphanedFile{}, o.files...)
+}
+
+// leakReader opens a file for reading and leaks it
+func leakReader(t *testing.T, fs *Fs, name string) {
+	file, err := fs.Open(name)
+	require.NoError(t, err)
+
+	_, err = file.Read(make([]byte, 1))
+	require.NoError(t, err)
+}
+
+func TestOrphanedFiles(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/leaked.txt", strings.Repeat("leaked\n", 100000))
+	testCreateFile(t, fs, "/closed.txt", "closed")
+
+	found := &orphans{}
+	fs.OnOrphanedFile = found.add
+
+	// The read handles are found once they're collected
+	leakReader(t, fs, "/leaked.txt")
+
+	file, err := fs.Open("/closed.txt")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	req.Eventually(func() bool {
+		runtime.GC()
+		return len(found.list()) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+
+	orphan := found.list()[0]
+	req.Equal("/leaked.txt", orphan.Name)
+	req.False(orphan.Writing)
+	req.Contains(string(orphan.Stack), "leakReader")
+
+	// The write handles when their upload is abandoned
+	fs.UploadIdleTimeout = 100 * time.Millisecond
+
+	file, err = fs.OpenFile("/abandoned.txt", os.O_WRONLY|os.O_CREATE, 0644)
+	req.NoError(err)
+
+	req.Eventually(func() bool { return len(found.list()) == 2 }, 5*time.Second, 20*time.Millisecond)
+
+	orphan = found.list()[1]
+	req.Equal("/abandoned.txt", orphan.Name)
+	req.True(orphan.Writing)
+	req.Contains(string(orphan.Stack), "TestOrphanedFiles")
+	req.ErrorIs(file.Close(), ErrUploadAbandoned)
+}
+
+func TestOrphanedParallelDownload(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/large.txt", strings.Repeat("large\n", 500000))
+
+	found := &orphans{}
+	fs.OnOrphanedFile = found.add
+	fs.DownloadConcurrency = 2
+	fs.DownloadPartSize = 64 * 1024
+
+	// The parallel downloads don't prevent the files from being collected
+	leakReader(t, fs, "/large.txt")
+
+	req.Eventually(func() bool {
+		runtime.GC()
+		return len(found.list()) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+}
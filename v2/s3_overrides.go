@@ -0,0 +1,72 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"mime"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ResponseOverrides override the headers of the responses to the downloads of a file, so that the same stored
+// file can be downloaded with different names or types. The empty fields aren't overridden.
+type ResponseOverrides struct {
+	ContentType        string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	CacheControl       string
+	Expires            time.Time
+}
+
+// AttachmentOverrides returns the overrides making browsers download a file as an attachment with a filename
+func AttachmentOverrides(filename string) *ResponseOverrides {
+	return &ResponseOverrides{
+		ContentDisposition: mime.FormatMediaType("attachment", map[string]string{"filename": filename}),
+	}
+}
+
+// apply sets the overrides as the response parameters of a GetObject request
+func (o *ResponseOverrides) apply(input *s3.GetObjectInput) {
+	if o == nil {
+		return
+	}
+
+	for param, value := range map[**string]string{
+		&input.ResponseContentType:        o.ContentType,
+		&input.ResponseContentDisposition: o.ContentDisposition,
+		&input.ResponseContentEncoding:    o.ContentEncoding,
+		&input.ResponseContentLanguage:    o.ContentLanguage,
+		&input.ResponseCacheControl:       o.CacheControl,
+	} {
+		if value != "" {
+			*param = aws.String(value)
+		}
+	}
+
+	if !o.Expires.IsZero() {
+		input.ResponseExpires = aws.Time(o.Expires)
+	}
+}
+
+// PresignGet returns a URL allowing to download a file without credentials until it expires, with its response
+// headers overridden if overrides isn't nil
+func (fs *Fs) PresignGet(name string, expire time.Duration, overrides *ResponseOverrides) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	}
+
+	overrides.apply(input)
+
+	req, _ := fs.s3API.GetObjectRequest(input)
+
+	url, err := req.Presign(expire)
+	if err != nil {
+		return "", &os.PathError{Op: "presign", Path: name, Err: err}
+	}
+
+	return url, nil
+}
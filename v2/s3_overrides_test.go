@@ -0,0 +1,59 @@
+package s3
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseOverrides(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/report.csv", "a,b")
+
+	overrides := AttachmentOverrides("report-2024.csv")
+	overrides.ContentType = "application/octet-stream"
+
+	req.Equal(`attachment; filename=report-2024.csv`, overrides.ContentDisposition)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		w := httptest.NewRecorder()
+		fs.ServeContentWithOverrides(w, httptest.NewRequest(method, "/report.csv", nil), "/report.csv", overrides)
+		req.Equal(http.StatusOK, w.Code, method)
+		req.Equal(overrides.ContentDisposition, w.Header().Get("Content-Disposition"), method)
+		req.Equal("application/octet-stream", w.Header().Get("Content-Type"), method)
+	}
+
+	// The same file downloaded without overrides
+	w := httptest.NewRecorder()
+	fs.ServeContent(w, httptest.NewRequest(http.MethodGet, "/report.csv", nil), "/report.csv")
+	req.Empty(w.Header().Get("Content-Disposition"))
+	req.NotEqual("application/octet-stream", w.Header().Get("Content-Type"))
+}
+
+func TestPresignGet(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/report.csv", "a,b")
+
+	url, err := fs.PresignGet("/report.csv", time.Minute, AttachmentOverrides("other.csv"))
+	req.NoError(err)
+	req.Contains(url, "response-content-disposition=")
+
+	resp, err := http.Get(url) // nolint: gosec, noctx
+	req.NoError(err)
+
+	defer resp.Body.Close() // nolint: errcheck
+
+	content, err := io.ReadAll(resp.Body)
+	req.NoError(err)
+	req.Equal(http.StatusOK, resp.StatusCode)
+	req.Equal("a,b", string(content))
+	req.Equal("attachment; filename=other.csv", resp.Header.Get("Content-Disposition"))
+}
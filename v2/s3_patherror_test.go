@@ -0,0 +1,74 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathErrors(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/file", "content")
+
+	checkPathError := func(err error, op, name string) {
+		var pathErr *os.PathError
+		req.True(errors.As(err, &pathErr), "%v", err)
+		req.Equal(op, pathErr.Op)
+		req.Equal(name, pathErr.Path)
+	}
+
+	_, err := fs.Open("/missing")
+	checkPathError(err, "open", "/missing")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	checkPathError(fs.Remove("/missing"), "remove", "/missing")
+	checkPathError(fs.RemoveAll("/file/"), "removeall", "/file/")
+	checkPathError(fs.RemoveAllWithOptions(context.Background(), "/file/", nil), "removeall", "/file/")
+
+	// The bulk operations report the file that failed
+	testCreateFile(t, fs, "/dir/file", "content")
+	faults := NewFaultInjector(0)
+	fs.WithFaultInjector(faults)
+	faults.Set("PutObjectAcl", &Fault{StatusCode: http.StatusForbidden, Code: "AccessDenied"})
+	checkPathError(fs.ChmodAll("/dir", 0644, nil), "chmod", "/dir/file")
+	faults.Set("PutObjectAcl", nil)
+	checkPathError(fs.Chown("/file", 0, 0), "chown", "/file")
+	checkPathError(fs.Chmod("/dir/", 0644), "chmod", "/dir/")
+
+	err = fs.Rename("/missing", "/other")
+	var linkErr *os.LinkError
+	req.True(errors.As(err, &linkErr), "%v", err)
+	req.Equal("/missing", linkErr.Old)
+
+	_, err = fs.OpenFile("/file", os.O_APPEND, 0)
+	checkPathError(err, "open", "/file")
+	req.ErrorIs(err, ErrNotSupported)
+
+	file, err := fs.Open("/file")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	_, err = file.Read(make([]byte, 1))
+	checkPathError(err, "read", "/file")
+
+	checkPathError(file.Truncate(0), "truncate", "/file")
+
+	// The end of a file isn't wrapped
+	file, err = fs.Open("/file")
+	req.NoError(err)
+
+	defer func() { req.NoError(file.Close()) }()
+
+	_, err = io.ReadAll(file)
+	req.NoError(err)
+
+	_, err = file.Read(make([]byte, 1))
+	req.Equal(io.EOF, err)
+}
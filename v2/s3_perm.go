@@ -0,0 +1,215 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ModeMetadataKey is the metadata holding the permissions of the files in POSIX metadata mode
+const ModeMetadataKey = "Mode"
+
+// Metadata holding the owner of the files in POSIX metadata mode, see Chown
+const (
+	UIDMetadataKey = "Uid"
+	GIDMetadataKey = "Gid"
+)
+
+// Owner is the owner of a file stored by Chown in POSIX metadata mode. It's returned by the Sys method of the
+// FileInfo of the files that have one.
+type Owner struct {
+	UID int
+	GID int
+}
+
+// allUsersGroup is the grantee URI of the public ACLs
+const allUsersGroup = "http://acs.amazonaws.com/groups/global/AllUsers"
+
+// permParams returns the upload parameters storing the permissions of a created file
+func (fs *Fs) permParams(perm os.FileMode) uploadParams {
+	var params uploadParams
+
+	if fs.PosixMetadata {
+		params.metadata = map[string]*string{ModeMetadataKey: aws.String(fmt.Sprintf("%#o", perm.Perm()))}
+	}
+
+	if fs.PermissionsACL {
+		// The blocked ACLs make the uploads fail, unless they are skipped
+		acl := modeToACL(perm)
+		if apply, err := fs.checkACL(acl); apply || (err != nil && !fs.SkipBlockedACLs) {
+			params.acl = aws.String(acl)
+		}
+	}
+
+	return params
+}
+
+// writePermParams returns the upload parameters storing the permissions of a written file. Like with POSIX, the
+// permissions of an existing file are kept.
+func (fs *Fs) writePermParams(name string, perm os.FileMode) (uploadParams, error) {
+	if err := checkFileName(name); err != nil {
+		return uploadParams{}, err
+	}
+
+	if !fs.PosixMetadata && !fs.PermissionsACL {
+		return uploadParams{}, nil
+	}
+
+	info, err := fs.Stat(name)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return fs.permParams(perm), nil
+	case err != nil:
+		return uploadParams{}, err
+	default:
+		return fs.permParams(info.Mode()), nil
+	}
+}
+
+// parseModeMetadata reads the permissions stored in the metadata of a file, 0 if there are none
+func parseModeMetadata(metadata map[string]*string) os.FileMode {
+	value, ok := metadata[ModeMetadataKey]
+	if !ok || value == nil {
+		return 0
+	}
+
+	mode, err := strconv.ParseUint(*value, 0, 32)
+	if err != nil {
+		return 0
+	}
+
+	return os.FileMode(mode).Perm()
+}
+
+// aclMode returns the permissions matching the ACL of an object: the owner can always read and write it,
+// the public grants give the same rights to the group and the others.
+func (fs *Fs) aclMode(key string) (os.FileMode, error) {
+	acl, err := fs.s3API.GetObjectAcl(&s3.GetObjectAclInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	mode := os.FileMode(0600)
+
+	for _, grant := range acl.Grants {
+		if grant.Grantee == nil || aws.StringValue(grant.Grantee.URI) != allUsersGroup {
+			continue
+		}
+
+		switch aws.StringValue(grant.Permission) {
+		case s3.PermissionRead:
+			mode |= 0044
+		case s3.PermissionWrite:
+			mode |= 0022
+		case s3.PermissionFullControl:
+			mode |= 0066
+		}
+	}
+
+	return mode, nil
+}
+
+// storedMode returns the permissions stored for an object, 0 if there are none
+func (fs *Fs) storedMode(key string, metadata map[string]*string) (os.FileMode, error) {
+	if mode := parseModeMetadata(metadata); mode != 0 || !fs.PermissionsACL {
+		return mode, nil
+	}
+
+	return fs.aclMode(key)
+}
+
+// parseOwnerMetadata reads the owner stored in the metadata of a file, nil if there is none
+func parseOwnerMetadata(metadata map[string]*string) *Owner {
+	uid, errUID := strconv.Atoi(aws.StringValue(metadata[UIDMetadataKey]))
+	gid, errGID := strconv.Atoi(aws.StringValue(metadata[GIDMetadataKey]))
+
+	if errUID != nil || errGID != nil {
+		return nil
+	}
+
+	return &Owner{UID: uid, GID: gid}
+}
+
+// markerPerms returns the permissions and the owner stored on a directory marker
+func (fs *Fs) markerPerms(marker string) (os.FileMode, *Owner, error) {
+	head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(marker),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	mode, err := fs.storedMode(marker, head.Metadata)
+
+	return mode, parseOwnerMetadata(head.Metadata), err
+}
+
+// chmodMetadata stores the permissions in the metadata of an object
+func (fs *Fs) chmodMetadata(name string, mode os.FileMode) error {
+	return fs.updateMetadata(name, map[string]string{ModeMetadataKey: fmt.Sprintf("%#o", mode.Perm())})
+}
+
+// chownMetadata stores the owner in the metadata of an object, the negative ids being left unchanged like with
+// os.Chown
+func (fs *Fs) chownMetadata(name string, uid, gid int) error {
+	values := make(map[string]string, 2)
+
+	if uid >= 0 {
+		values[UIDMetadataKey] = strconv.Itoa(uid)
+	}
+
+	if gid >= 0 {
+		values[GIDMetadataKey] = strconv.Itoa(gid)
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return fs.updateMetadata(name, values)
+}
+
+// updateMetadata sets values in the metadata of an object, by copying it onto itself
+func (fs *Fs) updateMetadata(name string, values map[string]string) error {
+	head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		return err
+	}
+
+	metadata := head.Metadata
+	if metadata == nil {
+		metadata = make(map[string]*string)
+	}
+
+	for key, value := range values {
+		metadata[key] = aws.String(value)
+	}
+
+	_, err = fs.s3API.CopyObject(&s3.CopyObjectInput{
+		Bucket:             aws.String(fs.bucket),
+		Key:                aws.String(name),
+		CopySource:         aws.String(copySource(fs.bucket, name)),
+		MetadataDirective:  aws.String(s3.MetadataDirectiveReplace),
+		Metadata:           metadata,
+		ContentType:        head.ContentType,
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		ContentLanguage:    head.ContentLanguage,
+	})
+
+	return err
+}
@@ -0,0 +1,208 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// sniffLength is the number of bytes the content types are detected from, like http.DetectContentType
+const sniffLength = 512
+
+// ContentPolicy restricts the files that can be written, by extension and by content type, like for the upload
+// endpoints. The extensions are checked when the files are opened, and the content types are detected from their first
+// bytes before anything is written.
+type ContentPolicy struct {
+	// AllowedExtensions are the only extensions allowed, like ".jpg", all of them if empty
+	AllowedExtensions []string
+	// DeniedExtensions are forbidden extensions, like ".exe"
+	DeniedExtensions []string
+	// AllowedTypes are the only content types allowed, like "image/png" or "image/*", all of them if empty
+	AllowedTypes []string
+	// DeniedTypes are forbidden content types, like "application/x-msdownload"
+	DeniedTypes []string
+}
+
+// ErrContentPolicy is matched by the errors of the writes of files violating the ContentPolicy of the Fs
+var ErrContentPolicy = fmt.Errorf("content policy violation: %w", os.ErrPermission)
+
+// PolicyError is returned when writing a file violating the ContentPolicy of the Fs, it matches ErrContentPolicy
+type PolicyError struct {
+	Name        string // Name of the file
+	Extension   string // Extension of the file, if it's not allowed
+	ContentType string // ContentType is the detected content type of the file, if it's not allowed
+}
+
+func (e *PolicyError) Error() string {
+	if e.ContentType != "" {
+		return fmt.Sprintf("%s: content type %s is not allowed", e.Name, e.ContentType)
+	}
+
+	return fmt.Sprintf("%s: extension %q is not allowed", e.Name, e.Extension)
+}
+
+// Unwrap makes the error match ErrContentPolicy
+func (e *PolicyError) Unwrap() error {
+	return ErrContentPolicy
+}
+
+// checkName checks the extension of a file
+func (p *ContentPolicy) checkName(name string) error {
+	ext := strings.ToLower(path.Ext(name))
+
+	if (len(p.AllowedExtensions) > 0 && !extensionIn(ext, p.AllowedExtensions)) || extensionIn(ext, p.DeniedExtensions) {
+		return &PolicyError{Name: name, Extension: ext}
+	}
+
+	return nil
+}
+
+// checkContent checks the content type detected from the first bytes of a file, the empty files having none
+func (p *ContentPolicy) checkContent(name string, head []byte) error {
+	if len(head) == 0 {
+		return nil
+	}
+
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return &os.PathError{Op: "sniff", Path: name, Err: err}
+	}
+
+	if (len(p.AllowedTypes) > 0 && !typeIn(contentType, p.AllowedTypes)) || typeIn(contentType, p.DeniedTypes) {
+		return &PolicyError{Name: name, ContentType: contentType}
+	}
+
+	return nil
+}
+
+func extensionIn(ext string, extensions []string) bool {
+	for _, e := range extensions {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// typeIn tells if a content type matches one of the types, the types ending with /* matching all their subtypes
+func typeIn(contentType string, types []string) bool {
+	for _, t := range types {
+		if strings.EqualFold(contentType, t) ||
+			(strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, strings.ToLower(strings.TrimSuffix(t, "*")))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPolicyName checks the name of a file written against the content policy of the Fs
+func (fs *Fs) checkPolicyName(name string) error {
+	if fs.ContentPolicy == nil {
+		return nil
+	}
+
+	return fs.ContentPolicy.checkName(name)
+}
+
+// checkPolicyContent checks a file written with a single request against the content policy of the Fs, its body is
+// rewound
+func (fs *Fs) checkPolicyContent(name string, rs io.ReadSeeker, size int64) error {
+	if fs.ContentPolicy == nil {
+		return nil
+	}
+
+	if err := fs.ContentPolicy.checkName(name); err != nil {
+		return err
+	}
+
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return &os.PathError{Op: "sniff", Path: name, Err: err}
+	}
+
+	head := make([]byte, sniffLength)
+
+	n, err := io.ReadFull(io.LimitReader(rs, size), head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF { // nolint: errorlint
+		return &os.PathError{Op: "sniff", Path: name, Err: err}
+	}
+
+	if _, err = rs.Seek(start, io.SeekStart); err != nil {
+		return &os.PathError{Op: "sniff", Path: name, Err: err}
+	}
+
+	return fs.ContentPolicy.checkContent(name, head[:n])
+}
+
+// policyWriteCloser holds the first bytes written to a file until its content type is checked against the content
+// policy of the Fs. The uploads of a file violating it are failed, which aborts them before anything is written.
+type policyWriteCloser struct {
+	io.WriteCloser                // WriteCloser is the stream of the uploads
+	name           string         // name of the file
+	policy         *ContentPolicy // policy is the content policy of the Fs
+	pipes          []io.Closer    // pipes are the pipes of the uploads, failed if the file violates the policy
+	head           []byte         // head are the first bytes of the file, held until they're checked
+	checked        bool           // checked is set once the content type was checked
+	err            error          // err is the violation of the policy
+}
+
+func (w *policyWriteCloser) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	if w.checked {
+		return w.WriteCloser.Write(p)
+	}
+
+	held := sniffLength - len(w.head)
+	if len(p) < held {
+		w.head = append(w.head, p...)
+		return len(p), nil
+	}
+
+	w.head = append(w.head, p[:held]...)
+
+	if err := w.check(); err != nil {
+		return 0, err
+	}
+
+	n, err := w.WriteCloser.Write(p[held:])
+
+	return held + n, err
+}
+
+// check checks the content type of the file, and writes its first bytes if it's allowed
+func (w *policyWriteCloser) check() error {
+	w.checked = true
+
+	if w.err = w.policy.checkContent(w.name, w.head); w.err != nil {
+		failPipes(w.pipes, w.err)
+		return w.err
+	}
+
+	_, err := w.WriteCloser.Write(w.head)
+
+	return err
+}
+
+// Close ends the uploads once the content type of the file was checked, or fails them
+func (w *policyWriteCloser) Close() error {
+	if !w.checked {
+		_ = w.check()
+	}
+
+	if w.err != nil {
+		_ = w.WriteCloser.Close()
+		return w.err
+	}
+
+	return w.WriteCloser.Close()
+}
@@ -0,0 +1,109 @@
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pngHeader is the signature of the PNG files
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+func TestContentPolicyExtensions(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.ContentPolicy = &ContentPolicy{DeniedExtensions: []string{".exe"}}
+
+	// The denied extensions are refused when the files are opened
+	_, err := fs.Create("/setup.EXE")
+	req.ErrorIs(err, ErrContentPolicy)
+	req.ErrorIs(err, os.ErrPermission)
+
+	var policy *PolicyError
+	req.True(errors.As(err, &policy))
+	req.Equal("/setup.EXE", policy.Name)
+	req.Equal(".exe", policy.Extension)
+
+	_, err = fs.OpenFile("/setup.exe", os.O_WRONLY|os.O_CREATE, 0644)
+	req.ErrorIs(err, ErrContentPolicy)
+
+	req.ErrorIs(fs.WriteFile("/setup.exe", []byte("MZ"), nil), ErrContentPolicy)
+
+	_, err = fs.Stat("/setup.exe")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	// The others are written
+	testCreateFile(t, fs, "/readme.txt", "hello")
+
+	// Only the allowed ones when there are some
+	fs.ContentPolicy = &ContentPolicy{AllowedExtensions: []string{".txt", ".png"}}
+
+	_, err = fs.Create("/data.csv")
+	req.ErrorIs(err, ErrContentPolicy)
+
+	_, err = fs.Create("/noext")
+	req.ErrorIs(err, ErrContentPolicy)
+
+	testCreateFile(t, fs, "/notes.txt", "notes")
+
+	// The files are still readable
+	data, err := fs.ReadFile("/readme.txt")
+	req.NoError(err)
+	req.Equal("hello", string(data))
+}
+
+func TestContentPolicyTypes(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.ContentPolicy = &ContentPolicy{AllowedTypes: []string{"text/*"}}
+
+	// The small and the large files of allowed types are written
+	testCreateFile(t, fs, "/small.txt", "hello")
+
+	large := strings.Repeat("some text\n", 1000000)
+	testCreateFile(t, fs, "/large.txt", large)
+
+	data, err := fs.ReadFile("/large.txt")
+	req.NoError(err)
+	req.Equal(large, string(data))
+
+	// The other types are detected before anything is written
+	for _, size := range []int{100, 10000000} {
+		content := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{0}, size)...)
+
+		file, errOpen := fs.OpenFile("/image.txt", os.O_WRONLY|os.O_CREATE, 0644)
+		req.NoError(errOpen)
+
+		_, err = file.Write(content)
+		if err == nil {
+			err = file.Close()
+		} else {
+			req.Error(file.Close())
+		}
+
+		req.ErrorIs(err, ErrContentPolicy)
+		req.ErrorIs(err, os.ErrPermission)
+
+		var policy *PolicyError
+		req.True(errors.As(err, &policy))
+		req.Equal("image/png", policy.ContentType)
+
+		_, err = fs.Stat("/image.txt")
+		req.ErrorIs(err, os.ErrNotExist)
+	}
+
+	// Like the single request writes
+	req.ErrorIs(fs.WriteFile("/image.txt", pngHeader, nil), ErrContentPolicy)
+
+	_, err = fs.Stat("/image.txt")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	// The denied types are refused
+	fs.ContentPolicy = &ContentPolicy{DeniedTypes: []string{"image/png"}}
+	req.ErrorIs(fs.WriteFile("/image.png", pngHeader, nil), ErrContentPolicy)
+	req.NoError(fs.WriteFile("/text.png", []byte("not an image"), nil))
+}
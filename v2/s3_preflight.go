@@ -0,0 +1,184 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Operation is a kind of file system operation whose permissions can be checked
+type Operation string
+
+// The operations checked by CheckPermissions
+const (
+	OperationList   Operation = "list"   // OperationList lists directories, and tells if files or directories exist
+	OperationRead   Operation = "read"   // OperationRead reads files
+	OperationWrite  Operation = "write"  // OperationWrite creates and writes files
+	OperationDelete Operation = "delete" // OperationDelete removes files
+	OperationChmod  Operation = "chmod"  // OperationChmod changes the ACL of files
+)
+
+// AllOperationsChecked are the operations checked when none is given to CheckPermissions
+var AllOperationsChecked = []Operation{OperationList, OperationRead, OperationWrite, OperationDelete, OperationChmod}
+
+// ErrAccessDenied is the error of the checks of the operations denied to the credentials
+var ErrAccessDenied = errors.New("access denied")
+
+// ErrNotChecked is the error of the checks that couldn't be performed because the probe file couldn't be written
+var ErrNotChecked = errors.New("not checked")
+
+// preflightProbe is the name of the file written to check the permissions
+const preflightProbe = ".afero-s3-preflight-"
+
+// PermissionCheck is the result of the check of an operation, Err is nil if it's allowed
+type PermissionCheck struct {
+	Operation Operation
+	Err       error
+}
+
+// PermissionReport is the result of CheckPermissions
+type PermissionReport []PermissionCheck
+
+// Allowed tells if all the checked operations are allowed
+func (r PermissionReport) Allowed() bool {
+	for _, check := range r {
+		if check.Err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the report with one line per operation, meant to be logged at startup
+func (r PermissionReport) String() string {
+	var b strings.Builder
+
+	for _, check := range r {
+		if check.Err == nil {
+			fmt.Fprintf(&b, "%s: ok\n", check.Operation)
+		} else {
+			fmt.Fprintf(&b, "%s: %v\n", check.Operation, check.Err)
+		}
+	}
+
+	return b.String()
+}
+
+// permissionError returns the error of a check, with ErrAccessDenied if the request was denied
+func permissionError(err error) error {
+	var errRequestFailure awserr.RequestFailure
+	if errors.As(err, &errRequestFailure) && errRequestFailure.StatusCode() == http.StatusForbidden {
+		return fmt.Errorf("%w: %v", ErrAccessDenied, err)
+	}
+
+	return err
+}
+
+// CheckPermissions attempts representative requests to report which operations the credentials of the Fs allow,
+// so that misconfigured deployments are detected at startup. All the operations are checked if none is given.
+// A probe file is written at the root of the bucket for the write, read, delete and chmod operations, and removed.
+func (fs *Fs) CheckPermissions(ctx context.Context, ops ...Operation) PermissionReport {
+	if len(ops) == 0 {
+		ops = AllOperationsChecked
+	}
+
+	probe := &permissionProbe{fs: fs, ctx: ctx, key: preflightProbe}
+	if id, err := newUUID(); err == nil {
+		probe.key += id
+	}
+
+	report := make(PermissionReport, len(ops))
+
+	for i, op := range ops {
+		report[i] = PermissionCheck{Operation: op, Err: probe.check(op)}
+	}
+
+	probe.cleanup()
+
+	return report
+}
+
+// permissionProbe performs the checks on the probe file
+type permissionProbe struct {
+	fs      *Fs
+	ctx     context.Context
+	key     string
+	written bool  // written tells if the probe file was written
+	errPut  error // errPut is the error of the writing of the probe file
+}
+
+// write writes the probe file, once
+func (p *permissionProbe) write() error {
+	if !p.written && p.errPut == nil {
+		_, p.errPut = p.fs.s3API.PutObjectWithContext(p.ctx, &s3.PutObjectInput{
+			Bucket: aws.String(p.fs.bucket),
+			Key:    aws.String(p.key),
+			Body:   bytes.NewReader([]byte{}),
+		})
+		p.written = p.errPut == nil
+	}
+
+	return p.errPut
+}
+
+// check checks an operation
+func (p *permissionProbe) check(op Operation) error {
+	bucket := aws.String(p.fs.bucket)
+
+	if op == OperationList {
+		_, err := p.fs.s3API.ListObjectsV2WithContext(p.ctx, &s3.ListObjectsV2Input{Bucket: bucket, MaxKeys: aws.Int64(1)})
+		return permissionError(err)
+	}
+
+	if op == OperationWrite {
+		return permissionError(p.write())
+	}
+
+	if err := p.write(); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotChecked, permissionError(err))
+	}
+
+	var err error
+
+	switch op {
+	case OperationRead:
+		var out *s3.GetObjectOutput
+
+		out, err = p.fs.s3API.GetObjectWithContext(p.ctx, &s3.GetObjectInput{Bucket: bucket, Key: aws.String(p.key)})
+		if err == nil {
+			_ = out.Body.Close()
+		}
+	case OperationDelete:
+		_, err = p.fs.s3API.DeleteObjectWithContext(p.ctx, &s3.DeleteObjectInput{Bucket: bucket, Key: aws.String(p.key)})
+		p.written = err != nil
+	case OperationChmod:
+		_, err = p.fs.s3API.PutObjectAclWithContext(p.ctx, &s3.PutObjectAclInput{
+			Bucket: bucket,
+			Key:    aws.String(p.key),
+			ACL:    aws.String(s3.ObjectCannedACLPrivate),
+		})
+	default:
+		return ErrNotSupported
+	}
+
+	return permissionError(err)
+}
+
+// cleanup removes the probe file if it's still there
+func (p *permissionProbe) cleanup() {
+	if p.written {
+		_, _ = p.fs.s3API.DeleteObjectWithContext(p.ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(p.fs.bucket),
+			Key:    aws.String(p.key),
+		})
+	}
+}
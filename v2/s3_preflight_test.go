@@ -0,0 +1,45 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	report := fs.CheckPermissions(context.Background())
+	req.True(report.Allowed(), report.String())
+	req.Len(report, len(AllOperationsChecked))
+
+	faults := NewFaultInjector(0)
+	fs.WithFaultInjector(faults)
+	faults.Set("DeleteObject", &Fault{StatusCode: http.StatusForbidden, Code: "AccessDenied"})
+
+	report = fs.CheckPermissions(context.Background(), OperationRead, OperationDelete)
+	req.False(report.Allowed())
+	req.NoError(report[0].Err)
+	req.ErrorIs(report[1].Err, ErrAccessDenied)
+	req.Contains(report.String(), "delete: access denied")
+
+	// Nothing can be checked on the probe file if it can't be written
+	faults.Set("PutObject", &Fault{StatusCode: http.StatusForbidden, Code: "AccessDenied"})
+
+	report = fs.CheckPermissions(context.Background(), OperationList, OperationWrite, OperationRead)
+	req.NoError(report[0].Err)
+	req.ErrorIs(report[1].Err, ErrAccessDenied)
+	req.ErrorIs(report[2].Err, ErrNotChecked)
+
+	fs.WithFaultInjector(nil)
+
+	// The probe files were removed, apart from the one that couldn't be deleted
+	entries, err := fs.s3API.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(fs.bucket)})
+	req.NoError(err)
+	req.Len(entries.Contents, 1)
+}
@@ -0,0 +1,165 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DefaultPreloadTTL is the default time the listing loaded by Preload is used
+const DefaultPreloadTTL = time.Minute
+
+// preloadedDir is the listing of a preloaded directory, as it would be returned by a delimited listing
+type preloadedDir struct {
+	prefixes []*s3.CommonPrefix
+	contents []*s3.Object
+}
+
+// preloadState is the listing loaded by Preload
+type preloadState struct {
+	mu       sync.Mutex
+	prefix   string                   // prefix is the preloaded prefix
+	loadedAt time.Time                // loadedAt is the time of the listing, none is loaded if zero
+	dirs     map[string]*preloadedDir // dirs are the preloaded directories, by prefix
+	objects  map[string]*s3.Object    // objects are the preloaded objects, by key
+}
+
+func (fs *Fs) preloadTTL() time.Duration {
+	if fs.PreloadTTL <= 0 {
+		return DefaultPreloadTTL
+	}
+
+	return fs.PreloadTTL
+}
+
+// Preload lists all the files of a directory at once, with a few listing requests, so that the following Stat and
+// full Readdir calls within the directory are answered from memory for PreloadTTL. It makes the first browsing of a
+// directory tree fast, after a deployment for instance. Any change made through the Fs discards the preloaded listing,
+// the changes made by other clients are only seen once it expired. Only the last preloaded directory is kept.
+func (fs *Fs) Preload(dir string) error {
+	prefix := dirPrefix(dir)
+	dirs := map[string]*preloadedDir{prefix: {}}
+	objects := make(map[string]*s3.Object)
+	loadedAt := time.Now()
+
+	listed := make(map[string]bool) // listed are the directories listed in their parent
+
+	preloaded := func(prefix string) *preloadedDir {
+		if dirs[prefix] == nil {
+			dirs[prefix] = &preloadedDir{}
+		}
+
+		return dirs[prefix]
+	}
+
+	err := fs.walkObjects(prefix, func(obj *s3.Object) bool {
+		key := aws.StringValue(obj.Key)
+		objects[key] = obj
+
+		parent := key[:strings.LastIndex(key, "/")+1]
+		preloaded(parent).contents = append(preloaded(parent).contents, obj)
+
+		// The directories are listed in their parents, up to the preloaded one
+		for child := parent; len(child) > len(prefix) && !listed[child]; child = parent {
+			listed[child] = true
+			parent = child[:strings.LastIndex(strings.TrimSuffix(child, "/"), "/")+1]
+			preloaded(parent).prefixes = append(preloaded(parent).prefixes, &s3.CommonPrefix{Prefix: aws.String(child)})
+		}
+
+		return true
+	})
+	if err != nil {
+		return &os.PathError{Op: "preload", Path: dir, Err: err}
+	}
+
+	fs.preload.mu.Lock()
+	defer fs.preload.mu.Unlock()
+
+	fs.preload.prefix, fs.preload.loadedAt, fs.preload.dirs, fs.preload.objects = prefix, loadedAt, dirs, objects
+
+	return nil
+}
+
+// invalidate discards the preloaded listing
+func (p *preloadState) invalidate() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.loadedAt, p.dirs, p.objects = time.Time{}, nil, nil
+}
+
+// lookup returns the preloaded directory and object of a name, none if the preloaded listing doesn't cover it or
+// expired
+func (p *preloadState) lookup(name string, ttl time.Duration) (*preloadedDir, *s3.Object) {
+	if p == nil {
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := strings.TrimPrefix(name, "/")
+	if p.dirs == nil || time.Since(p.loadedAt) > ttl || !strings.HasPrefix(dirPrefix(key), p.prefix) {
+		return nil, nil
+	}
+
+	var obj *s3.Object
+	if !strings.HasSuffix(key, "/") {
+		obj = p.objects[key]
+	}
+
+	return p.dirs[dirPrefix(key)], obj
+}
+
+// statPreloaded describes a file or a directory of the preloaded listing, the files coming first like with Stat.
+// It returns false if the name isn't preloaded. The permissions stored in POSIX metadata mode aren't listed, the
+// files have to be described by Stat in this mode.
+func (fs *Fs) statPreloaded(name string) (os.FileInfo, bool) {
+	dir, obj := fs.preload.lookup(name, fs.preloadTTL())
+
+	switch {
+	case obj != nil && !fs.PosixMetadata:
+		return NewFileInfo(name, false, aws.Int64Value(obj.Size), aws.TimeValue(obj.LastModified)), true
+	case obj == nil && dir != nil:
+		return NewFileInfo(name, true, 0, time.Unix(0, 0)), true
+	default:
+		return nil, false
+	}
+}
+
+// readdirPreloaded lists a whole directory from the preloaded listing, it returns false if it isn't preloaded or the
+// listing already started
+func (f *File) readdirPreloaded() ([]os.FileInfo, bool, error) {
+	if f.readdirNotTruncated || f.readdirContinuationToken != nil {
+		return nil, false, nil
+	}
+
+	dir, _ := f.fs.preload.lookup(f.name, f.fs.preloadTTL())
+	if dir == nil {
+		return nil, false, nil
+	}
+
+	fis, err := f.listingEntries(dir.prefixes, dir.contents)
+	if err != nil {
+		return nil, true, err
+	}
+
+	// The versions directory is listed first at the root
+	if f.fs.VersionsDir && !f.versionsDirListed && path.Clean("/"+f.name) == "/" {
+		fis = append([]os.FileInfo{NewFileInfo(VersionsDirName, true, 0, time.Unix(0, 0))}, fis...)
+	}
+
+	f.readdirNotTruncated, f.versionsDirListed = true, true
+
+	return fis, true, nil
+}
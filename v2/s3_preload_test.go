@@ -0,0 +1,64 @@
+package s3
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreload(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/dir/a", "aaa")
+	testCreateFile(t, fs, "/dir/sub/b", "bb")
+	testCreateFile(t, fs, "/dir/sub/deep/c", "c")
+	testCreateFile(t, fs, "/other", "o")
+
+	req.NoError(fs.Preload("/dir"))
+
+	// The preloaded directory is browsed without any request
+	faults := NewFaultInjector(0)
+	fs.WithFaultInjector(faults)
+
+	defer fs.WithFaultInjector(nil)
+
+	faults.Set(AllOperations, &Fault{StatusCode: http.StatusInternalServerError, Code: "InternalError"})
+
+	info, err := fs.Stat("/dir/sub/b")
+	req.NoError(err)
+	req.False(info.IsDir())
+	req.Equal(int64(2), info.Size())
+
+	info, err = fs.Stat("/dir/sub/deep")
+	req.NoError(err)
+	req.True(info.IsDir())
+
+	fis, err := afero.ReadDir(fs, "/dir")
+	req.NoError(err)
+	req.Len(fis, 2)
+	req.Equal("a", fis[0].Name())
+	req.Equal("sub", fis[1].Name())
+	req.True(fis[1].IsDir())
+
+	fis, err = afero.ReadDir(fs, "/dir/sub")
+	req.NoError(err)
+	req.Len(fis, 2)
+
+	req.Zero(faults.Injected())
+
+	// The files that weren't preloaded are still looked up
+	_, err = fs.Stat("/other")
+	req.Error(err)
+	req.NotZero(faults.Injected())
+
+	// A change discards the preloaded listing
+	faults.Set(AllOperations, nil)
+	testCreateFile(t, fs, "/dir/d", "d")
+
+	fis, err = afero.ReadDir(fs, "/dir")
+	req.NoError(err)
+	req.Len(fis, 3)
+}
@@ -0,0 +1,112 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"crypto/md5" // nolint: gosec
+	"encoding/base64"
+	"io"
+	"os"
+)
+
+// MaxPutObjectSize is the maximum size of an object S3 accepts with a single PutObject request
+const MaxPutObjectSize = 5 * 1024 * 1024 * 1024
+
+// PutFile uploads the content of a seekable body of a known size, like a local file, to the name file.
+// Unlike the written files which are streamed through a pipe, it's sent with a single PutObject request which
+// the SDK can rewind and retry as a whole on failures. Bodies bigger than MaxPutObjectSize are sent with a
+// multipart upload.
+func (fs *Fs) PutFile(name string, rs io.ReadSeeker, size int64) error {
+	params, err := fs.writePermParams(name, 0666)
+	if err != nil {
+		return err
+	}
+
+	return fs.putFile(name, rs, size, params)
+}
+
+// putFile uploads a seekable body to a file and its mirror
+func (fs *Fs) putFile(name string, rs io.ReadSeeker, size int64, params uploadParams) error {
+	if err := fs.checkPolicyContent(name, rs, size); err != nil {
+		return err
+	}
+
+	if fs.Scan != nil {
+		if err := fs.scanContent(name, rs, size); err != nil {
+			return err
+		}
+	}
+
+	// The transformed content is prepared in memory
+	if transforms := fs.writeTransforms(name); transforms != nil {
+		content, err := transformContent(name, io.LimitReader(rs, size), transforms)
+		if err != nil {
+			return err
+		}
+
+		rs, size = bytes.NewReader(content), int64(len(content))
+		params.metadata = transformWriteMetadata(params.metadata, transforms)
+	}
+
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return &os.PathError{Op: "put", Path: name, Err: err}
+	}
+
+	if err = fs.put(name, rs, size, &params); err != nil {
+		return &os.PathError{Op: "put", Path: name, Err: err}
+	}
+
+	if fs.Mirror != nil {
+		if _, err = rs.Seek(start, io.SeekStart); err != nil {
+			return &os.PathError{Op: "put", Path: name, Err: err}
+		}
+
+		// The conditions only apply to the file system of reference
+		params.ifMatch, params.ifNoneMatch = nil, nil
+
+		if err = fs.Mirror.put(name, rs, size, &params); err != nil {
+			return &os.PathError{Op: "put", Path: name, Err: err}
+		}
+	}
+
+	if err = fs.fileWritten(name); err != nil {
+		return err
+	}
+
+	if fs.Replicator != nil {
+		fs.Replicator.enqueue(ReplicationWrite, name)
+	}
+
+	return nil
+}
+
+// contentMD5 returns the base64-encoded MD5 sum of the rest of a body, which is rewound
+func contentMD5(body io.ReadSeeker) (string, error) {
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	hash := md5.New() // nolint: gosec
+	if _, err = io.Copy(hash, body); err != nil {
+		return "", err
+	}
+
+	if _, err = body.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (fs *Fs) put(name string, rs io.ReadSeeker, size int64, params *uploadParams) error {
+	if size > MaxPutObjectSize {
+		return fs.uploadStream(name, io.LimitReader(rs, size), params)
+	}
+
+	release := fs.acquireUploadSlot()
+	defer release()
+
+	return fs.putObject(name, rs, size, params)
+}
@@ -0,0 +1,37 @@
+package s3
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutFile(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	// Half of the requests fail, the rewindable body is sent again
+	faults := NewFaultInjector(1)
+	faults.Set("PutObject", &Fault{StatusCode: http.StatusInternalServerError, Code: "InternalError", Rate: 0.5})
+	fs.WithFaultInjector(faults)
+
+	for i := 0; i < 4; i++ {
+		req.NoError(fs.PutFile("/file.txt", bytes.NewReader(content), int64(len(content))))
+	}
+
+	req.Positive(faults.Injected())
+	fs.WithFaultInjector(nil)
+
+	read, err := afero.ReadFile(fs, "/file.txt")
+	req.NoError(err)
+	req.Equal(content, read)
+
+	info, err := fs.Stat("/file.txt")
+	req.NoError(err)
+	req.Equal(int64(len(content)), info.Size())
+}
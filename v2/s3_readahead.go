@@ -0,0 +1,132 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bufio"
+	"io"
+)
+
+// PrefetchChunkSize is the maximum size of the chunks of data prefetched from the read streams, see PrefetchSize
+const PrefetchChunkSize = 256 * 1024
+
+// readAheadCloser reads a stream through a read-ahead buffer, which aggregates the small reads and allows short
+// forward seeks without reopening the stream
+type readAheadCloser struct {
+	*bufio.Reader
+	body io.Closer
+}
+
+func (r *readAheadCloser) Close() error {
+	return r.body.Close()
+}
+
+// withReadAhead wraps the body of a read stream in a read-ahead buffer, unless it's disabled
+func (fs *Fs) withReadAhead(body io.ReadCloser) io.ReadCloser {
+	if fs.ReadAheadSize <= 0 {
+		return body
+	}
+
+	return &readAheadCloser{Reader: bufio.NewReaderSize(body, fs.ReadAheadSize), body: body}
+}
+
+// seekAhead moves the read stream forward by skipping its data when the offset is within the read-ahead window,
+// which is faster than reopening it. It returns false if the stream has to be reopened.
+func (f *File) seekAhead(offset int64) bool {
+	stream, ok := f.streamRead.(*readAheadCloser)
+	if !ok {
+		return false
+	}
+
+	skip := offset - f.streamReadOffset
+	if skip < 0 || skip > int64(stream.Size()) {
+		return false
+	}
+
+	skipped, err := stream.Discard(int(skip))
+	f.streamReadOffset += int64(skipped)
+
+	return err == nil
+}
+
+// prefetchChunk is a chunk of data prefetched from a stream, or its error
+type prefetchChunk struct {
+	data []byte
+	err  error
+}
+
+// prefetchReader reads a stream in the background, up to a number of chunks ahead of the reads
+type prefetchReader struct {
+	body    io.ReadCloser      // body is the prefetched stream
+	chunks  chan prefetchChunk // chunks are the prefetched chunks
+	done    chan struct{}      // done is closed when the reader is closed
+	stopped chan struct{}      // stopped is closed once the prefetch stopped
+	current []byte             // current is the rest of the chunk being read
+	err     error              // err is the error of the stream
+}
+
+// withPrefetch prefetches the body of a read stream in the background, unless it's disabled
+func (fs *Fs) withPrefetch(body io.ReadCloser) io.ReadCloser {
+	if fs.PrefetchSize <= 0 {
+		return body
+	}
+
+	chunkSize := min(fs.PrefetchSize, PrefetchChunkSize)
+
+	r := &prefetchReader{
+		body:    body,
+		chunks:  make(chan prefetchChunk, max(fs.PrefetchSize/chunkSize, 1)),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go r.prefetch(chunkSize)
+
+	return r
+}
+
+// prefetch reads the stream until its end, its error or the closing of the reader
+func (r *prefetchReader) prefetch(chunkSize int) {
+	defer close(r.stopped)
+
+	for {
+		buffer := make([]byte, chunkSize)
+		n, err := r.body.Read(buffer)
+
+		if n > 0 || err != nil {
+			select {
+			case r.chunks <- prefetchChunk{data: buffer[:n], err: err}:
+			case <-r.done:
+				return
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *prefetchReader) Read(p []byte) (int, error) {
+	for len(r.current) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		chunk := <-r.chunks
+		r.current, r.err = chunk.data, chunk.err
+	}
+
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+
+	return n, nil
+}
+
+// Close stops the prefetch, the body being closed while it's read to interrupt it
+func (r *prefetchReader) Close() error {
+	close(r.done)
+	err := r.body.Close()
+	<-r.stopped
+
+	return err
+}
@@ -0,0 +1,99 @@
+package s3
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadAhead(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	content := strings.Repeat("0123456789", 1000)
+	testCreateFile(t, fs, "/file", content)
+
+	fs.ReadAheadSize = 1024
+
+	file, err := fs.Open("/file")
+	req.NoError(err)
+
+	defer func() { req.NoError(file.Close()) }()
+
+	buffer := make([]byte, 10)
+
+	// The small reads and short forward seeks are served by the read-ahead window
+	for offset := int64(0); offset < 1000; offset += 100 {
+		_, err = file.ReadAt(buffer, offset)
+		req.NoError(err)
+		req.Equal(content[offset:offset+10], string(buffer))
+	}
+
+	req.Zero(file.(*File).Stats().Reopens)
+
+	// The longer and backward seeks reopen the stream
+	_, err = file.ReadAt(buffer, 5000)
+	req.NoError(err)
+	req.Equal(content[5000:5010], string(buffer))
+
+	_, err = file.ReadAt(buffer, 10)
+	req.NoError(err)
+	req.Equal(content[10:20], string(buffer))
+
+	rest, err := io.ReadAll(file)
+	req.NoError(err)
+	req.Equal(content[20:], string(rest))
+	req.Equal(int64(2), file.(*File).Stats().Reopens)
+}
+
+func TestPrefetch(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	content := strings.Repeat("0123456789", 200000)
+	testCreateFile(t, fs, "/file", content)
+
+	fs.PrefetchSize = 512 * 1024
+
+	file, err := fs.Open("/file")
+	req.NoError(err)
+
+	// The data is prefetched before it's read
+	prefetch, ok := file.(*File).streamRead.(*prefetchReader)
+	req.True(ok)
+	req.Eventually(func() bool { return len(prefetch.chunks) == cap(prefetch.chunks) }, 5*time.Second,
+		10*time.Millisecond)
+
+	// And read in order by the slow readers
+	buffer := make([]byte, 1000)
+	for offset := 0; offset < 100000; offset += len(buffer) {
+		_, err = io.ReadFull(file, buffer)
+		req.NoError(err)
+		req.Equal(content[offset:offset+len(buffer)], string(buffer))
+	}
+
+	// From any offset
+	_, err = file.Seek(1500000, io.SeekStart)
+	req.NoError(err)
+
+	rest, err := io.ReadAll(file)
+	req.NoError(err)
+	req.Equal(content[1500000:], string(rest))
+	req.NoError(file.Close())
+
+	// The prefetch stops when the file is closed
+	file, err = fs.Open("/file")
+	req.NoError(err)
+
+	prefetch = file.(*File).streamRead.(*prefetchReader)
+	req.NoError(file.Close())
+
+	select {
+	case <-prefetch.stopped:
+	case <-time.After(5 * time.Second):
+		req.Fail("the prefetch didn't stop")
+	}
+}
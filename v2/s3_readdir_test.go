@@ -0,0 +1,181 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/require"
+)
+
+// countListRequests counts the listing requests sent by an Fs
+func countListRequests(fs *Fs) *int64 {
+	var count int64
+
+	fs.s3API.Handlers.Send.PushBack(func(r *request.Request) {
+		if r.Operation.Name == "ListObjectsV2" {
+			atomic.AddInt64(&count, 1)
+		}
+	})
+
+	return &count
+}
+
+func TestReaddirCursor(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	for i := 0; i < 5; i++ {
+		testCreateFile(t, fs, fmt.Sprintf("/dir/file-%d", i), "content")
+	}
+
+	dir, err := fs.Open("/dir")
+	req.NoError(err)
+
+	file := dir.(*File)
+
+	entries, err := file.ReadDir(2)
+	req.NoError(err)
+	req.Len(entries, 2)
+	req.Equal("file-0", entries[0].Name())
+
+	// Another handle resumes the listing
+	cursor, err := file.ReaddirCursor()
+	req.NoError(err)
+
+	other, err := fs.Open("/dir")
+	req.NoError(err)
+	other.(*File).ResumeReaddir(cursor)
+
+	entries, err = other.(*File).ReadDir(0)
+	req.NoError(err)
+	req.Len(entries, 3)
+	req.Equal("file-2", entries[0].Name())
+
+	_, err = other.(*File).ReadDir(1)
+	req.ErrorIs(err, io.EOF)
+
+	_, err = other.(*File).ReaddirCursor()
+	req.ErrorIs(err, io.EOF)
+
+	// The listing can be rewound
+	_, err = other.Seek(0, io.SeekStart)
+	req.NoError(err)
+
+	entries, err = other.(*File).ReadDir(0)
+	req.NoError(err)
+	req.Len(entries, 5)
+
+	_, err = other.Seek(1, io.SeekStart)
+	req.ErrorIs(err, ErrNotSupported)
+}
+
+func TestReaddirAdaptivePageSize(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	for i := 0; i < 350; i++ {
+		_, err := fs.Create(fmt.Sprintf("/dir/file-%03d", i))
+		req.NoError(err)
+	}
+
+	requests := countListRequests(fs)
+
+	names, err := NewFile(fs, "/dir").Readdirnames(-1)
+	req.NoError(err)
+	req.Len(names, 350)
+	// 100 + 200 + 400 entries
+	req.Equal(int64(3), atomic.LoadInt64(requests))
+
+	fs.ListPageSize = 50
+	names, err = NewFile(fs, "/dir").Readdirnames(-1)
+	req.NoError(err)
+	req.Len(names, 350)
+	req.Equal(int64(3+7), atomic.LoadInt64(requests))
+}
+
+func TestReaddirOrder(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	// The "a" directory comes after the "a-b" and "a.txt" files, as its prefix is "a/"
+	for _, name := range []string{"/dir/b", "/dir/a/x", "/dir/a.txt", "/dir/a0", "/dir/a-b", "/dir/c/y"} {
+		testCreateFile(t, fs, name, "content")
+	}
+
+	expected := []string{"a-b", "a.txt", "a", "a0", "b", "c"}
+
+	for _, pageSize := range []int{1, 2, 4, 100} {
+		file := NewFile(fs, "/dir")
+
+		var names []string
+
+		for {
+			fis, err := file.Readdir(pageSize)
+			for _, fi := range fis {
+				names = append(names, fi.Name())
+			}
+
+			if err == io.EOF {
+				break
+			}
+
+			req.NoError(err)
+		}
+
+		req.Equal(expected, names, fmt.Sprintf("page size %d", pageSize))
+	}
+
+	names, err := NewFile(fs, "/dir").Readdirnames(-1)
+	req.NoError(err)
+	req.Equal(expected, names)
+}
+
+func BenchmarkReaddir(b *testing.B) {
+	fs := __getS3Fs(b)
+
+	for i := 0; i < 5000; i++ {
+		if err := fs.putEmpty(fmt.Sprintf("/dir/file-%05d", i), &uploadParams{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	requests := countListRequests(fs)
+
+	for _, pageSize := range []int{0, 100, 1000} {
+		fs.ListPageSize = pageSize
+		name := fmt.Sprintf("page-%d", pageSize)
+
+		if pageSize == 0 {
+			name = "adaptive"
+		}
+
+		// Listing the first entries only
+		b.Run("first10/"+name, func(b *testing.B) {
+			atomic.StoreInt64(requests, 0)
+
+			for i := 0; i < b.N; i++ {
+				if _, err := NewFile(fs, "/dir").Readdir(10); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(atomic.LoadInt64(requests))/float64(b.N), "requests/op")
+		})
+
+		// Listing the whole directory
+		b.Run("all/"+name, func(b *testing.B) {
+			atomic.StoreInt64(requests, 0)
+
+			for i := 0; i < b.N; i++ {
+				if _, err := NewFile(fs, "/dir").Readdir(-1); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(atomic.LoadInt64(requests))/float64(b.N), "requests/op")
+		})
+	}
+}
@@ -0,0 +1,397 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DefaultFooterSize is the size of the footer cached by a ReaderAt when none is specified.
+// It's large enough for most parquet footers.
+const DefaultFooterSize = 64 * 1024
+
+// DefaultMinFetchSize is the minimum size of the ranges fetched by a ReaderAt when none is specified
+const DefaultMinFetchSize = 256 * 1024
+
+// ReaderAt provides random access to an object, it's tailored for analytics readers (parquet, CSV with
+// indexes, etc.) which read the footer of a file and then jump around between column chunks.
+//
+// The last bytes of the object are fetched (along with the object size) in a single request when the
+// ReaderAt is created and kept in memory. Other reads are rounded up to MinFetchSize, and optionally aligned,
+// the last fetched ranges are kept, and concurrent reads covered by an in-flight request wait for it instead
+// of issuing their own request.
+// nolint: govet
+type ReaderAt struct {
+	fs           *Fs
+	name         string
+	size         int64
+	footer       []byte // footer contains the last bytes of the object
+	footerOffset int64  // footerOffset is the offset of the footer in the object
+	minFetchSize int64
+	alignment    int64      // alignment is the boundary of the fetched ranges, if any
+	etag         string     // etag is the ETag of the read version of the object
+	since        uint64     // since is the sequence number of the changes announced before the read, see WithCoherence
+	blockCache   BlockCache // blockCache stores the fetched blocks, if any
+	blockSize    int64      // blockSize is the size of the blocks of the block cache
+	cachedRanges int        // cachedRanges is the number of fetched ranges kept
+	requests     int64      // requests is the number of GET requests performed
+
+	mu       sync.Mutex
+	inFlight []*rangeFetch // inFlight are the requests currently performed
+	cached   []*rangeFetch // cached are the last completed requests, the most recently used first
+}
+
+// rangeFetch is a ranged GET request, shared by all the reads it covers
+type rangeFetch struct {
+	done  chan struct{}
+	err   error
+	data  []byte
+	start int64
+	end   int64 // end is exclusive
+}
+
+func (rf *rangeFetch) covers(start, end int64) bool {
+	return start >= rf.start && end <= rf.end
+}
+
+// NewReaderAt creates a ReaderAt caching the last footerSize bytes of the object.
+// If footerSize is 0, DefaultFooterSize is used.
+func (fs *Fs) NewReaderAt(name string, footerSize int64) (*ReaderAt, error) {
+	if footerSize <= 0 {
+		footerSize = DefaultFooterSize
+	}
+
+	r := &ReaderAt{
+		fs:           fs,
+		name:         name,
+		minFetchSize: DefaultMinFetchSize,
+		cachedRanges: 1,
+	}
+
+	atomic.AddInt64(&r.requests, 1)
+	r.since = fs.coherence.sequence()
+
+	resp, err := fs.s3API.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+		Range:  aws.String(fmt.Sprintf("bytes=-%d", footerSize)),
+	})
+	if err != nil {
+		// An empty object can't be fetched with a suffix range
+		if info, errStat := fs.Stat(name); errStat == nil && !info.IsDir() && info.Size() == 0 {
+			return r, nil
+		}
+
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if r.footer, err = io.ReadAll(resp.Body); err != nil {
+		return nil, &os.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	r.size = int64(len(r.footer))
+	r.etag = aws.StringValue(resp.ETag)
+
+	// The total size is only provided through the content range
+	if resp.ContentRange != nil {
+		if idx := strings.LastIndex(*resp.ContentRange, "/"); idx >= 0 {
+			if size, errParse := strconv.ParseInt((*resp.ContentRange)[idx+1:], 10, 64); errParse == nil {
+				r.size = size
+			}
+		}
+	}
+
+	r.footerOffset = r.size - int64(len(r.footer))
+
+	return r, nil
+}
+
+// SetMinFetchSize defines the minimum size of ranged requests, small reads are rounded up to it
+func (r *ReaderAt) SetMinFetchSize(size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.minFetchSize = size
+}
+
+// SetAlignment aligns the fetched ranges on multiples of alignment, like 1MB blocks, so that random accesses to
+// the same areas, like the probes of an index or the pages of a database, hit the same ranges. 0 disables it.
+func (r *ReaderAt) SetAlignment(alignment int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alignment = alignment
+}
+
+// SetCachedRanges defines the number of fetched ranges kept in memory, the least recently used ones being evicted.
+// Only the last one is kept by default.
+func (r *ReaderAt) SetCachedRanges(count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if count < 1 {
+		count = 1
+	}
+
+	r.cachedRanges = count
+	if len(r.cached) > count {
+		r.cached = r.cached[:count]
+	}
+}
+
+// SetBlockCache stores the fetched ranges as blocks of blockSize bytes in a cache, possibly shared with other
+// readers, and reads the blocks from it before fetching them. The fetched ranges are aligned on the blocks.
+func (r *ReaderAt) SetBlockCache(cache BlockCache, blockSize int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blockCache = cache
+	r.blockSize = blockSize
+	r.alignment = blockSize
+}
+
+// Size returns the size of the object
+func (r *ReaderAt) Size() int64 {
+	return r.size
+}
+
+// Requests returns the number of GET requests performed so far
+func (r *ReaderAt) Requests() int64 {
+	return atomic.LoadInt64(&r.requests)
+}
+
+// ReadAt reads len(p) bytes from the object starting at byte offset off.
+// It is safe to call ReadAt from multiple goroutines.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p))
+	truncated := false
+
+	if end > r.size {
+		end = r.size
+		truncated = true
+	}
+
+	// The end of the read might be in the footer, which is copied once the beginning is read so that the bytes
+	// read are always the first ones of p
+	footerFrom := max(off, r.footerOffset)
+
+	read := 0
+	if off < min(end, footerFrom) {
+		fetch, err := r.fetch(off, min(end, footerFrom))
+		if err != nil {
+			return read, err
+		}
+
+		read = copy(p[:min(end, footerFrom)-off], fetch.data[off-fetch.start:])
+	}
+
+	if footerFrom < end {
+		read += copy(p[footerFrom-off:end-off], r.footer[footerFrom-r.footerOffset:])
+	}
+
+	if truncated {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+// fetch returns a completed request covering the [start, end) range
+func (r *ReaderAt) fetch(start, end int64) (*rangeFetch, error) {
+	r.mu.Lock()
+
+	if cached := r.cachedRange(start, end); cached != nil {
+		r.mu.Unlock()
+
+		return cached, nil
+	}
+
+	if block := r.cachedBlock(start, end); block != nil {
+		r.mu.Unlock()
+
+		return block, block.err
+	}
+
+	for _, rf := range r.inFlight {
+		if rf.covers(start, end) {
+			r.mu.Unlock()
+			<-rf.done
+
+			return rf, rf.err
+		}
+	}
+
+	start, fetchEnd := r.fetchRange(start, end)
+
+	// There's no need to fetch what the footer already contains, but the cached blocks are complete
+	if fetchEnd > r.footerOffset && r.blockCache == nil {
+		fetchEnd = r.footerOffset
+	} else if fetchEnd > r.size {
+		fetchEnd = r.size
+	}
+
+	rf := &rangeFetch{start: start, end: fetchEnd, done: make(chan struct{})}
+	r.inFlight = append(r.inFlight, rf)
+	r.mu.Unlock()
+
+	rf.data, rf.err = r.get(start, fetchEnd)
+	close(rf.done)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, other := range r.inFlight {
+		if other == rf {
+			r.inFlight = append(r.inFlight[:i], r.inFlight[i+1:]...)
+			break
+		}
+	}
+
+	if rf.err == nil {
+		r.putBlocks(rf)
+		r.cached = append([]*rangeFetch{rf}, r.cached...)
+		if len(r.cached) > r.cachedRanges {
+			r.cached = r.cached[:r.cachedRanges]
+		}
+	}
+
+	return rf, rf.err
+}
+
+// cachedRange returns the cached range covering [start, end), which becomes the most recently used one
+func (r *ReaderAt) cachedRange(start, end int64) *rangeFetch {
+	for i, rf := range r.cached {
+		if rf.covers(start, end) {
+			copy(r.cached[1:i+1], r.cached[:i])
+			r.cached[0] = rf
+
+			return rf
+		}
+	}
+
+	return nil
+}
+
+// blockKey returns the key of a block of the object in the block cache
+func (r *ReaderAt) blockKey(index int64) BlockKey {
+	return BlockKey{Name: r.name, ETag: r.etag, Index: index}
+}
+
+// cachedBlock returns the block of the block cache covering [start, end), if any
+func (r *ReaderAt) cachedBlock(start, end int64) *rangeFetch {
+	if r.blockCache == nil || r.etag == "" {
+		return nil
+	}
+
+	index := start / r.blockSize
+	blockStart := index * r.blockSize
+
+	blockEnd := blockStart + r.blockSize
+	if blockEnd > r.size {
+		blockEnd = r.size
+	}
+
+	if end > blockEnd {
+		return nil
+	}
+
+	data, ok := r.blockCache.Get(r.blockKey(index))
+	if !ok || int64(len(data)) != blockEnd-blockStart {
+		return nil
+	}
+
+	done := make(chan struct{})
+	close(done)
+
+	// The blocks of a version changed by another instance would hide the change, like a conditional GET would fail
+	if !r.fs.coherence.valid(r.name, r.etag, r.since) {
+		return &rangeFetch{err: &os.PathError{Op: "read", Path: r.name, Err: ErrModified}, done: done}
+	}
+
+	return &rangeFetch{start: blockStart, end: blockEnd, data: data, done: done}
+}
+
+// putBlocks stores the blocks of a fetched range in the block cache
+func (r *ReaderAt) putBlocks(rf *rangeFetch) {
+	if r.blockCache == nil || r.etag == "" {
+		return
+	}
+
+	for start := rf.start; start < rf.end; start += r.blockSize {
+		end := start + r.blockSize
+		if end > rf.end {
+			end = rf.end
+		}
+
+		// Only the last block of the object can be smaller
+		if start%r.blockSize == 0 && (end-start == r.blockSize || end == r.size) {
+			r.blockCache.Put(r.blockKey(start/r.blockSize), rf.data[start-rf.start:end-rf.start])
+		}
+	}
+}
+
+// fetchRange returns the range fetched to read [start, end), rounded up to the minimum fetch size and aligned
+func (r *ReaderAt) fetchRange(start, end int64) (int64, int64) {
+	if r.alignment > 0 {
+		start -= start % r.alignment
+	}
+
+	if end-start < r.minFetchSize {
+		end = start + r.minFetchSize
+	}
+
+	if r.alignment > 0 && end%r.alignment != 0 {
+		end += r.alignment - end%r.alignment
+	}
+
+	return start, end
+}
+
+func (r *ReaderAt) get(start, end int64) ([]byte, error) {
+	atomic.AddInt64(&r.requests, 1)
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(r.fs.bucket),
+		Key:    aws.String(r.name),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end-1)),
+	}
+
+	// All the reads, and so the cached blocks, must belong to the same version
+	if r.etag != "" {
+		input.IfMatch = aws.String(r.etag)
+	}
+
+	resp, err := r.fs.s3API.GetObject(input)
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: r.name, Err: err}
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: r.name, Err: err}
+	}
+
+	if int64(len(data)) != end-start {
+		return nil, &os.PathError{Op: "read", Path: r.name, Err: errors.New("object changed during read")}
+	}
+
+	return data, nil
+}
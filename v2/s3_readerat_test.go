@@ -0,0 +1,170 @@
+package s3
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderAt(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	size := 1024 * 1024
+
+	testWriteFile(t, fs, "/file", size)
+
+	content, err := io.ReadAll(NewLimitedReader(rand.New(rand.NewSource(0)), size))
+	req.NoError(err)
+
+	reader, err := fs.NewReaderAt("/file", 1024)
+	req.NoError(err)
+	req.Equal(int64(size), reader.Size())
+
+	buffer := make([]byte, 100)
+
+	// The footer is already there
+	_, err = reader.ReadAt(buffer, int64(size-100))
+	req.NoError(err)
+	req.Equal(content[size-100:], buffer)
+	req.Equal(int64(1), reader.Requests())
+
+	// Two small reads close to each other only lead to one request
+	_, err = reader.ReadAt(buffer, 1000)
+	req.NoError(err)
+	req.Equal(content[1000:1100], buffer)
+	_, err = reader.ReadAt(buffer, 2000)
+	req.NoError(err)
+	req.Equal(content[2000:2100], buffer)
+	req.Equal(int64(2), reader.Requests())
+
+	// Reading across the footer boundary and after the end
+	n, err := reader.ReadAt(buffer, int64(size-1050))
+	req.NoError(err)
+	req.Equal(100, n)
+	req.Equal(content[size-1050:size-950], buffer)
+
+	n, err = reader.ReadAt(buffer, int64(size-10))
+	req.ErrorIs(err, io.EOF)
+	req.Equal(10, n)
+
+	// Concurrent reads
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(off int64) {
+			defer wg.Done()
+
+			buf := make([]byte, 1000)
+			_, errRead := reader.ReadAt(buf, off)
+			req.NoError(errRead)
+			req.Equal(content[off:off+1000], buf)
+		}(int64(i * 50000))
+	}
+
+	wg.Wait()
+}
+
+func TestReaderAtFailure(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	size := 64 * 1024
+
+	faults := NewFaultInjector(0)
+	fs.WithFaultInjector(faults)
+
+	testWriteFile(t, fs, "/file", size)
+
+	content, err := io.ReadAll(NewLimitedReader(rand.New(rand.NewSource(0)), size))
+	req.NoError(err)
+
+	reader, err := fs.NewReaderAt("/file", 1024)
+	req.NoError(err)
+
+	// A failed read across the footer boundary only reports the bytes copied to the buffer
+	faults.Set("GetObject", &Fault{StatusCode: http.StatusForbidden, Code: "AccessDenied"})
+
+	buffer := bytes.Repeat([]byte{0xff}, 100)
+	n, err := reader.ReadAt(buffer, int64(size-1050))
+	req.Error(err)
+	req.Equal(0, n)
+	req.Equal(bytes.Repeat([]byte{0xff}, 100), buffer)
+
+	faults.Set("GetObject", nil)
+
+	n, err = reader.ReadAt(buffer, int64(size-1050))
+	req.NoError(err)
+	req.Equal(100, n)
+	req.Equal(content[size-1050:size-950], buffer)
+}
+
+func TestReaderAtAlignment(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	size := 1024 * 1024
+
+	testWriteFile(t, fs, "/file", size)
+
+	content, err := io.ReadAll(NewLimitedReader(rand.New(rand.NewSource(0)), size))
+	req.NoError(err)
+
+	reader, err := fs.NewReaderAt("/file", 1024)
+	req.NoError(err)
+
+	reader.SetMinFetchSize(0)
+	reader.SetAlignment(64 * 1024)
+	reader.SetCachedRanges(2)
+
+	buffer := make([]byte, 100)
+	read := func(offset int) {
+		_, err := reader.ReadAt(buffer, int64(offset))
+		req.NoError(err)
+		req.Equal(content[offset:offset+100], buffer)
+	}
+
+	// The reads within the same aligned blocks share a request, the footer was fetched first
+	read(70000)
+	read(65536)
+	read(140000)
+	req.Equal(int64(3), reader.Requests())
+
+	// The probes of both blocks are served by the cache
+	read(66000)
+	read(150000)
+	read(200000)
+	read(150500)
+	req.Equal(int64(4), reader.Requests())
+
+	// The least recently used block was evicted
+	read(65536)
+	req.Equal(int64(5), reader.Requests())
+}
+
+func TestReaderAtChanged(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	size := 64 * 1024
+
+	testWriteFile(t, fs, "/file", size)
+
+	reader, err := fs.NewReaderAt("/file", 1024)
+	req.NoError(err)
+
+	// A file overwritten with the same size can't be mixed with the version being read
+	file, err := fs.Create("/file")
+	req.NoError(err)
+	_, err = file.Write(bytes.Repeat([]byte{0xff}, size))
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	buffer := make([]byte, 100)
+	_, err = reader.ReadAt(buffer, 1000)
+	req.Error(err)
+	req.NotEqual(bytes.Repeat([]byte{0xff}, 100), buffer)
+}
@@ -0,0 +1,131 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ReadFile reads a whole file with a single request
+func (fs *Fs) ReadFile(name string) ([]byte, error) {
+	resp, err := fs.s3API.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			err = os.ErrNotExist
+		}
+
+		return nil, &os.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	body, err := transformRead(name, resp.Body, fs.readTransforms(name, resp.Metadata))
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close() // nolint: errcheck
+
+	buffer := bytes.NewBuffer(make([]byte, 0, aws.Int64Value(resp.ContentLength)))
+	if _, err = io.Copy(buffer, body); err != nil {
+		return nil, &os.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// WriteFile writes a whole file with a single request. The props, if not nil, override the FileProps of the Fs
+// for this file.
+func (fs *Fs) WriteFile(name string, data []byte, props *UploadedFileProperties) error {
+	params, err := fs.writePermParams(name, 0666)
+	if err != nil {
+		return err
+	}
+
+	params.applyProps(props)
+
+	// The inlined files have no metadata to record the transforms
+	if fs.inlined(len(data)) && fs.writeTransforms(name) == nil {
+		if err = fs.checkPolicyContent(name, bytes.NewReader(data), int64(len(data))); err != nil {
+			return err
+		}
+
+		return fs.writeInline([]batchFile{{name: name, data: data}})
+	}
+
+	return fs.putFile(name, bytes.NewReader(data), int64(len(data)), params)
+}
+
+// ErrFileTooLarge is returned by AppendSmall when the file would be bigger than the allowed size
+var ErrFileTooLarge = errors.New("file too large")
+
+// appendSmallAttempts is the number of times AppendSmall tries to append when the file is modified concurrently
+const appendSmallAttempts = 5
+
+// AppendSmall appends data to a small file, it's created if it doesn't exist. The file is read and written back
+// with its new content on the condition that it wasn't modified in between, which makes the append atomic. It's
+// retried a few times on concurrent modifications before failing with ErrModified. The file can't get bigger than
+// maxSize.
+func (fs *Fs) AppendSmall(name string, data []byte, maxSize int64) error {
+	var err error
+
+	for attempt := 0; attempt < appendSmallAttempts; attempt++ {
+		if err = fs.appendSmall(name, data, maxSize); !errors.Is(err, ErrModified) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (fs *Fs) appendSmall(name string, data []byte, maxSize int64) error {
+	params, err := fs.writePermParams(name, 0666)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+
+	resp, err := fs.s3API.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	})
+
+	switch {
+	case isNotFound(err):
+		params.ifNoneMatch = aws.String("*")
+	case err != nil:
+		return &os.PathError{Op: "append", Path: name, Err: err}
+	default:
+		defer resp.Body.Close() // nolint: errcheck
+
+		if aws.Int64Value(resp.ContentLength)+int64(len(data)) > maxSize {
+			return &os.PathError{Op: "append", Path: name, Err: ErrFileTooLarge}
+		}
+
+		if content, err = io.ReadAll(resp.Body); err != nil {
+			return &os.PathError{Op: "append", Path: name, Err: err}
+		}
+
+		params.ifMatch = resp.ETag
+	}
+
+	if int64(len(content)+len(data)) > maxSize {
+		return &os.PathError{Op: "append", Path: name, Err: ErrFileTooLarge}
+	}
+
+	content = append(content, data...)
+
+	err = fs.putFile(name, bytes.NewReader(content), int64(len(content)), params)
+	if isPreconditionFailed(err) {
+		return &os.PathError{Op: "append", Path: name, Err: ErrModified}
+	}
+
+	return err
+}
@@ -0,0 +1,81 @@
+package s3
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWriteFile(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	requests := 0
+
+	fs.s3API.Handlers.Send.PushFrontNamed(request.NamedHandler{Name: "test.Count", Fn: func(*request.Request) {
+		requests++
+	}})
+
+	fs.ContentMD5 = true
+
+	req.NoError(fs.WriteFile("/config.json", []byte(`{"key":"value"}`), &UploadedFileProperties{
+		CacheControl: aws.String("no-cache"),
+	}))
+
+	content, err := fs.ReadFile("/config.json")
+	req.NoError(err)
+	req.Equal(`{"key":"value"}`, string(content))
+	req.Equal(2, requests)
+
+	head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String("/config.json")})
+	req.NoError(err)
+	req.Equal("no-cache", aws.StringValue(head.CacheControl))
+	req.Equal("application/json", aws.StringValue(head.ContentType))
+
+	_, err = fs.ReadFile("/missing.json")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	// Empty files
+	req.NoError(fs.WriteFile("/empty", nil, nil))
+
+	content, err = fs.ReadFile("/empty")
+	req.NoError(err)
+	req.Empty(content)
+}
+
+func TestAppendSmall(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	req.NoError(fs.AppendSmall("/manifest.txt", []byte("line 1\n"), 32))
+	req.NoError(fs.AppendSmall("/manifest.txt", []byte("line 2\n"), 32))
+
+	// A concurrent modification makes the append read the file again
+	modified := false
+	fs.BeforeUpload = func(name string, _ *s3manager.UploadInput) {
+		if !modified {
+			modified = true
+			_, err := fs.s3API.PutObject(&s3.PutObjectInput{
+				Bucket: aws.String(fs.bucket),
+				Key:    aws.String(name),
+				Body:   strings.NewReader("line 1\nline 2\nother\n"),
+			})
+			req.NoError(err)
+		}
+	}
+
+	req.NoError(fs.AppendSmall("/manifest.txt", []byte("line 3\n"), 32))
+	req.True(modified)
+
+	content, err := fs.ReadFile("/manifest.txt")
+	req.NoError(err)
+	req.Equal("line 1\nline 2\nother\nline 3\n", string(content))
+
+	req.ErrorIs(fs.AppendSmall("/manifest.txt", []byte("line 4\n"), 32), ErrFileTooLarge)
+}
@@ -0,0 +1,101 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// bucketRegionHeader is the header in which S3 returns the region of a bucket, even when the request was sent
+// to the wrong region
+const bucketRegionHeader = "X-Amz-Bucket-Region"
+
+// ErrUnknownRegion is returned when the region of the bucket can't be detected
+var ErrUnknownRegion = errors.New("unknown bucket region")
+
+// DetectRegion discovers the region of the bucket and, if it's not the configured one, switches the Fs and its
+// Mirror to it. This saves from knowing the region up front, a wrong one making the requests fail with confusing
+// PermanentRedirect or AuthorizationHeaderMalformed errors.
+// It should be called before the Fs is used.
+func (fs *Fs) DetectRegion() (string, error) {
+	region, err := fs.regionOf(fs.bucket)
+	if err != nil {
+		return "", &os.PathError{Op: "detect-region", Path: fs.bucket, Err: err}
+	}
+
+	if region != aws.StringValue(fs.s3API.Config.Region) {
+		fs.useRegion(region)
+	}
+
+	if fs.Mirror != nil {
+		if _, err = fs.Mirror.DetectRegion(); err != nil {
+			return "", err
+		}
+	}
+
+	return region, nil
+}
+
+// regionOf returns the region of a bucket. It's returned by HeadBucket in a header, whatever the region the request
+// is sent to, and falls back to GetBucketLocation for the servers not returning it.
+func (fs *Fs) regionOf(bucket string) (string, error) {
+	// The requests to a Multi-Region Access Point are routed to the nearest region
+	if parseMRAP(bucket) != nil {
+		return "", fmt.Errorf("%w: %s is a multi-region access point", ErrUnknownRegion, bucket)
+	}
+
+	if ap, err := parseAccessPoint(bucket); ap != nil && err == nil {
+		return ap.Region, nil
+	}
+
+	req, _ := fs.s3API.HeadBucketRequest(&s3.HeadBucketInput{Bucket: aws.String(bucket)})
+
+	// The redirections to the bucket region must not be followed
+	req.DisableFollowRedirects = true
+	req.Retryer = client.NoOpRetryer{}
+
+	errHead := req.Send()
+
+	if req.HTTPResponse != nil {
+		if region := req.HTTPResponse.Header.Get(bucketRegionHeader); region != "" {
+			return region, nil
+		}
+	}
+
+	location, err := fs.s3API.GetBucketLocation(&s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		if errHead != nil {
+			return "", fmt.Errorf("%w: %v", ErrUnknownRegion, errHead)
+		}
+
+		return "", fmt.Errorf("%w: %v", ErrUnknownRegion, err)
+	}
+
+	return normalizeLocation(aws.StringValue(location.LocationConstraint)), nil
+}
+
+// normalizeLocation returns the region of a bucket location constraint, us-east-1 having an empty one
+func normalizeLocation(location string) string {
+	switch location {
+	case "":
+		return "us-east-1"
+	case s3.BucketLocationConstraintEu:
+		return "eu-west-1"
+	default:
+		return location
+	}
+}
+
+// useRegion switches the client of the Fs to another region, keeping its handlers
+func (fs *Fs) useRegion(region string) {
+	fs.session = fs.session.Copy(&aws.Config{Region: aws.String(region)})
+
+	api := s3.New(fs.session)
+	api.Handlers = fs.s3API.Handlers.Copy()
+	fs.s3API = api
+}
@@ -0,0 +1,41 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRegion(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	info, err := fs.BucketInfo()
+	req.NoError(err)
+
+	region, err := fs.DetectRegion()
+	req.NoError(err)
+	req.Equal(info.Region, region)
+	req.Equal(region, aws.StringValue(fs.s3API.Config.Region))
+
+	// The Fs keeps working with its new client, with the same handlers
+	gets := 0
+	fs.BeforeGet = func(*s3.GetObjectInput) { gets++ }
+
+	req.NoError(afero.WriteFile(fs, "/file.txt", []byte("content"), 0600))
+
+	content, err := afero.ReadFile(fs, "/file.txt")
+	req.NoError(err)
+	req.Equal("content", string(content))
+	req.Equal(1, gets)
+}
+
+func TestNormalizeLocation(t *testing.T) {
+	req := require.New(t)
+	req.Equal("us-east-1", normalizeLocation(""))
+	req.Equal("eu-west-1", normalizeLocation("EU"))
+	req.Equal("ap-south-1", normalizeLocation("ap-south-1"))
+}
@@ -0,0 +1,283 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DefaultRemoveAllBatchSize is the default number of objects removed between two cancellation checks
+const DefaultRemoveAllBatchSize = 1000
+
+// maxDeleteObjectsKeys is the maximum number of keys of a DeleteObjects request
+const maxDeleteObjectsKeys = 1000
+
+// RemoveAllOptions defines how RemoveAllWithOptions removes a directory tree
+type RemoveAllOptions struct {
+	// BatchSize is the number of objects listed and removed between two cancellation checks,
+	// DefaultRemoveAllBatchSize if 0
+	BatchSize int
+	// Concurrency is the number of parallel requests, DefaultBulkConcurrency if 0
+	Concurrency int
+	// Progress is called after each removed object with the total number of objects removed and bytes freed so far
+	Progress func(removed, freed int64, key string)
+}
+
+func (o *RemoveAllOptions) batchSize() int {
+	if o == nil || o.BatchSize <= 0 {
+		return DefaultRemoveAllBatchSize
+	}
+
+	return o.BatchSize
+}
+
+func (o *RemoveAllOptions) concurrency() int {
+	if o == nil || o.Concurrency <= 0 {
+		return DefaultBulkConcurrency
+	}
+
+	return o.Concurrency
+}
+
+// RemoveAllError is returned by RemoveAllWithOptions when some objects couldn't be removed. It matches the error of
+// the first failed key.
+type RemoveAllError struct {
+	Errors map[string]error // Errors are the errors of the objects that couldn't be removed, by key
+}
+
+// keys returns the failed keys in order
+func (e *RemoveAllError) keys() []string {
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (e *RemoveAllError) Error() string {
+	key := e.keys()[0]
+	return fmt.Sprintf("%d objects couldn't be removed, like %s: %v", len(e.Errors), key, e.Errors[key])
+}
+
+// Unwrap returns the error of the first failed key
+func (e *RemoveAllError) Unwrap() error {
+	return e.Errors[e.keys()[0]]
+}
+
+// removeAllRun is the state of a RemoveAllWithOptions call
+type removeAllRun struct {
+	fs           *Fs
+	opts         *RemoveAllOptions
+	mu           sync.Mutex
+	removedCount int64
+	freed        int64
+	errs         map[string]error
+}
+
+// RemoveAllWithOptions removes a file or a directory tree like RemoveAll, with a single flat listing. The objects
+// are removed by batches, the cancellation of the context being checked between them. The failed objects don't stop
+// the removal, they're all reported by a *RemoveAllError. The opts can be nil.
+func (fs *Fs) RemoveAllWithOptions(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	return pathError("removeall", name, fs.removeAllJournaled(ctx, name, opts))
+}
+
+// removeAllJournaled checks and journals the removal of a file or a directory tree before performing it
+func (fs *Fs) removeAllJournaled(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	if err := fs.checkWritable("removeall", name); err != nil {
+		return err
+	}
+
+	if err := fs.checkDirName(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := fs.checkTreeRetention(name); err != nil {
+		return err
+	}
+
+	entry, err := fs.journalBegin(JournalOpRemoveAll, name, "")
+	if err != nil {
+		return err
+	}
+
+	return fs.journalEnd(entry, fs.removeAllWithOptions(ctx, name, opts))
+}
+
+func (fs *Fs) removeAllWithOptions(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	run := &removeAllRun{fs: fs, opts: opts, errs: make(map[string]error)}
+	batch := make([]*s3.Object, 0, opts.batchSize())
+
+	errList := fs.walkObjects(dirPrefix(name), func(obj *s3.Object) bool {
+		if batch = append(batch, obj); len(batch) < cap(batch) {
+			return true
+		}
+
+		run.removeBatch(batch)
+		batch = batch[:0]
+
+		return ctx.Err() == nil
+	})
+	if errList != nil {
+		return &os.PathError{Op: "removeall", Path: name, Err: errList}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return &os.PathError{Op: "removeall", Path: name, Err: err}
+	}
+
+	file, err := fs.removedFile(name)
+	if err != nil {
+		return err
+	}
+
+	if file != nil {
+		batch = append(batch, file)
+	}
+
+	run.removeBatch(batch)
+
+	if len(run.errs) > 0 {
+		return &RemoveAllError{Errors: run.errs}
+	}
+
+	return fs.removeSuffixMarkers(name)
+}
+
+// removedFile returns the object of a file removed by RemoveAllWithOptions, nil if it's a directory. An inlined file
+// is removed from its index.
+func (fs *Fs) removedFile(name string) (*s3.Object, error) {
+	clean := path.Clean("/" + name)
+	if clean == "/" {
+		return nil, nil
+	}
+
+	if fs.InlineThreshold > 0 {
+		if err := fs.removeInline(clean); err != nil {
+			return nil, &os.PathError{Op: "removeall", Path: name, Err: err}
+		}
+	}
+
+	out, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(clean),
+	})
+	if isNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, &os.PathError{Op: "removeall", Path: name, Err: err}
+	}
+
+	return &s3.Object{Key: aws.String(clean[1:]), Size: out.ContentLength}, nil
+}
+
+// removeBatch removes a batch of objects with parallel DeleteObjects requests
+func (r *removeAllRun) removeBatch(batch []*s3.Object) {
+	sizes := make(map[string]int64, len(batch))
+	keys := make([]string, 0, len(batch))
+
+	for _, obj := range batch {
+		key := aws.StringValue(obj.Key)
+		sizes[key] = aws.Int64Value(obj.Size)
+		keys = append(keys, key)
+	}
+
+	chunks := (len(keys) + maxDeleteObjectsKeys - 1) / maxDeleteObjectsKeys
+
+	_ = forEachIndex("removeall", chunks, &BulkOptions{Concurrency: r.opts.concurrency()}, func(i int) (string, error) {
+		chunk := keys[i*maxDeleteObjectsKeys : min((i+1)*maxDeleteObjectsKeys, len(keys))]
+		errs := r.fs.deleteObjects(chunk, func(key string) { r.removed(key, sizes[key]) })
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		for key, err := range errs {
+			r.errs[key] = err
+		}
+
+		return "", nil
+	})
+}
+
+// removed reports the progress of a removed object
+func (r *removeAllRun) removed(key string, size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removedCount++
+	r.freed += size
+
+	if r.opts != nil && r.opts.Progress != nil {
+		r.opts.Progress(r.removedCount, r.freed, key)
+	}
+}
+
+// deleteObjects removes objects by their keys with DeleteObjects requests of up to maxDeleteObjectsKeys keys,
+// calling removed for each removed key. It returns the errors of the keys that couldn't be removed.
+func (fs *Fs) deleteObjects(keys []string, removed func(key string)) map[string]error {
+	errs := make(map[string]error)
+
+	for start := 0; start < len(keys); start += maxDeleteObjectsKeys {
+		chunk := keys[start:min(start+maxDeleteObjectsKeys, len(keys))]
+		objects := make([]*s3.ObjectIdentifier, 0, len(chunk))
+
+		for _, key := range chunk {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		out, err := fs.s3API.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(fs.bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			for _, key := range chunk {
+				errs[key] = err
+			}
+
+			continue
+		}
+
+		failed := make(map[string]bool, len(out.Errors))
+		for _, e := range out.Errors {
+			key := aws.StringValue(e.Key)
+			failed[key] = true
+			errs[key] = awserr.New(aws.StringValue(e.Code), aws.StringValue(e.Message), nil)
+		}
+
+		removedKeys := make([]string, 0, len(chunk))
+
+		for _, key := range chunk {
+			if !failed[key] {
+				fs.objectRemoved("/" + key)
+				removedKeys = append(removedKeys, key)
+			}
+		}
+
+		// The mirror loses the same objects
+		if fs.Mirror != nil {
+			for key, err := range fs.Mirror.deleteObjects(removedKeys, nil) {
+				failed[key] = true
+				errs[key] = err
+			}
+		}
+
+		for _, key := range removedKeys {
+			if !failed[key] && removed != nil {
+				removed(key)
+			}
+		}
+	}
+
+	return errs
+}
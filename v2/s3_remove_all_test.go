@@ -0,0 +1,149 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveAllWithOptions(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	createFiles := func() {
+		for i := 0; i < 3; i++ {
+			testCreateFile(t, fs, fmt.Sprintf("/dir/sub%d/file", i), "0123456789")
+		}
+		testCreateFile(t, fs, "/other", "other")
+	}
+
+	t.Run("Progress", func(t *testing.T) {
+		createFiles()
+
+		var removed, freed int64
+
+		req.NoError(fs.RemoveAllWithOptions(context.Background(), "/dir", &RemoveAllOptions{
+			BatchSize:   2,
+			Concurrency: 2,
+			Progress: func(r, f int64, _ string) {
+				removed, freed = r, f
+			},
+		}))
+		req.Equal(int64(3), removed)
+		req.Equal(int64(30), freed)
+
+		_, err := fs.Stat("/dir")
+		req.ErrorIs(err, os.ErrNotExist)
+
+		_, err = fs.Stat("/other")
+		req.NoError(err)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		req.NoError(fs.RemoveAllWithOptions(context.Background(), "/other", nil))
+
+		_, err := fs.Stat("/other")
+		req.ErrorIs(err, os.ErrNotExist)
+	})
+
+	t.Run("Cancellation", func(t *testing.T) {
+		createFiles()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The cancellation stops the removal after the current batch
+		err := fs.RemoveAllWithOptions(ctx, "/dir", &RemoveAllOptions{
+			BatchSize:   1,
+			Concurrency: 1,
+			Progress:    func(int64, int64, string) { cancel() },
+		})
+		req.ErrorIs(err, context.Canceled)
+
+		fis, err := afero.ReadDir(fs, "/dir")
+		req.NoError(err)
+		req.Len(fis, 2)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		createFiles()
+
+		faults := NewFaultInjector(0)
+		fs.WithFaultInjector(faults)
+
+		defer fs.WithFaultInjector(nil)
+
+		faults.Set("DeleteObjects", &Fault{StatusCode: http.StatusForbidden, Code: "AccessDenied"})
+
+		// All the objects are attempted, in a single request
+		err := fs.RemoveAllWithOptions(context.Background(), "/dir", nil)
+
+		var removeErr *RemoveAllError
+		req.ErrorAs(err, &removeErr)
+		req.Len(removeErr.Errors, 3)
+		req.Contains(removeErr.Errors, "dir/sub1/file")
+		req.Equal(int64(1), faults.Injected())
+	})
+}
+
+func TestRemoveAllBatches(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	// The directories are implicit, without markers
+	for i := 0; i < 1100; i++ {
+		_, err := fs.s3API.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(fs.bucket),
+			Key:    aws.String(fmt.Sprintf("dir/sub%d/deep/file%d", i%3, i)),
+			Body:   strings.NewReader("x"),
+		})
+		req.NoError(err)
+	}
+
+	var (
+		mu       sync.Mutex
+		requests = make(map[string]int)
+	)
+
+	fs.s3API.Handlers.Send.PushBack(func(r *request.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests[r.Operation.Name]++
+	})
+
+	// The objects are listed flat and removed by DeleteObjects requests of up to 1000 keys
+	req.NoError(fs.RemoveAll("/dir"))
+	req.Equal(2, requests["ListObjectsV2"])
+	req.Zero(requests["DeleteObject"])
+	req.Equal(2, requests["DeleteObjects"])
+
+	_, err := fs.Stat("/dir")
+	req.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestRemoveAllFile(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/a/file", "content")
+	testCreateFile(t, fs, "/a/other", "content")
+
+	// A file is removed like with os.RemoveAll, the other files of its directory are kept
+	req.NoError(fs.RemoveAll("/a/file"))
+
+	_, err := fs.Stat("/a/file")
+	req.ErrorIs(err, os.ErrNotExist)
+	_, err = fs.Stat("/a/other")
+	req.NoError(err)
+}
@@ -0,0 +1,79 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+)
+
+// RenameCollisionError is returned by Rename, wrapped in an *os.LinkError, when the target is below the source or
+// the source below the target, which would make a file and a directory share the same name. It matches os.ErrInvalid.
+// It's also returned by CopyDir when the target is the source or below it.
+type RenameCollisionError struct {
+	Reason string // Reason why the rename is rejected
+}
+
+func (e *RenameCollisionError) Error() string {
+	return "rename collision: " + e.Reason
+}
+
+// Unwrap makes the error match os.ErrInvalid
+func (e *RenameCollisionError) Unwrap() error {
+	return os.ErrInvalid
+}
+
+// checkRename detects the renames which don't change anything or can't make sense. It returns true if there is
+// nothing to do, the source still has to exist like with os.Rename.
+func (fs *Fs) checkRename(oldname, newname string) (bool, error) {
+	oldClean, newClean := path.Clean("/"+oldname), path.Clean("/"+newname)
+
+	var reason string
+
+	switch {
+	case oldClean == newClean:
+		_, err := fs.Stat(oldname)
+		return true, renameError(oldname, newname, err)
+	case oldClean == "/" || strings.HasPrefix(newClean, oldClean+"/"):
+		reason = "the target is below the source"
+	case newClean == "/" || strings.HasPrefix(oldClean, newClean+"/"):
+		reason = "the target is a parent directory of the source"
+	case strings.HasSuffix(oldname, "/") || strings.HasSuffix(newname, "/"):
+		return true, fs.checkRenameDir(oldname, newname)
+	default:
+		return false, nil
+	}
+
+	return true, &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: &RenameCollisionError{Reason: reason}}
+}
+
+// checkRenameDir rejects the renames with a trailing slash: the files can't be directories and the directories can't
+// be renamed
+func (fs *Fs) checkRenameDir(oldname, newname string) error {
+	info, err := fs.Stat(oldname)
+
+	switch {
+	case err != nil:
+		return renameError(oldname, newname, err)
+	case info.IsDir():
+		return renameError(oldname, newname, ErrNotSupported)
+	default:
+		return renameError(oldname, newname, syscall.ENOTDIR)
+	}
+}
+
+// renameError converts an error to the *os.LinkError returned by Rename, nil staying nil
+func renameError(oldname, newname string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+}
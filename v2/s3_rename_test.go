@@ -0,0 +1,113 @@
+package s3
+
+import (
+	"bytes"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameCollisions(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/a", "a")
+	testCreateFile(t, fs, "/dir/file", "file")
+
+	t.Run("NoOp", func(t *testing.T) {
+		req.NoError(fs.Rename("/a", "/a"))
+		req.NoError(fs.Rename("/a", "a"))
+		req.NoError(fs.Rename("/dir/", "/dir"))
+
+		err := fs.Rename("/missing", "/missing")
+		req.ErrorIs(err, os.ErrNotExist)
+
+		var linkErr *os.LinkError
+		req.ErrorAs(err, &linkErr)
+	})
+
+	t.Run("IntoItself", func(t *testing.T) {
+		for _, names := range [][2]string{
+			{"/a", "/a/b"},
+			{"/dir", "/dir/sub"},
+			{"/dir/", "/dir/sub/file"},
+			{"/", "/root"},
+		} {
+			err := fs.Rename(names[0], names[1])
+
+			var collision *RenameCollisionError
+			req.ErrorAs(err, &collision, names)
+			req.ErrorIs(err, os.ErrInvalid)
+		}
+	})
+
+	t.Run("IntoParent", func(t *testing.T) {
+		for _, names := range [][2]string{
+			{"/dir/file", "/dir"},
+			{"/dir/file", "/"},
+		} {
+			var collision *RenameCollisionError
+			req.ErrorAs(fs.Rename(names[0], names[1]), &collision, names)
+		}
+	})
+
+	// Nothing was changed
+	for _, name := range []string{"/a", "/dir/file"} {
+		_, err := fs.Stat(name)
+		req.NoError(err)
+	}
+
+	_, err := fs.Stat("/a/b")
+	req.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestRenameMultipart(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.MultipartCopyThreshold = 6 * 1024 * 1024
+	fs.CopyPartSize = 5 * 1024 * 1024
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 12*1024*1024/16)
+
+	_, err := fs.s3API.PutObject(&s3.PutObjectInput{
+		Bucket:   aws.String(fs.bucket),
+		Key:      aws.String("big.bin"),
+		Body:     bytes.NewReader(content),
+		Metadata: map[string]*string{"Origin": aws.String("test")},
+	})
+	req.NoError(err)
+
+	var copies int64
+
+	fs.s3API.Handlers.Send.PushBack(func(r *request.Request) {
+		if r.Operation.Name == "UploadPartCopy" {
+			atomic.AddInt64(&copies, 1)
+		}
+	})
+
+	// The files bigger than the threshold are copied by parts
+	req.NoError(fs.Rename("/big.bin", "/renamed.bin"))
+	req.Equal(int64(3), atomic.LoadInt64(&copies))
+
+	renamed, err := afero.ReadFile(fs, "/renamed.bin")
+	req.NoError(err)
+	req.True(bytes.Equal(content, renamed))
+
+	head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String("renamed.bin")})
+	req.NoError(err)
+	req.Equal("test", aws.StringValue(head.Metadata["Origin"]))
+
+	_, err = fs.Stat("/big.bin")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	// Not the small ones
+	testCreateFile(t, fs, "/small.txt", "small")
+	req.NoError(fs.Rename("/small.txt", "/small-renamed.txt"))
+	req.Equal(int64(3), atomic.LoadInt64(&copies))
+}
@@ -0,0 +1,299 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// ReplicationOp is the type of operation a replication task replays
+type ReplicationOp int
+
+const (
+	// ReplicationWrite means the file was written on the source
+	ReplicationWrite ReplicationOp = iota
+	// ReplicationRemove means the file was removed from the source
+	ReplicationRemove
+)
+
+// retryPollInterval is the maximum delay before a worker pops a task again after popping one not due yet
+const retryPollInterval = 100 * time.Millisecond
+
+// ErrQueueFull is returned when a replication task can't be queued
+var ErrQueueFull = errors.New("replication queue is full")
+
+// ReplicationTask describes an operation that must be replayed on the replication targets
+type ReplicationTask struct {
+	Enqueued time.Time     // Enqueued is the time of the source operation
+	Name     string        // Name of the file
+	Op       ReplicationOp // Op is the operation to replay
+	Attempts int           // Attempts is the number of failed attempts so far
+	// NotBefore is the time of the next attempt of a failed task, which waits in the queue until then
+	NotBefore time.Time
+}
+
+// ReplicationQueue stores the pending replication tasks. It can be backed by any persistent queue to survive
+// restarts.
+type ReplicationQueue interface {
+	// Push adds a task to the queue
+	Push(task *ReplicationTask) error
+	// Pop waits for a task and removes it from the queue
+	Pop(ctx context.Context) (*ReplicationTask, error)
+}
+
+// memoryReplicationQueue is an in-memory ReplicationQueue
+type memoryReplicationQueue struct {
+	tasks chan *ReplicationTask
+}
+
+// NewMemoryReplicationQueue creates an in-memory replication queue holding up to size tasks
+func NewMemoryReplicationQueue(size int) ReplicationQueue {
+	return &memoryReplicationQueue{tasks: make(chan *ReplicationTask, size)}
+}
+
+func (q *memoryReplicationQueue) Push(task *ReplicationTask) error {
+	select {
+	case q.tasks <- task:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *memoryReplicationQueue) Pop(ctx context.Context) (*ReplicationTask, error) {
+	select {
+	case task := <-q.tasks:
+		return task, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Replicator asynchronously replays the writes and removals performed on an Fs to some target file systems.
+// It provides eventual consistency, unlike Fs.Mirror which writes synchronously.
+// nolint: govet
+type Replicator struct {
+	MaxAttempts int                                    // MaxAttempts before a task is dropped, 0 means no limit
+	MinBackoff  time.Duration                          // MinBackoff is the delay before the first retry
+	MaxBackoff  time.Duration                          // MaxBackoff is the maximum delay between two retries
+	OnError     func(task *ReplicationTask, err error) // OnError is called on every failed attempt
+
+	source  *Fs
+	targets []afero.Fs
+	queue   ReplicationQueue
+	pending int64 // pending is the number of tasks not replicated yet
+	lag     int64 // lag is the delay between the source operation and its replication, in nanoseconds
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	locksMu sync.Mutex
+	locks   map[string]chan struct{} // locks are the files being replicated
+}
+
+// NewReplicator creates a replicator for an Fs and registers it, replication starts with Start.
+func NewReplicator(source *Fs, queue ReplicationQueue, targets ...afero.Fs) *Replicator {
+	r := &Replicator{
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+		source:     source,
+		targets:    targets,
+		queue:      queue,
+	}
+	source.Replicator = r
+
+	return r
+}
+
+// enqueue is called by the Fs after each successful mutation
+func (r *Replicator) enqueue(op ReplicationOp, name string) {
+	atomic.AddInt64(&r.pending, 1)
+
+	r.push(&ReplicationTask{Name: name, Op: op, Enqueued: time.Now()})
+}
+
+// push adds a pending task to the queue, the tasks that can't be queued are dropped
+func (r *Replicator) push(task *ReplicationTask) {
+	if err := r.queue.Push(task); err != nil {
+		atomic.AddInt64(&r.pending, -1)
+		r.failed(task, err)
+	}
+}
+
+// Start starts the replication workers
+func (r *Replicator) Start(workers int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	for i := 0; i < workers; i++ {
+		r.wg.Add(1)
+
+		go func() {
+			defer r.wg.Done()
+			r.work(ctx)
+		}()
+	}
+}
+
+// Stop stops the workers, the pending tasks stay in the queue
+func (r *Replicator) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	r.wg.Wait()
+}
+
+// Pending returns the number of tasks that haven't been replicated yet
+func (r *Replicator) Pending() int64 {
+	return atomic.LoadInt64(&r.pending)
+}
+
+// Lag returns the delay between the last replicated operation and its replication
+func (r *Replicator) Lag() time.Duration {
+	return time.Duration(atomic.LoadInt64(&r.lag))
+}
+
+func (r *Replicator) work(ctx context.Context) {
+	for {
+		task, err := r.queue.Pop(ctx)
+		if err != nil {
+			return
+		}
+
+		// The tasks waiting for their retry stay in the queue, the other ones being processed in the meantime
+		if wait := time.Until(task.NotBefore); wait > 0 {
+			r.push(task)
+
+			select {
+			case <-time.After(min(wait, retryPollInterval)):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := r.replicate(task); err != nil {
+			task.Attempts++
+			r.failed(task, err)
+
+			if r.MaxAttempts == 0 || task.Attempts < r.MaxAttempts {
+				r.retryLater(task)
+				continue
+			}
+		} else {
+			atomic.StoreInt64(&r.lag, int64(time.Since(task.Enqueued)))
+		}
+
+		atomic.AddInt64(&r.pending, -1)
+	}
+}
+
+func (r *Replicator) failed(task *ReplicationTask, err error) {
+	if r.OnError != nil {
+		r.OnError(task, err)
+	}
+}
+
+// retryLater pushes back the task right away, to be retried once its exponential backoff delay has elapsed
+func (r *Replicator) retryLater(task *ReplicationTask) {
+	delay := r.MinBackoff << (task.Attempts - 1)
+	if delay > r.MaxBackoff || delay <= 0 {
+		delay = r.MaxBackoff
+	}
+
+	task.NotBefore = time.Now().Add(delay)
+	r.push(task)
+}
+
+func (r *Replicator) replicate(task *ReplicationTask) error {
+	unlock := r.lock(task.Name)
+	defer unlock()
+
+	for _, target := range r.targets {
+		if err := r.syncTarget(target, task.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lock prevents tasks on the same file from being processed concurrently
+func (r *Replicator) lock(name string) func() {
+	for {
+		r.locksMu.Lock()
+
+		if r.locks == nil {
+			r.locks = make(map[string]chan struct{})
+		}
+
+		if busy, ok := r.locks[name]; ok {
+			r.locksMu.Unlock()
+			<-busy
+
+			continue
+		}
+
+		done := make(chan struct{})
+		r.locks[name] = done
+		r.locksMu.Unlock()
+
+		return func() {
+			r.locksMu.Lock()
+			delete(r.locks, name)
+			r.locksMu.Unlock()
+			close(done)
+		}
+	}
+}
+
+// syncTarget aligns a target on the current state of the source. The operation of the task is ignored, which
+// makes the replication converge whatever the order in which the tasks are processed.
+func (r *Replicator) syncTarget(target afero.Fs, name string) error {
+	info, err := r.source.Stat(name)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist) && strings.HasSuffix(name, "/"):
+		return target.RemoveAll(name)
+	case errors.Is(err, os.ErrNotExist):
+		if err = target.Remove(name); errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+
+		return err
+	case err != nil:
+		return err
+	case info.IsDir() || strings.HasSuffix(name, "/"):
+		return target.MkdirAll(name, 0750)
+	default:
+		return r.copyTo(target, name)
+	}
+}
+
+func (r *Replicator) copyTo(target afero.Fs, name string) error {
+	src, err := r.source.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = src.Close() }()
+
+	dst, err := target.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
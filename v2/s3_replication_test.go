@@ -0,0 +1,64 @@
+package s3
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicator(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	target := afero.NewMemMapFs()
+
+	replicator := NewReplicator(fs, NewMemoryReplicationQueue(100), target)
+	replicator.Start(2)
+	defer replicator.Stop()
+
+	testCreateFile(t, fs, "/dir/file1", "Hello")
+	testCreateFile(t, fs, "/dir/file2", "world")
+	req.NoError(fs.Remove("/dir/file2"))
+
+	req.Eventually(func() bool { return replicator.Pending() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	content, err := afero.ReadFile(target, "/dir/file1")
+	req.NoError(err)
+	req.Equal("Hello", string(content))
+
+	_, err = target.Stat("/dir/file2")
+	req.Error(err)
+	req.Greater(int64(replicator.Lag()), int64(0))
+}
+
+func TestReplicatorRetry(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	queue := NewMemoryReplicationQueue(100)
+
+	var failures int64
+
+	replicator := NewReplicator(fs, queue, afero.NewReadOnlyFs(afero.NewMemMapFs()))
+	replicator.MinBackoff = time.Hour
+	replicator.OnError = func(*ReplicationTask, error) { atomic.AddInt64(&failures, 1) }
+	replicator.Start(2)
+
+	testCreateFile(t, fs, "/dir/file", "Hello")
+	req.Eventually(func() bool { return atomic.LoadInt64(&failures) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// The task waiting for its retry stays in the queue once the replicator is stopped
+	replicator.Stop()
+	req.Equal(int64(1), replicator.Pending())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	task, err := queue.Pop(ctx)
+	req.NoError(err)
+	req.Equal("/dir/file", task.Name)
+	req.Equal(1, task.Attempts)
+	req.True(task.NotBefore.After(time.Now()))
+}
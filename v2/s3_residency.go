@@ -0,0 +1,178 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// residencyHandler is the name of the handler checking the regions of the buckets
+const residencyHandler = "afero-s3.CheckResidency"
+
+// dataOperations are the operations transferring the content of the files
+var dataOperations = map[string]bool{
+	"GetObject":               true,
+	"PutObject":               true,
+	"CopyObject":              true,
+	"CreateMultipartUpload":   true,
+	"UploadPart":              true,
+	"UploadPartCopy":          true,
+	"CompleteMultipartUpload": true,
+	"SelectObjectContent":     true,
+}
+
+// RegionNotAllowedError is returned by the requests transferring data to or from a bucket outside the allowed
+// regions, see WithAllowedRegions. It matches os.ErrPermission.
+type RegionNotAllowedError struct {
+	Bucket  string   // Bucket outside the allowed regions
+	Region  string   // Region of the bucket
+	Allowed []string // Allowed are the allowed regions
+}
+
+func (e *RegionNotAllowedError) Error() string {
+	return fmt.Sprintf("bucket %s is in region %s, outside the allowed regions %s", e.Bucket, e.Region,
+		strings.Join(e.Allowed, ", "))
+}
+
+// Unwrap makes the error match os.ErrPermission
+func (e *RegionNotAllowedError) Unwrap() error {
+	return os.ErrPermission
+}
+
+// residencyState is the allowlist of regions of WithAllowedRegions
+type residencyState struct {
+	allowed map[string]bool
+	mu      sync.Mutex
+	regions map[string]string // regions are the regions of the buckets already checked, which can't change
+}
+
+// WithAllowedRegions restricts the data transfers of the Fs and its Mirror to the buckets of some regions, for data
+// residency requirements. Before any request transferring the content of files, the region of its bucket, and the
+// one of the source bucket of copies, is checked against the allowlist. The requests fail with a
+// *RegionNotAllowedError otherwise, or with the error of the region detection, without being sent. The region of
+// every bucket is only detected once. Calling it without any region removes the restriction.
+// It should be called before the Fs is used.
+func (fs *Fs) WithAllowedRegions(regions ...string) *Fs {
+	fs.residency = nil
+	if len(regions) > 0 {
+		fs.residency = &residencyState{allowed: make(map[string]bool), regions: make(map[string]string)}
+		for _, region := range regions {
+			fs.residency.allowed[region] = true
+		}
+	}
+
+	fs.s3API.Handlers.Validate.Remove(request.NamedHandler{Name: residencyHandler})
+	if fs.residency != nil {
+		fs.s3API.Handlers.Validate.PushBackNamed(fs.residencyHandler())
+	}
+
+	if fs.Mirror != nil {
+		fs.Mirror.WithAllowedRegions(regions...)
+	}
+
+	return fs
+}
+
+// residencyHandler checks the region of the buckets of the requests transferring data
+func (fs *Fs) residencyHandler() request.NamedHandler {
+	return request.NamedHandler{Name: residencyHandler, Fn: func(r *request.Request) {
+		if !dataOperations[r.Operation.Name] {
+			return
+		}
+
+		for _, bucket := range requestBuckets(r.Params) {
+			if err := fs.checkResidency(bucket); err != nil {
+				r.Error = err
+				return
+			}
+		}
+	}}
+}
+
+// residencyOption applies the allowed regions of the Fs to the requests of other clients
+func (fs *Fs) residencyOption() request.Option {
+	return func(r *request.Request) {
+		if fs.residency != nil {
+			r.Handlers.Validate.PushBackNamed(fs.residencyHandler())
+		}
+	}
+}
+
+// checkResidency checks that a bucket is in an allowed region
+func (fs *Fs) checkResidency(bucket string) error {
+	fs.residency.mu.Lock()
+	region, ok := fs.residency.regions[bucket]
+	fs.residency.mu.Unlock()
+
+	if !ok {
+		var err error
+		if region, err = fs.regionOf(bucket); err != nil {
+			return err
+		}
+
+		fs.residency.mu.Lock()
+		fs.residency.regions[bucket] = region
+		fs.residency.mu.Unlock()
+	}
+
+	if fs.residency.allowed[region] {
+		return nil
+	}
+
+	allowed := make([]string, 0, len(fs.residency.allowed))
+	for region := range fs.residency.allowed {
+		allowed = append(allowed, region)
+	}
+
+	sort.Strings(allowed)
+
+	return &RegionNotAllowedError{Bucket: bucket, Region: region, Allowed: allowed}
+}
+
+// copySourceBucket returns the bucket of a copy source: the URL-encoded bucket and key, possibly with a version, or
+// the ARN of an access point followed by /object/ and the key
+func copySourceBucket(source string) string {
+	if bucket, ok := accessPointSource(source); ok {
+		return bucket
+	}
+
+	bucket := strings.SplitN(strings.TrimPrefix(source, "/"), "/", 2)[0]
+	if unescaped, err := url.PathUnescape(bucket); err == nil {
+		bucket = unescaped
+	}
+
+	return bucket
+}
+
+// requestBuckets returns the bucket of a request and the source bucket of the copies
+func requestBuckets(params interface{}) []string {
+	var buckets []string
+
+	for _, field := range []string{"Bucket", "CopySource"} {
+		values, _ := awsutil.ValuesAtPath(params, field)
+
+		for _, value := range values {
+			name, ok := value.(*string)
+			if !ok || aws.StringValue(name) == "" {
+				continue
+			}
+
+			bucket := aws.StringValue(name)
+			if field == "CopySource" {
+				bucket = copySourceBucket(bucket)
+			}
+
+			buckets = append(buckets, bucket)
+		}
+	}
+
+	return buckets
+}
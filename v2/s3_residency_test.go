@@ -0,0 +1,71 @@
+package s3
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowedRegions(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/file", "content")
+
+	region, err := fs.regionOf(fs.bucket)
+	req.NoError(err)
+
+	fs.WithAllowedRegions(region, "eu-west-3")
+
+	req.NoError(afero.WriteFile(fs, "/allowed", []byte("allowed"), 0600))
+
+	data, err := afero.ReadFile(fs, "/file")
+	req.NoError(err)
+	req.Equal("content", string(data))
+
+	// The data of other regions can't be transferred, but the files can still be described
+	fs.WithAllowedRegions("eu-west-3")
+
+	_, err = afero.ReadFile(fs, "/file")
+
+	var regionErr *RegionNotAllowedError
+	req.True(errors.As(err, &regionErr), "%v", err)
+	req.Equal(fs.bucket, regionErr.Bucket)
+	req.Equal(region, regionErr.Region)
+	req.Equal([]string{"eu-west-3"}, regionErr.Allowed)
+	req.ErrorIs(err, os.ErrPermission)
+
+	err = afero.WriteFile(fs, "/denied", []byte("denied"), 0600)
+	req.ErrorIs(err, os.ErrPermission)
+
+	_, err = fs.Stat("/file")
+	req.NoError(err)
+
+	// The source bucket of the copies is checked too
+	fs.WithAllowedRegions(region)
+
+	_, err = fs.s3API.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(fs.bucket),
+		CopySource: aws.String("other-bucket-" + fs.bucket + "/file"),
+		Key:        aws.String("/copy"),
+	})
+	req.ErrorIs(err, ErrUnknownRegion)
+
+	// The restriction is removed
+	fs.WithAllowedRegions()
+	req.NoError(afero.WriteFile(fs, "/denied", []byte("denied"), 0600))
+}
+
+func TestRequestBuckets(t *testing.T) {
+	req := require.New(t)
+	req.Equal([]string{"dst", "my.src"}, requestBuckets(&s3.CopyObjectInput{
+		Bucket:     aws.String("dst"),
+		CopySource: aws.String("/my%2Esrc/dir/file?versionId=1"),
+	}))
+	req.Equal([]string{"bucket"}, requestBuckets(&s3.GetObjectInput{Bucket: aws.String("bucket")}))
+}
@@ -0,0 +1,152 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ErrRetained is matched by the errors of the removals of files under object lock retention or legal hold, see
+// RetentionAware
+var ErrRetained = fmt.Errorf("file is retained: %w", os.ErrPermission)
+
+// RetainedError is returned when removing a file under object lock retention or legal hold, it matches ErrRetained
+type RetainedError struct {
+	Key         string    // Key of the retained object
+	Mode        string    // Mode of the retention, GOVERNANCE or COMPLIANCE, empty if it's only under legal hold
+	RetainUntil time.Time // RetainUntil is the date until which the object is retained, zero if only under legal hold
+	LegalHold   bool      // LegalHold tells if the object is under legal hold
+}
+
+func (e *RetainedError) Error() string {
+	var reasons []string
+
+	if !e.RetainUntil.IsZero() {
+		reasons = append(reasons, fmt.Sprintf("retained in %s mode until %s", e.Mode, e.RetainUntil.Format(time.RFC3339)))
+	}
+
+	if e.LegalHold {
+		reasons = append(reasons, "under legal hold")
+	}
+
+	return fmt.Sprintf("%s is %s", e.Key, strings.Join(reasons, " and "))
+}
+
+// Unwrap makes the error match ErrRetained
+func (e *RetainedError) Unwrap() error {
+	return ErrRetained
+}
+
+// objectLockState caches the object lock configuration of the bucket
+type objectLockState struct {
+	mu      sync.Mutex
+	checked bool
+	enabled bool
+}
+
+// objectLockEnabled tells if object lock is enabled on the bucket. The buckets whose configuration can't be read are
+// considered as having it, their objects being checked one by one.
+func (fs *Fs) objectLockEnabled() (bool, error) {
+	fs.objectLock.mu.Lock()
+	defer fs.objectLock.mu.Unlock()
+
+	if fs.objectLock.checked {
+		return fs.objectLock.enabled, nil
+	}
+
+	out, err := fs.s3API.GetObjectLockConfiguration(&s3.GetObjectLockConfigurationInput{Bucket: aws.String(fs.bucket)})
+
+	switch {
+	case isNotFound(err):
+	case httpStatus(err) == http.StatusForbidden:
+		fs.objectLock.enabled = true
+	case err != nil:
+		return false, err
+	default:
+		fs.objectLock.enabled = out.ObjectLockConfiguration != nil &&
+			aws.StringValue(out.ObjectLockConfiguration.ObjectLockEnabled) == s3.ObjectLockEnabledEnabled
+	}
+
+	fs.objectLock.checked = true
+
+	return fs.objectLock.enabled, nil
+}
+
+// checkRetention fails with a *RetainedError if a file, or one of its versions, is retained in RetentionAware mode
+func (fs *Fs) checkRetention(name, versionID string) error {
+	if !fs.RetentionAware {
+		return nil
+	}
+
+	if enabled, err := fs.objectLockEnabled(); !enabled || err != nil {
+		return err
+	}
+
+	return fs.checkObjectRetention(name, versionID)
+}
+
+// checkObjectRetention fails with a *RetainedError if an object is retained
+func (fs *Fs) checkObjectRetention(name, versionID string) error {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	}
+
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	out, err := fs.s3API.HeadObject(input)
+
+	// The delete markers can't be described
+	if isNotFound(err) || httpStatus(err) == http.StatusMethodNotAllowed {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	retained := &RetainedError{
+		Key:       strings.TrimPrefix(name, "/"),
+		LegalHold: aws.StringValue(out.ObjectLockLegalHoldStatus) == s3.ObjectLockLegalHoldStatusOn,
+	}
+
+	if until := aws.TimeValue(out.ObjectLockRetainUntilDate); until.After(time.Now()) {
+		retained.Mode, retained.RetainUntil = aws.StringValue(out.ObjectLockMode), until
+	}
+
+	if retained.LegalHold || !retained.RetainUntil.IsZero() {
+		return retained
+	}
+
+	return nil
+}
+
+// checkTreeRetention fails with a *RetainedError if a file, or any file of a directory tree, is retained in
+// RetentionAware mode. The files are checked with parallel requests.
+func (fs *Fs) checkTreeRetention(name string) error {
+	if !fs.RetentionAware {
+		return nil
+	}
+
+	if enabled, err := fs.objectLockEnabled(); !enabled || err != nil {
+		return err
+	}
+
+	if clean := path.Clean("/" + name); clean != "/" && !strings.HasSuffix(name, "/") {
+		if err := fs.checkObjectRetention(clean, ""); err != nil {
+			return err
+		}
+	}
+
+	return fs.forEachObject("removeall", name, nil, func(obj *s3.Object) error {
+		return fs.checkObjectRetention("/"+aws.StringValue(obj.Key), "")
+	})
+}
@@ -0,0 +1,98 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionAware(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.RetentionAware = true
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	_, err := fs.s3API.PutObjectLockConfiguration(&s3.PutObjectLockConfigurationInput{
+		Bucket: aws.String(fs.bucket),
+		ObjectLockConfiguration: &s3.ObjectLockConfiguration{
+			ObjectLockEnabled: aws.String(s3.ObjectLockEnabledEnabled),
+		},
+	})
+	req.NoError(err)
+
+	put := func(name string, input *s3.PutObjectInput) {
+		input.Bucket, input.Key, input.Body = aws.String(fs.bucket), aws.String(name), strings.NewReader(name)
+		_, err := fs.s3API.PutObject(input)
+		req.NoError(err)
+	}
+
+	put("/dir/retained", &s3.PutObjectInput{
+		ObjectLockMode:            aws.String(s3.ObjectLockModeGovernance),
+		ObjectLockRetainUntilDate: aws.Time(until),
+	})
+	put("/dir/held", &s3.PutObjectInput{ObjectLockLegalHoldStatus: aws.String(s3.ObjectLockLegalHoldStatusOn)})
+	put("/dir/expired", &s3.PutObjectInput{
+		ObjectLockMode:            aws.String(s3.ObjectLockModeCompliance),
+		ObjectLockRetainUntilDate: aws.Time(time.Now().Add(-time.Hour)),
+	})
+	testCreateFile(t, fs, "/dir/free", "free")
+
+	err = fs.Remove("/dir/retained")
+
+	var retained *RetainedError
+	req.True(errors.As(err, &retained), "%v", err)
+	req.Equal("dir/retained", retained.Key)
+	req.Equal(s3.ObjectLockModeGovernance, retained.Mode)
+	req.True(until.Equal(retained.RetainUntil))
+	req.False(retained.LegalHold)
+	req.ErrorIs(err, ErrRetained)
+	req.ErrorIs(err, os.ErrPermission)
+
+	err = fs.Remove("/dir/held")
+	req.True(errors.As(err, &retained))
+	req.True(retained.LegalHold)
+	req.True(retained.RetainUntil.IsZero())
+
+	req.NoError(fs.Remove("/dir/expired"))
+	req.NoError(fs.Remove("/dir/free"))
+
+	// Nothing is removed from a tree containing retained files
+	req.ErrorIs(fs.RemoveAll("/dir"), ErrRetained)
+	req.ErrorIs(fs.RemoveAllWithOptions(context.Background(), "/dir", nil), ErrRetained)
+
+	_, err = fs.Stat("/dir/retained")
+	req.NoError(err)
+	_, err = fs.Stat("/dir/held")
+	req.NoError(err)
+
+	// The checks are only performed in RetentionAware mode
+	fs.RetentionAware = false
+	req.NoError(fs.RemoveAll("/dir"))
+}
+
+func TestRetentionWithoutObjectLock(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.RetentionAware = true
+
+	testCreateFile(t, fs, "/dir/file", "content")
+
+	// Only the removed name is checked, to remove it if it's a file
+	heads := 0
+	fs.BeforeHead = func(input *s3.HeadObjectInput) {
+		if aws.StringValue(input.Key) != "/dir" {
+			heads++
+		}
+	}
+
+	req.NoError(fs.RemoveAll("/dir"))
+	req.Zero(heads)
+}
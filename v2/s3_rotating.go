@@ -0,0 +1,149 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DefaultSegmentSize is the maximum size of the segments of a RotatingWriter when none is specified
+const DefaultSegmentSize = 64 * 1024 * 1024
+
+// ErrNotComposed is returned when appending to an existing file that is not a composed file
+var ErrNotComposed = errors.New("file is not a composed file")
+
+// RotatingWriter appends to a composed file without ever modifying the existing objects, which S3 can't do in
+// place. The appended data is written to new segments, "name.0001", "name.0002" and so on, and the manifest of the
+// composed file is updated to include them, so that the file can be read as a continuous file with ComposedFs.
+//
+// A segment is written when the buffered data reaches the segment size, and on Sync and Close.
+type RotatingWriter struct {
+	cfs         *ComposedFs
+	name        string
+	segmentSize int64
+	manifest    composedManifest
+	size        int64        // size is the size of the file, including the buffered data
+	buffer      bytes.Buffer // buffer holds the data of the next segment
+	closed      bool
+}
+
+// OpenRotating opens a composed file for appending, it's created with its first segment if it doesn't exist.
+// The segments are at most segmentSize bytes long, DefaultSegmentSize if 0.
+func (cfs *ComposedFs) OpenRotating(name string, segmentSize int64) (*RotatingWriter, error) {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+
+	w := &RotatingWriter{cfs: cfs, name: name, segmentSize: segmentSize}
+
+	manifest, resp, err := cfs.getManifest(name)
+
+	switch {
+	case isNotFound(err):
+	case err != nil:
+		return nil, err
+	case manifest == nil && resp != nil:
+		return nil, &os.PathError{Op: "open", Path: name, Err: ErrNotComposed}
+	default:
+		w.manifest = *manifest
+	}
+
+	for _, part := range w.manifest.Parts {
+		w.size += part.Size
+	}
+
+	return w, nil
+}
+
+// Name returns the name of the file
+func (w *RotatingWriter) Name() string { return w.name }
+
+// Size returns the size of the file, including the data that wasn't written yet
+func (w *RotatingWriter) Size() int64 { return w.size }
+
+// Write appends data to the file
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	written := 0
+
+	for len(p) > 0 {
+		n := len(p)
+		if room := w.segmentSize - int64(w.buffer.Len()); int64(n) > room {
+			n = int(room)
+		}
+
+		w.buffer.Write(p[:n])
+		w.size += int64(n)
+		written += n
+		p = p[n:]
+
+		if int64(w.buffer.Len()) >= w.segmentSize {
+			if err := w.Sync(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// WriteString appends a string to the file
+func (w *RotatingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteAt writes at the end of the file, the other offsets can't be written as the segments are never modified
+func (w *RotatingWriter) WriteAt(p []byte, off int64) (int, error) {
+	if off != w.size {
+		return 0, &os.PathError{Op: "writeat", Path: w.name, Err: ErrNotSupported}
+	}
+
+	return w.Write(p)
+}
+
+// Sync writes the buffered data as a new segment and updates the manifest
+func (w *RotatingWriter) Sync() error {
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+
+	segment := fmt.Sprintf("%s.%04d", w.name, len(w.manifest.Parts)+1)
+	params := w.cfs.permParams(0666)
+
+	if err := w.cfs.putObject(segment, bytes.NewReader(w.buffer.Bytes()), int64(w.buffer.Len()), &params); err != nil {
+		return &os.PathError{Op: "sync", Path: segment, Err: err}
+	}
+
+	w.manifest.Parts = append(w.manifest.Parts, composedPart{Name: segment, Size: int64(w.buffer.Len())})
+	w.buffer.Reset()
+
+	if err := w.cfs.putManifest(w.name, &w.manifest, w.size); err != nil {
+		return err
+	}
+
+	if err := w.cfs.fileWritten(w.name); err != nil {
+		return err
+	}
+
+	if w.cfs.Replicator != nil {
+		w.cfs.Replicator.enqueue(ReplicationWrite, w.name)
+	}
+
+	return nil
+}
+
+// Close writes the buffered data
+func (w *RotatingWriter) Close() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	w.closed = true
+
+	return w.Sync()
+}
@@ -0,0 +1,176 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ScanFunc scans the content of a file being written, like an antivirus or a content validator, before it's visible.
+// It reads the content from r, the file being rejected if it returns an error.
+type ScanFunc func(name string, r io.Reader) error
+
+// ErrRejected is matched by the errors of the writes of files rejected by the Scan function of the Fs
+var ErrRejected = fmt.Errorf("file rejected by the scan: %w", os.ErrPermission)
+
+// RejectedError is returned when writing a file rejected by the Scan function of the Fs, it matches ErrRejected and
+// the error of the scan
+type RejectedError struct {
+	Name string // Name of the rejected file
+	Err  error  // Err is the error of the scan
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%s was rejected by the scan: %v", e.Name, e.Err)
+}
+
+// Is makes the error match ErrRejected
+func (e *RejectedError) Is(target error) bool {
+	return errors.Is(ErrRejected, target)
+}
+
+// Unwrap makes the error match the error of the scan
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// scanWriteCloser feeds the content written to a file to the Scan function of the Fs, and only ends its uploads once
+// the content was accepted. The uploads of a rejected file are failed, which aborts them before anything is written.
+type scanWriteCloser struct {
+	io.WriteCloser                // WriteCloser is the stream of the uploads
+	name           string         // name of the scanned file
+	scan           ScanFunc       // scan is the Scan function of the Fs
+	pipes          []io.Closer    // pipes are the pipes of the uploads, failed if the file is rejected
+	feed           *io.PipeWriter // feed is the stream of the content scanned while it's written
+	verdict        chan error     // verdict is the error of the scan of the streamed content
+	spool          *os.File       // spool is the copy of the content scanned once it's complete, see ScanSpooled
+	spoolErr       error          // spoolErr is the error of the copy of the content
+}
+
+// scanWriter returns the writer scanning the content written to a file before it's uploaded
+func (fs *Fs) scanWriter(name string, stream io.WriteCloser, pipes []io.Closer) (io.WriteCloser, error) {
+	w := &scanWriteCloser{WriteCloser: stream, name: name, scan: fs.Scan, pipes: pipes}
+
+	if fs.ScanSpooled {
+		spool, err := os.CreateTemp("", "afero-s3-scan-")
+		if err != nil {
+			return nil, &os.PathError{Op: "scan", Path: name, Err: err}
+		}
+
+		w.spool = spool
+
+		return w, nil
+	}
+
+	reader, feed := io.Pipe()
+	w.feed = feed
+	w.verdict = make(chan error, 1)
+
+	go func() {
+		err := w.scan(name, reader)
+
+		// The content the scan didn't read is discarded, it's rejected as soon as possible otherwise
+		if err != nil {
+			_ = reader.CloseWithError(&RejectedError{Name: name, Err: err})
+		} else {
+			_, _ = io.Copy(io.Discard, reader)
+		}
+
+		w.verdict <- err
+	}()
+
+	return w, nil
+}
+
+func (w *scanWriteCloser) Write(p []byte) (int, error) {
+	var err error
+
+	if w.spool != nil {
+		if _, err = w.spool.Write(p); err != nil && w.spoolErr == nil {
+			w.spoolErr = &os.PathError{Op: "scan", Path: w.name, Err: err}
+		}
+	} else {
+		_, err = w.feed.Write(p)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return w.WriteCloser.Write(p)
+}
+
+// Close ends the uploads once the content was accepted, or fails them
+func (w *scanWriteCloser) Close() error {
+	err := w.result()
+	if err == nil {
+		return w.WriteCloser.Close()
+	}
+
+	failPipes(w.pipes, err)
+	_ = w.WriteCloser.Close()
+
+	return err
+}
+
+// result returns the result of the scan of the content
+func (w *scanWriteCloser) result() error {
+	if w.spool == nil {
+		_ = w.feed.Close()
+
+		if err := <-w.verdict; err != nil {
+			return &RejectedError{Name: w.name, Err: err}
+		}
+
+		return nil
+	}
+
+	defer func() {
+		_ = w.spool.Close()
+		_ = os.Remove(w.spool.Name())
+	}()
+
+	if w.spoolErr != nil {
+		return w.spoolErr
+	}
+
+	if _, err := w.spool.Seek(0, io.SeekStart); err != nil {
+		return &os.PathError{Op: "scan", Path: w.name, Err: err}
+	}
+
+	if err := w.scan(w.name, w.spool); err != nil {
+		return &RejectedError{Name: w.name, Err: err}
+	}
+
+	return nil
+}
+
+// scanContent scans the content of a file written with a single request, which is rewound
+func (fs *Fs) scanContent(name string, rs io.ReadSeeker, size int64) error {
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return &os.PathError{Op: "scan", Path: name, Err: err}
+	}
+
+	if err = fs.Scan(name, io.LimitReader(rs, size)); err != nil {
+		return &RejectedError{Name: name, Err: err}
+	}
+
+	if _, err = rs.Seek(start, io.SeekStart); err != nil {
+		return &os.PathError{Op: "scan", Path: name, Err: err}
+	}
+
+	return nil
+}
+
+// isRejected tells if an error is the rejection of the content of a file, by the scan or the content policy
+func isRejected(err error) bool {
+	var (
+		rejected *RejectedError
+		policy   *PolicyError
+	)
+
+	return errors.As(err, &rejected) || errors.As(err, &policy)
+}
@@ -0,0 +1,122 @@
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errInfected = errors.New("infected")
+
+// eicarScan rejects the content containing the EICAR signature
+func eicarScan(_ string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Contains(data, []byte("EICAR")) {
+		return errInfected
+	}
+
+	return nil
+}
+
+func testScan(t *testing.T, spooled bool) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.Scan = eicarScan
+	fs.ScanSpooled = spooled
+
+	// The accepted files are written
+	clean := strings.Repeat("clean content\n", 100000)
+	testCreateFile(t, fs, "/clean.txt", clean)
+
+	data, err := fs.ReadFile("/clean.txt")
+	req.NoError(err)
+	req.Equal(clean, string(data))
+
+	// The rejected files aren't
+	file, err := fs.OpenFile("/infected.txt", os.O_WRONLY|os.O_CREATE, 0644)
+	req.NoError(err)
+	_, err = file.Write([]byte(clean + "EICAR" + clean))
+
+	if err == nil {
+		err = file.Close()
+	} else {
+		req.Error(file.Close())
+	}
+
+	req.ErrorIs(err, ErrRejected)
+	req.ErrorIs(err, errInfected)
+	req.ErrorIs(err, os.ErrPermission)
+
+	var rejected *RejectedError
+	req.True(errors.As(err, &rejected))
+	req.Equal("/infected.txt", rejected.Name)
+
+	_, err = fs.Stat("/infected.txt")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	// Like the single request writes
+	err = fs.WriteFile("/infected.bin", []byte("EICAR"), nil)
+	req.ErrorIs(err, ErrRejected)
+
+	_, err = fs.Stat("/infected.bin")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	req.NoError(fs.WriteFile("/clean.bin", []byte("clean"), nil))
+}
+
+func TestScan(t *testing.T) {
+	testScan(t, false)
+}
+
+func TestScanSpooled(t *testing.T) {
+	testScan(t, true)
+}
+
+func TestScanPartial(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	// The scans can only read the beginning of the files
+	fs.Scan = func(_ string, r io.Reader) error {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+
+		if string(header) == "MZ\x90\x00" {
+			return errInfected
+		}
+
+		return nil
+	}
+
+	content := strings.Repeat("document", 100000)
+	testCreateFile(t, fs, "/document.txt", content)
+
+	data, err := fs.ReadFile("/document.txt")
+	req.NoError(err)
+	req.Equal(content, string(data))
+
+	file, err := fs.OpenFile("/program.exe", os.O_WRONLY|os.O_CREATE, 0644)
+	req.NoError(err)
+
+	// The rejection fails the writes as soon as it's known
+	for err == nil {
+		_, err = file.Write([]byte("MZ\x90\x00" + content))
+	}
+
+	req.ErrorIs(err, ErrRejected)
+	req.ErrorIs(file.Close(), ErrRejected)
+
+	_, err = fs.Stat("/program.exe")
+	req.ErrorIs(err, os.ErrNotExist)
+}
@@ -0,0 +1,234 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ServeContent replies to an HTTP request with the content of an object, in the manner of http.ServeContent.
+// The Range and conditional headers of the request are passed through to S3 so that a single (ranged) GetObject
+// request is performed and its body is streamed as-is, which makes it suitable for audio/video streaming.
+func (fs *Fs) ServeContent(w http.ResponseWriter, r *http.Request, name string) {
+	fs.ServeContentWithOverrides(w, r, name, nil)
+}
+
+// ServeContentWithOverrides is like ServeContent with the headers of the response overridden by S3, like its
+// Content-Disposition or Content-Type
+func (fs *Fs) ServeContentWithOverrides(w http.ResponseWriter, r *http.Request, name string,
+	overrides *ResponseOverrides) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	}
+
+	if v := r.Header.Get("Range"); v != "" {
+		input.Range = aws.String(v)
+	}
+
+	applyConditionalHeaders(input, r.Header)
+	overrides.apply(input)
+
+	if r.Method == http.MethodHead {
+		fs.serveHead(w, input)
+		return
+	}
+
+	// If-Range can't be given to S3: a strong ETag is checked with If-Match, the complete object being asked again
+	// if it fails, unless the request has its own If-Match. The other validators are checked beforehand.
+	ifRange, ifRangeMatch := r.Header.Get("If-Range"), false
+	if input.Range != nil && ifRange != "" {
+		switch {
+		case strings.HasPrefix(ifRange, `"`) && input.IfMatch == nil:
+			input.IfMatch, ifRangeMatch = aws.String(ifRange), true
+		case !fs.ifRangeMatches(r, input, ifRange):
+			input.Range = nil
+		}
+	}
+
+	resp, err := fs.s3API.GetObjectWithContext(r.Context(), input)
+	if err != nil && ifRangeMatch && httpStatus(err) == http.StatusPreconditionFailed {
+		input.Range, input.IfMatch = nil, nil
+		resp, err = fs.s3API.GetObjectWithContext(r.Context(), input)
+	}
+
+	if err != nil {
+		if httpStatus(err) == http.StatusRequestedRangeNotSatisfiable {
+			fs.setUnsatisfiedRange(w.Header(), r, input)
+		}
+
+		writeHTTPError(w, err)
+
+		return
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	header := w.Header()
+	setContentHeaders(header, resp.ContentType, resp.ETag, resp.LastModified, resp.CacheControl,
+		resp.ContentDisposition, resp.ContentEncoding)
+	header.Set("Accept-Ranges", "bytes")
+
+	if resp.ContentLength != nil {
+		header.Set("Content-Length", strconv.FormatInt(*resp.ContentLength, 10))
+	}
+
+	status := http.StatusOK
+
+	if resp.ContentRange != nil {
+		header.Set("Content-Range", *resp.ContentRange)
+		status = http.StatusPartialContent
+	}
+
+	w.WriteHeader(status)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (fs *Fs) serveHead(w http.ResponseWriter, get *s3.GetObjectInput) {
+	resp, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket:            get.Bucket,
+		Key:               get.Key,
+		IfMatch:           get.IfMatch,
+		IfNoneMatch:       get.IfNoneMatch,
+		IfModifiedSince:   get.IfModifiedSince,
+		IfUnmodifiedSince: get.IfUnmodifiedSince,
+
+		ResponseContentType:        get.ResponseContentType,
+		ResponseContentDisposition: get.ResponseContentDisposition,
+		ResponseContentEncoding:    get.ResponseContentEncoding,
+		ResponseContentLanguage:    get.ResponseContentLanguage,
+		ResponseCacheControl:       get.ResponseCacheControl,
+		ResponseExpires:            get.ResponseExpires,
+	})
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	header := w.Header()
+	setContentHeaders(header, resp.ContentType, resp.ETag, resp.LastModified, resp.CacheControl,
+		resp.ContentDisposition, resp.ContentEncoding)
+	header.Set("Accept-Ranges", "bytes")
+
+	if resp.ContentLength != nil {
+		header.Set("Content-Length", strconv.FormatInt(*resp.ContentLength, 10))
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ifRangeMatches tells if the If-Range validator of a request, an ETag or a date, matches the object. The weak ETags
+// never match as If-Range requires a strong comparison.
+func (fs *Fs) ifRangeMatches(r *http.Request, input *s3.GetObjectInput, ifRange string) bool {
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+
+	var date time.Time
+
+	if !strings.HasPrefix(ifRange, `"`) {
+		var err error
+		if date, err = http.ParseTime(ifRange); err != nil {
+			return false
+		}
+	}
+
+	head, err := fs.s3API.HeadObjectWithContext(r.Context(), &s3.HeadObjectInput{
+		Bucket: input.Bucket,
+		Key:    input.Key,
+	})
+	if err != nil {
+		return false
+	}
+
+	if date.IsZero() {
+		return aws.StringValue(head.ETag) == ifRange
+	}
+
+	return head.LastModified != nil && head.LastModified.Truncate(time.Second).Equal(date)
+}
+
+// setUnsatisfiedRange sets the Content-Range header of a 416 response, which gives the size of the object
+func (fs *Fs) setUnsatisfiedRange(header http.Header, r *http.Request, input *s3.GetObjectInput) {
+	head, err := fs.s3API.HeadObjectWithContext(r.Context(), &s3.HeadObjectInput{
+		Bucket: input.Bucket,
+		Key:    input.Key,
+	})
+	if err == nil && head.ContentLength != nil {
+		header.Set("Content-Range", "bytes */"+strconv.FormatInt(*head.ContentLength, 10))
+	}
+}
+
+func applyConditionalHeaders(input *s3.GetObjectInput, header http.Header) {
+	if v := header.Get("If-Match"); v != "" {
+		input.IfMatch = aws.String(v)
+	}
+
+	if v := header.Get("If-None-Match"); v != "" {
+		input.IfNoneMatch = aws.String(v)
+	}
+
+	if t, err := http.ParseTime(header.Get("If-Modified-Since")); err == nil {
+		input.IfModifiedSince = aws.Time(t)
+	}
+
+	if t, err := http.ParseTime(header.Get("If-Unmodified-Since")); err == nil {
+		input.IfUnmodifiedSince = aws.Time(t)
+	}
+}
+
+func setContentHeaders(header http.Header, contentType, etag *string, lastModified *time.Time,
+	cacheControl, contentDisposition, contentEncoding *string) {
+	for name, value := range map[string]*string{
+		"Content-Type":        contentType,
+		"ETag":                etag,
+		"Cache-Control":       cacheControl,
+		"Content-Disposition": contentDisposition,
+		"Content-Encoding":    contentEncoding,
+	} {
+		if value != nil && *value != "" {
+			header.Set(name, *value)
+		}
+	}
+
+	if lastModified != nil {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// httpStatus returns the HTTP status code of an S3 error, or 0 if it's not a request failure
+func httpStatus(err error) int {
+	var failure awserr.RequestFailure
+	if errors.As(err, &failure) {
+		return failure.StatusCode()
+	}
+
+	return 0
+}
+
+func writeHTTPError(w http.ResponseWriter, err error) {
+	switch status := httpStatus(err); status {
+	case http.StatusNotModified:
+		w.WriteHeader(status)
+	case http.StatusNotFound, http.StatusForbidden, http.StatusPreconditionFailed,
+		http.StatusRequestedRangeNotSatisfiable:
+		http.Error(w, http.StatusText(status), status)
+	default:
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,97 @@
+package s3
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeContent(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/video.mp4", "0123456789")
+
+	serve := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/video.mp4", nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		fs.ServeContent(w, r, "/video.mp4")
+
+		return w
+	}
+
+	t.Run("Full", func(t *testing.T) {
+		w := serve(http.MethodGet, nil)
+		req.Equal(http.StatusOK, w.Code)
+		req.Equal("0123456789", w.Body.String())
+		req.Equal("video/mp4", w.Header().Get("Content-Type"))
+		req.Equal("bytes", w.Header().Get("Accept-Ranges"))
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		w := serve(http.MethodGet, map[string]string{"Range": "bytes=2-5"})
+		req.Equal(http.StatusPartialContent, w.Code)
+		req.Equal("2345", w.Body.String())
+		req.Equal("bytes 2-5/10", w.Header().Get("Content-Range"))
+		req.Equal("4", w.Header().Get("Content-Length"))
+	})
+
+	t.Run("IfRange", func(t *testing.T) {
+		w := serve(http.MethodGet, map[string]string{"Range": "bytes=2-5", "If-Range": `"outdated"`})
+		req.Equal(http.StatusOK, w.Code)
+		req.Equal("0123456789", w.Body.String())
+	})
+
+	t.Run("IfRangeValidators", func(t *testing.T) {
+		head := serve(http.MethodHead, nil)
+		etag, lastModified := head.Header().Get("ETag"), head.Header().Get("Last-Modified")
+
+		for ifRange, status := range map[string]int{
+			etag:                            http.StatusPartialContent,
+			lastModified:                    http.StatusPartialContent,
+			"W/" + etag:                     http.StatusOK,
+			"Mon, 02 Jan 2006 15:04:05 GMT": http.StatusOK,
+		} {
+			w := serve(http.MethodGet, map[string]string{"Range": "bytes=2-5", "If-Range": ifRange})
+			req.Equal(status, w.Code, ifRange)
+		}
+	})
+
+	t.Run("IfRangeWithIfMatch", func(t *testing.T) {
+		etag := serve(http.MethodHead, nil).Header().Get("ETag")
+
+		// The If-Match of the request is kept, and not retried without when it fails
+		w := serve(http.MethodGet, map[string]string{"Range": "bytes=2-5", "If-Range": etag, "If-Match": `"other"`})
+		req.Equal(http.StatusPreconditionFailed, w.Code)
+
+		w = serve(http.MethodGet, map[string]string{"Range": "bytes=2-5", "If-Range": `"outdated"`, "If-Match": etag})
+		req.Equal(http.StatusOK, w.Code)
+		req.Equal("0123456789", w.Body.String())
+	})
+
+	t.Run("RangeNotSatisfiable", func(t *testing.T) {
+		w := serve(http.MethodGet, map[string]string{"Range": "bytes=20-30"})
+		req.Equal(http.StatusRequestedRangeNotSatisfiable, w.Code)
+		req.Equal("bytes */10", w.Header().Get("Content-Range"))
+	})
+
+	t.Run("NotModified", func(t *testing.T) {
+		etag := serve(http.MethodHead, nil).Header().Get("ETag")
+		req.NotEmpty(etag)
+
+		w := serve(http.MethodGet, map[string]string{"If-None-Match": etag})
+		req.Equal(http.StatusNotModified, w.Code)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		fs.ServeContent(w, httptest.NewRequest(http.MethodGet, "/missing", nil), "/missing")
+		req.Equal(http.StatusNotFound, w.Code)
+	})
+}
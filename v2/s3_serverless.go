@@ -0,0 +1,215 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// Defaults of the serverless mode, see WithServerlessMode
+const (
+	// DefaultServerlessCopyPartSize is the size of the parts of the multipart copies
+	DefaultServerlessCopyPartSize = 64 * 1024 * 1024
+	// DefaultServerlessConnectTimeout is the timeout of the connections and of the TLS handshakes
+	DefaultServerlessConnectTimeout = 2 * time.Second
+	// DefaultServerlessResponseTimeout is the time to wait for the response headers once a request was sent
+	DefaultServerlessResponseTimeout = 10 * time.Second
+	// DefaultServerlessMaxRetries is the maximum number of retries of the requests
+	DefaultServerlessMaxRetries = 2
+)
+
+// serverlessRetriesHandler is the name of the handler limiting the retries of the requests
+const serverlessRetriesHandler = "afero-s3.ServerlessRetries"
+
+// serverlessMaxRetryDelay is the maximum delay between two retries, the default one being 5 minutes
+const serverlessMaxRetryDelay = time.Second
+
+// flushPollInterval is the interval at which FlushAll checks the pending replications
+const flushPollInterval = 10 * time.Millisecond
+
+// ServerlessOptions tunes the serverless mode, the zero values use the defaults
+type ServerlessOptions struct {
+	// PartSize is the size of the parts of the uploads, which is the memory used by each written file,
+	// s3manager.MinUploadPartSize if 0
+	PartSize int64
+	// CopyPartSize is the size of the parts of the multipart copies, DefaultServerlessCopyPartSize if 0. Smaller
+	// parts complete within the timeouts.
+	CopyPartSize int64
+	// ConnectTimeout is the timeout of the connections and TLS handshakes, DefaultServerlessConnectTimeout if 0
+	ConnectTimeout time.Duration
+	// ResponseTimeout is the time to wait for the response headers of a request, DefaultServerlessResponseTimeout
+	// if 0
+	ResponseTimeout time.Duration
+	// MaxRetries is the maximum number of retries of the requests, DefaultServerlessMaxRetries if 0
+	MaxRetries int
+}
+
+// serverlessMode is the state of the serverless mode of an Fs
+type serverlessMode struct {
+	partSize     int64
+	copyPartSize int64
+	maxRetries   int
+}
+
+// WithServerlessMode tunes the Fs for short-lived runtimes like AWS Lambda handlers, whose execution is frozen
+// between invocations: small parts, aggressive timeouts and few retries, so that an invocation fails fast instead of
+// reaching its deadline, and no goroutine of the Fs outliving the requests, the connections being prewarmed
+// synchronously. FlushAll should be called before the handler returns. The janitors and watches shouldn't be used in
+// this mode. It should be called before the Fs is used, opts can be nil.
+func (fs *Fs) WithServerlessMode(opts *ServerlessOptions) *Fs {
+	if opts == nil {
+		opts = &ServerlessOptions{}
+	}
+
+	mode := &serverlessMode{
+		partSize:     opts.PartSize,
+		copyPartSize: opts.CopyPartSize,
+		maxRetries:   opts.MaxRetries,
+	}
+
+	if mode.partSize <= 0 {
+		mode.partSize = s3manager.MinUploadPartSize
+	}
+
+	if mode.copyPartSize <= 0 {
+		mode.copyPartSize = DefaultServerlessCopyPartSize
+	}
+
+	if mode.maxRetries <= 0 {
+		mode.maxRetries = DefaultServerlessMaxRetries
+	}
+
+	connectTimeout, responseTimeout := opts.ConnectTimeout, opts.ResponseTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = DefaultServerlessConnectTimeout
+	}
+
+	if responseTimeout <= 0 {
+		responseTimeout = DefaultServerlessResponseTimeout
+	}
+
+	fs.withTransport(func(transport *http.Transport) {
+		transport.DialContext = (&net.Dialer{Timeout: connectTimeout}).DialContext
+		transport.TLSHandshakeTimeout = connectTimeout
+		transport.ResponseHeaderTimeout = responseTimeout
+	})
+
+	fs.serverless = mode
+	fs.serverless.install(fs, &fs.s3API.Handlers)
+
+	return fs
+}
+
+// install installs the handler limiting the retries of the requests
+func (mode *serverlessMode) install(fs *Fs, handlers *request.Handlers) {
+	handlers.Validate.Remove(request.NamedHandler{Name: serverlessRetriesHandler})
+
+	if mode == nil {
+		return
+	}
+
+	handlers.Validate.PushFrontNamed(request.NamedHandler{
+		Name: serverlessRetriesHandler,
+		Fn: func(r *request.Request) {
+			// The retries of the parts are defined by UploadPartRetries
+			if r.Operation.Name == "UploadPart" && fs.UploadPartRetries > 0 {
+				return
+			}
+
+			r.Retryer = client.DefaultRetryer{
+				NumMaxRetries:    mode.maxRetries,
+				MinRetryDelay:    client.DefaultRetryerMinRetryDelay,
+				MaxRetryDelay:    serverlessMaxRetryDelay,
+				MinThrottleDelay: client.DefaultRetryerMinThrottleDelay,
+				MaxThrottleDelay: serverlessMaxRetryDelay,
+			}
+		},
+	})
+}
+
+// serverlessOption applies the serverless mode of the Fs to the requests of other clients
+func (fs *Fs) serverlessOption() request.Option {
+	return func(r *request.Request) {
+		fs.serverless.install(fs, &r.Handlers)
+	}
+}
+
+// FlushAll closes all the files being written, waits for the end of their uploads, including the ones closed in
+// write-behind mode, and waits for the pending replications if a Replicator is set, until ctx is done. The closed
+// files must not be used concurrently. It returns the first error, and is meant to be called before a serverless
+// handler returns.
+func (fs *Fs) FlushAll(ctx context.Context) error {
+	var firstErr error
+
+	for _, file := range fs.writing.list() {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = &os.PathError{Op: "flush", Path: file.name, Err: err}
+		}
+	}
+
+	if err := fs.Wait(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+
+	if fs.Replicator == nil || firstErr != nil {
+		return firstErr
+	}
+
+	ticker := time.NewTicker(flushPollInterval)
+	defer ticker.Stop()
+
+	for fs.Replicator.Pending() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// fileSet is a set of files, safe for concurrent use
+type fileSet struct {
+	mu      sync.Mutex
+	files   map[*File]struct{}
+	reaping bool // reaping is set while the idle uploads are reaped, see UploadIdleTimeout
+}
+
+func newFileSet() *fileSet {
+	return &fileSet{files: make(map[*File]struct{})}
+}
+
+func (s *fileSet) add(file *File) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[file] = struct{}{}
+}
+
+func (s *fileSet) remove(file *File) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.files, file)
+}
+
+func (s *fileSet) list() []*File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := make([]*File, 0, len(s.files))
+	for file := range s.files {
+		files = append(files, file)
+	}
+
+	return files
+}
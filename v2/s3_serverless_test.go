@@ -0,0 +1,53 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerlessMode(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	fs.WithServerlessMode(nil)
+
+	transport, ok := fs.s3API.Config.HTTPClient.Transport.(*http.Transport)
+	req.True(ok)
+	req.Equal(DefaultServerlessResponseTimeout, transport.ResponseHeaderTimeout)
+	req.Equal(DefaultServerlessConnectTimeout, transport.TLSHandshakeTimeout)
+
+	t.Run("FlushAll", func(t *testing.T) {
+		file, err := fs.Create("/file")
+		req.NoError(err)
+
+		_, err = file.WriteString("content")
+		req.NoError(err)
+
+		req.NoError(fs.FlushAll(context.Background()))
+
+		content, err := afero.ReadFile(fs, "/file")
+		req.NoError(err)
+		req.Equal("content", string(content))
+
+		// The file was already closed
+		req.NoError(file.Close())
+		req.Empty(fs.writing.list())
+	})
+
+	t.Run("Retries", func(t *testing.T) {
+		faults := NewFaultInjector(0)
+		fs.WithFaultInjector(faults)
+
+		defer fs.WithFaultInjector(nil)
+
+		faults.Set("HeadObject", &Fault{StatusCode: http.StatusInternalServerError, Code: "InternalError"})
+
+		_, err := fs.Stat("/file")
+		req.Error(err)
+		req.Equal(int64(DefaultServerlessMaxRetries+1), faults.Injected())
+	})
+}
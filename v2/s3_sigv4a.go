@@ -0,0 +1,222 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// sigV4AAlgorithm is the name of the SigV4A signing algorithm
+const sigV4AAlgorithm = "AWS4-ECDSA-P256-SHA256"
+
+// sigV4AHandler is the name of the handler signing the requests with SigV4A
+const sigV4AHandler = "afero-s3.SignV4A"
+
+// sigV4AUnsignedHeaders are the headers that are never signed, like with SigV4
+var sigV4AUnsignedHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+}
+
+// SigV4AHandler returns a handler signing the requests with SigV4A, the asymmetric variant of SigV4 whose signatures
+// are valid in a set of regions, "*" for all of them. It replaces the SigV4 handler of the clients of services
+// requiring it, like S3 Multi-Region Access Points, which are handled by the Fs itself:
+//
+//	client.Handlers.Sign.Swap(v4.SignRequestHandler.Name, s3.SigV4AHandler("s3", "*"))
+func SigV4AHandler(service string, regionSet ...string) request.NamedHandler {
+	return request.NamedHandler{Name: sigV4AHandler, Fn: func(r *request.Request) {
+		if r.Config.Credentials == credentials.AnonymousCredentials {
+			return
+		}
+
+		creds, err := r.Config.Credentials.GetWithContext(r.Context())
+		if err != nil {
+			r.Error = err
+			return
+		}
+
+		r.LastSignedAt = time.Now().UTC()
+		if err = signV4A(r, creds, service, strings.Join(regionSet, ","), r.LastSignedAt); err != nil {
+			r.Error = err
+		}
+	}}
+}
+
+// signV4A signs a request with SigV4A
+func signV4A(r *request.Request, creds credentials.Value, service, regionSet string, now time.Time) error {
+	key, err := deriveV4AKey(creds.AccessKeyID, creds.SecretAccessKey)
+	if err != nil {
+		return err
+	}
+
+	header := r.HTTPRequest.Header
+
+	// The hash can already be set, to UNSIGNED-PAYLOAD for instance
+	bodyHash := header.Get("X-Amz-Content-Sha256")
+	if bodyHash == "" {
+		if bodyHash, err = v4ABodyHash(r.Body); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range []string{"Authorization", "X-Amz-Date", "X-Amz-Security-Token", "X-Amz-Region-Set"} {
+		header.Del(name)
+	}
+
+	header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	header.Set("X-Amz-Region-Set", regionSet)
+	header.Set("X-Amz-Content-Sha256", bodyHash)
+
+	if creds.SessionToken != "" {
+		header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	canonical, signedHeaders := v4ACanonicalRequest(r.HTTPRequest, bodyHash)
+	scope := now.Format("20060102") + "/" + service + "/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := sigV4AAlgorithm + "\n" + now.Format("20060102T150405Z") + "\n" + scope + "\n" +
+		hex.EncodeToString(canonicalHash[:])
+
+	digest := sha256.Sum256([]byte(stringToSign))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return err
+	}
+
+	header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4AAlgorithm, creds.AccessKeyID, scope, signedHeaders, hex.EncodeToString(signature)))
+
+	return nil
+}
+
+// v4ABodyHash returns the hex SHA-256 of the body of a request, which is read from its current position
+func v4ABodyHash(body io.ReadSeeker) (string, error) {
+	hash := sha256.New()
+	if body == nil {
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = io.Copy(hash, body); err != nil {
+		return "", err
+	}
+
+	if _, err = body.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// v4ACanonicalRequest returns the canonical request of SigV4, the paths being used as they are like S3 requires,
+// and its signed headers
+func v4ACanonicalRequest(req *http.Request, bodyHash string) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values := map[string][]string{"host": {host}}
+
+	for name, headerValues := range req.Header {
+		name = strings.ToLower(name)
+		if !sigV4AUnsignedHeaders[name] {
+			values[name] = append(values[name], headerValues...)
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	headers := make([]string, len(names))
+	for i, name := range names {
+		trimmed := make([]string, len(values[name]))
+		for j, value := range values[name] {
+			trimmed[j] = strings.Join(strings.Fields(value), " ")
+		}
+
+		headers[i] = name + ":" + strings.Join(trimmed, ",")
+	}
+
+	// The query parameters are sorted, and the spaces encoded as %20
+	req.URL.RawQuery = strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20")
+
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	return strings.Join([]string{
+		req.Method,
+		uri,
+		req.URL.RawQuery,
+		strings.Join(headers, "\n") + "\n",
+		signedHeaders,
+		bodyHash,
+	}, "\n"), signedHeaders
+}
+
+// deriveV4AKey derives the ECDSA P-256 key of SigV4A from an access key: its private key is the first output of the
+// NIST SP 800-108 HMAC-SHA256 counter mode KDF, keyed by the secret and the access key id with an external counter,
+// that is smaller than n-2, plus one.
+func deriveV4AKey(accessKeyID, secretAccessKey string) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	nMinusTwo := new(big.Int).Sub(curve.Params().N, big.NewInt(2))
+	inputKey := []byte("AWS4A" + secretAccessKey)
+
+	for counter := 1; counter <= 0xff; counter++ {
+		var input bytes.Buffer
+
+		_ = binary.Write(&input, binary.BigEndian, uint32(1))
+		input.WriteString(sigV4AAlgorithm)
+		input.WriteByte(0)
+		input.WriteString(accessKeyID)
+		input.WriteByte(byte(counter))
+		_ = binary.Write(&input, binary.BigEndian, uint32(curve.Params().BitSize))
+
+		mac := hmac.New(sha256.New, inputKey)
+		mac.Write(input.Bytes()) // nolint: errcheck
+
+		candidate := new(big.Int).SetBytes(mac.Sum(nil))
+		if candidate.Cmp(nMinusTwo) >= 0 {
+			continue
+		}
+
+		key := &ecdsa.PrivateKey{D: candidate.Add(candidate, big.NewInt(1))}
+		key.PublicKey.Curve = curve
+		key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(key.D.Bytes())
+
+		return key, nil
+	}
+
+	return nil, errors.New("no SigV4A key can be derived from the credentials")
+}
@@ -0,0 +1,193 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidName is returned when an encrypted name can't be decrypted
+var ErrInvalidName = errors.New("invalid encrypted name")
+
+// NameCipher encrypts the segments of the names of the files, see NewEncryptedNamesFs. The encryption must be
+// deterministic, so that a name always has the same encrypted form, and the encrypted segments must be valid in S3
+// keys without containing any slash. The implementations must be safe for concurrent use.
+type NameCipher interface {
+	// EncryptName encrypts a segment of a name
+	EncryptName(segment string) (string, error)
+	// DecryptName decrypts a segment encrypted by EncryptName, it fails with ErrInvalidName if it can't
+	DecryptName(encrypted string) (string, error)
+}
+
+// sivNameCipher encrypts the names with AES-SIV (RFC 5297), the segments being encoded in URL-safe base64
+type sivNameCipher struct {
+	mac cipher.Block // mac is the block cipher of the S2V authentication
+	ctr cipher.Block // ctr is the block cipher of the CTR encryption
+}
+
+// NewSIVNameCipher creates a NameCipher using the deterministic authenticated encryption AES-SIV, with a 32, 48 or 64
+// bytes key for AES-128, AES-192 or AES-256. The encrypted segments are a third longer than the clear ones, plus 22
+// characters, and the encrypted names must fit the 1024 bytes limit of the S3 keys.
+func NewSIVNameCipher(key []byte) (NameCipher, error) {
+	if len(key) != 32 && len(key) != 48 && len(key) != 64 {
+		return nil, fmt.Errorf("invalid AES-SIV key size %d", len(key))
+	}
+
+	mac, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+
+	ctr, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &sivNameCipher{mac: mac, ctr: ctr}, nil
+}
+
+func (c *sivNameCipher) EncryptName(segment string) (string, error) {
+	return base64.RawURLEncoding.EncodeToString(c.seal([]byte(segment))), nil
+}
+
+func (c *sivNameCipher) DecryptName(encrypted string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", ErrInvalidName
+	}
+
+	plaintext, ok := c.open(sealed)
+	if !ok {
+		return "", ErrInvalidName
+	}
+
+	return string(plaintext), nil
+}
+
+// seal returns the synthetic IV followed by the encrypted plaintext
+func (c *sivNameCipher) seal(plaintext []byte, ad ...[]byte) []byte {
+	iv := c.s2v(append(ad, plaintext))
+	sealed := make([]byte, aes.BlockSize+len(plaintext))
+	copy(sealed, iv)
+	c.xorCTR(sealed[aes.BlockSize:], plaintext, iv)
+
+	return sealed
+}
+
+// open decrypts and authenticates a sealed plaintext
+func (c *sivNameCipher) open(sealed []byte, ad ...[]byte) ([]byte, bool) {
+	if len(sealed) < aes.BlockSize {
+		return nil, false
+	}
+
+	iv := sealed[:aes.BlockSize]
+	plaintext := make([]byte, len(sealed)-aes.BlockSize)
+	c.xorCTR(plaintext, sealed[aes.BlockSize:], iv)
+
+	if subtle.ConstantTimeCompare(iv, c.s2v(append(ad, plaintext))) != 1 {
+		return nil, false
+	}
+
+	return plaintext, true
+}
+
+// xorCTR applies the CTR keystream starting at the synthetic IV, whose 31st and 63rd bits are cleared
+func (c *sivNameCipher) xorCTR(dst, src, iv []byte) {
+	counter := make([]byte, aes.BlockSize)
+	copy(counter, iv)
+	counter[8] &= 0x7f
+	counter[12] &= 0x7f
+
+	cipher.NewCTR(c.ctr, counter).XORKeyStream(dst, src)
+}
+
+// s2v computes the synthetic IV of the strings, the last one being the plaintext
+func (c *sivNameCipher) s2v(strings [][]byte) []byte {
+	d := c.cmac(make([]byte, aes.BlockSize))
+
+	for _, s := range strings[:len(strings)-1] {
+		d = xorBlock(dbl(d), c.cmac(s))
+	}
+
+	last := strings[len(strings)-1]
+	if len(last) >= aes.BlockSize {
+		t := append([]byte{}, last...)
+		end := t[len(t)-aes.BlockSize:]
+		copy(end, xorBlock(end, d))
+
+		return c.cmac(t)
+	}
+
+	padded := make([]byte, aes.BlockSize)
+	copy(padded, last)
+	padded[len(last)] = 0x80
+
+	return c.cmac(xorBlock(dbl(d), padded))
+}
+
+// cmac computes the AES-CMAC (RFC 4493) of a message
+func (c *sivNameCipher) cmac(message []byte) []byte {
+	k1 := make([]byte, aes.BlockSize)
+	c.mac.Encrypt(k1, k1)
+	k1 = dbl(k1)
+	k2 := dbl(k1)
+
+	blocks := (len(message) + aes.BlockSize - 1) / aes.BlockSize
+	if blocks == 0 {
+		blocks = 1
+	}
+
+	// The last block is padded if it's incomplete
+	last := make([]byte, aes.BlockSize)
+	tail := message[(blocks-1)*aes.BlockSize:]
+	copy(last, tail)
+
+	if len(tail) == aes.BlockSize {
+		last = xorBlock(last, k1)
+	} else {
+		last[len(tail)] = 0x80
+		last = xorBlock(last, k2)
+	}
+
+	mac := make([]byte, aes.BlockSize)
+	for i := 0; i < blocks-1; i++ {
+		mac = xorBlock(mac, message[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		c.mac.Encrypt(mac, mac)
+	}
+
+	mac = xorBlock(mac, last)
+	c.mac.Encrypt(mac, mac)
+
+	return mac
+}
+
+// dbl multiplies a block by x in GF(2^128)
+func dbl(block []byte) []byte {
+	doubled := make([]byte, aes.BlockSize)
+
+	for i := 0; i < aes.BlockSize-1; i++ {
+		doubled[i] = block[i]<<1 | block[i+1]>>7
+	}
+
+	doubled[aes.BlockSize-1] = block[aes.BlockSize-1] << 1
+
+	if block[0]&0x80 != 0 {
+		doubled[aes.BlockSize-1] ^= 0x87
+	}
+
+	return doubled
+}
+
+// xorBlock returns the XOR of two blocks
+func xorBlock(a, b []byte) []byte {
+	result := make([]byte, aes.BlockSize)
+	for i := range result {
+		result[i] = a[i] ^ b[i]
+	}
+
+	return result
+}
@@ -0,0 +1,49 @@
+package s3
+
+import (
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatDirectoryFallback(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/dir/file", "content")
+	testCreateFile(t, fs, "/dirfile", "content")
+	req.NoError(fs.Mkdir("/empty", 0750))
+
+	var requests int64
+
+	fs.s3API.Handlers.Send.PushBack(func(*request.Request) { atomic.AddInt64(&requests, 1) })
+
+	// A prefix of a file isn't a directory
+	_, err := fs.Stat("/dirf")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	for _, name := range []string{"/dir", "/dir/", "/empty", "/empty/", "/"} {
+		info, errStat := fs.Stat(name)
+		req.NoError(errStat, name)
+		req.True(info.IsDir(), name)
+	}
+
+	// A trailing slash means a directory
+	_, err = fs.Stat("/dirfile/")
+	req.ErrorIs(err, syscall.ENOTDIR)
+
+	fs.SkipDirFallback = true
+
+	atomic.StoreInt64(&requests, 0)
+	_, err = fs.Stat("/missing")
+	req.ErrorIs(err, os.ErrNotExist)
+	req.Equal(int64(1), atomic.LoadInt64(&requests))
+
+	info, err := fs.Stat("/dir/")
+	req.NoError(err)
+	req.True(info.IsDir())
+}
@@ -0,0 +1,93 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrPatternHasSeparator is returned when a temporary file pattern contains a path separator
+var ErrPatternHasSeparator = errors.New("pattern contains path separator")
+
+// newUUID generates a random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// TempFile creates a new file in the directory dir and opens it for writing, like os.CreateTemp.
+// The file name is generated by taking pattern and adding a UUID to the end. If pattern includes a "*",
+// the UUID replaces the last "*". If dir is the empty string, the file is created at the root of the bucket.
+// When TempFileAutoDelete is set, the file is removed on Close if nothing was written to it.
+func (fs *Fs) TempFile(dir, pattern string) (*File, error) {
+	file, err := fs.tempFile(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return file, file.openWriteStream()
+}
+
+// tempFile creates the File of a new temporary file, without opening it
+func (fs *Fs) tempFile(dir, pattern string) (*File, error) {
+	name, err := tempName("createtemp", dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	file := NewFile(fs, name)
+	file.removeIfUnwritten = fs.TempFileAutoDelete
+
+	return file, nil
+}
+
+// TempDir creates a new directory in the directory dir and returns its name, like os.MkdirTemp. The directory name
+// is generated from pattern like the ones of TempFile.
+func (fs *Fs) TempDir(dir, pattern string) (string, error) {
+	name, err := tempName("mkdirtemp", dir, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	if err := fs.Mkdir(name, 0700); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// tempName generates the name of a temporary file or directory, with a UUID replacing the last "*" of the pattern
+// or added to its end
+func tempName(op, dir, pattern string) (string, error) {
+	if strings.Contains(pattern, "/") {
+		return "", &os.PathError{Op: op, Path: pattern, Err: ErrPatternHasSeparator}
+	}
+
+	prefix, suffix := pattern, ""
+	if pos := strings.LastIndex(pattern, "*"); pos != -1 {
+		prefix, suffix = pattern[:pos], pattern[pos+1:]
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return "", &os.PathError{Op: op, Path: pattern, Err: err}
+	}
+
+	return path.Join("/", dir, prefix+id+suffix), nil
+}
+
+// removeUnwritten removes a file from the Fs and its mirror
+func (f *File) removeUnwritten() error {
+	return f.fs.forceRemove(f.name)
+}
@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTempFile(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	file, err := fs.TempFile("/staging", "upload-*.csv")
+	req.NoError(err)
+	req.True(strings.HasPrefix(file.Name(), "/staging/upload-"))
+	req.True(strings.HasSuffix(file.Name(), ".csv"))
+
+	_, err = file.WriteString("a,b,c")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	info, err := fs.Stat(file.Name())
+	req.NoError(err)
+	req.Equal(int64(5), info.Size())
+
+	other, err := fs.TempFile("/staging", "upload-*.csv")
+	req.NoError(err)
+	req.NotEqual(file.Name(), other.Name())
+	req.NoError(other.Close())
+
+	_, err = fs.TempFile("/staging", "bad/pattern")
+	req.ErrorIs(err, ErrPatternHasSeparator)
+}
+
+func TestTempFileAutoDelete(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.TempFileAutoDelete = true
+
+	file, err := fs.TempFile("", "unused")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	_, err = fs.Stat(file.Name())
+	req.Error(err)
+}
+
+func TestTempDir(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	name, err := fs.TempDir("/staging", "job-*.d")
+	req.NoError(err)
+	req.True(strings.HasPrefix(name, "/staging/job-"))
+	req.True(strings.HasSuffix(name, ".d"))
+
+	info, err := fs.Stat(name)
+	req.NoError(err)
+	req.True(info.IsDir())
+
+	other, err := fs.TempDir("/staging", "job-*.d")
+	req.NoError(err)
+	req.NotEqual(name, other)
+
+	_, err = fs.TempDir("/staging", "bad/pattern")
+	req.ErrorIs(err, ErrPatternHasSeparator)
+}
@@ -0,0 +1,212 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// ReadTransform transforms the content of the files read, between S3 and the reader, like a decompression,
+// a decryption or a migration of the schema of the documents. It applies to the files matching one of its
+// extensions or having its metadata flag.
+type ReadTransform struct {
+	// Extensions are the extensions of the names of the transformed files, like ".gz"
+	Extensions []string
+	// MetadataKey is the metadata flag of the transformed files, like "Content-Codec", matched case-insensitively
+	MetadataKey string
+	// MetadataValue is the value of the metadata flag of the transformed files, any value if empty
+	MetadataValue string
+	// Transform returns the transformed content of a file from its stored content, closing it when it's closed
+	Transform func(name string, body io.ReadCloser) (io.ReadCloser, error)
+}
+
+// matches tells if a transform applies to a file
+func (t *ReadTransform) matches(name string, metadata map[string]*string) bool {
+	ext := path.Ext(name)
+
+	for _, extension := range t.Extensions {
+		if strings.EqualFold(ext, extension) {
+			return true
+		}
+	}
+
+	if t.MetadataKey == "" {
+		return false
+	}
+
+	for key, value := range metadata {
+		if strings.EqualFold(key, t.MetadataKey) {
+			return t.MetadataValue == "" || aws.StringValue(value) == t.MetadataValue
+		}
+	}
+
+	return false
+}
+
+// readTransforms returns the read transforms applying to a file, in order
+func (fs *Fs) readTransforms(name string, metadata map[string]*string) []*ReadTransform {
+	var transforms []*ReadTransform
+
+	for _, t := range fs.ReadTransforms {
+		if t.matches(name, metadata) {
+			transforms = append(transforms, t)
+		}
+	}
+
+	return transforms
+}
+
+// transformRead applies a chain of read transforms to the content of a file, the body is closed on failure
+func transformRead(name string, body io.ReadCloser, transforms []*ReadTransform) (io.ReadCloser, error) {
+	for _, t := range transforms {
+		transformed, err := t.Transform(name, body)
+		if err != nil {
+			_ = body.Close()
+
+			return nil, &os.PathError{Op: "transform", Path: name, Err: err}
+		}
+
+		body = transformed
+	}
+
+	return body, nil
+}
+
+// openTransformedStream opens the read stream of a transformed file at an offset of its transformed content. It can't
+// be requested by range: the file is read from the beginning and the content before the offset is skipped.
+func (f *File) openTransformedStream(startAt int64) error {
+	resp, err := f.fs.s3API.GetObjectWithContext(f.fs.requestContext(), f.getInput(nil), countRetries(&f.counters.retries))
+	if err != nil {
+		return err
+	}
+
+	stream, err := transformRead(f.name, f.fs.withPrefetch(resp.Body), f.transforms)
+	if err != nil {
+		return err
+	}
+
+	f.streamReadOffset = 0
+	f.streamRead = f.fs.withReadAhead(stream)
+
+	return f.skipTransformed(startAt)
+}
+
+// skipTransformed moves the read stream of a transformed file forward by skipping its content, the offsets beyond
+// the end of the content reading nothing
+func (f *File) skipTransformed(offset int64) error {
+	skipped, err := io.CopyN(io.Discard, f.streamRead, offset-f.streamReadOffset)
+	f.streamReadOffset += skipped
+
+	if err == io.EOF {
+		f.streamReadOffset = offset
+
+		return nil
+	}
+
+	return err
+}
+
+// WriteTransform transforms the content of the files written, between the writer and S3, like a compression,
+// an encryption or a redaction. It applies to the files matching one of its extensions, to all of them if it has
+// none. Its metadata is recorded with the transformed files, for the ReadTransform reversing it to find them.
+type WriteTransform struct {
+	// Extensions are the extensions of the names of the transformed files, like ".gz"
+	Extensions []string
+	// Metadata is added to the metadata of the transformed files, like "Content-Codec": "gzip"
+	Metadata map[string]string
+	// Transform returns a writer transforming the content of a file to the stored content written to w. Closing it
+	// must flush it and close w.
+	Transform func(name string, w io.WriteCloser) (io.WriteCloser, error)
+}
+
+// matches tells if a transform applies to a file
+func (t *WriteTransform) matches(name string) bool {
+	if len(t.Extensions) == 0 {
+		return true
+	}
+
+	ext := path.Ext(name)
+
+	for _, extension := range t.Extensions {
+		if strings.EqualFold(ext, extension) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeTransforms returns the write transforms applying to a file, in order
+func (fs *Fs) writeTransforms(name string) []*WriteTransform {
+	var transforms []*WriteTransform
+
+	for _, t := range fs.WriteTransforms {
+		if t.matches(name) {
+			transforms = append(transforms, t)
+		}
+	}
+
+	return transforms
+}
+
+// transformWrite returns the writer applying a chain of write transforms to the content written to w, the first
+// transform being applied first
+func transformWrite(name string, w io.WriteCloser, transforms []*WriteTransform) (io.WriteCloser, error) {
+	for i := len(transforms) - 1; i >= 0; i-- {
+		transformed, err := transforms[i].Transform(name, w)
+		if err != nil {
+			return nil, &os.PathError{Op: "transform", Path: name, Err: err}
+		}
+
+		w = transformed
+	}
+
+	return w, nil
+}
+
+// transformWriteMetadata returns the metadata of a file with the metadata of its write transforms
+func transformWriteMetadata(metadata map[string]*string, transforms []*WriteTransform) map[string]*string {
+	if len(transforms) == 0 {
+		return metadata
+	}
+
+	transformed := make(map[string]*string, len(metadata))
+	for key, value := range metadata {
+		transformed[key] = value
+	}
+
+	for _, t := range transforms {
+		for key, value := range t.Metadata {
+			transformed[key] = aws.String(value)
+		}
+	}
+
+	return transformed
+}
+
+// transformContent applies a chain of write transforms to the content of a file in memory
+func transformContent(name string, content io.Reader, transforms []*WriteTransform) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	w, err := transformWrite(name, &multiWriteCloser{Writer: &buffer}, transforms)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = io.Copy(w, content); err != nil {
+		_ = w.Close()
+
+		return nil, &os.PathError{Op: "transform", Path: name, Err: err}
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, &os.PathError{Op: "transform", Path: name, Err: err}
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,212 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/require"
+)
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (r *gzipReadCloser) Close() error {
+	return r.body.Close()
+}
+
+func gunzipTransform(_ string, body io.ReadCloser) (io.ReadCloser, error) {
+	reader, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gzipReadCloser{Reader: reader, body: body}, nil
+}
+
+func upperTransform(_ string, body io.ReadCloser) (io.ReadCloser, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(bytes.ToUpper(data))), body.Close()
+}
+
+func gzipped(t *testing.T, content string) string {
+	var buffer bytes.Buffer
+
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return buffer.String()
+}
+
+func TestReadTransforms(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	content := strings.Repeat("0123456789abcdef", 1000)
+	testCreateFile(t, fs, "/file.gz", gzipped(t, content))
+	testCreateFile(t, fs, "/file.txt", "plain")
+
+	fs.BeforeUpload = func(name string, input *s3manager.UploadInput) {
+		if strings.HasSuffix(name, ".json") {
+			input.Metadata = map[string]*string{"Codec": aws.String("upper")}
+		}
+	}
+	testCreateFile(t, fs, "/doc.json", `{"name": "gzipped"}`)
+
+	fs.ReadTransforms = []*ReadTransform{
+		{Extensions: []string{".gz"}, Transform: gunzipTransform},
+		{MetadataKey: "codec", MetadataValue: "upper", Transform: upperTransform},
+	}
+
+	// The files are transformed by extension or by metadata flag
+	data, err := fs.ReadFile("/file.gz")
+	req.NoError(err)
+	req.Equal(content, string(data))
+
+	data, err = fs.ReadFile("/file.txt")
+	req.NoError(err)
+	req.Equal("plain", string(data))
+
+	var doc map[string]string
+	_, err = fs.ReadJSON("/doc.json", &doc)
+	req.NoError(err)
+	req.Equal("GZIPPED", doc["NAME"])
+
+	// The streams are transformed, and seeked within the transformed content
+	file, err := fs.Open("/file.gz")
+	req.NoError(err)
+
+	defer func() { req.NoError(file.Close()) }()
+
+	buffer := make([]byte, 16)
+	_, err = file.ReadAt(buffer, 8000)
+	req.NoError(err)
+	req.Equal(content[8000:8016], string(buffer))
+
+	_, err = file.ReadAt(buffer, 16)
+	req.NoError(err)
+	req.Equal(content[16:32], string(buffer))
+	req.Equal(int64(1), file.(*File).Stats().Reopens)
+
+	_, err = file.Seek(-10, io.SeekEnd)
+	req.ErrorIs(err, ErrNotSupported)
+
+	_, err = file.Seek(100000, io.SeekStart)
+	req.NoError(err)
+
+	_, err = file.Read(buffer)
+	req.ErrorIs(err, io.EOF)
+
+	// The transform failures are reported
+	fs.ReadTransforms = []*ReadTransform{{Extensions: []string{".txt"}, Transform: gunzipTransform}}
+
+	_, err = fs.Open("/file.txt")
+	req.Error(err)
+
+	_, err = fs.ReadFile("/file.txt")
+
+	var pathErr *os.PathError
+	req.True(errors.As(err, &pathErr))
+	req.Equal("transform", pathErr.Op)
+}
+
+func gzipTransform(_ string, w io.WriteCloser) (io.WriteCloser, error) {
+	return &gzipWriteCloser{Writer: gzip.NewWriter(w), w: w}, nil
+}
+
+type gzipWriteCloser struct {
+	*gzip.Writer
+	w io.Closer
+}
+
+func (g *gzipWriteCloser) Close() error {
+	if err := g.Writer.Close(); err != nil {
+		return err
+	}
+
+	return g.w.Close()
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	return string(content)
+}
+
+func TestWriteTransforms(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	fs.WriteTransforms = []*WriteTransform{{
+		Extensions: []string{".log", ".json"},
+		Metadata:   map[string]string{"Codec": "gzip"},
+		Transform:  gzipTransform,
+	}}
+
+	// The streamed and single request writes are transformed
+	content := strings.Repeat("line\n", 10000)
+	testCreateFile(t, fs, "/app.log", content)
+	req.NoError(fs.WriteFile("/small.log", []byte("small"), nil))
+	req.NoError(fs.WriteJSON("/doc.json", map[string]string{"name": "value"}, nil))
+	testCreateFile(t, fs, "/file.txt", "plain")
+
+	data, err := fs.ReadFile("/app.log")
+	req.NoError(err)
+	req.Equal(content, gunzip(t, data))
+	req.Less(len(data), len(content))
+
+	data, err = fs.ReadFile("/small.log")
+	req.NoError(err)
+	req.Equal("small", gunzip(t, data))
+
+	data, err = fs.ReadFile("/file.txt")
+	req.NoError(err)
+	req.Equal("plain", string(data))
+
+	// The read transforms find them with their metadata
+	fs.ReadTransforms = []*ReadTransform{{MetadataKey: "Codec", MetadataValue: "gzip", Transform: gunzipTransform}}
+
+	file, err := fs.Open("/app.log")
+	req.NoError(err)
+
+	data, err = io.ReadAll(file)
+	req.NoError(err)
+	req.Equal(content, string(data))
+	req.NoError(file.Close())
+
+	var doc map[string]string
+	_, err = fs.ReadJSON("/doc.json", &doc)
+	req.NoError(err)
+	req.Equal("value", doc["name"])
+
+	// The transform failures are reported, and nothing is written
+	fs.WriteTransforms = []*WriteTransform{{Transform: func(string, io.WriteCloser) (io.WriteCloser, error) {
+		return nil, errors.New("no key")
+	}}}
+
+	_, err = fs.Create("/failed.log")
+	req.Error(err)
+
+	req.Error(fs.WriteFile("/failed.txt", []byte("data"), nil))
+
+	_, err = fs.Stat("/failed.txt")
+	req.ErrorIs(err, os.ErrNotExist)
+}
@@ -0,0 +1,155 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// TTLTagKey is the tag holding the time to live of the temporary objects, its value is a number of days
+// followed by "d" (e.g. "ttl=1d")
+const TTLTagKey = "ttl"
+
+// ttlRulePrefix is the prefix of the ID of the lifecycle rules expiring the temporary objects
+const ttlRulePrefix = "afero-s3-ttl-"
+
+// ttlDays converts a time to live to the number of days used by lifecycle rules, rounded up
+func ttlDays(ttl time.Duration) int64 {
+	days := int64((ttl + 24*time.Hour - 1) / (24 * time.Hour))
+	if days < 1 {
+		days = 1
+	}
+
+	return days
+}
+
+// ttlTagValue returns the value of the TTL tag of a time to live
+func ttlTagValue(ttl time.Duration) string {
+	return fmt.Sprintf("%dd", ttlDays(ttl))
+}
+
+// parseTTLTag parses the value of a TTL tag
+func parseTTLTag(value string) (time.Duration, bool) {
+	days, err := strconv.ParseInt(strings.TrimSuffix(value, "d"), 10, 64)
+	if err != nil || !strings.HasSuffix(value, "d") || days < 1 {
+		return 0, false
+	}
+
+	return time.Duration(days) * 24 * time.Hour, true
+}
+
+// CreateTemp creates a temporary file like TempFile, tagged with its time to live. The TTL is rounded up to
+// days as it's the granularity of lifecycle rules. The objects are expired by S3 once SetTTLLifecycleRule
+// was called for this TTL, or by the janitor (see RemoveExpired and StartJanitor).
+func (fs *Fs) CreateTemp(dir, pattern string, ttl time.Duration) (*File, error) {
+	tags := url.Values{}
+	tags.Set(TTLTagKey, ttlTagValue(ttl))
+
+	file, err := fs.tempFile(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	file.upload.tagging = aws.String(tags.Encode())
+
+	return file, file.openWriteStream()
+}
+
+// SetTTLLifecycleRule configures the bucket lifecycle rule expiring the objects created by CreateTemp with
+// this TTL. The other rules of the bucket are kept.
+func (fs *Fs) SetTTLLifecycleRule(ttl time.Duration) error {
+	rules, err := fs.bucketLifecycleRules()
+	if err != nil {
+		return err
+	}
+
+	value := ttlTagValue(ttl)
+	id := ttlRulePrefix + value
+	kept := make([]*s3.LifecycleRule, 0, len(rules)+1)
+
+	for _, rule := range rules {
+		if aws.StringValue(rule.ID) != id {
+			kept = append(kept, rule)
+		}
+	}
+
+	kept = append(kept, &s3.LifecycleRule{
+		ID:     aws.String(id),
+		Status: aws.String(s3.ExpirationStatusEnabled),
+		Filter: &s3.LifecycleRuleFilter{
+			Tag: &s3.Tag{Key: aws.String(TTLTagKey), Value: aws.String(value)},
+		},
+		Expiration: &s3.LifecycleExpiration{Days: aws.Int64(ttlDays(ttl))},
+	})
+
+	return fs.putBucketLifecycleRules(kept)
+}
+
+// RemoveExpired removes the temporary objects of a directory whose TTL has expired. It's meant for the
+// S3-compatible servers that don't support lifecycle rules.
+func (fs *Fs) RemoveExpired(dir string, opts *BulkOptions) error {
+	return fs.removeExpired(dir, time.Now(), opts)
+}
+
+func (fs *Fs) removeExpired(dir string, now time.Time, opts *BulkOptions) error {
+	return fs.forEachObject("removeexpired", dir, opts, func(obj *s3.Object) error {
+		tagging, err := fs.s3API.GetObjectTagging(&s3.GetObjectTaggingInput{
+			Bucket: aws.String(fs.bucket),
+			Key:    obj.Key,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, tag := range tagging.TagSet {
+			if aws.StringValue(tag.Key) != TTLTagKey {
+				continue
+			}
+
+			if ttl, ok := parseTTLTag(aws.StringValue(tag.Value)); ok && obj.LastModified.Add(ttl).Before(now) {
+				return fs.forceRemove("/" + *obj.Key)
+			}
+		}
+
+		return nil
+	})
+}
+
+// StartJanitor periodically removes the expired temporary objects of a directory, until the returned function
+// is called, which waits for the current run to end and can be called several times. Errors are reported to
+// onError, which can be nil and must not call stop.
+func (fs *Fs) StartJanitor(dir string, interval time.Duration, onError func(err error)) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	var once sync.Once
+
+	go func() {
+		defer close(exited)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := fs.RemoveExpired(dir, nil); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
@@ -0,0 +1,69 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTemp(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	file, err := fs.CreateTemp("/tmp", "export-*.json", 36*time.Hour)
+	req.NoError(err)
+	_, err = file.WriteString("{}")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	testCreateFile(t, fs, "/tmp/kept.json", "{}")
+
+	tagging, err := fs.s3API.GetObjectTagging(&s3.GetObjectTaggingInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(file.Name()),
+	})
+	req.NoError(err)
+	req.Len(tagging.TagSet, 1)
+	req.Equal("2d", *tagging.TagSet[0].Value)
+
+	req.NoError(fs.SetTTLLifecycleRule(36 * time.Hour))
+	req.NoError(fs.SetTTLLifecycleRule(36 * time.Hour))
+	req.NoError(fs.SetTTLLifecycleRule(time.Hour))
+
+	rules, err := fs.bucketLifecycleRules()
+	req.NoError(err)
+	req.Len(rules, 2)
+
+	// Nothing has expired yet
+	req.NoError(fs.RemoveExpired("/tmp", nil))
+	_, err = fs.Stat(file.Name())
+	req.NoError(err)
+
+	req.NoError(fs.removeExpired("/tmp", time.Now().Add(72*time.Hour), nil))
+	_, err = fs.Stat(file.Name())
+	req.Error(err)
+	_, err = fs.Stat("/tmp/kept.json")
+	req.NoError(err)
+}
+
+func TestJanitor(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	file, err := fs.CreateTemp("/tmp", "export-*.json", 24*time.Hour)
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	stop := fs.StartJanitor("/tmp", 10*time.Millisecond, nil)
+	time.Sleep(50 * time.Millisecond)
+
+	// Nothing has expired, and the janitor can be stopped several times
+	stop()
+	req.NotPanics(stop)
+
+	_, err = fs.Stat(file.Name())
+	req.NoError(err)
+}
@@ -0,0 +1,140 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUploadAbandoned is the error of the writes of the files whose upload was aborted by UploadIdleTimeout
+var ErrUploadAbandoned = errors.New("upload abandoned after being idle")
+
+// minReapInterval is the minimum interval between two checks of the idle uploads
+const minReapInterval = 10 * time.Millisecond
+
+// ActiveUpload describes the upload of a file being written, see Fs.ActiveUploads
+type ActiveUpload struct {
+	Name      string    // Name of the file
+	Opened    time.Time // Opened is the time the file was opened
+	LastWrite time.Time // LastWrite is the time of the last write to the file, the opening time if it has none
+}
+
+// uploadState tracks the activity of the upload of a file being written
+type uploadState struct {
+	opened    time.Time   // opened is the time the file was opened
+	lastWrite int64       // lastWrite is the time in nanoseconds of the last write, updated atomically
+	pipes     []io.Closer // pipes are the pipes of the uploads, failed when the upload is abandoned
+	abandoned int32       // abandoned is set once the upload is aborted by UploadIdleTimeout, updated atomically
+}
+
+// touch records a write
+func (s *uploadState) touch() {
+	atomic.StoreInt64(&s.lastWrite, time.Now().UnixNano())
+}
+
+// idle returns the time since the last write
+func (s *uploadState) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&s.lastWrite)))
+}
+
+// abandon aborts the upload
+func (s *uploadState) abandon() {
+	atomic.StoreInt32(&s.abandoned, 1)
+	failPipes(s.pipes, ErrUploadAbandoned)
+}
+
+// uploadError returns the error of an upload, which is ErrUploadAbandoned once it's abandoned
+func (s *uploadState) uploadError(err error) error {
+	if err != nil && atomic.LoadInt32(&s.abandoned) != 0 {
+		return ErrUploadAbandoned
+	}
+
+	return err
+}
+
+// ActiveUploads returns the uploads of the files opened for writing and not closed yet, shared by the copies of
+// the Fs returned by WithContext, sorted by name. The files that are never closed keep their upload running until
+// they're reaped by UploadIdleTimeout.
+func (fs *Fs) ActiveUploads() []ActiveUpload {
+	files := fs.writing.list()
+	uploads := make([]ActiveUpload, 0, len(files))
+
+	for _, file := range files {
+		uploads = append(uploads, ActiveUpload{
+			Name:      file.name,
+			Opened:    file.uploadState.opened,
+			LastWrite: time.Unix(0, atomic.LoadInt64(&file.uploadState.lastWrite)),
+		})
+	}
+
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].Name < uploads[j].Name })
+
+	return uploads
+}
+
+// trackUpload registers a file being written, and starts reaping the idle uploads if needed
+func (f *File) trackUpload(pipes []io.Closer) {
+	f.uploadState = &uploadState{opened: time.Now(), pipes: pipes}
+	f.uploadState.touch()
+
+	f.fs.writing.add(f)
+
+	if f.fs.UploadIdleTimeout > 0 && f.fs.writing.startReaping() {
+		go f.fs.writing.reapIdleUploads(max(f.fs.UploadIdleTimeout/4, minReapInterval))
+	}
+}
+
+// reapIdleUploads aborts the uploads of the files idle for longer than the UploadIdleTimeout of their Fs, until no
+// file is being written
+func (s *fileSet) reapIdleUploads(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, file := range s.list() {
+			if timeout := file.fs.UploadIdleTimeout; timeout > 0 && file.uploadState.idle() > timeout {
+				s.remove(file)
+				file.uploadState.abandon()
+
+				if file.fs.OnOrphanedFile != nil {
+					file.fs.OnOrphanedFile(OrphanedFile{Name: file.name, Writing: true, Stack: file.openStack})
+				}
+			}
+		}
+
+		if s.stopReaping() {
+			return
+		}
+	}
+}
+
+// startReaping tells if the idle uploads have to be reaped, when they aren't already
+func (s *fileSet) startReaping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reaping {
+		return false
+	}
+
+	s.reaping = true
+
+	return true
+}
+
+// stopReaping tells if the idle uploads stop being reaped, once no file is being written
+func (s *fileSet) stopReaping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.files) > 0 {
+		return false
+	}
+
+	s.reaping = false
+
+	return true
+}
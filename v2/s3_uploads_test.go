@@ -0,0 +1,90 @@
+package s3
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveUploads(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	req.Empty(fs.ActiveUploads())
+
+	// The files being written are listed until they're closed
+	b, err := fs.OpenFile("/b.txt", os.O_WRONLY|os.O_CREATE, 0644)
+	req.NoError(err)
+
+	a, err := fs.OpenFile("/a.txt", os.O_WRONLY|os.O_CREATE, 0644)
+	req.NoError(err)
+
+	_, err = a.WriteString("a")
+	req.NoError(err)
+
+	uploads := fs.ActiveUploads()
+	req.Len(uploads, 2)
+	req.Equal("/a.txt", uploads[0].Name)
+	req.Equal("/b.txt", uploads[1].Name)
+	req.False(uploads[0].LastWrite.Before(uploads[0].Opened))
+
+	req.NoError(a.Close())
+	req.NoError(b.Close())
+	req.Empty(fs.ActiveUploads())
+}
+
+func TestUploadIdleTimeout(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+	fs.UploadIdleTimeout = 200 * time.Millisecond
+
+	// The idle uploads are aborted
+	idle, err := fs.OpenFile("/idle.txt", os.O_WRONLY|os.O_CREATE, 0644)
+	req.NoError(err)
+
+	_, err = idle.WriteString("idle")
+	req.NoError(err)
+
+	// Not the active ones
+	active, err := fs.OpenFile("/active.txt", os.O_WRONLY|os.O_CREATE, 0644)
+	req.NoError(err)
+
+	req.Eventually(func() bool {
+		_, errWrite := active.WriteString("active\n")
+		req.NoError(errWrite)
+
+		return len(fs.ActiveUploads()) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+
+	req.Equal("/active.txt", fs.ActiveUploads()[0].Name)
+
+	_, err = idle.Write(make([]byte, 1024*1024))
+	req.ErrorIs(err, ErrUploadAbandoned)
+
+	err = idle.Close()
+	req.ErrorIs(err, ErrUploadAbandoned)
+
+	var pathErr *os.PathError
+	req.True(errors.As(err, &pathErr))
+	req.Equal("/idle.txt", pathErr.Path)
+
+	_, err = fs.Stat("/idle.txt")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	req.NoError(active.Close())
+
+	info, err := fs.Stat("/active.txt")
+	req.NoError(err)
+	req.NotZero(info.Size())
+
+	// The reaping stops once no file is being written
+	req.Eventually(func() bool {
+		fs.writing.mu.Lock()
+		defer fs.writing.mu.Unlock()
+
+		return !fs.writing.reaping
+	}, 5*time.Second, 20*time.Millisecond)
+}
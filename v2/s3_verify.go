@@ -0,0 +1,169 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"crypto/md5" // nolint: gosec
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// md5ETag matches the ETags that are MD5 sums, of the whole object or of its parts followed by their count
+var md5ETag = regexp.MustCompile(`^"[0-9a-f]{32}(-([0-9]+))?"$`)
+
+// VerifyMismatch is an object whose content doesn't match its ETag
+type VerifyMismatch struct {
+	Key      string // Key of the object
+	Expected string // Expected is the ETag stored by S3
+	Actual   string // Actual is the ETag computed from the content
+}
+
+// VerifyReport is the result of VerifyPrefix
+type VerifyReport struct {
+	Verified   int64            // Verified is the number of objects whose content matches their ETag
+	Mismatches []VerifyMismatch // Mismatches are the objects whose content doesn't match their ETag, by key
+	Skipped    []string         // Skipped are the keys of the objects whose ETag isn't an MD5 sum, in order
+}
+
+// VerifyPrefix downloads all the objects of a directory, recursively, with concurrency parallel requests
+// (DefaultBulkConcurrency if 0), and recomputes their MD5 sums to check them against their ETags, for periodic
+// integrity audits. The ETags of the multipart uploads are recomputed from the size of their first part. The objects
+// encrypted with SSE-KMS or a customer key don't have an MD5 ETag, they're skipped. It stops at the first error.
+func (fs *Fs) VerifyPrefix(prefix string, concurrency int) (*VerifyReport, error) {
+	var mu sync.Mutex
+
+	report := &VerifyReport{}
+
+	err := fs.forEachObject("verify", prefix, &BulkOptions{Concurrency: concurrency}, func(obj *s3.Object) error {
+		key := aws.StringValue(obj.Key)
+
+		expected, actual, err := fs.verifyObject(key)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case actual == "":
+			report.Skipped = append(report.Skipped, key)
+		case actual != expected:
+			report.Mismatches = append(report.Mismatches, VerifyMismatch{Key: key, Expected: expected, Actual: actual})
+		default:
+			report.Verified++
+		}
+
+		return nil
+	})
+
+	sort.Strings(report.Skipped)
+	sort.Slice(report.Mismatches, func(i, j int) bool { return report.Mismatches[i].Key < report.Mismatches[j].Key })
+
+	return report, err
+}
+
+// verifyObject returns the ETag of an object and the one computed from its content, none if it can't be computed
+func (fs *Fs) verifyObject(key string) (string, string, error) {
+	out, err := fs.s3API.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	defer out.Body.Close() // nolint: errcheck
+
+	etag := aws.StringValue(out.ETag)
+
+	match := md5ETag.FindStringSubmatch(etag)
+	if match == nil || aws.StringValue(out.ServerSideEncryption) == s3.ServerSideEncryptionAwsKms ||
+		out.SSECustomerAlgorithm != nil {
+		return etag, "", nil
+	}
+
+	if match[2] == "" {
+		actual, errSum := computeETag(out.Body, 0)
+		return etag, actual, errSum
+	}
+
+	partSize, err := fs.firstPartSize(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	actual, err := computeETag(out.Body, partSize)
+
+	return etag, actual, err
+}
+
+// firstPartSize returns the size of the first part of a multipart upload. The S3 implementations that don't describe
+// the parts are assumed to have received the parts of the uploads of the Fs.
+func (fs *Fs) firstPartSize(key string) (int64, error) {
+	out, err := fs.s3API.HeadObject(&s3.HeadObjectInput{
+		Bucket:     aws.String(fs.bucket),
+		Key:        aws.String(key),
+		PartNumber: aws.Int64(1),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if out.PartsCount != nil {
+		return aws.Int64Value(out.ContentLength), nil
+	}
+
+	if fs.serverless != nil {
+		return fs.serverless.partSize, nil
+	}
+
+	return s3manager.DefaultUploadPartSize, nil
+}
+
+// computeETag returns the ETag of a content, uploaded in a single part if partSize is 0
+func computeETag(body io.Reader, partSize int64) (string, error) {
+	hash := md5.New() // nolint: gosec
+
+	if partSize == 0 {
+		if _, err := io.Copy(hash, body); err != nil {
+			return "", err
+		}
+
+		return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+	}
+
+	var (
+		sums  []byte
+		parts int
+	)
+
+	for {
+		part := md5.New() // nolint: gosec
+
+		n, err := io.CopyN(part, body, partSize)
+		if err != nil && err != io.EOF { // nolint: errorlint
+			return "", err
+		}
+
+		if n > 0 {
+			sums = part.Sum(sums)
+			parts++
+		}
+
+		if n < partSize {
+			break
+		}
+	}
+
+	hash.Write(sums) // nolint: errcheck
+
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(hash.Sum(nil)), parts), nil
+}
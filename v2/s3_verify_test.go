@@ -0,0 +1,57 @@
+package s3
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPrefix(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/dir/small", "small")
+	testCreateFile(t, fs, "/dir/sub/empty", "")
+	testCreateFile(t, fs, "/dir/sub/kms", "kms")
+	testCreateFile(t, fs, "/other", "other")
+
+	// Uploaded in 2 parts
+	file, err := fs.OpenFile("/dir/big", os.O_WRONLY, 0666)
+	req.NoError(err)
+	_, err = file.Write(bytes.Repeat([]byte("0123456789"), 700*1024))
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	report, err := fs.VerifyPrefix("/dir", 2)
+	req.NoError(err)
+	req.Equal(int64(4), report.Verified)
+	req.Empty(report.Mismatches)
+	req.Empty(report.Skipped)
+
+	// The ETag of an object doesn't match its content anymore, and another one is encrypted with KMS
+	fs.s3API.Handlers.Send.PushBack(func(r *request.Request) {
+		if r.Operation.Name != "GetObject" || r.HTTPResponse == nil {
+			return
+		}
+
+		switch r.HTTPRequest.URL.Path {
+		case "/" + fs.bucket + "/dir/sub/empty":
+			r.HTTPResponse.Header.Set("ETag", `"00000000000000000000000000000000"`)
+		case "/" + fs.bucket + "/dir/sub/kms":
+			r.HTTPResponse.Header.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		}
+	})
+
+	report, err = fs.VerifyPrefix("/dir/sub", 0)
+	req.NoError(err)
+	req.Equal(int64(0), report.Verified)
+	req.Equal([]VerifyMismatch{{
+		Key:      "dir/sub/empty",
+		Expected: `"00000000000000000000000000000000"`,
+		Actual:   `"d41d8cd98f00b204e9800998ecf8427e"`,
+	}}, report.Mismatches)
+	req.Equal([]string{"dir/sub/kms"}, report.Skipped)
+}
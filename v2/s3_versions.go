@@ -0,0 +1,196 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// deleteMarkerHeader is the header telling that the requested version of an object is a delete marker
+const deleteMarkerHeader = "X-Amz-Delete-Marker"
+
+// ErrDeleteMarker is returned when opening a version of a file that is a delete marker
+var ErrDeleteMarker = errors.New("version is a delete marker")
+
+// ErrNoVersion is returned when a version of a file doesn't exist
+var ErrNoVersion = errors.New("no such version")
+
+// FileVersion is a version of a file of a versioned bucket
+type FileVersion struct {
+	VersionID string
+	IsLatest  bool // IsLatest tells if the version is the current one
+	// DeleteMarker tells if the version is a delete marker, left when the file was removed. A file whose
+	// latest version is a delete marker doesn't exist, but its previous versions are kept.
+	DeleteMarker bool
+	Size         int64
+	ModTime      time.Time
+}
+
+// Versions returns all the versions of a file, from the latest, including the delete markers
+func (fs *Fs) Versions(name string) ([]*FileVersion, error) {
+	var versions []*FileVersion
+
+	key := strings.TrimPrefix(name, "/")
+
+	err := fs.s3API.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+		Bucket: aws.String(fs.bucket),
+		Prefix: aws.String(key),
+	}, func(page *s3.ListObjectVersionsOutput, _ bool) bool {
+		for _, v := range page.Versions {
+			if aws.StringValue(v.Key) == key {
+				versions = append(versions, &FileVersion{
+					VersionID: aws.StringValue(v.VersionId),
+					IsLatest:  aws.BoolValue(v.IsLatest),
+					Size:      aws.Int64Value(v.Size),
+					ModTime:   aws.TimeValue(v.LastModified),
+				})
+			}
+		}
+
+		for _, m := range page.DeleteMarkers {
+			if aws.StringValue(m.Key) == key {
+				versions = append(versions, &FileVersion{
+					VersionID:    aws.StringValue(m.VersionId),
+					IsLatest:     aws.BoolValue(m.IsLatest),
+					DeleteMarker: true,
+					ModTime:      aws.TimeValue(m.LastModified),
+				})
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "versions", Path: name, Err: err}
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+// sortVersions sorts the versions from the latest, the delete markers and versions being listed separately
+func sortVersions(versions []*FileVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].IsLatest != versions[j].IsLatest {
+			return versions[i].IsLatest
+		}
+
+		return versions[i].ModTime.After(versions[j].ModTime)
+	})
+}
+
+// IsDeleted tells if a file was removed from a versioned bucket, its latest version being a delete marker
+func (fs *Fs) IsDeleted(name string) (bool, error) {
+	versions, err := fs.Versions(name)
+	if err != nil {
+		return false, err
+	}
+
+	return len(versions) > 0 && versions[0].DeleteMarker, nil
+}
+
+// Undelete restores a removed file of a versioned bucket by permanently deleting the delete markers above its
+// latest version
+func (fs *Fs) Undelete(name string) error {
+	versions, err := fs.Versions(name)
+	if err != nil {
+		return err
+	}
+
+	restored := false
+
+	for _, v := range versions {
+		if !v.DeleteMarker {
+			restored = true
+			break
+		}
+
+		if err = fs.DeleteVersion(name, v.VersionID); err != nil {
+			return err
+		}
+	}
+
+	if !restored {
+		return &os.PathError{Op: "undelete", Path: name, Err: os.ErrNotExist}
+	}
+
+	return fs.fileWritten(name)
+}
+
+// DeleteVersion permanently deletes a version of a file, which can be a delete marker. The MFA function is used
+// on the buckets with MFA delete enabled. The retention of the version is checked in RetentionAware mode.
+func (fs *Fs) DeleteVersion(name, versionID string) error {
+	if err := fs.checkRetention(name, versionID); err != nil {
+		return &os.PathError{Op: "delete-version", Path: name, Err: err}
+	}
+
+	input := &s3.DeleteObjectInput{
+		Bucket:    aws.String(fs.bucket),
+		Key:       aws.String(name),
+		VersionId: aws.String(versionID),
+	}
+
+	if fs.MFA != nil {
+		mfa, err := fs.MFA()
+		if err != nil {
+			return &os.PathError{Op: "delete-version", Path: name, Err: err}
+		}
+
+		input.MFA = aws.String(mfa)
+	}
+
+	if _, err := fs.s3API.DeleteObject(input); err != nil {
+		return &os.PathError{Op: "delete-version", Path: name, Err: err}
+	}
+
+	return nil
+}
+
+// OpenVersion opens a version of a file for reading
+func (fs *Fs) OpenVersion(name, versionID string) (*File, error) {
+	file := NewFile(fs, name)
+	file.versionID = versionID
+
+	if _, err := file.Stat(); err != nil {
+		return nil, err
+	}
+
+	return file, file.openReadStream(0)
+}
+
+// statVersion returns the FileInfo of a version of a file
+func (fs *Fs) statVersion(name, versionID string) (os.FileInfo, error) {
+	req, out := fs.s3API.HeadObjectRequest(&s3.HeadObjectInput{
+		Bucket:    aws.String(fs.bucket),
+		Key:       aws.String(name),
+		VersionId: aws.String(versionID),
+	})
+
+	if err := req.Send(); err != nil {
+		switch {
+		case req.HTTPResponse.Header.Get(deleteMarkerHeader) == "true":
+			err = ErrDeleteMarker
+		case isNotFound(err):
+			err = ErrNoVersion
+		}
+
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	info := NewFileInfo(name, false, aws.Int64Value(out.ContentLength), aws.TimeValue(out.LastModified))
+
+	var err error
+	if info.mode, err = fs.storedMode(name, out.Metadata); err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+	info.owner = parseOwnerMetadata(out.Metadata)
+
+	return info, nil
+}
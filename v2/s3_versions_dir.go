@@ -0,0 +1,266 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+)
+
+// VersionsDirName is the read-only virtual directory exposing the versions of the files when Fs.VersionsDir is set.
+// "/.versions/dir/file" is a directory listing the versions of "/dir/file", named after their modification time.
+const VersionsDirName = "/.versions"
+
+// versionTimeFormat is the format of the names of the versions in the versions directory
+const versionTimeFormat = "20060102T150405.000Z"
+
+// versionsEntry is what a name of the versions directory refers to
+type versionsEntry struct {
+	info      FileInfo
+	key       string // key is the name of the file, for the versions and the files listing them
+	versionID string // versionID is the version of the file, empty for the directories
+}
+
+// versionsPath returns the path a name of the versions directory refers to, "/" for the versions directory itself
+func (fs *Fs) versionsPath(name string) (string, bool) {
+	if !fs.VersionsDir {
+		return "", false
+	}
+
+	clean := path.Clean("/" + name)
+
+	switch {
+	case clean == VersionsDirName:
+		return "/", true
+	case strings.HasPrefix(clean, VersionsDirName+"/"):
+		return strings.TrimPrefix(clean, VersionsDirName), true
+	default:
+		return "", false
+	}
+}
+
+// checkWritable forbids the changes in the versions directory
+func (fs *Fs) checkWritable(op, name string) error {
+	if _, ok := fs.versionsPath(name); ok {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+
+	return nil
+}
+
+// namedVersions returns the versions of a file by name, the delete markers being left out. The versions are named
+// after their modification time, the ones modified at the same time being told apart by their ID.
+func (fs *Fs) namedVersions(name string) (map[string]*FileVersion, error) {
+	versions, err := fs.Versions(name)
+	if err != nil {
+		return nil, err
+	}
+
+	times := make(map[string]int)
+
+	for _, v := range versions {
+		if !v.DeleteMarker {
+			times[v.ModTime.UTC().Format(versionTimeFormat)]++
+		}
+	}
+
+	named := make(map[string]*FileVersion)
+
+	for _, v := range versions {
+		if v.DeleteMarker {
+			continue
+		}
+
+		versionName := v.ModTime.UTC().Format(versionTimeFormat)
+		if times[versionName] > 1 {
+			versionName += "-" + v.VersionID
+		}
+
+		named[versionName] = v
+	}
+
+	return named, nil
+}
+
+// resolveVersionsPath returns what a path of the versions directory refers to: a directory, a file listing its
+// versions as a directory, or a version of a file
+func (fs *Fs) resolveVersionsPath(name, rel string) (*versionsEntry, error) {
+	if rel == "/" {
+		return &versionsEntry{info: NewFileInfo(VersionsDirName, true, 0, time.Unix(0, 0))}, nil
+	}
+
+	versions, err := fs.namedVersions(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) > 0 {
+		return &versionsEntry{info: NewFileInfo(name, true, 0, time.Unix(0, 0)), key: rel}, nil
+	}
+
+	if dir := path.Dir(rel); dir != "/" {
+		if versions, err = fs.namedVersions(dir); err != nil {
+			return nil, err
+		}
+
+		if v, ok := versions[path.Base(rel)]; ok {
+			return &versionsEntry{
+				info:      NewFileInfo(name, false, v.Size, v.ModTime),
+				key:       dir,
+				versionID: v.VersionID,
+			}, nil
+		}
+	}
+
+	// The directories exist as long as they have versions of files, even removed ones
+	out, err := fs.s3API.ListObjectVersions(&s3.ListObjectVersionsInput{
+		Bucket:  aws.String(fs.bucket),
+		Prefix:  aws.String(dirPrefix(rel)),
+		MaxKeys: aws.Int64(1),
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	if len(out.Versions) == 0 && len(out.DeleteMarkers) == 0 {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	return &versionsEntry{info: NewFileInfo(name, true, 0, time.Unix(0, 0))}, nil
+}
+
+// statVersionsPath returns the FileInfo of a name of the versions directory
+func (fs *Fs) statVersionsPath(name, rel string) (os.FileInfo, error) {
+	entry, err := fs.resolveVersionsPath(name, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.info, nil
+}
+
+// openVersionsPath opens a name of the versions directory, which can only be read
+func (fs *Fs) openVersionsPath(name, rel string, flag int) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_CREATE) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+
+	entry, err := fs.resolveVersionsPath(name, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	file := NewFile(fs, name)
+
+	if entry.versionID != "" {
+		file.name, file.virtualName, file.versionID = entry.key, name, entry.versionID
+
+		return file, file.openReadStream(0)
+	}
+
+	return file, nil
+}
+
+// readdirVersions lists a directory of the versions directory, which is listed at once
+func (f *File) readdirVersions(rel string, n int) ([]os.FileInfo, error) {
+	if !f.readdirNotTruncated {
+		entries, err := f.fs.listVersionsPath(f.name, rel)
+		if err != nil {
+			return nil, err
+		}
+
+		f.readdirNotTruncated = true
+		f.versionsEntries = entries
+	}
+
+	if n <= 0 || n > len(f.versionsEntries) {
+		n = len(f.versionsEntries)
+	}
+
+	fis := f.versionsEntries[:n]
+	f.versionsEntries = f.versionsEntries[n:]
+
+	if len(fis) == 0 && n > 0 {
+		return nil, io.EOF
+	}
+
+	return fis, nil
+}
+
+// listVersionsPath returns the entries of a directory of the versions directory
+func (fs *Fs) listVersionsPath(name, rel string) ([]os.FileInfo, error) {
+	entry, err := fs.resolveVersionsPath(name, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	if !entry.info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: ErrNotSupported}
+	}
+
+	if entry.key != "" {
+		versions, errVersions := fs.namedVersions(entry.key)
+		if errVersions != nil {
+			return nil, errVersions
+		}
+
+		fis := make([]os.FileInfo, 0, len(versions))
+		for versionName, v := range versions {
+			fis = append(fis, NewFileInfo(path.Join(name, versionName), false, v.Size, v.ModTime))
+		}
+
+		// From the latest
+		sort.Slice(fis, func(i, j int) bool { return fis[i].Name() > fis[j].Name() })
+
+		return fis, nil
+	}
+
+	return fs.listVersionedDir(name, rel)
+}
+
+// listVersionedDir lists the directories and the files having versions in a directory, as directories
+func (fs *Fs) listVersionedDir(name, rel string) ([]os.FileInfo, error) {
+	prefix := dirPrefix(rel)
+	listed := make(map[string]bool)
+
+	var fis []os.FileInfo
+
+	add := func(key string) {
+		if base := path.Base("/" + key); !listed[base] {
+			listed[base] = true
+			fis = append(fis, NewFileInfo(path.Join(name, base), true, 0, time.Unix(0, 0)))
+		}
+	}
+
+	err := fs.s3API.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+		Bucket:    aws.String(fs.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	}, func(page *s3.ListObjectVersionsOutput, _ bool) bool {
+		for _, p := range page.CommonPrefixes {
+			add(aws.StringValue(p.Prefix))
+		}
+
+		for _, v := range page.Versions {
+			if key := aws.StringValue(v.Key); key != prefix && !strings.HasSuffix(key, "/") {
+				add(key)
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+
+	return fis, nil
+}
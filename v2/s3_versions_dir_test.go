@@ -0,0 +1,102 @@
+package s3
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionsDir(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	_, err := fs.s3API.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(fs.bucket),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(s3.BucketVersioningStatusEnabled)},
+	})
+	req.NoError(err)
+
+	testCreateFile(t, fs, "/dir/file.txt", "v1")
+	testCreateFile(t, fs, "/dir/file.txt", "v22")
+	testCreateFile(t, fs, "/removed.txt", "gone")
+	req.NoError(fs.Remove("/removed.txt"))
+
+	// The versions directory is opt-in
+	_, err = fs.Stat(VersionsDirName)
+	req.ErrorIs(err, os.ErrNotExist)
+
+	fs.VersionsDir = true
+
+	root, err := afero.ReadDir(fs, "/")
+	req.NoError(err)
+	req.Equal(".versions", root[0].Name())
+	req.True(root[0].IsDir())
+
+	names := func(dir string) []string {
+		fis, errRead := afero.ReadDir(fs, dir)
+		req.NoError(errRead)
+
+		list := make([]string, len(fis))
+		for i, fi := range fis {
+			list[i] = fi.Name()
+		}
+
+		return list
+	}
+
+	// The removed files are still listed
+	req.Equal([]string{"dir", "removed.txt"}, names("/.versions"))
+	req.Equal([]string{"file.txt"}, names("/.versions/dir"))
+
+	info, err := fs.Stat("/.versions/dir/file.txt")
+	req.NoError(err)
+	req.True(info.IsDir())
+
+	versions := names("/.versions/dir/file.txt")
+	req.Len(versions, 2)
+
+	read := func(name string) string {
+		file, errOpen := fs.Open(name)
+		req.NoError(errOpen)
+
+		defer file.Close() // nolint: errcheck
+
+		req.Equal(name, file.Name())
+
+		info, errStat := file.Stat()
+		req.NoError(errStat)
+		req.False(info.IsDir())
+
+		content, errRead := io.ReadAll(file)
+		req.NoError(errRead)
+		req.Equal(info.Size(), int64(len(content)))
+
+		return string(content)
+	}
+
+	contents := []string{read("/.versions/dir/file.txt/" + versions[0]), read("/.versions/dir/file.txt/" + versions[1])}
+	req.ElementsMatch([]string{"v1", "v22"}, contents)
+	req.Equal("gone", read("/.versions/removed.txt/"+names("/.versions/removed.txt")[0]))
+
+	_, err = fs.Stat("/.versions/dir/file.txt/19700101T000000.000Z")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	_, err = fs.Stat("/.versions/other")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	// The versions can't be changed
+	req.ErrorIs(fs.Remove("/.versions/dir/file.txt/"+versions[0]), os.ErrPermission)
+	req.ErrorIs(fs.RemoveAll("/.versions"), os.ErrPermission)
+	req.ErrorIs(fs.Mkdir("/.versions/new", 0755), os.ErrPermission)
+	_, err = fs.Create("/.versions/dir/file.txt/new")
+	req.ErrorIs(err, os.ErrPermission)
+
+	// The versions directory isn't removed with the files
+	req.NoError(fs.RemoveAll("/dir"))
+	req.Equal([]string{"dir", "removed.txt"}, names("/.versions"))
+}
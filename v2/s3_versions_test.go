@@ -0,0 +1,87 @@
+package s3
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersions(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	_, err := fs.s3API.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(fs.bucket),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(s3.BucketVersioningStatusEnabled)},
+	})
+	req.NoError(err)
+
+	testCreateFile(t, fs, "/file.txt", "v1")
+	testCreateFile(t, fs, "/file.txt", "v2")
+	req.NoError(fs.Remove("/file.txt"))
+
+	_, err = fs.Stat("/file.txt")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	deleted, err := fs.IsDeleted("/file.txt")
+	req.NoError(err)
+	req.True(deleted)
+
+	versions, err := fs.Versions("/file.txt")
+	req.NoError(err)
+	req.Len(versions, 3)
+	req.True(versions[0].DeleteMarker)
+	req.True(versions[0].IsLatest)
+	req.Equal(int64(2), versions[1].Size)
+
+	read := func(versionID string) string {
+		file, errOpen := fs.OpenVersion("/file.txt", versionID)
+		req.NoError(errOpen)
+
+		defer file.Close() // nolint: errcheck
+
+		content, errRead := io.ReadAll(file)
+		req.NoError(errRead)
+
+		return string(content)
+	}
+
+	// The previous versions can still be read
+	req.Equal("v2", read(versions[1].VersionID))
+	req.Equal("v1", read(versions[2].VersionID))
+
+	_, err = fs.OpenVersion("/file.txt", versions[0].VersionID)
+	req.ErrorIs(err, ErrDeleteMarker)
+
+	// Undeleting the file restores its latest version
+	req.NoError(fs.Undelete("/file.txt"))
+
+	content, err := afero.ReadFile(fs, "/file.txt")
+	req.NoError(err)
+	req.Equal("v2", string(content))
+
+	// The MFA is sent when deleting versions permanently
+	var mfa string
+
+	fs.s3API.Handlers.Send.PushFrontNamed(request.NamedHandler{Name: "test.MFA", Fn: func(r *request.Request) {
+		if r.Operation.Name == "DeleteObject" {
+			mfa = r.HTTPRequest.Header.Get("X-Amz-Mfa")
+		}
+	}})
+	fs.MFA = func() (string, error) { return "serial 123456", nil }
+
+	req.NoError(fs.DeleteVersion("/file.txt", versions[1].VersionID))
+	req.Equal("serial 123456", mfa)
+
+	content, err = afero.ReadFile(fs, "/file.txt")
+	req.NoError(err)
+	req.Equal("v1", string(content))
+
+	req.Error(fs.Undelete("/missing.txt"))
+}
@@ -0,0 +1,115 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DefaultWatchInterval is the default delay between two checks of a watched file
+const DefaultWatchInterval = 10 * time.Second
+
+// WatchOptions defines how a file is watched
+type WatchOptions struct {
+	// Interval is the delay between two checks, DefaultWatchInterval if zero
+	Interval time.Duration
+	// ETag is the ETag of the known content of the file, like the one returned by ReadJSON. The changes are
+	// reported from this version, otherwise the first check reports the file if it exists.
+	ETag string
+	// OnError is called when a check fails, it can be nil
+	OnError func(err error)
+}
+
+// KeyChange describes a change of a watched file
+type KeyChange struct {
+	Name    string    // Name is the name of the watched file
+	ETag    string    // ETag is the ETag of the new content, empty if the file was removed
+	Size    int64     // Size is the size of the new content
+	ModTime time.Time // ModTime is the modification time of the new content
+	Removed bool      // Removed tells if the file was removed
+}
+
+// WatchKey polls a single file and calls onChange every time its content changes, until the returned function is
+// called. Each check is a HeadObject request conditioned by the last known ETag, which makes it cheap enough to
+// hot-reload a configuration file without watching a whole prefix.
+func (fs *Fs) WatchKey(name string, opts *WatchOptions, onChange func(change KeyChange)) (stop func()) {
+	if opts == nil {
+		opts = &WatchOptions{}
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	etag := opts.ETag
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			change, err := fs.checkKey(ctx, name, etag)
+
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				if opts.OnError != nil {
+					opts.OnError(err)
+				}
+			case change != nil:
+				etag = change.ETag
+				onChange(*change)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// checkKey returns the change of a file since the etag version, or nil if it didn't change
+func (fs *Fs) checkKey(ctx context.Context, name, etag string) (*KeyChange, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	}
+
+	if etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+
+	out, err := fs.s3API.HeadObjectWithContext(ctx, input)
+
+	switch {
+	case httpStatus(err) == http.StatusNotModified:
+		return nil, nil
+	case isNotFound(err):
+		if etag == "" {
+			return nil, nil
+		}
+
+		return &KeyChange{Name: name, Removed: true}, nil
+	case err != nil:
+		return nil, &os.PathError{Op: "watch", Path: name, Err: err}
+	}
+
+	return &KeyChange{
+		Name:    name,
+		ETag:    aws.StringValue(out.ETag),
+		Size:    aws.Int64Value(out.ContentLength),
+		ModTime: aws.TimeValue(out.LastModified),
+	}, nil
+}
@@ -0,0 +1,90 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchKey(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	req.NoError(fs.WriteJSON("/config.json", map[string]int{"version": 1}, nil))
+
+	var config map[string]int
+	etag, err := fs.ReadJSON("/config.json", &config)
+	req.NoError(err)
+
+	changes := make(chan KeyChange, 10)
+	errs := make(chan error, 10)
+
+	stop := fs.WatchKey("/config.json", &WatchOptions{
+		Interval: 50 * time.Millisecond,
+		ETag:     etag,
+		OnError:  func(err error) { errs <- err },
+	}, func(change KeyChange) { changes <- change })
+	defer stop()
+
+	// The known version isn't reported
+	select {
+	case change := <-changes:
+		req.Failf("unexpected change", "%+v", change)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	req.NoError(fs.WriteJSON("/config.json", map[string]int{"version": 2}, nil))
+
+	change := waitKeyChange(t, changes)
+	req.Equal("/config.json", change.Name)
+	req.NotEqual(etag, change.ETag)
+	req.NotEmpty(change.ETag)
+	req.False(change.Removed)
+	req.NotZero(change.Size)
+
+	req.NoError(fs.Remove("/config.json"))
+
+	change = waitKeyChange(t, changes)
+	req.True(change.Removed)
+	req.Empty(change.ETag)
+
+	testCreateFile(t, fs, "/config.json", "{}")
+
+	change = waitKeyChange(t, changes)
+	req.False(change.Removed)
+	req.Equal(int64(2), change.Size)
+
+	req.Empty(errs)
+}
+
+func TestWatchKeyInitial(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	testCreateFile(t, fs, "/watched", "content")
+
+	changes := make(chan KeyChange, 10)
+	stop := fs.WatchKey("/watched", &WatchOptions{Interval: 50 * time.Millisecond}, func(change KeyChange) {
+		changes <- change
+	})
+
+	// Without a known version, the current one is reported first
+	change := waitKeyChange(t, changes)
+	req.Equal(int64(7), change.Size)
+
+	stop()
+}
+
+func waitKeyChange(t *testing.T, changes <-chan KeyChange) KeyChange {
+	t.Helper()
+
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+
+	return KeyChange{}
+}
@@ -0,0 +1,292 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"crypto/md5" // nolint: gosec
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+)
+
+// ErrInvalidRedirect is returned when a redirect target is neither an absolute path nor an HTTP(S) URL
+var ErrInvalidRedirect = errors.New("redirect target must start with /, http:// or https://")
+
+// CreateRedirect creates an empty name file redirecting the website requests to the target, which is either
+// another object of the bucket ("/other/page.html") or an external URL
+func (fs *Fs) CreateRedirect(name, target string) error {
+	if !strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "http://") &&
+		!strings.HasPrefix(target, "https://") {
+		return &os.PathError{Op: "redirect", Path: name, Err: ErrInvalidRedirect}
+	}
+
+	params := fs.permParams(0666)
+	params.redirect = &target
+
+	if err := fs.putEmpty(name, &params); err != nil {
+		return &os.PathError{Op: "redirect", Path: name, Err: err}
+	}
+
+	if fs.Mirror != nil {
+		if err := fs.Mirror.putEmpty(name, &params); err != nil {
+			return &os.PathError{Op: "redirect", Path: name, Err: err}
+		}
+	}
+
+	if err := fs.fileWritten(name); err != nil {
+		return err
+	}
+
+	if fs.Replicator != nil {
+		fs.Replicator.enqueue(ReplicationWrite, name)
+	}
+
+	return nil
+}
+
+// DeployOptions defines how a static website is deployed
+type DeployOptions struct {
+	BulkOptions
+	// CacheControl is the Cache-Control header of the files per extension, like ".html", the "" extension
+	// applying to all the others
+	CacheControl map[string]string
+	// ContentTypes overrides the Content-Type of the files per extension, it's guessed otherwise
+	ContentTypes map[string]string
+	// KeepStale keeps the files of the destination that don't exist in the source
+	KeepStale bool
+	// DistributionID is the CloudFront distribution invalidated for the changed files, if set
+	DistributionID string
+	// Invalidate invalidates the changed paths of the website, it defaults to a CloudFront invalidation of
+	// DistributionID
+	Invalidate func(paths []string) error
+}
+
+// DeployResult describes the changes of a deployment
+type DeployResult struct {
+	Uploaded []string // Uploaded are the files that were new or changed
+	Deleted  []string // Deleted are the stale files that were removed
+	Skipped  int      // Skipped is the number of unchanged files
+}
+
+// deployFile is a file of the source of a deployment
+type deployFile struct {
+	src  string
+	dst  string
+	size int64
+}
+
+// DeploySite synchronizes a static website from src to the dstPrefix directory. Only the changed files are uploaded,
+// the HTML pages after the other files so that they never reference missing assets, and the stale files are
+// removed once everything was uploaded. The changed paths can then be invalidated in a CloudFront distribution.
+func (fs *Fs) DeploySite(src afero.Fs, dstPrefix string, opts *DeployOptions) (*DeployResult, error) {
+	if opts == nil {
+		opts = &DeployOptions{}
+	}
+
+	existing := make(map[string]string)
+	if err := fs.walkObjects(dirPrefix(dstPrefix), func(obj *s3.Object) bool {
+		existing["/"+aws.StringValue(obj.Key)] = aws.StringValue(obj.ETag)
+		return true
+	}); err != nil {
+		return nil, &os.PathError{Op: "deploy", Path: dstPrefix, Err: err}
+	}
+
+	changed, skipped, err := deployChanges(src, dstPrefix, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DeployResult{Skipped: skipped}
+
+	if err = fs.deployUpload(src, changed, opts); err != nil {
+		return nil, err
+	}
+
+	for _, file := range changed {
+		result.Uploaded = append(result.Uploaded, file.dst)
+	}
+
+	if !opts.KeepStale {
+		for name := range existing {
+			result.Deleted = append(result.Deleted, name)
+		}
+
+		sort.Strings(result.Deleted)
+
+		if err = fs.deployDelete(result.Deleted, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if paths := append(result.Uploaded, result.Deleted...); len(paths) > 0 { // nolint: gocritic
+		if err = fs.invalidate(paths, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// deployChanges lists the files of the source that are new or changed, and removes all the source files from the
+// existing ones so that only the stale ones are left
+func deployChanges(src afero.Fs, dstPrefix string, existing map[string]string) ([]deployFile, int, error) {
+	var (
+		changed []deployFile
+		skipped int
+	)
+
+	err := afero.Walk(src, "/", func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		dst := path.Join("/", dstPrefix, filepath.ToSlash(name))
+
+		etag, ok := existing[dst]
+		delete(existing, dst)
+
+		if ok {
+			sum, errSum := fileMD5(src, name)
+			if errSum != nil {
+				return errSum
+			}
+
+			if etag == `"`+sum+`"` {
+				skipped++
+				return nil
+			}
+		}
+
+		changed = append(changed, deployFile{src: name, dst: dst, size: info.Size()})
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, &os.PathError{Op: "deploy", Path: dstPrefix, Err: err}
+	}
+
+	// The pages are uploaded after the assets they reference
+	sort.SliceStable(changed, func(i, j int) bool {
+		return !isHTML(changed[i].dst) && isHTML(changed[j].dst)
+	})
+
+	return changed, skipped, nil
+}
+
+func isHTML(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".html" || ext == ".htm"
+}
+
+// fileMD5 returns the hex-encoded MD5 sum of a file, which is the ETag of the objects uploaded in a single part
+func fileMD5(src afero.Fs, name string) (string, error) {
+	file, err := src.Open(name)
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close() // nolint: errcheck
+
+	hash := md5.New() // nolint: gosec
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// deployParams returns the upload parameters of a deployed file
+func (fs *Fs) deployParams(name string, opts *DeployOptions) uploadParams {
+	params := fs.permParams(0666)
+	ext := strings.ToLower(path.Ext(name))
+
+	if cacheControl, ok := opts.CacheControl[ext]; ok {
+		params.cacheControl = aws.String(cacheControl)
+	} else if cacheControl, ok := opts.CacheControl[""]; ok {
+		params.cacheControl = aws.String(cacheControl)
+	}
+
+	if contentType, ok := opts.ContentTypes[ext]; ok {
+		params.contentType = aws.String(contentType)
+	}
+
+	return params
+}
+
+// deployUpload uploads the changed files, in parallel but with the pages after the other files
+func (fs *Fs) deployUpload(src afero.Fs, files []deployFile, opts *DeployOptions) error {
+	split := sort.Search(len(files), func(i int) bool { return isHTML(files[i].dst) })
+
+	for _, batch := range [][]deployFile{files[:split], files[split:]} {
+		err := forEachIndex("deploy", len(batch), &opts.BulkOptions, func(i int) (string, error) {
+			file := batch[i]
+
+			content, err := src.Open(file.src)
+			if err != nil {
+				return file.dst, err
+			}
+
+			defer content.Close() // nolint: errcheck
+
+			params := fs.deployParams(file.dst, opts)
+
+			if err = fs.put(file.dst, content, file.size, &params); err != nil {
+				return file.dst, err
+			}
+
+			return file.dst, fs.fileWritten(file.dst)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deployDelete removes the stale files
+func (fs *Fs) deployDelete(names []string, opts *DeployOptions) error {
+	return forEachIndex("deploy", len(names), &opts.BulkOptions, func(i int) (string, error) {
+		return names[i], fs.forceRemove(names[i])
+	})
+}
+
+// invalidate invalidates the changed paths of a website
+func (fs *Fs) invalidate(paths []string, opts *DeployOptions) error {
+	if opts.Invalidate != nil {
+		return opts.Invalidate(paths)
+	}
+
+	if opts.DistributionID == "" {
+		return nil
+	}
+
+	items := make([]*string, len(paths))
+	for i, p := range paths {
+		items[i] = aws.String(p)
+	}
+
+	reference, err := newUUID()
+	if err != nil {
+		return err
+	}
+
+	_, err = cloudfront.New(fs.session).CreateInvalidation(&cloudfront.CreateInvalidationInput{
+		DistributionId: aws.String(opts.DistributionID),
+		InvalidationBatch: &cloudfront.InvalidationBatch{
+			CallerReference: aws.String(reference),
+			Paths:           &cloudfront.Paths{Items: items, Quantity: aws.Int64(int64(len(items)))},
+		},
+	})
+
+	return err
+}
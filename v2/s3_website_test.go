@@ -0,0 +1,95 @@
+package s3
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateRedirect(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	redirect := func(name string) string {
+		head, err := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String(name)})
+		req.NoError(err)
+
+		return aws.StringValue(head.WebsiteRedirectLocation)
+	}
+
+	req.NoError(fs.CreateRedirect("/old.html", "/new.html"))
+	req.Equal("/new.html", redirect("/old.html"))
+
+	req.ErrorIs(fs.CreateRedirect("/bad.html", "new.html"), ErrInvalidRedirect)
+
+	// All the new files can redirect somewhere
+	fs.FileProps = &UploadedFileProperties{WebsiteRedirectLocation: aws.String("https://example.com/")}
+
+	file, err := fs.OpenFile("/written.html", os.O_WRONLY, 0)
+	req.NoError(err)
+	_, err = file.WriteString("<html></html>")
+	req.NoError(err)
+	req.NoError(file.Close())
+
+	req.Equal("https://example.com/", redirect("/written.html"))
+}
+
+func TestDeploySite(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	src := afero.NewMemMapFs()
+	req.NoError(afero.WriteFile(src, "/index.html", []byte("<html>v1</html>"), 0600))
+	req.NoError(afero.WriteFile(src, "/css/style.css", []byte("body {}"), 0600))
+	req.NoError(afero.WriteFile(src, "/data.bin", []byte("data"), 0600))
+
+	var invalidated []string
+
+	opts := &DeployOptions{
+		CacheControl: map[string]string{".html": "no-cache", "": "max-age=3600"},
+		ContentTypes: map[string]string{".bin": "application/x-custom"},
+		Invalidate: func(paths []string) error {
+			invalidated = paths
+			return nil
+		},
+	}
+
+	result, err := fs.DeploySite(src, "/site", opts)
+	req.NoError(err)
+	req.Equal([]string{"/site/css/style.css", "/site/data.bin", "/site/index.html"}, result.Uploaded)
+	req.Empty(result.Deleted)
+	req.Equal(result.Uploaded, invalidated)
+
+	head := func(name string) *s3.HeadObjectOutput {
+		out, errHead := fs.s3API.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(fs.bucket), Key: aws.String(name)})
+		req.NoError(errHead)
+
+		return out
+	}
+
+	req.Equal("no-cache", aws.StringValue(head("/site/index.html").CacheControl))
+	req.Equal("max-age=3600", aws.StringValue(head("/site/css/style.css").CacheControl))
+	req.Equal("application/x-custom", aws.StringValue(head("/site/data.bin").ContentType))
+
+	// Only the changes are deployed
+	req.NoError(afero.WriteFile(src, "/index.html", []byte("<html>v2</html>"), 0600))
+	req.NoError(src.Remove("/data.bin"))
+
+	result, err = fs.DeploySite(src, "/site", opts)
+	req.NoError(err)
+	req.Equal([]string{"/site/index.html"}, result.Uploaded)
+	req.Equal([]string{"/site/data.bin"}, result.Deleted)
+	req.Equal(1, result.Skipped)
+	req.Equal([]string{"/site/index.html", "/site/data.bin"}, invalidated)
+
+	_, err = fs.Stat("/site/data.bin")
+	req.ErrorIs(err, os.ErrNotExist)
+
+	content, err := afero.ReadFile(fs, "/site/index.html")
+	req.NoError(err)
+	req.Equal("<html>v2</html>", string(content))
+}
@@ -0,0 +1,100 @@
+// Package s3 brings S3 files handling to afero
+package s3
+
+import (
+	"os"
+	"sync"
+)
+
+// DefaultWriteBehindLimit is the default maximum number of files committed in the background
+const DefaultWriteBehindLimit = 64
+
+// writeBehind commits the closed files in the background
+type writeBehind struct {
+	once    sync.Once
+	slots   chan struct{} // slots limit the number of files committed in the background
+	mu      sync.Mutex
+	done    *sync.Cond // done is signaled when all the commits are done
+	pending int        // pending is the number of commits in progress
+	errs    []error    // errs are the errors of the commits, until they are returned
+}
+
+func newWriteBehind() *writeBehind {
+	wb := &writeBehind{}
+	wb.done = sync.NewCond(&wb.mu)
+
+	return wb
+}
+
+func (fs *Fs) writeBehindLimit() int {
+	if fs.WriteBehindLimit <= 0 {
+		return DefaultWriteBehindLimit
+	}
+
+	return fs.WriteBehindLimit
+}
+
+// commitBehind commits a closed file in the background, it waits for a slot when WriteBehindLimit files are already
+// being committed
+func (fs *Fs) commitBehind(name string, commit func() error) {
+	wb := fs.behind
+	wb.once.Do(func() { wb.slots = make(chan struct{}, fs.writeBehindLimit()) })
+
+	wb.slots <- struct{}{}
+
+	wb.mu.Lock()
+	wb.pending++
+	wb.mu.Unlock()
+
+	go func() {
+		defer func() { <-wb.slots }()
+
+		err := commit()
+
+		wb.mu.Lock()
+		defer wb.mu.Unlock()
+
+		if err != nil {
+			wb.errs = append(wb.errs, &os.PathError{Op: "close", Path: name, Err: err})
+		}
+
+		wb.pending--
+		if wb.pending == 0 {
+			wb.done.Broadcast()
+		}
+	}()
+}
+
+// Wait waits for the files closed in write-behind mode to be committed, and returns the first error of the commits
+// that failed since the last call to Wait or Errors. The other errors are discarded.
+func (fs *Fs) Wait() error {
+	wb := fs.behind
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	for wb.pending > 0 {
+		wb.done.Wait()
+	}
+
+	errs := wb.errs
+	wb.errs = nil
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// Errors returns the errors of the commits of the files closed in write-behind mode that failed since the last call
+// to Wait or Errors, without waiting for the pending commits. They are *os.PathError.
+func (fs *Fs) Errors() []error {
+	wb := fs.behind
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	errs := wb.errs
+	wb.errs = nil
+
+	return errs
+}
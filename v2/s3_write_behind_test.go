@@ -0,0 +1,61 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBehind(t *testing.T) {
+	req := require.New(t)
+	fs := __getS3Fs(t)
+
+	fs.WriteBehind = true
+	fs.WriteBehindLimit = 4
+
+	for i := 0; i < 20; i++ {
+		file, err := fs.Create(fmt.Sprintf("/file-%d", i))
+		req.NoError(err)
+
+		_, err = file.WriteString("content")
+		req.NoError(err)
+		req.NoError(file.Close())
+	}
+
+	req.NoError(fs.Wait())
+
+	for i := 0; i < 20; i++ {
+		content, err := afero.ReadFile(fs, fmt.Sprintf("/file-%d", i))
+		req.NoError(err)
+		req.Equal("content", string(content))
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		faults := NewFaultInjector(0)
+		fs.WithFaultInjector(faults)
+
+		defer fs.WithFaultInjector(nil)
+
+		faults.Set("PutObject", &Fault{StatusCode: http.StatusBadRequest, Code: "InvalidRequest"})
+
+		file, err := fs.OpenFile("/failed", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		req.NoError(err)
+
+		// The failure is only reported once the file is committed
+		req.NoError(file.Close())
+
+		err = fs.Wait()
+		req.Error(err)
+
+		var pathErr *os.PathError
+		req.True(errors.As(err, &pathErr))
+		req.Equal("/failed", pathErr.Path)
+
+		req.Empty(fs.Errors())
+	})
+}
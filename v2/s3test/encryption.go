@@ -0,0 +1,48 @@
+// Package s3test provides helpers to test the code relying on S3 file systems
+package s3test
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Headers of the server-side encryption
+const (
+	sseHeaderPrefix                   = "X-Amz-Server-Side-Encryption"
+	sseCustomerKeyMD5Header           = "X-Amz-Server-Side-Encryption-Customer-Key-Md5"
+	copySourceSSECustomerKeyMD5Header = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key-Md5"
+)
+
+var errSSECustomerKey = newError(http.StatusBadRequest, "InvalidRequest",
+	"The object was stored using a form of Server Side Encryption. "+
+		"The correct parameters must be provided to retrieve the object.")
+
+// checkCustomerKey checks that a request provides the key an object was encrypted with, when it was encrypted with
+// a customer-provided key, header being the header of the MD5 of the key
+func checkCustomerKey(r *http.Request, encryption http.Header, header string) *s3Error {
+	if encryption.Get(sseCustomerKeyMD5Header) != r.Header.Get(header) {
+		return errSSECustomerKey
+	}
+
+	return nil
+}
+
+// withEncryption returns the headers of an object encrypted as requested, as copies aren't encrypted like their
+// source
+func withEncryption(header http.Header, r *http.Request) http.Header {
+	encrypted := make(http.Header, len(header))
+
+	for name, values := range header {
+		if !strings.HasPrefix(name, sseHeaderPrefix) {
+			encrypted[name] = values
+		}
+	}
+
+	for name, values := range extractHeaders(r.Header) {
+		if strings.HasPrefix(name, sseHeaderPrefix) {
+			encrypted[name] = values
+		}
+	}
+
+	return encrypted
+}
@@ -0,0 +1,289 @@
+// Package s3test provides helpers to test the code relying on S3 file systems
+package s3test
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/spf13/afero"
+)
+
+// ErrInjected is the default error injected by a FaultyFs
+var ErrInjected = errors.New("injected fault")
+
+// Faults configures the faults injected by a FaultyFs. All the rates are probabilities between 0 and 1.
+type Faults struct {
+	Err          error           // Err is the injected error, ErrInjected if nil
+	Operations   map[string]bool // Operations restricts the faults to some operations, like "Open" or "Read"
+	Seed         int64           // Seed of the random generator, which makes the faults reproducible
+	Latency      time.Duration   // Latency added to every operation
+	ErrorRate    float64         // ErrorRate is the probability of an operation to fail with Err
+	ThrottleRate float64         // ThrottleRate is the probability of an operation to fail with a SlowDown error
+	TruncateRate float64         // TruncateRate is the probability of a read to end prematurely
+}
+
+// FaultyFs wraps an afero.Fs and injects faults in its operations
+type FaultyFs struct {
+	fs       afero.Fs
+	faults   Faults
+	mu       sync.Mutex
+	random   *rand.Rand
+	injected int64
+}
+
+// NewFaultyFs wraps a file system with some faults
+func NewFaultyFs(fs afero.Fs, faults Faults) *FaultyFs {
+	if faults.Err == nil {
+		faults.Err = ErrInjected
+	}
+
+	return &FaultyFs{
+		fs:     fs,
+		faults: faults,
+		random: rand.New(rand.NewSource(faults.Seed)), // nolint: gosec
+	}
+}
+
+// ThrottlingError returns the error S3 returns when requests are throttled
+func ThrottlingError() error {
+	return awserr.NewRequestFailure(
+		awserr.New("SlowDown", "Please reduce your request rate.", nil), http.StatusServiceUnavailable, "",
+	)
+}
+
+// Injected returns the number of faults injected so far, latencies excluded
+func (f *FaultyFs) Injected() int64 {
+	return atomic.LoadInt64(&f.injected)
+}
+
+func (f *FaultyFs) draw(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.random.Float64() < rate
+}
+
+// fault applies the latency of an operation and returns the error to inject, if any
+func (f *FaultyFs) fault(op, name string) error {
+	if len(f.faults.Operations) > 0 && !f.faults.Operations[op] {
+		return nil
+	}
+
+	if f.faults.Latency > 0 {
+		time.Sleep(f.faults.Latency)
+	}
+
+	var err error
+
+	switch {
+	case f.draw(f.faults.ThrottleRate):
+		err = ThrottlingError()
+	case f.draw(f.faults.ErrorRate):
+		err = f.faults.Err
+	default:
+		return nil
+	}
+
+	atomic.AddInt64(&f.injected, 1)
+
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// truncated tells if a read should end prematurely
+func (f *FaultyFs) truncated() bool {
+	if len(f.faults.Operations) > 0 && !f.faults.Operations["Read"] {
+		return false
+	}
+
+	if !f.draw(f.faults.TruncateRate) {
+		return false
+	}
+
+	atomic.AddInt64(&f.injected, 1)
+
+	return true
+}
+
+// Name returns the name of the wrapped file system
+func (f *FaultyFs) Name() string { return "faulty-" + f.fs.Name() }
+
+// Create creates a file
+func (f *FaultyFs) Create(name string) (afero.File, error) {
+	if err := f.fault("Create", name); err != nil {
+		return nil, err
+	}
+
+	return f.wrap(f.fs.Create(name))
+}
+
+// Mkdir creates a directory
+func (f *FaultyFs) Mkdir(name string, perm os.FileMode) error {
+	if err := f.fault("Mkdir", name); err != nil {
+		return err
+	}
+
+	return f.fs.Mkdir(name, perm)
+}
+
+// MkdirAll creates a directory and its parents
+func (f *FaultyFs) MkdirAll(name string, perm os.FileMode) error {
+	if err := f.fault("MkdirAll", name); err != nil {
+		return err
+	}
+
+	return f.fs.MkdirAll(name, perm)
+}
+
+// Open opens a file for reading
+func (f *FaultyFs) Open(name string) (afero.File, error) {
+	if err := f.fault("Open", name); err != nil {
+		return nil, err
+	}
+
+	return f.wrap(f.fs.Open(name))
+}
+
+// OpenFile opens a file
+func (f *FaultyFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if err := f.fault("OpenFile", name); err != nil {
+		return nil, err
+	}
+
+	return f.wrap(f.fs.OpenFile(name, flag, perm))
+}
+
+// Remove removes a file
+func (f *FaultyFs) Remove(name string) error {
+	if err := f.fault("Remove", name); err != nil {
+		return err
+	}
+
+	return f.fs.Remove(name)
+}
+
+// RemoveAll removes a directory and its content
+func (f *FaultyFs) RemoveAll(name string) error {
+	if err := f.fault("RemoveAll", name); err != nil {
+		return err
+	}
+
+	return f.fs.RemoveAll(name)
+}
+
+// Rename renames a file
+func (f *FaultyFs) Rename(oldname, newname string) error {
+	if err := f.fault("Rename", oldname); err != nil {
+		return err
+	}
+
+	return f.fs.Rename(oldname, newname)
+}
+
+// Stat describes a file
+func (f *FaultyFs) Stat(name string) (os.FileInfo, error) {
+	if err := f.fault("Stat", name); err != nil {
+		return nil, err
+	}
+
+	return f.fs.Stat(name)
+}
+
+// Chmod changes the mode of a file
+func (f *FaultyFs) Chmod(name string, mode os.FileMode) error {
+	if err := f.fault("Chmod", name); err != nil {
+		return err
+	}
+
+	return f.fs.Chmod(name, mode)
+}
+
+// Chown changes the owner of a file
+func (f *FaultyFs) Chown(name string, uid, gid int) error {
+	if err := f.fault("Chown", name); err != nil {
+		return err
+	}
+
+	return f.fs.Chown(name, uid, gid)
+}
+
+// Chtimes changes the times of a file
+func (f *FaultyFs) Chtimes(name string, atime, mtime time.Time) error {
+	if err := f.fault("Chtimes", name); err != nil {
+		return err
+	}
+
+	return f.fs.Chtimes(name, atime, mtime)
+}
+
+func (f *FaultyFs) wrap(file afero.File, err error) (afero.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &faultyFile{File: file, fs: f}, nil
+}
+
+// faultyFile injects faults in the reads and writes of a file
+type faultyFile struct {
+	afero.File
+	fs *FaultyFs
+}
+
+func (f *faultyFile) Read(p []byte) (int, error) {
+	if err := f.fs.fault("Read", f.Name()); err != nil {
+		return 0, err
+	}
+
+	// Only a part of the data is returned before the body ends
+	if len(p) > 1 && f.fs.truncated() {
+		n, _ := f.File.Read(p[:len(p)/2])
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return f.File.Read(p)
+}
+
+func (f *faultyFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.fs.fault("Read", f.Name()); err != nil {
+		return 0, err
+	}
+
+	if len(p) > 1 && f.fs.truncated() {
+		n, _ := f.File.ReadAt(p[:len(p)/2], off)
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return f.File.ReadAt(p, off)
+}
+
+func (f *faultyFile) Write(p []byte) (int, error) {
+	if err := f.fs.fault("Write", f.Name()); err != nil {
+		return 0, err
+	}
+
+	return f.File.Write(p)
+}
+
+func (f *faultyFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *faultyFile) Close() error {
+	if err := f.fs.fault("Close", f.Name()); err != nil {
+		_ = f.File.Close()
+		return err
+	}
+
+	return f.File.Close()
+}
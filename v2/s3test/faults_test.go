@@ -0,0 +1,73 @@
+package s3test
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultyFs(t *testing.T) {
+	req := require.New(t)
+	base := afero.NewMemMapFs()
+	req.NoError(afero.WriteFile(base, "/file", []byte("Hello world !"), 0600))
+
+	t.Run("Errors", func(t *testing.T) {
+		fs := NewFaultyFs(base, Faults{ErrorRate: 0.5, Seed: 1})
+
+		failures := 0
+		for i := 0; i < 100; i++ {
+			if _, err := fs.Stat("/file"); err != nil {
+				req.ErrorIs(err, ErrInjected)
+				failures++
+			}
+		}
+
+		req.InDelta(50, failures, 15)
+		req.Equal(int64(failures), fs.Injected())
+
+		// The same seed gives the same faults
+		again := NewFaultyFs(base, Faults{ErrorRate: 0.5, Seed: 1})
+		for i := 0; i < 100; i++ {
+			_, _ = again.Stat("/file")
+		}
+
+		req.Equal(fs.Injected(), again.Injected())
+	})
+
+	t.Run("Throttling", func(t *testing.T) {
+		fs := NewFaultyFs(base, Faults{ThrottleRate: 1, Operations: map[string]bool{"Open": true}})
+
+		_, err := fs.Open("/file")
+
+		var errAws awserr.RequestFailure
+		req.True(errors.As(err, &errAws))
+		req.Equal("SlowDown", errAws.Code())
+
+		_, err = fs.Stat("/file")
+		req.NoError(err)
+	})
+
+	t.Run("Truncation", func(t *testing.T) {
+		fs := NewFaultyFs(base, Faults{TruncateRate: 1})
+
+		file, err := fs.Open("/file")
+		req.NoError(err)
+
+		_, err = io.ReadAll(file)
+		req.ErrorIs(err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		fs := NewFaultyFs(base, Faults{Latency: 20 * time.Millisecond})
+
+		start := time.Now()
+		_, err := fs.Stat("/file")
+		req.NoError(err)
+		req.GreaterOrEqual(time.Since(start), 20*time.Millisecond)
+	})
+}
@@ -0,0 +1,1287 @@
+// Package s3test provides helpers to test the code relying on S3 file systems
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5" // nolint: gosec
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minPartSize is the minimum size S3 accepts for every part but the last one of a multipart upload
+const minPartSize = 5 * 1024 * 1024
+
+// timeFormat is the format used by S3 for dates in XML documents
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// Handler is an in-memory implementation of the subset of the S3 HTTP API used by this package.
+// It only supports path-style addressing and completely ignores authentication.
+type Handler struct {
+	// Region is returned by GetBucketLocation and HeadBucket, an empty region being us-east-1
+	Region string
+	// MaxCopySize is the maximum size of an object that can be copied with CopyObject,
+	// 0 means 5GB like S3
+	MaxCopySize int64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	uploads map[string]*upload
+	counter int
+}
+
+type object struct {
+	modTime   time.Time
+	header    http.Header
+	tags      url.Values
+	etag      string
+	data      []byte
+	versionID string // versionID is the version of the object in a versioned bucket
+}
+
+type bucket struct {
+	objects   map[string]*object
+	versions  map[string][]*version // versions are the versions of the objects of a versioned bucket
+	lifecycle []byte
+	configs   map[string][]byte // configs are the bucket sub-resources stored as is, like versioning
+}
+
+// bucketConfigs are the bucket sub-resources stored as is, with the error returned when they are not set.
+// A nil error means an empty document is returned.
+var bucketConfigs = map[string]*s3Error{
+	"versioning": nil,
+	"encryption": newError(http.StatusNotFound, "ServerSideEncryptionConfigurationNotFoundError",
+		"The server side encryption configuration was not found"),
+	"ownershipControls": newError(http.StatusNotFound, "OwnershipControlsNotFoundError",
+		"The bucket ownership controls were not found"),
+	"publicAccessBlock": newError(http.StatusNotFound, "NoSuchPublicAccessBlockConfiguration",
+		"The public access block configuration was not found"),
+	"website": newError(http.StatusNotFound, "NoSuchWebsiteConfiguration",
+		"The specified bucket does not have a website configuration"),
+	"notification": nil,
+	"object-lock": newError(http.StatusNotFound, "ObjectLockConfigurationNotFoundError",
+		"Object Lock configuration does not exist for this bucket"),
+}
+
+type upload struct {
+	header http.Header
+	tags   url.Values
+	parts  map[int]*object
+	bucket string
+	key    string
+}
+
+// NewHandler creates an empty S3 handler
+func NewHandler() *Handler {
+	return &Handler{
+		buckets: make(map[string]*bucket),
+		uploads: make(map[string]*upload),
+	}
+}
+
+// Server is an HTTP test server backed by a Handler
+type Server struct {
+	*httptest.Server
+	Handler *Handler
+}
+
+// NewServer starts an in-process S3-compatible server, it should be closed once the tests are done.
+func NewServer() *Server {
+	h := NewHandler()
+
+	return &Server{
+		Server:  httptest.NewServer(h),
+		Handler: h,
+	}
+}
+
+// NewTLSServer starts an in-process S3-compatible server over HTTPS, required to send the keys of the encryption with
+// customer-provided keys. Its Client trusts its certificate.
+func NewTLSServer() *Server {
+	h := NewHandler()
+
+	return &Server{
+		Server:  httptest.NewTLSServer(h),
+		Handler: h,
+	}
+}
+
+// storedHeaders are the headers that are saved with an object and returned on GET/HEAD
+var storedHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Type",
+	"Expires",
+	"X-Amz-Acl",
+	"X-Amz-Storage-Class",
+	"X-Amz-Website-Redirect-Location",
+	"X-Amz-Server-Side-Encryption",
+	"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id",
+	"X-Amz-Server-Side-Encryption-Customer-Algorithm",
+	"X-Amz-Server-Side-Encryption-Customer-Key-Md5",
+	"X-Amz-Object-Lock-Mode",
+	"X-Amz-Object-Lock-Retain-Until-Date",
+	"X-Amz-Object-Lock-Legal-Hold",
+}
+
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+	status  int
+}
+
+func newError(status int, code, message string) *s3Error {
+	return &s3Error{status: status, Code: code, Message: message}
+}
+
+var (
+	errNoSuchBucket = newError(http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	errNoSuchKey    = newError(http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	errNoSuchUpload = newError(http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+	errPrecondition = newError(http.StatusPreconditionFailed, "PreconditionFailed",
+		"At least one of the pre-conditions you specified did not hold")
+	errMalformedXML   = newError(http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed")
+	errNotImplemented = newError(http.StatusNotImplemented, "NotImplemented",
+		"A header you provided implies functionality that is not implemented")
+)
+
+func writeError(w http.ResponseWriter, r *http.Request, err *s3Error) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(err.status)
+		return
+	}
+
+	writeXML(w, err.status, err)
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	content, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(content)
+}
+
+func splitPath(p string) (string, string) {
+	p = strings.TrimPrefix(p, "/")
+	idx := strings.Index(p, "/")
+
+	if idx < 0 {
+		return p, ""
+	}
+
+	// Like MinIO, we don't keep leading slashes in object names
+	return p[:idx], strings.TrimLeft(p[idx+1:], "/")
+}
+
+func etagOf(data []byte) string {
+	sum := md5.Sum(data) // nolint: gosec
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// deferredWriter holds the content of an object until the handler lock is released, so that a client not reading
+// a response doesn't block the other requests
+type deferredWriter struct {
+	http.ResponseWriter
+	body []byte
+}
+
+// writeBody sends the content of an object
+func writeBody(w http.ResponseWriter, data []byte) {
+	if d, ok := w.(*deferredWriter); ok {
+		d.body = data
+		return
+	}
+
+	_, _ = w.Write(data)
+}
+
+// ServeHTTP dispatches the S3 requests
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	deferred := &deferredWriter{ResponseWriter: w}
+
+	h.mu.Lock()
+	h.serve(deferred, r)
+	h.mu.Unlock()
+
+	if deferred.body != nil {
+		_, _ = w.Write(deferred.body)
+	}
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	bucketName, key := splitPath(r.URL.Path)
+
+	if bucketName == "" {
+		writeError(w, r, errNotImplemented)
+		return
+	}
+
+	if key == "" {
+		h.serveBucket(w, r, bucketName)
+		return
+	}
+
+	b := h.buckets[bucketName]
+	if b == nil {
+		writeError(w, r, errNoSuchBucket)
+		return
+	}
+
+	h.serveObject(w, r, b, bucketName, key)
+}
+
+func (h *Handler) serveBucket(w http.ResponseWriter, r *http.Request, name string) {
+	query := r.URL.Query()
+	b := h.buckets[name]
+
+	if r.Method == http.MethodPut && len(query) == 0 {
+		if b != nil {
+			writeError(w, r, newError(http.StatusConflict, "BucketAlreadyOwnedByYou",
+				"Your previous request to create the named bucket succeeded and you already own it."))
+			return
+		}
+
+		h.buckets[name] = &bucket{objects: make(map[string]*object)}
+		w.Header().Set("Location", "/"+name)
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	if b == nil {
+		writeError(w, r, errNoSuchBucket)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodHead:
+		w.Header().Set("X-Amz-Bucket-Region", h.region())
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodDelete && len(query) == 0:
+		if len(b.objects) > 0 {
+			writeError(w, r, newError(http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty"))
+			return
+		}
+
+		delete(h.buckets, name)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && has(query, "delete"):
+		h.deleteObjects(w, r, b)
+	case r.Method == http.MethodGet && has(query, "versions"):
+		h.listVersions(w, b, name, query)
+	case r.Method == http.MethodGet && has(query, "location"):
+		writeXML(w, http.StatusOK, &struct {
+			XMLName xml.Name `xml:"LocationConstraint"`
+			Region  string   `xml:",chardata"`
+		}{Region: h.Region})
+	case has(query, "lifecycle"):
+		h.serveLifecycle(w, r, b)
+	case bucketConfig(query) != "":
+		h.serveConfig(w, r, b, bucketConfig(query))
+	case r.Method == http.MethodGet && has(query, "uploads"):
+		h.listUploads(w, name, query)
+	case r.Method == http.MethodGet && len(subResources(query)) == 0:
+		h.listObjects(w, r, b, name, query)
+	default:
+		writeError(w, r, errNotImplemented)
+	}
+}
+
+// region returns the region of the buckets
+func (h *Handler) region() string {
+	if h.Region == "" {
+		return "us-east-1"
+	}
+
+	return h.Region
+}
+
+func (h *Handler) serveLifecycle(w http.ResponseWriter, r *http.Request, b *bucket) {
+	switch r.Method {
+	case http.MethodPut:
+		content, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, errMalformedXML)
+			return
+		}
+
+		b.lifecycle = content
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		if b.lifecycle == nil {
+			writeError(w, r, newError(http.StatusNotFound, "NoSuchLifecycleConfiguration",
+				"The lifecycle configuration does not exist"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write(b.lifecycle)
+	case http.MethodDelete:
+		b.lifecycle = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, errNotImplemented)
+	}
+}
+
+// bucketConfig returns the bucket configuration sub-resource of a request, if any
+func bucketConfig(query url.Values) string {
+	resources := subResources(query)
+	if len(resources) != 1 {
+		return ""
+	}
+
+	if _, ok := bucketConfigs[resources[0]]; !ok {
+		return ""
+	}
+
+	return resources[0]
+}
+
+func (h *Handler) serveConfig(w http.ResponseWriter, r *http.Request, b *bucket, resource string) {
+	if b.configs == nil {
+		b.configs = make(map[string][]byte)
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		content, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, errMalformedXML)
+			return
+		}
+
+		b.configs[resource] = content
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		content, ok := b.configs[resource]
+		if !ok {
+			if errMissing := bucketConfigs[resource]; errMissing != nil {
+				writeError(w, r, errMissing)
+				return
+			}
+
+			content = []byte("<VersioningConfiguration></VersioningConfiguration>")
+		}
+
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write(content)
+	case http.MethodDelete:
+		delete(b.configs, resource)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, errNotImplemented)
+	}
+}
+
+// listingSubResources are the query parameters that don't change the nature of a bucket GET
+var listingParameters = map[string]bool{
+	"list-type":          true,
+	"prefix":             true,
+	"delimiter":          true,
+	"max-keys":           true,
+	"continuation-token": true,
+	"start-after":        true,
+	"marker":             true,
+	"encoding-type":      true,
+	"fetch-owner":        true,
+}
+
+func subResources(query url.Values) []string {
+	var list []string
+
+	for k := range query {
+		if !listingParameters[k] {
+			list = append(list, k)
+		}
+	}
+
+	return list
+}
+
+func has(query url.Values, key string) bool {
+	_, ok := query[key]
+	return ok
+}
+
+type listEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	StorageClass string `xml:"StorageClass"`
+	Size         int64  `xml:"Size"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	Marker                *string        `xml:"Marker,omitempty"`
+	NextMarker            string         `xml:"NextMarker,omitempty"`
+	Contents              []listEntry    `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+	KeyCount              *int           `xml:"KeyCount,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+}
+
+// nolint: funlen
+func (h *Handler) listObjects(w http.ResponseWriter, r *http.Request, b *bucket, name string, query url.Values) {
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	v2 := query.Get("list-type") == "2"
+	maxKeys := 1000
+
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, newError(http.StatusBadRequest, "InvalidArgument", "Invalid max-keys"))
+			return
+		}
+
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	result := &listResult{
+		Xmlns:     "http://s3.amazonaws.com/doc/2006-03-01/",
+		Name:      name,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		MaxKeys:   maxKeys,
+	}
+
+	var after string
+
+	if v2 {
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.StdEncoding.DecodeString(token)
+			if err != nil {
+				writeError(w, r, newError(http.StatusBadRequest, "InvalidArgument", "Invalid continuation token"))
+				return
+			}
+
+			result.ContinuationToken = token
+			after = string(decoded)
+		} else {
+			after = query.Get("start-after")
+			result.StartAfter = after
+		}
+	} else {
+		after = query.Get("marker")
+		result.Marker = &after
+	}
+
+	keys := make([]string, 0, len(b.objects))
+
+	for k := range b.objects {
+		// Like MinIO, a directory marker isn't listed as part of its own content
+		if k == prefix && delimiter != "" && strings.HasSuffix(k, delimiter) {
+			continue
+		}
+
+		if strings.HasPrefix(k, prefix) && k > after {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	// last is the marker of the last returned entry, lastPrefix the last returned common prefix
+	count, last, lastPrefix := 0, "", ""
+
+	for _, k := range keys {
+		if lastPrefix != "" && strings.HasPrefix(k, lastPrefix) {
+			// Already reported through the common prefix
+			continue
+		}
+
+		if count >= maxKeys {
+			result.IsTruncated = true
+			break
+		}
+
+		count++
+
+		if delimiter != "" {
+			if idx := strings.Index(k[len(prefix):], delimiter); idx >= 0 {
+				lastPrefix = k[:len(prefix)+idx+len(delimiter)]
+				// No valid UTF-8 key can be greater than this while sharing the common prefix
+				last = lastPrefix + "\xff"
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: lastPrefix})
+
+				continue
+			}
+		}
+
+		last = k
+		obj := b.objects[k]
+		result.Contents = append(result.Contents, listEntry{
+			Key:          k,
+			LastModified: obj.modTime.Format(timeFormat),
+			ETag:         obj.etag,
+			Size:         int64(len(obj.data)),
+			StorageClass: "STANDARD",
+		})
+	}
+
+	if result.IsTruncated {
+		if v2 {
+			result.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(last))
+		} else {
+			result.NextMarker = last
+		}
+	}
+
+	if v2 {
+		result.KeyCount = &count
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *Handler) listUploads(w http.ResponseWriter, name string, query url.Values) {
+	type uploadEntry struct {
+		Key      string `xml:"Key"`
+		UploadID string `xml:"UploadId"`
+	}
+
+	result := &struct {
+		XMLName xml.Name      `xml:"ListMultipartUploadsResult"`
+		Bucket  string        `xml:"Bucket"`
+		Uploads []uploadEntry `xml:"Upload"`
+	}{Bucket: name}
+
+	for id, u := range h.uploads {
+		if u.bucket == name && strings.HasPrefix(u.key, query.Get("prefix")) {
+			result.Uploads = append(result.Uploads, uploadEntry{Key: u.key, UploadID: id})
+		}
+	}
+
+	sort.Slice(result.Uploads, func(i, j int) bool { return result.Uploads[i].Key < result.Uploads[j].Key })
+
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *Handler) deleteObjects(w http.ResponseWriter, r *http.Request, b *bucket) {
+	var input struct {
+		Objects []struct {
+			Key       string `xml:"Key"`
+			VersionID string `xml:"VersionId"`
+		} `xml:"Object"`
+		Quiet bool `xml:"Quiet"`
+	}
+
+	if err := xml.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, r, errMalformedXML)
+		return
+	}
+
+	type deleted struct {
+		Key       string `xml:"Key"`
+		VersionID string `xml:"VersionId,omitempty"`
+	}
+
+	result := &struct {
+		XMLName xml.Name  `xml:"DeleteResult"`
+		Deleted []deleted `xml:"Deleted"`
+	}{}
+
+	for _, o := range input.Objects {
+		if o.VersionID != "" && b.mfaDelete() && r.Header.Get("X-Amz-Mfa") == "" {
+			writeError(w, r, errMfaRequired)
+			return
+		}
+	}
+
+	for _, o := range input.Objects {
+		key := strings.TrimLeft(o.Key, "/")
+
+		if o.VersionID == "" {
+			h.remove(b, key)
+		} else {
+			b.removeVersion(key, o.VersionID)
+		}
+
+		if !input.Quiet {
+			result.Deleted = append(result.Deleted, deleted{Key: o.Key, VersionID: o.VersionID})
+		}
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *Handler) serveObject(w http.ResponseWriter, r *http.Request, b *bucket, bucketName, key string) {
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPost && has(query, "uploads"):
+		h.createUpload(w, r, bucketName, key)
+	case r.Method == http.MethodPut && has(query, "uploadId"):
+		h.uploadPart(w, r, query)
+	case r.Method == http.MethodPost && has(query, "uploadId"):
+		h.completeUpload(w, r, b, query.Get("uploadId"))
+	case r.Method == http.MethodDelete && has(query, "uploadId"):
+		if _, ok := h.uploads[query.Get("uploadId")]; !ok {
+			writeError(w, r, errNoSuchUpload)
+			return
+		}
+
+		delete(h.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case has(query, "acl"):
+		h.serveACL(w, r, b, key)
+	case has(query, "tagging"):
+		h.serveTagging(w, r, b, key)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		h.copyObject(w, r, b, key)
+	case r.Method == http.MethodPut && len(query) == 0:
+		h.putObject(w, r, b, key)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		h.getObject(w, r, b, key)
+	case r.Method == http.MethodDelete && has(query, "versionId"):
+		h.deleteVersion(w, r, b, key)
+	case r.Method == http.MethodDelete && len(query) == 0:
+		if id := h.remove(b, key); id != "" {
+			setVersionID(w, id)
+			w.Header().Set("X-Amz-Delete-Marker", "true")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, errNotImplemented)
+	}
+}
+
+func extractHeaders(src http.Header) http.Header {
+	header := make(http.Header)
+
+	for _, name := range storedHeaders {
+		if v := src.Get(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+
+	for name, values := range src {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), "X-Amz-Meta-") {
+			header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+
+	return header
+}
+
+func parseTags(raw string) url.Values {
+	if raw == "" {
+		return nil
+	}
+
+	tags, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil
+	}
+
+	return tags
+}
+
+// checkWritePreconditions implements the conditional writes (If-Match / If-None-Match)
+func checkWritePreconditions(r *http.Request, existing *object) *s3Error {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if existing != nil && (inm == "*" || inm == existing.etag) {
+			return errPrecondition
+		}
+	}
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if existing == nil {
+			return errNoSuchKey
+		}
+
+		if im != "*" && im != existing.etag {
+			return errPrecondition
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) putObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, newError(http.StatusBadRequest, "IncompleteBody", err.Error()))
+		return
+	}
+
+	if md5Header := r.Header.Get("Content-Md5"); md5Header != "" {
+		sum := md5.Sum(data) // nolint: gosec
+		if base64.StdEncoding.EncodeToString(sum[:]) != md5Header {
+			writeError(w, r, newError(http.StatusBadRequest, "BadDigest",
+				"The Content-MD5 you specified did not match what we received."))
+			return
+		}
+	}
+
+	if errCond := checkWritePreconditions(r, b.objects[key]); errCond != nil {
+		writeError(w, r, errCond)
+		return
+	}
+
+	obj := &object{
+		data:    data,
+		etag:    etagOf(data),
+		modTime: time.Now().UTC(),
+		header:  extractHeaders(r.Header),
+		tags:    parseTags(r.Header.Get("X-Amz-Tagging")),
+	}
+	setVersionID(w, h.store(b, key, obj))
+	w.Header().Set("ETag", obj.etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseRange parses a single "bytes=" range, it returns the start and end (inclusive) offsets
+func parseRange(header string, size int64) (int64, int64, bool) {
+	spec := strings.TrimPrefix(header, "bytes=")
+	if spec == header || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	if parts[0] == "" {
+		suffix, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, false
+		}
+
+		if suffix > size {
+			suffix = size
+		}
+
+		return size - suffix, size - 1, true
+	}
+
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+
+	end := size - 1
+
+	if parts[1] != "" {
+		if end, err = strconv.ParseInt(parts[1], 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return start, end, true
+}
+
+// responseOverrides maps the query parameters allowing to override the response headers
+var responseOverrides = map[string]string{
+	"response-cache-control":       "Cache-Control",
+	"response-content-disposition": "Content-Disposition",
+	"response-content-encoding":    "Content-Encoding",
+	"response-content-language":    "Content-Language",
+	"response-content-type":        "Content-Type",
+	"response-expires":             "Expires",
+}
+
+func checkReadPreconditions(r *http.Request, obj *object) (int, bool) {
+	if im := r.Header.Get("If-Match"); im != "" && im != "*" && im != obj.etag {
+		return http.StatusPreconditionFailed, false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && (inm == "*" || inm == obj.etag) {
+		return http.StatusNotModified, false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !obj.modTime.Truncate(time.Second).After(t) {
+			return http.StatusNotModified, false
+		}
+	}
+
+	if ius := r.Header.Get("If-Unmodified-Since"); ius != "" {
+		if t, err := http.ParseTime(ius); err == nil && obj.modTime.Truncate(time.Second).After(t) {
+			return http.StatusPreconditionFailed, false
+		}
+	}
+
+	return 0, true
+}
+
+// currentObject returns the current version of an object, or the one requested with the versionId parameter
+func currentObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) *object {
+	id := r.URL.Query().Get("versionId")
+	if id == "" {
+		obj := b.objects[key]
+		if obj == nil {
+			if b.isDeleteMarked(key) {
+				w.Header().Set("X-Amz-Delete-Marker", "true")
+			}
+
+			writeError(w, r, errNoSuchKey)
+		}
+
+		return obj
+	}
+
+	v := b.findVersion(key, id)
+
+	switch {
+	case v == nil:
+		writeError(w, r, errNoSuchVersion)
+		return nil
+	case v.obj == nil:
+		w.Header().Set("X-Amz-Delete-Marker", "true")
+		writeError(w, r, errDeleteMarker)
+
+		return nil
+	default:
+		return v.obj
+	}
+}
+
+// setVersionID returns the version of a written object, if any
+func setVersionID(w http.ResponseWriter, id string) {
+	if id != "" {
+		w.Header().Set("X-Amz-Version-Id", id)
+	}
+}
+
+func (h *Handler) getObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	obj := currentObject(w, r, b, key)
+	if obj == nil {
+		return
+	}
+
+	if errKey := checkCustomerKey(r, obj.header, sseCustomerKeyMD5Header); errKey != nil {
+		writeError(w, r, errKey)
+		return
+	}
+
+	header := w.Header()
+	setVersionID(w, obj.versionID)
+
+	for name, values := range obj.header {
+		if name != "X-Amz-Acl" {
+			header[name] = values
+		}
+	}
+
+	for param, name := range responseOverrides {
+		if v := r.URL.Query().Get(param); v != "" {
+			header.Set(name, v)
+		}
+	}
+
+	header.Set("ETag", obj.etag)
+	header.Set("Last-Modified", obj.modTime.Format(http.TimeFormat))
+	header.Set("Accept-Ranges", "bytes")
+
+	if len(obj.tags) > 0 {
+		header.Set("X-Amz-Tagging-Count", strconv.Itoa(len(obj.tags)))
+	}
+
+	if status, ok := checkReadPreconditions(r, obj); !ok {
+		if status == http.StatusPreconditionFailed {
+			writeError(w, r, errPrecondition)
+		} else {
+			w.WriteHeader(status)
+		}
+
+		return
+	}
+
+	size := int64(len(obj.data))
+	data := obj.data
+	status := http.StatusOK
+
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		start, end, ok := parseRange(rangeHeader, size)
+		if !ok {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			writeError(w, r, newError(http.StatusRequestedRangeNotSatisfiable, "InvalidRange",
+				"The requested range is not satisfiable"))
+
+			return
+		}
+
+		data = obj.data[start : end+1]
+		status = http.StatusPartialContent
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+
+	if r.Method != http.MethodHead {
+		writeBody(w, data)
+	}
+}
+
+func (h *Handler) serveACL(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	obj := b.objects[key]
+	if obj == nil {
+		writeError(w, r, errNoSuchKey)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if acl := r.Header.Get("X-Amz-Acl"); acl != "" {
+			obj.header.Set("X-Amz-Acl", acl)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		type grant struct {
+			Permission string `xml:"Permission"`
+			URI        string `xml:"Grantee>URI,omitempty"`
+		}
+
+		grants := []grant{{Permission: "FULL_CONTROL"}}
+
+		switch obj.header.Get("X-Amz-Acl") {
+		case "public-read":
+			grants = append(grants, grant{Permission: "READ", URI: "http://acs.amazonaws.com/groups/global/AllUsers"})
+		case "public-read-write":
+			grants = append(grants,
+				grant{Permission: "READ", URI: "http://acs.amazonaws.com/groups/global/AllUsers"},
+				grant{Permission: "WRITE", URI: "http://acs.amazonaws.com/groups/global/AllUsers"})
+		}
+
+		writeXML(w, http.StatusOK, &struct {
+			XMLName xml.Name `xml:"AccessControlPolicy"`
+			Grants  []grant  `xml:"AccessControlList>Grant"`
+		}{Grants: grants})
+	default:
+		writeError(w, r, errNotImplemented)
+	}
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Tags    []tag    `xml:"TagSet>Tag"`
+}
+
+func (h *Handler) serveTagging(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	obj := b.objects[key]
+	if obj == nil {
+		writeError(w, r, errNoSuchKey)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var input tagging
+		if err := xml.NewDecoder(r.Body).Decode(&input); err != nil {
+			writeError(w, r, errMalformedXML)
+			return
+		}
+
+		obj.tags = make(url.Values)
+		for _, t := range input.Tags {
+			obj.tags.Set(t.Key, t.Value)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		output := &tagging{}
+		for k := range obj.tags {
+			output.Tags = append(output.Tags, tag{Key: k, Value: obj.tags.Get(k)})
+		}
+
+		sort.Slice(output.Tags, func(i, j int) bool { return output.Tags[i].Key < output.Tags[j].Key })
+		writeXML(w, http.StatusOK, output)
+	case http.MethodDelete:
+		obj.tags = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, errNotImplemented)
+	}
+}
+
+func (h *Handler) copySource(r *http.Request) (*object, *s3Error) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return nil, newError(http.StatusBadRequest, "InvalidArgument", "Invalid copy source")
+	}
+
+	versionID := ""
+
+	if idx := strings.Index(source, "?"); idx >= 0 {
+		versionID = strings.TrimPrefix(source[idx+1:], "versionId=")
+		source = source[:idx]
+	}
+
+	bucketName, key := splitPath(source)
+
+	b := h.buckets[bucketName]
+	if b == nil {
+		return nil, errNoSuchBucket
+	}
+
+	obj := b.objects[key]
+	if versionID != "" {
+		obj = nil
+		if v := b.findVersion(key, versionID); v != nil {
+			obj = v.obj
+		}
+	}
+
+	if obj == nil {
+		return nil, errNoSuchKey
+	}
+
+	if im := r.Header.Get("X-Amz-Copy-Source-If-Match"); im != "" && im != obj.etag {
+		return nil, errPrecondition
+	}
+
+	if inm := r.Header.Get("X-Amz-Copy-Source-If-None-Match"); inm != "" && inm == obj.etag {
+		return nil, errPrecondition
+	}
+
+	if errKey := checkCustomerKey(r, obj.header, copySourceSSECustomerKeyMD5Header); errKey != nil {
+		return nil, errKey
+	}
+
+	return obj, nil
+}
+
+func (h *Handler) copyObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	source, errSource := h.copySource(r)
+	if errSource != nil {
+		writeError(w, r, errSource)
+		return
+	}
+
+	maxCopySize := h.MaxCopySize
+	if maxCopySize == 0 {
+		maxCopySize = 5 * 1024 * 1024 * 1024
+	}
+
+	if int64(len(source.data)) > maxCopySize {
+		writeError(w, r, newError(http.StatusBadRequest, "InvalidRequest",
+			"The specified copy source is larger than the maximum allowable size for a copy source"))
+		return
+	}
+
+	if errCond := checkWritePreconditions(r, b.objects[key]); errCond != nil {
+		writeError(w, r, errCond)
+		return
+	}
+
+	obj := &object{
+		data:    source.data,
+		etag:    source.etag,
+		modTime: time.Now().UTC(),
+		header:  withEncryption(source.header, r),
+		tags:    source.tags,
+	}
+
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		obj.header = extractHeaders(r.Header)
+	}
+
+	if r.Header.Get("X-Amz-Tagging-Directive") == "REPLACE" {
+		obj.tags = parseTags(r.Header.Get("X-Amz-Tagging"))
+	}
+
+	setVersionID(w, h.store(b, key, obj))
+
+	writeXML(w, http.StatusOK, &struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string   `xml:"ETag"`
+		LastModified string   `xml:"LastModified"`
+	}{ETag: obj.etag, LastModified: obj.modTime.Format(timeFormat)})
+}
+
+func (h *Handler) createUpload(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	h.counter++
+	id := fmt.Sprintf("upload-%d-%d", time.Now().UnixNano(), h.counter)
+	h.uploads[id] = &upload{
+		bucket: bucketName,
+		key:    key,
+		header: extractHeaders(r.Header),
+		tags:   parseTags(r.Header.Get("X-Amz-Tagging")),
+		parts:  make(map[int]*object),
+	}
+
+	writeXML(w, http.StatusOK, &struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}{Bucket: bucketName, Key: key, UploadID: id})
+}
+
+func (h *Handler) uploadPart(w http.ResponseWriter, r *http.Request, query url.Values) {
+	u := h.uploads[query.Get("uploadId")]
+	if u == nil {
+		writeError(w, r, errNoSuchUpload)
+		return
+	}
+
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > 10000 {
+		writeError(w, r, newError(http.StatusBadRequest, "InvalidArgument", "Invalid part number"))
+		return
+	}
+
+	if errKey := checkCustomerKey(r, u.header, sseCustomerKeyMD5Header); errKey != nil {
+		writeError(w, r, errKey)
+		return
+	}
+
+	var data []byte
+
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		source, errSource := h.copySource(r)
+		if errSource != nil {
+			writeError(w, r, errSource)
+			return
+		}
+
+		data = source.data
+
+		if rangeHeader := r.Header.Get("X-Amz-Copy-Source-Range"); rangeHeader != "" {
+			start, end, ok := parseRange(rangeHeader, int64(len(data)))
+			if !ok {
+				writeError(w, r, newError(http.StatusBadRequest, "InvalidArgument", "Invalid copy source range"))
+				return
+			}
+
+			data = data[start : end+1]
+		}
+	} else if data, err = io.ReadAll(r.Body); err != nil {
+		writeError(w, r, newError(http.StatusBadRequest, "IncompleteBody", err.Error()))
+		return
+	}
+
+	part := &object{data: data, etag: etagOf(data), modTime: time.Now().UTC()}
+	u.parts[partNumber] = part
+
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeXML(w, http.StatusOK, &struct {
+			XMLName      xml.Name `xml:"CopyPartResult"`
+			ETag         string   `xml:"ETag"`
+			LastModified string   `xml:"LastModified"`
+		}{ETag: part.etag, LastModified: part.modTime.Format(timeFormat)})
+
+		return
+	}
+
+	w.Header().Set("ETag", part.etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) completeUpload(w http.ResponseWriter, r *http.Request, b *bucket, id string) {
+	u := h.uploads[id]
+	if u == nil {
+		writeError(w, r, errNoSuchUpload)
+		return
+	}
+
+	var input struct {
+		Parts []struct {
+			ETag       string `xml:"ETag"`
+			PartNumber int    `xml:"PartNumber"`
+		} `xml:"Part"`
+	}
+
+	if err := xml.NewDecoder(r.Body).Decode(&input); err != nil || len(input.Parts) == 0 {
+		writeError(w, r, errMalformedXML)
+		return
+	}
+
+	var data bytes.Buffer
+
+	sums := md5.New() // nolint: gosec
+
+	for i, p := range input.Parts {
+		part := u.parts[p.PartNumber]
+		if part == nil || part.etag != p.ETag {
+			writeError(w, r, newError(http.StatusBadRequest, "InvalidPart",
+				"One or more of the specified parts could not be found."))
+			return
+		}
+
+		if i < len(input.Parts)-1 && len(part.data) < minPartSize {
+			writeError(w, r, newError(http.StatusBadRequest, "EntityTooSmall",
+				"Your proposed upload is smaller than the minimum allowed object size."))
+			return
+		}
+
+		raw, _ := hex.DecodeString(strings.Trim(part.etag, `"`))
+		_, _ = sums.Write(raw)
+		_, _ = data.Write(part.data)
+	}
+
+	if errCond := checkWritePreconditions(r, b.objects[u.key]); errCond != nil {
+		writeError(w, r, errCond)
+		return
+	}
+
+	obj := &object{
+		data:    data.Bytes(),
+		etag:    fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sums.Sum(nil)), len(input.Parts)),
+		modTime: time.Now().UTC(),
+		header:  u.header,
+		tags:    u.tags,
+	}
+	setVersionID(w, h.store(b, u.key, obj))
+	delete(h.uploads, id)
+
+	writeXML(w, http.StatusOK, &struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string   `xml:"Bucket"`
+		Key     string   `xml:"Key"`
+		ETag    string   `xml:"ETag"`
+	}{Bucket: u.bucket, Key: u.key, ETag: obj.etag})
+}
@@ -0,0 +1,124 @@
+package s3test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	req := require.New(t)
+	server := NewServer()
+
+	defer server.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials("key", "secret", ""),
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("eu-west-1"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	req.NoError(err)
+
+	client := s3.New(sess)
+
+	_, err = client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket")})
+	req.NoError(err)
+
+	_, err = client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("dir/file"),
+		Body:   bytes.NewReader([]byte("content")),
+	})
+	req.NoError(err)
+
+	list, err := client.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:    aws.String("bucket"),
+		Delimiter: aws.String("/"),
+	})
+	req.NoError(err)
+	req.Len(list.CommonPrefixes, 1)
+	req.Equal("dir/", aws.StringValue(list.CommonPrefixes[0].Prefix))
+
+	obj, err := client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("dir/file"),
+		Range:  aws.String("bytes=3-"),
+	})
+	req.NoError(err)
+
+	content, err := io.ReadAll(obj.Body)
+	req.NoError(err)
+	req.Equal("tent", string(content))
+
+	_, err = client.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String("bucket"), Key: aws.String("dir/file")})
+	req.NoError(err)
+
+	_, err = client.HeadObject(&s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("dir/file")})
+	req.Error(err)
+}
+
+func TestServerMFADelete(t *testing.T) {
+	req := require.New(t)
+	server := NewServer()
+
+	defer server.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials("key", "secret", ""),
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("eu-west-1"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	req.NoError(err)
+
+	client := s3.New(sess)
+	bucket := aws.String("bucket")
+
+	_, err = client.CreateBucket(&s3.CreateBucketInput{Bucket: bucket})
+	req.NoError(err)
+
+	_, err = client.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket: bucket,
+		MFA:    aws.String("serial 123456"),
+		VersioningConfiguration: &s3.VersioningConfiguration{
+			Status:    aws.String(s3.BucketVersioningStatusEnabled),
+			MFADelete: aws.String(s3.MFADeleteEnabled),
+		},
+	})
+	req.NoError(err)
+
+	put, err := client.PutObject(&s3.PutObjectInput{
+		Bucket: bucket,
+		Key:    aws.String("file"),
+		Body:   bytes.NewReader([]byte("content")),
+	})
+	req.NoError(err)
+	req.NotEmpty(aws.StringValue(put.VersionId))
+
+	// Removing the file doesn't require the MFA, deleting a version permanently does
+	_, err = client.DeleteObject(&s3.DeleteObjectInput{Bucket: bucket, Key: aws.String("file")})
+	req.NoError(err)
+
+	_, err = client.DeleteObject(&s3.DeleteObjectInput{Bucket: bucket, Key: aws.String("file"), VersionId: put.VersionId})
+	req.Error(err)
+
+	_, err = client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket:    bucket,
+		Key:       aws.String("file"),
+		VersionId: put.VersionId,
+		MFA:       aws.String("serial 123456"),
+	})
+	req.NoError(err)
+
+	versions, err := client.ListObjectVersions(&s3.ListObjectVersionsInput{Bucket: bucket})
+	req.NoError(err)
+	req.Empty(versions.Versions)
+	req.Len(versions.DeleteMarkers, 1)
+}
@@ -0,0 +1,236 @@
+// Package s3test provides helpers to test the code relying on S3 file systems
+package s3test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// nullVersion is the version ID of the objects written before the versioning was enabled
+const nullVersion = "null"
+
+var (
+	errNoSuchVersion = newError(http.StatusNotFound, "NoSuchVersion",
+		"The specified version does not exist.")
+	errDeleteMarker = newError(http.StatusMethodNotAllowed, "MethodNotAllowed",
+		"The specified method is not allowed against this resource.")
+	errMfaRequired = newError(http.StatusForbidden, "AccessDenied",
+		"Mfa Authentication must be used for this request")
+)
+
+// version is a version of an object of a versioned bucket, a delete marker if obj is nil
+type version struct {
+	id      string
+	modTime time.Time
+	obj     *object
+}
+
+// versioning tells if the versioning of the bucket is enabled
+func (b *bucket) versioning() bool {
+	return bytes.Contains(b.configs["versioning"], []byte("<Status>Enabled</Status>"))
+}
+
+// mfaDelete tells if the permanent deletions of versions require an MFA code
+func (b *bucket) mfaDelete() bool {
+	return bytes.Contains(b.configs["versioning"], []byte("<MfaDelete>Enabled</MfaDelete>"))
+}
+
+// keyVersions returns the versions of an object, the latest last. An object written before the versioning was
+// enabled is its null version.
+func (b *bucket) keyVersions(key string) []*version {
+	versions := b.versions[key]
+
+	if obj := b.objects[key]; obj != nil && obj.versionID == "" && len(versions) == 0 {
+		versions = []*version{{id: nullVersion, modTime: obj.modTime, obj: obj}}
+	}
+
+	return versions
+}
+
+// addVersion adds a version to an object, a delete marker if obj is nil
+func (h *Handler) addVersion(b *bucket, key string, obj *object) string {
+	if b.versions == nil {
+		b.versions = make(map[string][]*version)
+	}
+
+	h.counter++
+	v := &version{id: fmt.Sprintf("%d.%d", time.Now().UnixNano(), h.counter), modTime: time.Now().UTC(), obj: obj}
+	b.versions[key] = append(b.keyVersions(key), v)
+
+	return v.id
+}
+
+// store saves an object, it returns its version ID if the bucket is versioned
+func (h *Handler) store(b *bucket, key string, obj *object) string {
+	if b.versioning() {
+		obj.versionID = h.addVersion(b, key, obj)
+	}
+
+	b.objects[key] = obj
+
+	return obj.versionID
+}
+
+// remove removes an object, it returns the version ID of the delete marker if the bucket is versioned
+func (h *Handler) remove(b *bucket, key string) string {
+	id := ""
+	if b.versioning() {
+		id = h.addVersion(b, key, nil)
+	}
+
+	delete(b.objects, key)
+
+	return id
+}
+
+// removeVersion permanently removes a version of an object, it tells if it was a delete marker
+func (b *bucket) removeVersion(key, id string) bool {
+	versions := b.keyVersions(key)
+
+	for i, v := range versions {
+		if v.id != id {
+			continue
+		}
+
+		versions = append(versions[:i:i], versions[i+1:]...)
+
+		// The previous version becomes the current one
+		delete(b.objects, key)
+		delete(b.versions, key)
+
+		if len(versions) > 0 {
+			b.versions[key] = versions
+
+			if latest := versions[len(versions)-1]; latest.obj != nil {
+				b.objects[key] = latest.obj
+			}
+		}
+
+		return v.obj == nil
+	}
+
+	return false
+}
+
+// isDeleteMarked tells if the latest version of an object is a delete marker
+func (b *bucket) isDeleteMarked(key string) bool {
+	versions := b.versions[key]
+	return len(versions) > 0 && versions[len(versions)-1].obj == nil
+}
+
+// findVersion returns a version of an object
+func (b *bucket) findVersion(key, id string) *version {
+	for _, v := range b.keyVersions(key) {
+		if v.id == id {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) deleteVersion(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	if b.mfaDelete() && r.Header.Get("X-Amz-Mfa") == "" {
+		writeError(w, r, errMfaRequired)
+		return
+	}
+
+	id := r.URL.Query().Get("versionId")
+	if b.removeVersion(key, id) {
+		w.Header().Set("X-Amz-Delete-Marker", "true")
+	}
+
+	w.Header().Set("X-Amz-Version-Id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type versionEntry struct {
+	Key          string `xml:"Key"`
+	VersionID    string `xml:"VersionId"`
+	IsLatest     bool   `xml:"IsLatest"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag,omitempty"`
+	Size         *int64 `xml:"Size,omitempty"`
+	StorageClass string `xml:"StorageClass,omitempty"`
+}
+
+func (h *Handler) listVersions(w http.ResponseWriter, b *bucket, name string, query url.Values) {
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+
+	result := &struct {
+		XMLName        xml.Name       `xml:"ListVersionsResult"`
+		Name           string         `xml:"Name"`
+		Prefix         string         `xml:"Prefix"`
+		Delimiter      string         `xml:"Delimiter,omitempty"`
+		IsTruncated    bool           `xml:"IsTruncated"`
+		Versions       []versionEntry `xml:"Version"`
+		DeleteMarkers  []versionEntry `xml:"DeleteMarker"`
+		CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+	}{Name: name, Prefix: prefix, Delimiter: delimiter}
+
+	keys := make(map[string]bool)
+
+	for key := range b.objects {
+		keys[key] = true
+	}
+
+	for key := range b.versions {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+
+	for key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			sorted = append(sorted, key)
+		}
+	}
+
+	sort.Strings(sorted)
+
+	lastPrefix := ""
+
+	for _, key := range sorted {
+		if delimiter != "" {
+			if idx := strings.Index(key[len(prefix):], delimiter); idx >= 0 {
+				if common := key[:len(prefix)+idx+len(delimiter)]; common != lastPrefix {
+					lastPrefix = common
+					result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: common})
+				}
+
+				continue
+			}
+		}
+
+		versions := b.keyVersions(key)
+
+		// The versions are listed from the latest
+		for i := len(versions) - 1; i >= 0; i-- {
+			v := versions[i]
+			entry := versionEntry{
+				Key:          key,
+				VersionID:    v.id,
+				IsLatest:     i == len(versions)-1,
+				LastModified: v.modTime.Format(timeFormat),
+			}
+
+			if v.obj == nil {
+				result.DeleteMarkers = append(result.DeleteMarkers, entry)
+				continue
+			}
+
+			size := int64(len(v.obj.data))
+			entry.ETag, entry.Size, entry.StorageClass = v.obj.etag, &size, "STANDARD"
+			result.Versions = append(result.Versions, entry)
+		}
+	}
+
+	writeXML(w, http.StatusOK, result)
+}